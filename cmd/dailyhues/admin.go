@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/mgabor3141/dailyhues/internal/ai"
+	"github.com/mgabor3141/dailyhues/internal/auth"
+)
+
+// loadAuthConfig reads admin credential settings from the environment
+func loadAuthConfig() auth.Config {
+	return auth.Config{
+		APIKeys:   splitList(os.Getenv("ADMIN_API_KEYS")),
+		JWTSecret: os.Getenv("ADMIN_JWT_SECRET"),
+		JWKSURL:   os.Getenv("ADMIN_JWKS_URL"),
+		Issuer:    os.Getenv("ADMIN_JWT_ISSUER"),
+		Audience:  os.Getenv("ADMIN_JWT_AUDIENCE"),
+	}
+}
+
+// requireAdmin wraps a handler so it only runs for authenticated admin callers
+// The caller's identity is available to the handler via auth.SubjectFromContext
+func (app *App) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		subject, ok := app.authenticateAdmin(w, r)
+		if !ok {
+			return
+		}
+		next(w, r.WithContext(auth.WithSubject(r.Context(), subject)))
+	}
+}
+
+// authenticateAdmin returns the admin subject of a request, for public routes with admin-only options
+// If the caller is not an admin, the error response has been written and ok is false
+func (app *App) authenticateAdmin(w http.ResponseWriter, r *http.Request) (subject string, ok bool) {
+	if app.authenticator == nil || !app.authenticator.Enabled() {
+		respondWithError(w, http.StatusForbidden, "Admin API is disabled. Configure ADMIN_API_KEYS, ADMIN_JWT_SECRET, or ADMIN_JWKS_URL to enable it")
+		return "", false
+	}
+
+	subject, err := app.authenticator.Authenticate(r)
+	if err != nil {
+		if !errors.Is(err, auth.ErrMissingCredentials) {
+			slog.Info("Rejected admin request", "path", r.URL.Path, "error", err)
+		}
+		w.Header().Set("WWW-Authenticate", `Bearer realm="dailyhues"`)
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return "", false
+	}
+	return subject, true
+}
+
+// handleWhoami returns the authenticated admin identity, for checking identity provider setup
+func handleWhoami(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, map[string]string{
+		"subject": auth.SubjectFromContext(r.Context()),
+	})
+}
+
+// handleDebugResponses lists saved AI debug responses, or returns one when a file name follows /admin/debug/
+func handleDebugResponses(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/admin/debug"), "/")
+	if name == "" {
+		files, err := ai.ListDebugResponses()
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		respondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"directory": ai.DebugDir(),
+			"files":     files,
+		})
+		return
+	}
+
+	data, err := ai.ReadDebugResponse(name)
+	if errors.Is(err, ai.ErrDebugResponseNotFound) {
+		respondWithError(w, http.StatusNotFound, "Debug response not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"regexp"
+
+	"github.com/mgabor3141/dailyhues/internal/bing"
+)
+
+// resolutionPattern matches the resolution keys of bing.WallpaperInfo.ImageURLs
+var resolutionPattern = regexp.MustCompile(`^(UHD|\d+x\d+)$`)
+
+// analysisImageSize returns the resolution downloaded for analysis from ANALYSIS_IMAGE_SIZE, e.g. "800x600"
+// Empty means the wallpaper's default image (reanalysisImageSize), which analyses were always made from before
+func analysisImageSize() string {
+	size := os.Getenv("ANALYSIS_IMAGE_SIZE")
+	if size == "" || size == reanalysisImageSize {
+		return ""
+	}
+	if !resolutionPattern.MatchString(size) {
+		slog.Info("Invalid ANALYSIS_IMAGE_SIZE, using default", "value", size, "default", reanalysisImageSize)
+		return ""
+	}
+	return size
+}
+
+// analysisImage downloads the wallpaper at ANALYSIS_IMAGE_SIZE to analyze in place of its default image
+// Bing scales the image down, so small hosts skip decoding and resizing the default image before analysis.
+// The default image is still what is hashed, so the setting does not change which wallpapers are cached.
+// Returns nil, and the default image is analyzed, when the setting is off, the size is missing, on video days
+// (whose frames come in one size), or when the download fails
+func (app *App) analysisImage(ctx context.Context, info *bing.WallpaperInfo) ([]byte, string) {
+	size := analysisImageSize()
+	url := info.ImageURLs[size]
+	if size == "" || url == "" || info.HasVideo() {
+		return nil, ""
+	}
+
+	data, err := app.bingClient.DownloadImage(ctx, url)
+	if err != nil {
+		slog.Info("Failed to download analysis size, using the default image", "size", size, "error", err)
+		return nil, ""
+	}
+	return data, size
+}
+
+// imageToAnalyze returns the image to analyze for a wallpaper whose default image is imageData, and its size
+// The size is "" when imageData itself is analyzed
+func (app *App) imageToAnalyze(ctx context.Context, info *bing.WallpaperInfo, imageData []byte) ([]byte, string) {
+	if data, size := app.analysisImage(ctx, info); data != nil {
+		return data, size
+	}
+	return imageData, ""
+}
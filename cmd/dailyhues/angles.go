@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mgabor3141/dailyhues/internal/palette"
+)
+
+// anglesAny disables the configured angle constraint for a request
+const anglesAny = "any"
+
+// parseAngles parses a comma separated list of gradient angles in degrees
+func parseAngles(value string) ([]float64, error) {
+	var angles []float64
+	for _, entry := range splitList(value) {
+		angle, err := strconv.ParseFloat(entry, 64)
+		if err != nil || angle < 0 || angle >= 360 {
+			return nil, fmt.Errorf("invalid angle %q. Angles must be degrees from 0 up to 360", entry)
+		}
+		angles = append(angles, angle)
+	}
+	return angles, nil
+}
+
+// loadAllowedAngles reads GRADIENT_ANGLES, the angles gradients are limited to; empty allows any angle
+// Read per request so a config reload takes effect immediately
+func loadAllowedAngles() []float64 {
+	value := os.Getenv("GRADIENT_ANGLES")
+	angles, err := parseAngles(value)
+	if err != nil {
+		slog.Info("Ignoring invalid GRADIENT_ANGLES", "value", value, "error", err)
+		return nil
+	}
+	return angles
+}
+
+// validateAngles resolves the ?angles= parameter: a list overrides GRADIENT_ANGLES, "any" lifts it
+func validateAngles(param string) ([]float64, error) {
+	switch param {
+	case "":
+		return loadAllowedAngles(), nil
+	case anglesAny:
+		return nil, nil
+	}
+
+	angles, err := parseAngles(param)
+	if err != nil {
+		return nil, err
+	}
+	if len(angles) == 0 {
+		return nil, fmt.Errorf("angles must list at least one angle, or be %q", anglesAny)
+	}
+	return angles, nil
+}
+
+// anglesOption identifies an angle constraint in render cache keys
+func anglesOption(angles []float64) string {
+	values := make([]string, len(angles))
+	for i, angle := range angles {
+		values[i] = strconv.FormatFloat(angle, 'g', -1, 64)
+	}
+	return strings.Join(values, ",")
+}
+
+// withAllowedAngles moves the theme's gradient angles to the nearest allowed angle,
+// for clients whose toolkit can only draw some directions (e.g. 0/90/180/270)
+func withAllowedAngles(theme ColorTheme, angles []float64) ColorTheme {
+	if len(angles) == 0 {
+		return theme
+	}
+
+	theme.Colors = palette.ConstrainAngles(theme.Colors, angles)
+	if gradient, err := palette.GradientFromColors(theme.Colors); err == nil {
+		theme.CSSGradient = gradient.CSS()
+		theme.HyprlandGradient = gradient.Hyprland()
+	}
+	return theme
+}
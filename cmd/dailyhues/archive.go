@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mgabor3141/dailyhues/internal/cache"
+)
+
+// daysAgoOfDate returns the daysAgo of a locale's wallpaper by its start date (YYYY-MM-DD)
+// The locale's cached rollover is used when known, since markets change wallpapers at different times; otherwise UTC days
+func (app *App) daysAgoOfDate(locale, date string, now time.Time) (int, error) {
+	day, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return 0, fmt.Errorf("invalid date. Use YYYY-MM-DD")
+	}
+
+	daysAgo, ok := app.requestCache.DaysAgo(locale, day.Format("20060102"))
+	if !ok {
+		daysAgo = int(now.UTC().Truncate(24*time.Hour).Sub(day) / (24 * time.Hour))
+	}
+	switch {
+	case daysAgo < 0:
+		return 0, fmt.Errorf("date is in the future")
+	case daysAgo > maxArchivedDaysBack:
+		return 0, fmt.Errorf("date too old. At most %d days back is supported", maxArchivedDaysBack)
+	}
+	return daysAgo, nil
+}
+
+// archivedImageSizes are the resolutions kept locally when ARCHIVE_IMAGES is enabled
+var archivedImageSizes = []string{"1920x1080", "UHD"}
+
+// archiveImagesEnabled reports whether wallpapers are stored locally, read per use so a reload takes effect
+func archiveImagesEnabled() bool {
+	return os.Getenv("ARCHIVE_IMAGES") == "true"
+}
+
+// archivedImagePath is where a wallpaper size is stored, by image content hash
+func archivedImagePath(imageHash, size string) string {
+	return filepath.Join(cacheDir(), "images", imageHash, size+".jpg")
+}
+
+// archiveImages downloads the archived sizes of a wallpaper that are not stored yet
+// analysisKey may include a prompt addendum suffix; files are stored under the plain image hash
+func (app *App) archiveImages(analysisKey string, imageURLs map[string]string) {
+	if !cache.ValidHash(analysisKey) {
+		return
+	}
+	imageHash := cache.ImageHashOf(analysisKey)
+	for _, size := range archivedImageSizes {
+		url := imageURLs[size]
+		path := archivedImagePath(imageHash, size)
+		if url == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err == nil {
+			continue
+		}
+
+		data, err := app.bingClient.DownloadImage(context.Background(), url)
+		if err != nil {
+			slog.Info("Failed to archive wallpaper", "hash", imageHash, "size", size, "error", err)
+			continue
+		}
+		if err := writeFileAtomic(path, data); err != nil {
+			slog.Error("Failed to store archived wallpaper", "hash", imageHash, "size", size, "error", err)
+			continue
+		}
+		slog.Debug("Archived wallpaper", "hash", imageHash, "size", size, "bytes", len(data))
+	}
+}
+
+// archiveImagesAsync archives a wallpaper in the background; app.archiving tracks it so the CLI can wait
+func (app *App) archiveImagesAsync(analysisKey string, imageURLs map[string]string) {
+	if !archiveImagesEnabled() {
+		return
+	}
+	app.archiving.Add(1)
+	go func() {
+		defer app.archiving.Done()
+		app.archiveImages(analysisKey, imageURLs)
+	}()
+}
+
+// archiveCachedImages archives every cached wallpaper that is missing locally, e.g. after enabling ARCHIVE_IMAGES
+// Wallpapers past Bing's retention fail to download and are skipped
+func (app *App) archiveCachedImages() {
+	if !archiveImagesEnabled() {
+		return
+	}
+	for _, entry := range app.requestCache.Entries() {
+		app.archiveImages(entry.ImageHash, entry.ImageURLs)
+	}
+}
+
+// writeFileAtomic writes data through a temporary file so readers never see a partial image
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// archivedImageURLs returns the local URLs of a wallpaper's stored sizes, or nil if none are stored
+func archivedImageURLs(analysisKey string) map[string]string {
+	if !cache.ValidHash(analysisKey) {
+		return nil
+	}
+	imageHash := cache.ImageHashOf(analysisKey)
+
+	baseURL := strings.TrimSuffix(os.Getenv("BASE_URL"), "/")
+	var urls map[string]string
+	for _, size := range archivedImageSizes {
+		if _, err := os.Stat(archivedImagePath(imageHash, size)); err != nil {
+			continue
+		}
+		if urls == nil {
+			urls = make(map[string]string, len(archivedImageSizes))
+		}
+		urls[size] = fmt.Sprintf("%s/archive/images/%s/%s.jpg", baseURL, imageHash, size)
+	}
+	return urls
+}
+
+// handleArchivedImage serves a locally stored wallpaper at /archive/images/{hash}/{size}.jpg
+func handleArchivedImage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	imageHash, file, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/archive/images/"), "/")
+	size, isJPEG := strings.CutSuffix(file, ".jpg")
+	if !ok || !isJPEG || !cache.ValidHash(imageHash) || cache.ImageHashOf(imageHash) != imageHash || !slices.Contains(archivedImageSizes, size) {
+		respondWithError(w, http.StatusNotFound, "Image not found")
+		return
+	}
+
+	path := archivedImagePath(imageHash, size)
+	if _, err := os.Stat(path); err != nil {
+		respondWithError(w, http.StatusNotFound, "Image not found")
+		return
+	}
+
+	// Stored under the wallpaper's image hash, so the file never changes
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	http.ServeFile(w, r, path)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"github.com/mgabor3141/dailyhues/internal/bing"
+	"github.com/mgabor3141/dailyhues/internal/cache"
+)
+
+// attributionLicense reminds clients under what terms Bing's wallpapers are shown
+const attributionLicense = "Licensed to Microsoft for use as a Bing wallpaper. Other uses require permission from the copyright holder."
+
+// Attribution is a ready-to-render photo credit for the wallpaper
+type Attribution struct {
+	Credit       string `json:"credit"` // e.g., "© romikatarina/Shutterstock"
+	Photographer string `json:"photographer,omitempty"`
+	Agency       string `json:"agency,omitempty"`
+	License      string `json:"license"`
+	Link         string `json:"link,omitempty"` // Bing's page about the photo
+}
+
+// newAttribution builds the photo credit from the photographer and agency parsed from Bing's copyright string
+// Returns nil when the copyright string credits no one
+func newAttribution(photographer, agency, copyrightLink string) *Attribution {
+	if photographer == "" && agency == "" {
+		return nil
+	}
+
+	credit := "© " + agency
+	if photographer != "" {
+		credit = "© " + photographer + "/" + agency
+	}
+
+	return &Attribution{
+		Credit:       credit,
+		Photographer: photographer,
+		Agency:       agency,
+		License:      attributionLicense,
+		Link:         copyrightLink,
+	}
+}
+
+// requestAttribution builds the photo credit of a cached request
+// Entries cached before the credit was stored have their copyright string parsed instead
+func requestAttribution(reqEntry *cache.RequestEntry) *Attribution {
+	photographer, agency := reqEntry.Photographer, reqEntry.Agency
+	if photographer == "" && agency == "" {
+		credit := bing.ParseCopyright(reqEntry.Copyright)
+		photographer, agency = credit.Photographer, credit.Agency
+	}
+	return newAttribution(photographer, agency, reqEntry.CopyrightLink)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mgabor3141/dailyhues/internal/audit"
+	"github.com/mgabor3141/dailyhues/internal/auth"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// audit records an admin mutation made by the authenticated caller of r
+func (app *App) audit(r *http.Request, action string, keys []string, details map[string]string) {
+	app.recordAudit(auth.SubjectFromContext(r.Context()), action, keys, details)
+}
+
+// recordAudit appends an event to the audit log; failures are logged but never fail the mutation itself
+func (app *App) recordAudit(actor, action string, keys []string, details map[string]string) {
+	event := audit.Event{Actor: actor, Action: action, Keys: keys, Details: details}
+	if err := app.auditLog.Record(event); err != nil {
+		slog.Error("Failed to record audit event", "action", action, "actor", actor, "error", err)
+	}
+}
+
+// handleAudit returns the audit log, newest first, filtered by actor, action, key and since
+func (app *App) handleAudit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	query := r.URL.Query()
+	filter := audit.Filter{
+		Actor:  query.Get("actor"),
+		Action: query.Get("action"),
+		Key:    query.Get("key"),
+		Limit:  defaultAuditLimit,
+	}
+
+	if since := query.Get("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid since parameter. Use RFC 3339, e.g. 2025-01-01T00:00:00Z")
+			return
+		}
+		filter.Since = t
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxAuditLimit {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxAuditLimit))
+			return
+		}
+		filter.Limit = n
+	}
+
+	events, err := app.auditLog.Query(filter)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{"events": events})
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"time"
+)
+
+const (
+	backfillDays            = 7
+	defaultBackfillInterval = time.Minute
+)
+
+// loadBackfillConfig reads the locales to backfill on a fresh cache and the pause between analyses
+// Locales that are not allowed are dropped; an empty result disables backfilling
+func loadBackfillConfig() ([]string, time.Duration) {
+	var locales []string
+	for _, param := range splitList(os.Getenv("BACKFILL_LOCALES")) {
+		locale, err := validateLocale(param)
+		if err != nil {
+			slog.Info("Ignoring backfill locale that is not allowed", "locale", param)
+			continue
+		}
+		locales = append(locales, locale)
+	}
+
+	interval := defaultBackfillInterval
+	if value := os.Getenv("BACKFILL_INTERVAL"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed < 0 {
+			slog.Info("Invalid BACKFILL_INTERVAL, using default", "value", value, "default", defaultBackfillInterval)
+		} else {
+			interval = parsed
+		}
+	}
+
+	return locales, interval
+}
+
+// backfill generates the last backfillDays palettes for each locale, pausing between uncached days
+// Meant to run in the background on a fresh cache so a new instance starts with history
+func (app *App) backfill(locales []string, interval time.Duration) {
+	slog.Info("Starting backfill", "locales", locales, "days", backfillDays, "interval", interval)
+
+	analyzed := 0
+	for _, locale := range locales {
+		infos, err := app.bingClient.WithLocale(locale).GetRecentWallpaperInfos(context.Background())
+		if err != nil {
+			slog.Error("Failed to fetch wallpaper archive for backfill", "locale", locale, "error", err)
+			continue
+		}
+
+		for daysAgo, info := range infos[:min(len(infos), backfillDays)] {
+			// Earlier locales may already have covered this day through a shared image
+			if _, ok := app.cachedThemeForDate(locale, info.StartDate); ok {
+				continue
+			}
+
+			if analyzed > 0 {
+				time.Sleep(interval)
+			}
+			analyzed++
+
+			if _, err := app.palettes().ResolveWallpaper(context.Background(), sourceBing, locale, daysAgo, info); err != nil {
+				slog.Error("Backfill failed", "locale", locale, "startdate", info.StartDate, "error", err)
+			}
+		}
+	}
+
+	slog.Info("Backfill finished", "analyzed", analyzed)
+}
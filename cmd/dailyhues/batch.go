@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// batchConcurrency caps how many locales or days a batch resolves at once
+	// Bing requests are paced by the shared scheduler either way; this bounds concurrent AI analyses
+	batchConcurrency = 4
+	// batchTaskTimeout bounds how long a batch waits for one locale or day
+	batchTaskTimeout = 2 * time.Minute
+	// maxBatchLocales caps the locales of a single /api/batch request
+	maxBatchLocales = 16
+)
+
+// taskResult is what one runBounded task produced
+type taskResult[T any] struct {
+	value T
+	err   error
+}
+
+// runBounded calls task for 0..n-1 with at most limit running at once, each with its own context
+// A task's context is cancelled when the parent is done or after timeout; a task that does not
+// return by then is reported as failed, but keeps running so its result still reaches the caches
+// Unlike an errgroup, one failure does not cancel the others: every task gets its own result and error
+func runBounded[T any](ctx context.Context, limit int, timeout time.Duration, n int, task func(ctx context.Context, i int) (T, error)) ([]T, []error) {
+	values := make([]T, n)
+	errs := make([]error, n)
+	slots := make(chan struct{}, limit)
+
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+			case <-ctx.Done():
+				errs[i] = ctx.Err()
+				return
+			}
+
+			taskCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			done := make(chan taskResult[T], 1)
+			go func() {
+				value, err := task(taskCtx, i)
+				done <- taskResult[T]{value, err}
+			}()
+
+			select {
+			case result := <-done:
+				values[i], errs[i] = result.value, result.err
+			case <-taskCtx.Done():
+				errs[i] = fmt.Errorf("gave up waiting: %w", taskCtx.Err())
+			}
+		}()
+	}
+	wg.Wait()
+
+	return values, errs
+}
+
+// BatchResponse lists one day's palettes for several locales, in the requested order
+type BatchResponse struct {
+	DaysAgo int         `json:"days_ago"`
+	Locales []BatchItem `json:"locales"`
+}
+
+// BatchItem is a single locale of a BatchResponse
+// If the palette could not be resolved, Error is set and the palette fields are left out
+type BatchItem struct {
+	Locale string `json:"locale"`
+	*ColorTheme
+	Error string `json:"error,omitempty"`
+}
+
+// handleBatch returns the palettes of several locales for one day, resolving them in parallel
+// Locales that fail are reported per item, so one bad locale does not fail the whole batch
+func (app *App) handleBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	query := r.URL.Query()
+	var locales []string
+	for _, param := range splitList(query.Get("locales")) {
+		locale, err := validateLocale(param)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		locales = append(locales, locale)
+	}
+	if len(locales) == 0 {
+		respondWithError(w, http.StatusBadRequest, "locales is required")
+		return
+	}
+	if len(locales) > maxBatchLocales {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("at most %d locales can be requested at once", maxBatchLocales))
+		return
+	}
+
+	daysAgo, err := validateDaysAgo(query.Get("daysAgo"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	angles, err := validateAngles(query.Get("angles"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	encoding, err := negotiateEncoding(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if encoding.themeOnly {
+		respondWithError(w, http.StatusBadRequest, "format is only supported for single palette responses")
+		return
+	}
+
+	items := app.getBatch(r.Context(), locales, daysAgo)
+	for _, item := range items {
+		if item.ColorTheme != nil {
+			*item.ColorTheme = withAllowedAngles(*item.ColorTheme, angles)
+		}
+	}
+
+	respondEncoded(w, http.StatusOK, encoding, BatchResponse{
+		DaysAgo: daysAgo,
+		Locales: items,
+	})
+}
+
+// getBatch resolves one day's palette for each locale, at most batchConcurrency at once
+func (app *App) getBatch(ctx context.Context, locales []string, daysAgo int) []BatchItem {
+	themes, errs := runBounded(ctx, batchConcurrency, batchTaskTimeout, len(locales), func(_ context.Context, i int) (ColorTheme, error) {
+		return app.getColorTheme(locales[i], daysAgo)
+	})
+
+	items := make([]BatchItem, len(locales))
+	for i, locale := range locales {
+		items[i].Locale = locale
+		if errs[i] != nil {
+			items[i].Error = errs[i].Error()
+		} else {
+			items[i].ColorTheme = &themes[i]
+		}
+	}
+	return items
+}
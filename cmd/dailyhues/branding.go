@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Upstream branding used when a deployment does not configure its own
+const (
+	defaultInstanceName = "dailyhues"
+	upstreamSourceURL   = "https://github.com/mgabor3141/dailyhues"
+)
+
+// Branding identifies a deployment on its landing page and in link previews
+type Branding struct {
+	Name       string `json:"name"`                  // INSTANCE_NAME
+	BaseURL    string `json:"base_url,omitempty"`    // BASE_URL without a trailing slash; empty derives it from each request
+	ContactURL string `json:"contact_url,omitempty"` // CONTACT_URL, e.g. a mailto: link or issue tracker; empty hides the link
+	SourceURL  string `json:"source_url"`            // Where the code and documentation live
+}
+
+// loadBranding reads the deployment's branding from the environment
+func loadBranding() Branding {
+	branding := Branding{
+		Name:       os.Getenv("INSTANCE_NAME"),
+		BaseURL:    strings.TrimSuffix(os.Getenv("BASE_URL"), "/"),
+		ContactURL: os.Getenv("CONTACT_URL"),
+		SourceURL:  upstreamSourceURL,
+	}
+	if branding.Name == "" {
+		branding.Name = defaultInstanceName
+	}
+	return branding
+}
+
+// baseURL returns the configured base URL, or the one the request was made to
+func (b Branding) baseURL(r *http.Request) string {
+	if b.BaseURL != "" {
+		return b.BaseURL
+	}
+
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/mgabor3141/dailyhues/internal/ai"
+	"github.com/mgabor3141/dailyhues/internal/bing"
+	"github.com/mgabor3141/dailyhues/internal/cache"
+	"github.com/mgabor3141/dailyhues/internal/palette"
+)
+
+// defaultCanaryPercent is the share of new analyses repeated on CANARY_MODEL when CANARY_PERCENT is unset
+const defaultCanaryPercent = 10
+
+// canaryFileName keeps model names usable as file names
+var canaryFileName = regexp.MustCompile(`[^a-zA-Z0-9-_.]`)
+
+// CanaryResult stores a primary analysis next to the same image analyzed by a candidate model
+// Canary results are never served; they only feed the comparison report
+type CanaryResult struct {
+	ImageHash  string            `json:"image_hash"`
+	Title      string            `json:"title"`
+	StartDate  string            `json:"startdate"`
+	AnalyzedAt time.Time         `json:"analyzed_at"`
+	Primary    CanaryAnalysis    `json:"primary"`
+	Candidate  CanaryAnalysis    `json:"candidate"`
+	Comparison *CanaryComparison `json:"comparison,omitempty"` // Missing if either side has no usable gradient
+}
+
+// CanaryAnalysis is one model's side of a CanaryResult
+type CanaryAnalysis struct {
+	Model            string                 `json:"model"`
+	Colors           map[string]interface{} `json:"colors,omitempty"`
+	PromptTokens     int                    `json:"prompt_tokens,omitempty"`
+	CompletionTokens int                    `json:"completion_tokens,omitempty"`
+	Cost             float64                `json:"cost,omitempty"`
+	Error            string                 `json:"error,omitempty"`
+}
+
+// CanaryComparison measures how far the candidate's palette is from the primary one
+// Color differences are ΔEOK×100 (see palette.DeltaE), the angle difference is in degrees
+type CanaryComparison struct {
+	GradientFrom   float64  `json:"gradient_from_delta"`
+	GradientTo     float64  `json:"gradient_to_delta"`
+	InactiveBorder *float64 `json:"inactive_border_delta,omitempty"`
+	Angle          float64  `json:"angle_delta"`
+}
+
+// CanaryReport summarizes the stored canary results for each candidate model
+type CanaryReport struct {
+	Candidates []CanaryModelReport `json:"candidates"`
+}
+
+// CanaryModelReport aggregates one candidate model's results, newest first
+type CanaryModelReport struct {
+	Model                   string         `json:"model"`
+	Samples                 int            `json:"samples"`
+	Failures                int            `json:"failures"` // Candidate errors, e.g. unparseable replies
+	MeanGradientFromDelta   float64        `json:"mean_gradient_from_delta"`
+	MeanGradientToDelta     float64        `json:"mean_gradient_to_delta"`
+	MeanInactiveBorderDelta float64        `json:"mean_inactive_border_delta"`
+	MeanAngleDelta          float64        `json:"mean_angle_delta"`
+	PrimaryCost             float64        `json:"primary_cost"`
+	CandidateCost           float64        `json:"candidate_cost"`
+	Results                 []CanaryResult `json:"results"`
+}
+
+// loadCanaryConfig reads the candidate model and the percentage of analyses repeated on it
+// Read per analysis so a config reload starts or stops the canary immediately
+func loadCanaryConfig() (string, float64) {
+	model := os.Getenv("CANARY_MODEL")
+	if model == "" {
+		return "", 0
+	}
+
+	percent := float64(defaultCanaryPercent)
+	if value := os.Getenv("CANARY_PERCENT"); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed < 0 || parsed > 100 {
+			slog.Info("Invalid CANARY_PERCENT, using default", "value", value, "default", defaultCanaryPercent)
+		} else {
+			percent = parsed
+		}
+	}
+	return model, percent
+}
+
+// canarySampled picks analyses by image hash, so the same wallpaper is always in or out of the sample
+// and repeated analyses of one image do not skew the comparison
+func canarySampled(imageHash string, percent float64) bool {
+	if len(imageHash) < 8 {
+		return false
+	}
+	bucket, err := strconv.ParseUint(imageHash[:8], 16, 32)
+	if err != nil {
+		return false
+	}
+	return float64(bucket%10000) < percent*100
+}
+
+// canaryDir is where canary results are stored, next to the caches
+func canaryDir() string {
+	return filepath.Join(cacheDir(), "canary")
+}
+
+// startCanary repeats a finished analysis on the candidate model in the background, if this image is sampled
+// app.canaries tracks it so the CLI can wait
+func (app *App) startCanary(imageData []byte, primary cache.AnalysisEntry, info *bing.WallpaperInfo) {
+	model, percent := loadCanaryConfig()
+	if model == "" || model == ai.Model() || !canarySampled(primary.ImageHash, percent) {
+		return
+	}
+
+	app.canaries.Add(1)
+	go func() {
+		defer app.canaries.Done()
+		app.runCanary(model, imageData, primary, info)
+	}()
+}
+
+// runCanary analyzes an image on the candidate model and stores the result beside the primary analysis
+func (app *App) runCanary(model string, imageData []byte, primary cache.AnalysisEntry, info *bing.WallpaperInfo) {
+	slog.Info("Starting canary analysis", "hash", primary.ImageHash, "model", model)
+
+	result := CanaryResult{
+		ImageHash:  primary.ImageHash,
+		Title:      info.Title,
+		StartDate:  info.StartDate,
+		AnalyzedAt: time.Now().UTC(),
+		Primary: CanaryAnalysis{
+			Model:            primary.Model,
+			Colors:           primary.Colors,
+			PromptTokens:     primary.PromptTokens,
+			CompletionTokens: primary.CompletionTokens,
+			Cost:             primary.Cost,
+		},
+		Candidate: CanaryAnalysis{Model: model},
+	}
+
+	colors, usage, err := app.aiAnalyzer.AnalyzeColorsWithModel(model, imageData, primary.ImageHash, info.Title, info.Copyright, primary.PromptAddendum)
+	if errors.Is(err, ai.ErrCircuitOpen) {
+		// Not the candidate's fault, so it is not counted as one of its failures
+		slog.Info("Skipped canary analysis while OpenRouter is unavailable", "hash", primary.ImageHash, "model", model)
+		return
+	}
+	// A failed candidate may still have been billed, which counts towards its cost
+	result.Candidate.PromptTokens = usage.PromptTokens
+	result.Candidate.CompletionTokens = usage.CompletionTokens
+	result.Candidate.Cost = usage.Cost
+	if err != nil {
+		slog.Info("Canary analysis failed", "hash", primary.ImageHash, "model", model, "error", err)
+		result.Candidate.Error = err.Error()
+	} else {
+		result.Candidate.Colors = loadNormalizePolicy().Apply(colors)
+		result.Comparison = compareCanary(result.Primary.Colors, result.Candidate.Colors)
+	}
+
+	if err := saveCanaryResult(result); err != nil {
+		slog.Error("Failed to store canary result", "hash", primary.ImageHash, "model", model, "error", err)
+	}
+}
+
+// compareCanary measures the difference between two palettes, or nil if either lacks a gradient
+func compareCanary(primary, candidate map[string]interface{}) *CanaryComparison {
+	p, err := palette.GradientFromColors(primary)
+	if err != nil {
+		return nil
+	}
+	c, err := palette.GradientFromColors(candidate)
+	if err != nil {
+		return nil
+	}
+
+	comparison := &CanaryComparison{Angle: math.Abs(math.Mod(p.Angle-c.Angle+540, 360) - 180)}
+	var ok bool
+	if comparison.GradientFrom, ok = hexDeltaE(p.From, c.From); !ok {
+		return nil
+	}
+	if comparison.GradientTo, ok = hexDeltaE(p.To, c.To); !ok {
+		return nil
+	}
+
+	primaryInactive, _ := primary["inactive_border"].(string)
+	candidateInactive, _ := candidate["inactive_border"].(string)
+	if delta, ok := hexDeltaE(primaryInactive, candidateInactive); ok {
+		comparison.InactiveBorder = &delta
+	}
+	return comparison
+}
+
+// hexDeltaE returns palette.DeltaE of two hex colors, rounded to two decimals
+func hexDeltaE(a, b string) (float64, bool) {
+	ca, err := palette.ParseHex(a)
+	if err != nil {
+		return 0, false
+	}
+	cb, err := palette.ParseHex(b)
+	if err != nil {
+		return 0, false
+	}
+	return math.Round(palette.DeltaE(ca, cb)*100) / 100, true
+}
+
+// saveCanaryResult stores a result under the image hash and candidate model, replacing an earlier run
+func saveCanaryResult(result CanaryResult) error {
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	name := result.ImageHash + "_" + canaryFileName.ReplaceAllString(result.Candidate.Model, "_") + ".json"
+	return writeFileAtomic(filepath.Join(canaryDir(), name), data)
+}
+
+// loadCanaryResults reads every stored canary result, skipping unreadable files
+func loadCanaryResults() ([]CanaryResult, error) {
+	entries, err := os.ReadDir(canaryDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var results []CanaryResult
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(canaryDir(), entry.Name()))
+		if err != nil {
+			slog.Info("Failed to read canary result", "file", entry.Name(), "error", err)
+			continue
+		}
+		var result CanaryResult
+		if err := json.Unmarshal(data, &result); err != nil {
+			slog.Info("Failed to parse canary result", "file", entry.Name(), "error", err)
+			continue
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// buildCanaryReport groups results by candidate model and averages their differences from the primary model
+func buildCanaryReport(results []CanaryResult) CanaryReport {
+	byModel := make(map[string]*CanaryModelReport)
+	var models []string
+	for _, result := range results {
+		report, ok := byModel[result.Candidate.Model]
+		if !ok {
+			report = &CanaryModelReport{Model: result.Candidate.Model, Results: []CanaryResult{}}
+			byModel[result.Candidate.Model] = report
+			models = append(models, result.Candidate.Model)
+		}
+		report.Samples++
+		report.PrimaryCost += result.Primary.Cost
+		report.CandidateCost += result.Candidate.Cost
+		if result.Candidate.Error != "" {
+			report.Failures++
+		}
+		report.Results = append(report.Results, result)
+	}
+	sort.Strings(models)
+
+	report := CanaryReport{Candidates: []CanaryModelReport{}}
+	for _, model := range models {
+		modelReport := byModel[model]
+		sort.Slice(modelReport.Results, func(i, j int) bool {
+			return modelReport.Results[i].AnalyzedAt.After(modelReport.Results[j].AnalyzedAt)
+		})
+
+		var compared, withInactive int
+		var from, to, inactive, angle float64
+		for _, result := range modelReport.Results {
+			if result.Comparison == nil {
+				continue
+			}
+			compared++
+			from += result.Comparison.GradientFrom
+			to += result.Comparison.GradientTo
+			angle += result.Comparison.Angle
+			if result.Comparison.InactiveBorder != nil {
+				withInactive++
+				inactive += *result.Comparison.InactiveBorder
+			}
+		}
+		if compared > 0 {
+			modelReport.MeanGradientFromDelta = math.Round(from/float64(compared)*100) / 100
+			modelReport.MeanGradientToDelta = math.Round(to/float64(compared)*100) / 100
+			modelReport.MeanAngleDelta = math.Round(angle/float64(compared)*100) / 100
+		}
+		if withInactive > 0 {
+			modelReport.MeanInactiveBorderDelta = math.Round(inactive/float64(withInactive)*100) / 100
+		}
+		report.Candidates = append(report.Candidates, *modelReport)
+	}
+	return report
+}
+
+// handleCanary returns the comparison report of the stored canary results
+func handleCanary(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	results, err := loadCanaryResults()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to read canary results")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, buildCanaryReport(results))
+}
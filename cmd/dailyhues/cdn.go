@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	defaultCacheMaxAge               = time.Hour
+	defaultCacheStaleWhileRevalidate = time.Minute
+	defaultCacheStaleIfError         = 24 * time.Hour
+)
+
+// defaultCacheKeyIgnoredParams are tracking parameters that never change a response
+var defaultCacheKeyIgnoredParams = []string{"utm_*", "fbclid", "gclid"}
+
+// cacheSettings control the caching directives for shared caches (CDNs, reverse proxies)
+type cacheSettings struct {
+	MaxAge               time.Duration // Upper bound; palettes are never cached past their next update
+	StaleWhileRevalidate time.Duration
+	StaleIfError         time.Duration
+	NormalizeQuery       bool     // Redirect API requests to their canonical query string
+	IgnoredParams        []string // Dropped during normalization; glob patterns such as utm_* are allowed
+}
+
+// loadCacheSettings reads the CACHE_* settings, falling back to defaults for invalid values
+// Read per request so a config reload takes effect immediately
+func loadCacheSettings() cacheSettings {
+	settings := cacheSettings{
+		MaxAge:               durationSetting("CACHE_MAX_AGE", defaultCacheMaxAge),
+		StaleWhileRevalidate: durationSetting("CACHE_STALE_WHILE_REVALIDATE", defaultCacheStaleWhileRevalidate),
+		StaleIfError:         durationSetting("CACHE_STALE_IF_ERROR", defaultCacheStaleIfError),
+		NormalizeQuery:       os.Getenv("CACHE_KEY_NORMALIZE") == "true",
+		IgnoredParams:        defaultCacheKeyIgnoredParams,
+	}
+	if value, ok := os.LookupEnv("CACHE_KEY_IGNORED_PARAMS"); ok {
+		settings.IgnoredParams = splitList(value)
+	}
+	return settings
+}
+
+// durationSetting parses a non-negative duration from the environment
+func durationSetting(name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed < 0 {
+		slog.Info("Invalid "+name+", using default", "value", value, "default", fallback)
+		return fallback
+	}
+	return parsed
+}
+
+// setCacheHeaders lets browsers and CDNs cache a palette until it next changes
+// Surrogate-Control (Fastly) and CDN-Cache-Control (Cloudflare) carry the same directives for the edge;
+// stale-while-revalidate lets the edge keep answering while one request fetches the new palette
+func setCacheHeaders(w http.ResponseWriter, nextUpdate, now time.Time) {
+	settings := loadCacheSettings()
+
+	maxAge := settings.MaxAge
+	if !nextUpdate.IsZero() {
+		maxAge = max(0, min(maxAge, nextUpdate.Sub(now)))
+	}
+
+	directives := fmt.Sprintf("max-age=%d, stale-while-revalidate=%d, stale-if-error=%d",
+		int64(maxAge.Seconds()), int64(settings.StaleWhileRevalidate.Seconds()), int64(settings.StaleIfError.Seconds()))
+	w.Header().Set("Cache-Control", "public, "+directives)
+	w.Header().Set("Surrogate-Control", directives)
+	w.Header().Set("CDN-Cache-Control", directives)
+}
+
+// withCanonicalQuery redirects API GET requests to a canonical query string when CACHE_KEY_NORMALIZE is set
+// Parameters are sorted, empty and ignored ones dropped, so a CDN keyed on the URL stores one copy per response
+func withCanonicalQuery(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if (r.Method != http.MethodGet && r.Method != http.MethodHead) || !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		settings := loadCacheSettings()
+		if !settings.NormalizeQuery {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		canonical := canonicalQuery(r.URL.Query(), settings.IgnoredParams)
+		if canonical == r.URL.RawQuery {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		target := url.URL{Path: r.URL.Path, RawQuery: canonical}
+		http.Redirect(w, r, target.String(), http.StatusMovedPermanently)
+	})
+}
+
+// canonicalQuery encodes the query with sorted keys, without empty or ignored parameters
+func canonicalQuery(query url.Values, ignored []string) string {
+	for name, values := range query {
+		if isIgnoredParam(name, ignored) {
+			query.Del(name)
+			continue
+		}
+
+		kept := values[:0]
+		for _, value := range values {
+			if value != "" {
+				kept = append(kept, value)
+			}
+		}
+		if len(kept) == 0 {
+			query.Del(name)
+		} else {
+			query[name] = kept
+		}
+	}
+	return query.Encode()
+}
+
+// isIgnoredParam matches a parameter name against the ignore list
+func isIgnoredParam(name string, ignored []string) bool {
+	for _, pattern := range ignored {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
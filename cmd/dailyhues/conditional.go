@@ -0,0 +1,119 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mgabor3141/dailyhues/internal/cache"
+)
+
+// imageHashHeader exposes the analyzed image's content hash as a palette fingerprint
+const imageHashHeader = "X-Dailyhues-Image-Hash"
+
+// paletteVersion identifies which colors were served for an image, so validators change when they are replaced
+type paletteVersion struct {
+	analyzedAt time.Time // When the colors were last analyzed; a re-analysis replaces them under the same image hash
+	model      string
+	pinnedAt   time.Time // When an operator pinned the colors, zero for AI results
+}
+
+// versionOf returns the palette version of an analysis entry
+func versionOf(entry *cache.AnalysisEntry) paletteVersion {
+	return paletteVersion{analyzedAt: entry.AnalyzedAt, model: entry.Model, pinnedAt: entry.PinnedAt()}
+}
+
+// subject names the colors of an image: the pin time for palettes set by an operator, otherwise the analysis time and model
+func (v paletteVersion) subject(imageHash string) string {
+	if !v.pinnedAt.IsZero() {
+		return imageHash + "-pinned-" + strconv.FormatInt(v.pinnedAt.Unix(), 10)
+	}
+	if v.analyzedAt.IsZero() {
+		return imageHash
+	}
+	subject := imageHash + "-" + strconv.FormatInt(v.analyzedAt.Unix(), 10)
+	if v.model != "" {
+		subject += "-" + v.model
+	}
+	return subject
+}
+
+// modified returns when the colors last changed: the pin time, or the analysis time for AI results
+func (v paletteVersion) modified() time.Time {
+	if !v.pinnedAt.IsZero() {
+		return v.pinnedAt
+	}
+	return v.analyzedAt
+}
+
+// paletteETag derives a weak ETag from the image hash and palette version
+// Weak because cached_at differs between otherwise equivalent responses
+func paletteETag(imageHash string, version paletteVersion) string {
+	return `W/"` + version.subject(imageHash) + `"`
+}
+
+// setValidators sets the fingerprint and Last-Modified headers and answers 304 when the client's copy is current
+// wentLive is when the wallpaper went live; a zero time omits Last-Modified
+// A later pin or re-analysis replaces it, so clients holding the earlier colors fetch the new ones
+// Returns true when the response has been fully written
+func setValidators(w http.ResponseWriter, r *http.Request, imageHash string, version paletteVersion, wentLive time.Time) bool {
+	if imageHash == "" {
+		return false
+	}
+
+	lastModified := wentLive
+	if modified := version.modified(); !lastModified.IsZero() && modified.After(lastModified) {
+		lastModified = modified
+	}
+
+	etag := paletteETag(imageHash, version)
+	w.Header().Set("ETag", etag)
+	w.Header().Set(imageHashHeader, imageHash)
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if notModified(r, etag, lastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+
+	return false
+}
+
+// notModified evaluates If-None-Match, or If-Modified-Since for clients that only send a date
+// If-None-Match takes precedence when both are present (RFC 9110 section 13.2.2)
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if header := r.Header.Get("If-None-Match"); header != "" {
+		return etagMatches(header, etag)
+	}
+
+	if lastModified.IsZero() {
+		return false
+	}
+
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+
+	// HTTP dates have second precision
+	return !lastModified.Truncate(time.Second).After(since)
+}
+
+// etagMatches implements the weak comparison used for If-None-Match
+func etagMatches(header string, etag string) bool {
+	if header == "" {
+		return false
+	}
+
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+
+	"github.com/mgabor3141/dailyhues/internal/ai"
+	"github.com/mgabor3141/dailyhues/internal/bing"
+	"github.com/mgabor3141/dailyhues/internal/palette"
+)
+
+const (
+	// defaultResizeCheckThreshold is the histogram divergence above which a resized image is flagged
+	defaultResizeCheckThreshold = 0.2
+	// dominantColorsLogged is how many dominant colors of each image are logged with a flagged divergence
+	dominantColorsLogged = 5
+)
+
+// loadResizeCheck reports whether RESIZE_CHECK is enabled and the divergence threshold from RESIZE_CHECK_THRESHOLD
+func loadResizeCheck() (bool, float64) {
+	threshold := defaultResizeCheckThreshold
+	if value := os.Getenv("RESIZE_CHECK_THRESHOLD"); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed < 0 || parsed > 1 {
+			slog.Info("Invalid RESIZE_CHECK_THRESHOLD, using default", "value", value, "default", defaultResizeCheckThreshold)
+		} else {
+			threshold = parsed
+		}
+	}
+
+	return os.Getenv("RESIZE_CHECK") == "true", threshold
+}
+
+// checkResizeConsistency compares the color histogram of the image sent to the model with the UHD original
+// Large divergences are logged so resize-induced color shifts can be spotted; the divergence is returned for the cache
+func (app *App) checkResizeConsistency(info *bing.WallpaperInfo, imageData []byte, threshold float64) (float64, error) {
+	analyzed, err := ai.AnalysisImage(imageData)
+	if err != nil {
+		return 0, fmt.Errorf("failed to resize image: %w", err)
+	}
+
+	original, err := app.bingClient.DownloadWallpaper(context.Background(), &bing.WallpaperInfo{URL: info.ImageURLs["UHD"]})
+	if err != nil {
+		return 0, fmt.Errorf("failed to download UHD original: %w", err)
+	}
+
+	analyzedHistogram, err := imageHistogram(analyzed)
+	if err != nil {
+		return 0, err
+	}
+	originalHistogram, err := imageHistogram(original)
+	if err != nil {
+		return 0, err
+	}
+
+	divergence := analyzedHistogram.Divergence(originalHistogram)
+	if divergence > threshold {
+		slog.Info("Analyzed image is not representative of the UHD original",
+			"title", info.Title,
+			"divergence", divergence,
+			"threshold", threshold,
+			"analyzed", analyzedHistogram.Dominant(dominantColorsLogged),
+			"original", originalHistogram.Dominant(dominantColorsLogged))
+	} else {
+		slog.Info("Resize consistency check passed", "title", info.Title, "divergence", divergence)
+	}
+
+	return divergence, nil
+}
+
+// imageHistogram decodes an image and computes its color histogram
+func imageHistogram(data []byte) (palette.Histogram, error) {
+	img, err := decodeImage(data)
+	if err != nil {
+		return palette.Histogram{}, err
+	}
+	return palette.NewHistogram(img), nil
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mgabor3141/dailyhues/internal/palette"
+)
+
+// WCAG 2 minimum contrast ratios for normal and large text
+const (
+	wcagAA      = 4.5
+	wcagAALarge = 3
+	wcagAAA     = 7
+)
+
+// ContrastResponse reports how a day's palette colors contrast with a color supplied by the client
+type ContrastResponse struct {
+	Locale    string          `json:"locale"`
+	StartDate string          `json:"startdate"`
+	Against   string          `json:"against"` // The supplied color
+	Role      string          `json:"role"`    // "foreground" if the palette colors are backgrounds to it, "background" otherwise
+	Colors    []ColorContrast `json:"colors"`
+}
+
+// ColorContrast is the contrast of one palette color, with the WCAG 2 levels it passes for text
+type ColorContrast struct {
+	Name    string  `json:"name"` // Nested colors are flattened like the txt format, e.g. notifications_low
+	Color   string  `json:"color"`
+	Ratio   float64 `json:"ratio"`
+	AA      bool    `json:"aa"`
+	AALarge bool    `json:"aa_large"`
+	AAA     bool    `json:"aaa"`
+}
+
+// handleContrast returns the WCAG contrast ratio of each palette color against ?fg= or ?bg=
+// so UI builders can check their text colors against the day's palette
+func (app *App) handleContrast(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	query := r.URL.Query()
+
+	against, role, err := validateContrastColor(query.Get("fg"), query.Get("bg"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	daysAgo, err := validateDaysAgo(query.Get("daysAgo"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	locale, err := validateLocale(query.Get("locale"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	encoding, err := negotiateEncoding(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if encoding.themeOnly {
+		respondWithError(w, http.StatusBadRequest, "format is only supported for single palette responses")
+		return
+	}
+
+	theme, err := app.getColorTheme(locale, daysAgo)
+	if err != nil {
+		respondWithResolveError(w, err)
+		return
+	}
+
+	respondEncoded(w, http.StatusOK, encoding, ContrastResponse{
+		Locale:    locale,
+		StartDate: theme.StartDate,
+		Against:   against.Hex(),
+		Role:      role,
+		Colors:    paletteContrast(theme, against),
+	})
+}
+
+// validateContrastColor parses the color to compare against; exactly one of fg and bg must be set
+func validateContrastColor(fg, bg string) (palette.RGB, string, error) {
+	if (fg == "") == (bg == "") {
+		return palette.RGB{}, "", fmt.Errorf("exactly one of fg or bg is required, e.g. fg=%%23000000")
+	}
+
+	value, role := fg, "foreground"
+	if bg != "" {
+		value, role = bg, "background"
+	}
+	color, err := palette.ParseHex(value)
+	if err != nil {
+		return palette.RGB{}, "", fmt.Errorf("invalid %s color %q. Use #rrggbb or #rgb", role, value)
+	}
+	return color, role, nil
+}
+
+// paletteContrast rates every color of a theme against one color, sorted by name
+// The derived inactive border and the wallpaper's region averages are included, since text may sit on either
+func paletteContrast(theme ColorTheme, against palette.RGB) []ColorContrast {
+	colors := make(map[string]string)
+	collectColors(colors, "", theme.Colors)
+	if _, ok := colors["inactive_border"]; !ok && theme.InactiveBorder != "" {
+		colors["inactive_border"] = theme.InactiveBorder
+	}
+	for region, color := range theme.Regions {
+		colors["region_"+region] = color
+	}
+
+	names := make([]string, 0, len(colors))
+	for name := range colors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	results := make([]ColorContrast, 0, len(names))
+	for _, name := range names {
+		color, err := palette.ParseHex(colors[name])
+		if err != nil {
+			continue
+		}
+		ratio := palette.ContrastRatio(color, against)
+		results = append(results, ColorContrast{
+			Name:    name,
+			Color:   colors[name],
+			Ratio:   math.Round(ratio*100) / 100,
+			AA:      ratio >= wcagAA,
+			AALarge: ratio >= wcagAALarge,
+			AAA:     ratio >= wcagAAA,
+		})
+	}
+	return results
+}
+
+// collectColors gathers the string values of a colors map, flattening nested groups with an underscore
+func collectColors(colors map[string]string, prefix string, values map[string]interface{}) {
+	for key, value := range values {
+		switch v := value.(type) {
+		case string:
+			if strings.HasPrefix(v, "#") {
+				colors[prefix+key] = v
+			}
+		case map[string]interface{}:
+			collectColors(colors, prefix+key+"_", v)
+		case []interface{}:
+			for i, item := range v {
+				if s, ok := item.(string); ok && strings.HasPrefix(s, "#") {
+					colors[prefix+key+"_"+strconv.Itoa(i+1)] = s
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"net/http"
+	"os"
+
+	"github.com/mgabor3141/dailyhues/internal/ai"
+	"github.com/mgabor3141/dailyhues/internal/bing"
+	"github.com/mgabor3141/dailyhues/internal/palette"
+	"github.com/mgabor3141/dailyhues/internal/version"
+)
+
+// ConfigSummary is the configuration in effect; secrets are only reported as set or not
+type ConfigSummary struct {
+	Version        version.Info    `json:"version"`
+	Port           string          `json:"port"`
+	CacheDir       string          `json:"cache_dir"`
+	ConfigFile     string          `json:"config_file,omitempty"`
+	CachedRequests int             `json:"cached_requests"`
+	CachedAnalyses int             `json:"cached_analyses"`
+	DefaultLocale  string          `json:"default_locale"`
+	AllowedLocales []string        `json:"allowed_locales"`
+	Model          string          `json:"model"`
+	BingUserAgent  string          `json:"bing_user_agent"`
+	BingInterval   string          `json:"bing_min_interval"` // Minimum time between two Bing requests
+	BingTimeout    string          `json:"bing_timeout"`
+	BingRetries    int             `json:"bing_retries"`
+	BingBackoff    string          `json:"bing_retry_backoff"`
+	BingMetadata   string          `json:"bing_metadata_ttl"` // How long archive API entries are reused
+	Instance       Branding        `json:"instance"`
+	Secrets        map[string]bool `json:"secrets"`
+	Features       FeatureSummary  `json:"features"`
+}
+
+// FeatureSummary lists the optional features and their settings
+type FeatureSummary struct {
+	AdminAPI           bool                    `json:"admin_api"`
+	LogLevel           string                  `json:"log_level"`
+	DebugAIResponses   bool                    `json:"debug_ai_responses"`
+	JSONP              bool                    `json:"jsonp"`
+	BackfillLocales    []string                `json:"backfill_locales,omitempty"`
+	ReanalysisMaxAge   string                  `json:"reanalysis_max_age,omitempty"`
+	ReanalysisBudget   int                     `json:"reanalysis_daily_budget,omitempty"`
+	ResizeCheck        bool                    `json:"resize_check"`
+	ArchiveImages      bool                    `json:"archive_images"`
+	VerifyImageURLs    bool                    `json:"verify_image_urls"`
+	AnalysisImageSize  string                  `json:"analysis_image_size,omitempty"`
+	ImageMaxBytes      int                     `json:"image_max_bytes,omitempty"`
+	ImageMaxTokens     int                     `json:"image_max_tokens,omitempty"`
+	ProviderRouting    *ai.ProviderPreferences `json:"provider_routing,omitempty"` // OPENROUTER_* routing settings, if any
+	ColorNormalization palette.NormalizePolicy `json:"color_normalization"`
+	PromptAddenda      []string                `json:"prompt_addenda,omitempty"` // Names of the PROMPT_ADDENDUM_* variables that are set
+	PromptProfiles     []string                `json:"prompt_profiles,omitempty"`
+	DigestLocales      []string                `json:"digest_locales,omitempty"` // Set when a digest target is configured
+	CanaryModel        string                  `json:"canary_model,omitempty"`
+	CanaryPercent      float64                 `json:"canary_percent,omitempty"`
+}
+
+// serverPort returns the port to listen on from PORT, or the default
+func serverPort() string {
+	if port := os.Getenv("PORT"); port != "" {
+		return port
+	}
+	return defaultPort
+}
+
+// cacheDir returns the cache directory from CACHE_DIR, or the default
+func cacheDir() string {
+	if dir := os.Getenv("CACHE_DIR"); dir != "" {
+		return dir
+	}
+	return defaultCacheDir
+}
+
+// configSummary collects the configuration in effect
+func (app *App) configSummary() ConfigSummary {
+	allowedLocalesMu.RLock()
+	locales := allowedLocales
+	defaultLocaleInEffect := defaultLocale
+	allowedLocalesMu.RUnlock()
+
+	backfillLocales, _ := loadBackfillConfig()
+	maxAge, reanalysisBudget := loadReanalysisConfig()
+	resizeCheck, _ := loadResizeCheck()
+	imageMaxBytes, imageMaxTokens := ai.ImageBudget()
+
+	digestLocales, digestTargets := loadDigestConfig()
+
+	features := FeatureSummary{
+		AdminAPI:           app.authenticator.Enabled(),
+		LogLevel:           currentRuntimeSettings().LogLevel,
+		DebugAIResponses:   currentRuntimeSettings().DebugAIResponses,
+		JSONP:              os.Getenv("ENABLE_JSONP") == "true",
+		BackfillLocales:    backfillLocales,
+		ResizeCheck:        resizeCheck,
+		ArchiveImages:      archiveImagesEnabled(),
+		VerifyImageURLs:    verifyImagesEnabled(),
+		AnalysisImageSize:  analysisImageSize(),
+		ImageMaxBytes:      imageMaxBytes,
+		ImageMaxTokens:     imageMaxTokens,
+		ProviderRouting:    ai.ProviderRouting(),
+		ColorNormalization: loadNormalizePolicy(),
+		PromptAddenda:      promptAddendumSettings(),
+		PromptProfiles:     promptProfileNames(),
+	}
+	if digestTargets.enabled() {
+		features.DigestLocales = digestLocales
+	}
+	features.CanaryModel, features.CanaryPercent = loadCanaryConfig()
+	if maxAge > 0 {
+		features.ReanalysisMaxAge = maxAge.String()
+		features.ReanalysisBudget = reanalysisBudget
+	}
+
+	return ConfigSummary{
+		Version:        version.Get(),
+		Port:           serverPort(),
+		CacheDir:       cacheDir(),
+		ConfigFile:     os.Getenv("CONFIG_FILE"),
+		CachedRequests: app.requestCache.Len(),
+		CachedAnalyses: app.analysisCache.Len(),
+		DefaultLocale:  defaultLocaleInEffect,
+		AllowedLocales: locales,
+		Model:          ai.Model(),
+		BingUserAgent:  bing.UserAgent(),
+		BingInterval:   bing.MinInterval().String(),
+		BingTimeout:    bing.Timeout().String(),
+		BingRetries:    bing.Retries(),
+		BingBackoff:    bing.RetryBackoff().String(),
+		BingMetadata:   bing.MetadataTTL().String(),
+		Instance:       loadBranding(),
+		Secrets: map[string]bool{
+			"OPENROUTER_API_KEY": os.Getenv("OPENROUTER_API_KEY") != "",
+			"ADMIN_API_KEYS":     os.Getenv("ADMIN_API_KEYS") != "",
+			"ADMIN_JWT_SECRET":   os.Getenv("ADMIN_JWT_SECRET") != "",
+			// Webhook and broker URLs embed tokens and credentials
+			"DIGEST_WEBHOOK_URL":         os.Getenv("DIGEST_WEBHOOK_URL") != "",
+			"DIGEST_DISCORD_WEBHOOK_URL": os.Getenv("DIGEST_DISCORD_WEBHOOK_URL") != "",
+			"DIGEST_MQTT_URL":            os.Getenv("DIGEST_MQTT_URL") != "",
+		},
+		Features: features,
+	}
+}
+
+// handleConfigSummary returns the configuration in effect with secrets redacted
+func (app *App) handleConfigSummary(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, app.configSummary())
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"slices"
+	"strconv"
+
+	"github.com/mgabor3141/dailyhues/internal/palette"
+)
+
+// einkLevels are the shade counts selectable with ?levels= for format=eink: 1-bit, 2-bit and 4-bit displays
+var einkLevels = []int{2, 4, 16}
+
+const (
+	defaultEinkLevels = 4
+	// Swatch image layout: a gradient strip, then one row per color
+	einkSwatchWidth  = 192
+	einkSwatchHeight = 24
+)
+
+// EinkPalette is a palette reduced to the shades of an e-paper display (format=eink)
+type EinkPalette struct {
+	StartDate string               `json:"startdate"`
+	Title     string               `json:"title"`
+	Levels    int                  `json:"levels"`
+	Grays     []string             `json:"grays"` // The display's shades, darkest first
+	Colors    map[string]EinkColor `json:"colors"`
+	// Swatch is a PNG data URI with the gradient as a dithered strip on top, if the palette has one,
+	// then a dithered row per color in the order of their names. It has one bit per pixel with 2 levels
+	Swatch string `json:"swatch"`
+}
+
+// EinkColor is a palette color and the display shade closest to it in lightness
+type EinkColor struct {
+	Hex   string `json:"hex"`
+	Gray  string `json:"gray"`
+	Level int    `json:"level"` // Index of gray in grays
+}
+
+// validateEinkLevels validates the levels parameter of format=eink
+func validateEinkLevels(param string) (int, error) {
+	if param == "" {
+		return defaultEinkLevels, nil
+	}
+	levels, err := strconv.Atoi(param)
+	if err != nil || !slices.Contains(einkLevels, levels) {
+		return 0, fmt.Errorf("invalid levels parameter. Supported levels: 2, 4, 16")
+	}
+	return levels, nil
+}
+
+// marshalEink renders a palette for e-paper with the given number of shades, encoding it with marshal
+func marshalEink(levels int, marshal func(interface{}) ([]byte, error)) func(interface{}) ([]byte, error) {
+	return func(data interface{}) ([]byte, error) {
+		theme, ok := data.(ColorTheme)
+		if !ok {
+			return nil, fmt.Errorf("format is only supported for single palette responses")
+		}
+		eink, err := einkPalette(theme, levels)
+		if err != nil {
+			return nil, err
+		}
+		return marshal(eink)
+	}
+}
+
+// einkPalette reduces a theme's colors to a display's shades and draws the swatch image
+func einkPalette(theme ColorTheme, levels int) (EinkPalette, error) {
+	grays := palette.GrayLevels(levels)
+
+	hexes := make(map[string]string)
+	collectColors(hexes, "", theme.Colors)
+	if _, ok := hexes["inactive_border"]; !ok && theme.InactiveBorder != "" {
+		hexes["inactive_border"] = theme.InactiveBorder
+	}
+
+	eink := EinkPalette{
+		StartDate: theme.StartDate,
+		Title:     theme.Title,
+		Levels:    levels,
+		Colors:    make(map[string]EinkColor, len(hexes)),
+	}
+	for _, gray := range grays {
+		eink.Grays = append(eink.Grays, gray.Hex())
+	}
+
+	names := make([]string, 0, len(hexes))
+	rows := make([]palette.RGB, 0, len(hexes))
+	for name, hex := range hexes {
+		c, err := palette.ParseHex(hex)
+		if err != nil {
+			continue // Colors with an alpha channel or other notations are left out
+		}
+		level := palette.NearestLevel(c, grays)
+		eink.Colors[name] = EinkColor{Hex: hex, Gray: eink.Grays[level], Level: level}
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		c, _ := palette.ParseHex(hexes[name])
+		rows = append(rows, c)
+	}
+
+	swatch, err := einkSwatch(theme.Colors, rows, grays)
+	if err != nil {
+		return EinkPalette{}, fmt.Errorf("failed to draw e-ink swatch: %w", err)
+	}
+	eink.Swatch = "data:image/png;base64," + base64.StdEncoding.EncodeToString(swatch)
+	return eink, nil
+}
+
+// einkSwatch draws the dithered swatch image as a paletted PNG, which Go encodes at 1, 2 or 4 bits per pixel
+func einkSwatch(colors map[string]interface{}, rows []palette.RGB, grays []palette.RGB) ([]byte, error) {
+	gradient, gradientErr := palette.GradientFromColors(colors)
+	var from, to palette.RGB
+	if gradientErr == nil {
+		from, _ = palette.ParseHex(gradient.From)
+		to, _ = palette.ParseHex(gradient.To)
+	}
+
+	strips := len(rows)
+	if gradientErr == nil {
+		strips++
+	}
+
+	shades := make(color.Palette, len(grays))
+	for i, gray := range grays {
+		shades[i] = color.Gray{Y: uint8(math.Round(gray.R * 255))}
+	}
+	img := image.NewPaletted(image.Rect(0, 0, einkSwatchWidth, max(strips, 1)*einkSwatchHeight), shades)
+
+	for y := 0; y < img.Bounds().Dy(); y++ {
+		strip := y / einkSwatchHeight
+		for x := 0; x < einkSwatchWidth; x++ {
+			var c palette.RGB
+			switch {
+			case gradientErr == nil && strip == 0:
+				c = mixRGB(from, to, float64(x)/float64(einkSwatchWidth-1))
+			case gradientErr == nil:
+				c = rows[strip-1]
+			case strip < len(rows):
+				c = rows[strip]
+			default:
+				c = grays[len(grays)-1]
+			}
+			img.SetColorIndex(x, y, uint8(palette.DitherLevel(c, grays, x, y)))
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// mixRGB blends two colors, t=0 being a and t=1 being b
+func mixRGB(a, b palette.RGB, t float64) palette.RGB {
+	return palette.RGB{R: a.R + (b.R-a.R)*t, G: a.G + (b.G-a.G)*t, B: a.B + (b.B-a.B)*t}
+}
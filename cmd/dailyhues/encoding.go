@@ -0,0 +1,285 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mgabor3141/dailyhues/internal/format"
+)
+
+// responseEncoding describes how a successful response body is serialized
+type responseEncoding struct {
+	contentType string
+	marshal     func(interface{}) ([]byte, error)
+	themeOnly   bool   // Only applicable to single-palette (ColorTheme) responses
+	cacheable   bool   // Output depends only on the palette's colors, so renders can be reused by image hash
+	name        string // Format name, filled in by selectEncoding
+}
+
+// responseEncodings are the formats selectable with ?format=
+var responseEncodings = map[string]responseEncoding{
+	"json":     {contentType: "application/json", marshal: marshalJSON},
+	"yaml":     {contentType: "application/yaml", marshal: format.MarshalYAML},
+	"toml":     {contentType: "application/toml", marshal: format.MarshalTOML},
+	"msgpack":  {contentType: "application/msgpack", marshal: format.MarshalMsgPack},
+	"cbor":     {contentType: "application/cbor", marshal: format.MarshalCBOR},
+	"txt":      {contentType: "text/plain; charset=utf-8", marshal: renderTheme(renderThemeText), themeOnly: true, cacheable: true},
+	"env":      {contentType: "text/plain; charset=utf-8", marshal: renderTheme(renderThemeEnv), themeOnly: true}, // Includes the update countdown
+	"eink":     {contentType: "application/json", marshal: marshalEink(defaultEinkLevels, marshalJSON), themeOnly: true},
+	"wled":     {contentType: "application/json", marshal: marshalWLED(defaultWLEDLayout, marshalJSON), themeOnly: true},
+	"obs":      {contentType: "application/json", marshal: marshalOBS(marshalJSON), themeOnly: true},
+	"starship": {contentType: "application/toml", marshal: marshalStarship, themeOnly: true},
+	"ohmyposh": {contentType: "application/json", marshal: marshalOhMyPosh, themeOnly: true},
+	"nvim":     {contentType: "text/x-lua; charset=utf-8", marshal: marshalNvim, themeOnly: true},
+	"rofi":     {contentType: "text/plain; charset=utf-8", marshal: marshalRofi, themeOnly: true},
+
+	// Settings for document viewers and browsers
+	"zathura":     {contentType: "text/plain; charset=utf-8", marshal: marshalZathura, themeOnly: true},
+	"qutebrowser": {contentType: "text/x-python; charset=utf-8", marshal: marshalQutebrowser, themeOnly: true},
+}
+
+// acceptEncodings maps Accept header media types to formats
+var acceptEncodings = []struct {
+	mediaType string
+	format    string
+}{
+	{"application/json", "json"},
+	{"application/msgpack", "msgpack"},
+	{"application/x-msgpack", "msgpack"},
+	{"application/cbor", "cbor"},
+	{"application/yaml", "yaml"},
+	{"application/toml", "toml"},
+}
+
+// jsonpCallbackPattern matches a JavaScript identifier or dotted path such as "app.onPalette"
+var jsonpCallbackPattern = regexp.MustCompile(`^[A-Za-z_$][\w$]*(\.[A-Za-z_$][\w$]*)*$`)
+
+// negotiateEncoding picks the response encoding from the format parameter, falling back to the Accept header
+// JSON responses can be indented with ?pretty=true or wrapped with ?callback= when JSONP is enabled
+func negotiateEncoding(r *http.Request) (responseEncoding, error) {
+	encoding, err := selectEncoding(r)
+	if err != nil {
+		return responseEncoding{}, err
+	}
+
+	query := r.URL.Query()
+	isJSON := encoding.contentType == "application/json"
+
+	jsonMarshal := marshalJSON
+	if query.Get("pretty") == "true" && isJSON {
+		jsonMarshal = marshalIndentedJSON
+	}
+	if encoding.name == "eink" {
+		levels, err := validateEinkLevels(query.Get("levels"))
+		if err != nil {
+			return responseEncoding{}, err
+		}
+		encoding.marshal = marshalEink(levels, jsonMarshal)
+	} else if encoding.name == "wled" {
+		layout, err := validateWLEDLayout(query.Get("leds"), query.Get("segments"))
+		if err != nil {
+			return responseEncoding{}, err
+		}
+		encoding.marshal = marshalWLED(layout, jsonMarshal)
+	} else if encoding.name == "obs" {
+		encoding.marshal = marshalOBS(jsonMarshal)
+	} else if encoding.name == "json" {
+		encoding.marshal = jsonMarshal
+	}
+
+	if callback := query.Get("callback"); callback != "" {
+		if os.Getenv("ENABLE_JSONP") != "true" {
+			return responseEncoding{}, fmt.Errorf("callback is not enabled on this server")
+		}
+		if !isJSON {
+			return responseEncoding{}, fmt.Errorf("callback is only supported for JSON responses")
+		}
+		if !jsonpCallbackPattern.MatchString(callback) {
+			return responseEncoding{}, fmt.Errorf("invalid callback. Must be a JavaScript identifier")
+		}
+		encoding.contentType = "application/javascript"
+		encoding.marshal = wrapJSONP(callback, encoding.marshal)
+	}
+
+	return encoding, nil
+}
+
+// selectEncoding picks the base encoding from the format parameter or the Accept header
+func selectEncoding(r *http.Request) (responseEncoding, error) {
+	if name := r.URL.Query().Get("format"); name != "" {
+		encoding, ok := responseEncodings[name]
+		if !ok {
+			return responseEncoding{}, fmt.Errorf("invalid format. Supported formats: %s", strings.Join(supportedFormats(), ", "))
+		}
+		encoding.name = name
+		return encoding, nil
+	}
+
+	name := acceptedFormat(r.Header.Get("Accept"))
+	encoding := responseEncodings[name]
+	encoding.name = name
+	return encoding, nil
+}
+
+// acceptedFormat picks the format of the Accept header's highest quality media range, earliest first on a tie
+// Ranges with q=0 are refused, wildcards stand for JSON, and JSON is used when nothing else is acceptable
+func acceptedFormat(accept string) string {
+	best, bestQuality := "json", 0.0
+	for _, mediaRange := range strings.Split(accept, ",") {
+		params := strings.Split(mediaRange, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+
+		quality := 1.0
+		for _, param := range params[1:] {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.ToLower(strings.TrimSpace(key)) != "q" {
+				continue
+			}
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil || parsed < 0 || parsed > 1 {
+				parsed = 0
+			}
+			quality = parsed
+		}
+		if quality <= bestQuality {
+			continue
+		}
+
+		name := ""
+		if mediaType == "*/*" || mediaType == "application/*" {
+			name = "json"
+		}
+		for _, candidate := range acceptEncodings {
+			if mediaType == candidate.mediaType {
+				name = candidate.format
+				break
+			}
+		}
+		if name != "" {
+			best, bestQuality = name, quality
+		}
+	}
+	return best
+}
+
+// supportedFormats lists the accepted values for ?format=
+func supportedFormats() []string {
+	names := make([]string, 0, len(responseEncodings))
+	for name := range responseEncodings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// respondEncoded sends data serialized with the negotiated encoding
+func respondEncoded(w http.ResponseWriter, statusCode int, encoding responseEncoding, data interface{}) {
+	body, err := encoding.marshal(data)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to encode response: %v", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", encoding.contentType)
+	w.Header().Add("Vary", "Accept")
+	w.WriteHeader(statusCode)
+	w.Write(body)
+}
+
+// respondTheme sends a single palette, reusing an earlier render of the same palette for cacheable formats
+// options identifies anything besides the format that changes the output, such as the profile
+func (app *App) respondTheme(w http.ResponseWriter, encoding responseEncoding, theme ColorTheme, options string) {
+	if !encoding.cacheable || theme.imageHash == "" {
+		respondEncoded(w, http.StatusOK, encoding, theme)
+		return
+	}
+
+	key := renderKey{Subject: themeRenderSubject(theme), Format: encoding.name, Options: options}
+	body, err := app.renderCache.render(key, func() ([]byte, error) {
+		return encoding.marshal(theme)
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to encode response: %v", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", encoding.contentType)
+	w.Header().Add("Vary", "Accept")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// renderTheme adapts a ColorTheme renderer to the generic marshal signature
+func renderTheme(render func(ColorTheme) []byte) func(interface{}) ([]byte, error) {
+	return func(data interface{}) ([]byte, error) {
+		theme, ok := data.(ColorTheme)
+		if !ok {
+			return nil, fmt.Errorf("format is only supported for single palette responses")
+		}
+		return render(theme), nil
+	}
+}
+
+// renderThemeText renders the palette as "name value" lines
+func renderThemeText(theme ColorTheme) []byte {
+	return format.PaletteText(theme.Colors)
+}
+
+// renderThemeEnv renders the palette and gradient strings as shell variable assignments
+func renderThemeEnv(theme ColorTheme) []byte {
+	values := make(map[string]interface{}, len(theme.Colors)+6)
+	for name, value := range theme.Colors {
+		values[name] = value
+	}
+	if theme.CSSGradient != "" {
+		values["css_gradient"] = theme.CSSGradient
+		values["hyprland_gradient"] = theme.HyprlandGradient
+	}
+	if theme.InactiveBorder != "" {
+		values["inactive_border"] = theme.InactiveBorder
+	}
+	values["title"] = theme.Title
+	values["startdate"] = theme.StartDate
+	if theme.NextUpdateAt != "" {
+		values["next_update_at"] = theme.NextUpdateAt
+		values["seconds_until_update"] = theme.SecondsUntilUpdate
+	}
+
+	return format.PaletteEnv(values)
+}
+
+// marshalIndentedJSON is marshalJSON with two-space indentation for ?pretty=true
+func marshalIndentedJSON(data interface{}) ([]byte, error) {
+	body, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(body, '\n'), nil
+}
+
+// wrapJSONP wraps a JSON marshaller's output in a call to callback
+// The leading comment guards against the body being sniffed as something other than script
+func wrapJSONP(callback string, marshal func(interface{}) ([]byte, error)) func(interface{}) ([]byte, error) {
+	return func(data interface{}) ([]byte, error) {
+		body, err := marshal(data)
+		if err != nil {
+			return nil, err
+		}
+		return []byte("/**/" + callback + "(" + strings.TrimSuffix(string(body), "\n") + ");\n"), nil
+	}
+}
+
+// marshalJSON matches respondWithJSON's output, including the trailing newline
+func marshalJSON(data interface{}) ([]byte, error) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return append(body, '\n'), nil
+}
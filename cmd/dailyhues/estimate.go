@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/mgabor3141/dailyhues/internal/ai"
+)
+
+const (
+	// defaultEstimateWidth and defaultEstimateHeight are the size of the Bing image analyzed by default
+	defaultEstimateWidth  = 1920
+	defaultEstimateHeight = 1080
+	// maxEstimateDimension rejects sizes no wallpaper has
+	maxEstimateDimension = 16384
+	// defaultCompletionTokens is assumed for models without recorded analyses; reasoning makes up most of it
+	defaultCompletionTokens = 1000
+)
+
+// EstimateResponse is the expected token count and cost of one analysis, without running it
+type EstimateResponse struct {
+	Model            string           `json:"model"`
+	SourceWidth      int              `json:"source_width"`
+	SourceHeight     int              `json:"source_height"`
+	Tokens           ai.TokenEstimate `json:"tokens"`
+	PromptTokens     int              `json:"prompt_tokens"`
+	CompletionTokens int              `json:"completion_tokens"`
+	CompletionSource string           `json:"completion_source"` // "history" if averaged from cached analyses, "default" otherwise
+	Pricing          *ai.Pricing      `json:"pricing,omitempty"`
+	Cost             *float64         `json:"cost,omitempty"`          // USD; missing when pricing is unavailable
+	PricingError     string           `json:"pricing_error,omitempty"` // Why pricing is unavailable
+	History          *EstimateHistory `json:"history,omitempty"`       // Actual usage of the model's cached analyses
+}
+
+// EstimateHistory averages the recorded usage of a model's cached analyses, for comparison with the estimate
+type EstimateHistory struct {
+	Samples              int     `json:"samples"`
+	MeanPromptTokens     int     `json:"mean_prompt_tokens"`
+	MeanCompletionTokens int     `json:"mean_completion_tokens"`
+	MeanCost             float64 `json:"mean_cost"`
+}
+
+// handleEstimate predicts the tokens and cost of analyzing an image of ?width= by ?height= on ?model=
+// Prompt tokens are estimated from the resize settings, completion tokens from past analyses of the model
+// and the price from OpenRouter's model list. Nothing is downloaded or analyzed
+func (app *App) handleEstimate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	query := r.URL.Query()
+
+	model := query.Get("model")
+	if model == "" {
+		model = ai.Model()
+	}
+
+	width, err := estimateDimension(query.Get("width"), "width", defaultEstimateWidth)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	height, err := estimateDimension(query.Get("height"), "height", defaultEstimateHeight)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var locale string
+	if param := query.Get("locale"); param != "" {
+		if locale, err = validateLocale(param); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	encoding, err := negotiateEncoding(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if encoding.themeOnly {
+		respondWithError(w, http.StatusBadRequest, "format is only supported for single palette responses")
+		return
+	}
+
+	tokens := ai.EstimateTokens(width, height, loadPromptAddendum(sourceBing, locale))
+	estimate := EstimateResponse{
+		Model:            model,
+		SourceWidth:      width,
+		SourceHeight:     height,
+		Tokens:           tokens,
+		PromptTokens:     tokens.PromptTokens(),
+		CompletionTokens: defaultCompletionTokens,
+		CompletionSource: "default",
+	}
+	if history := app.usageHistory(model); history != nil {
+		estimate.History = history
+		estimate.CompletionTokens = history.MeanCompletionTokens
+		estimate.CompletionSource = "history"
+	}
+
+	pricing, err := app.aiAnalyzer.ModelPricing(r.Context(), model)
+	if err != nil {
+		estimate.PricingError = err.Error()
+	} else {
+		cost := pricing.Cost(estimate.PromptTokens, estimate.CompletionTokens)
+		estimate.Pricing = &pricing
+		estimate.Cost = &cost
+	}
+
+	respondEncoded(w, http.StatusOK, encoding, estimate)
+}
+
+// estimateDimension parses a width or height parameter, or returns fallback if it is empty
+func estimateDimension(value, name string, fallback int) (int, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 1 || parsed > maxEstimateDimension {
+		return 0, fmt.Errorf("invalid %s parameter. Must be between 1 and %d", name, maxEstimateDimension)
+	}
+	return parsed, nil
+}
+
+// usageHistory averages the usage recorded for a model's cached analyses, or returns nil if there is none
+// Pinned entries are included, since their usage is that of the AI analysis they replaced
+func (app *App) usageHistory(model string) *EstimateHistory {
+	var samples, promptTokens, completionTokens int
+	var cost float64
+	for _, entry := range app.analysisCache.Entries() {
+		if entry.Model != model || entry.CompletionTokens == 0 {
+			continue
+		}
+		samples++
+		promptTokens += entry.PromptTokens
+		completionTokens += entry.CompletionTokens
+		cost += entry.Cost
+	}
+	if samples == 0 {
+		return nil
+	}
+
+	return &EstimateHistory{
+		Samples:              samples,
+		MeanPromptTokens:     promptTokens / samples,
+		MeanCompletionTokens: completionTokens / samples,
+		MeanCost:             math.Round(cost/float64(samples)*1e6) / 1e6,
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// eventsCheckInterval is how often a stream checks for a new palette, sending a keep-alive otherwise
+	eventsCheckInterval = 30 * time.Second
+	// eventsWriteTimeout bounds each write, extended per event since the stream itself never ends
+	eventsWriteTimeout = 30 * time.Second
+	// eventsRetry is the reconnect delay suggested to EventSource clients
+	eventsRetry = 10 * time.Second
+)
+
+// handleEvents streams a locale's palettes as server-sent events as they change
+// Event IDs are the wallpapers' fullstartdate, so they increase with every change; a reconnecting
+// client's Last-Event-ID is answered with the cached palettes it missed before live updates resume.
+// Live changes are also sent as palette.changed events with the previous and new palette
+func (app *App) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	locale, err := validateLocale(r.URL.Query().Get("locale"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	angles, err := validateAngles(r.URL.Query().Get("angles"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	lastEventID := strings.TrimSpace(r.Header.Get("Last-Event-ID"))
+
+	changes, unsubscribe := app.changes.subscribe(locale)
+	defer unsubscribe()
+
+	controller := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	// send writes one chunk of the stream and flushes it, extending the write deadline first
+	send := func(chunk string) bool {
+		controller.SetWriteDeadline(time.Now().Add(eventsWriteTimeout))
+		if _, err := fmt.Fprint(w, chunk); err != nil {
+			return false
+		}
+		return controller.Flush() == nil
+	}
+
+	if !send(fmt.Sprintf("retry: %d\n\n", eventsRetry.Milliseconds())) {
+		return
+	}
+
+	// sendTheme sends a palette event unless the client already has it
+	sendTheme := func(theme ColorTheme) bool {
+		if theme.FullStartDate <= lastEventID {
+			return true
+		}
+		data, err := json.Marshal(withAllowedAngles(theme, angles))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to encode palette event", "error", err)
+			return true
+		}
+		lastEventID = theme.FullStartDate
+		return send(fmt.Sprintf("id: %s\nevent: palette\ndata: %s\n\n", theme.FullStartDate, data))
+	}
+
+	if lastEventID != "" {
+		for _, theme := range app.cachedThemesSince(locale, lastEventID) {
+			if !sendTheme(theme) {
+				return
+			}
+		}
+	}
+
+	// checkForChange sends today's palette if it is new, or a keep-alive comment if asked to
+	checkForChange := func(keepAlive bool) bool {
+		previous := lastEventID
+		if theme, err := app.getColorTheme(locale, 0); err != nil {
+			slog.InfoContext(r.Context(), "Failed to check for a new palette", "locale", locale, "error", err)
+		} else if !sendTheme(theme) {
+			return false
+		}
+
+		if keepAlive && lastEventID == previous {
+			return send(": keep-alive\n\n")
+		}
+		return true
+	}
+
+	if !checkForChange(false) {
+		return
+	}
+
+	ticker := time.NewTicker(eventsCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			if !checkForChange(true) {
+				return
+			}
+		case change := <-changes:
+			change.Previous = withAllowedAngles(change.Previous, angles)
+			change.Current = withAllowedAngles(change.Current, angles)
+			data, err := json.Marshal(change)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Failed to encode palette change event", "error", err)
+				continue
+			}
+			if !send(fmt.Sprintf("event: %s\ndata: %s\n\n", paletteChangedEvent, data)) {
+				return
+			}
+		}
+	}
+}
+
+// cachedThemesSince returns the locale's cached palettes whose fullstartdate is after lastEventID, oldest first
+func (app *App) cachedThemesSince(locale, lastEventID string) []ColorTheme {
+	var themes []ColorTheme
+	for _, reqEntry := range app.requestCache.Entries() {
+		if reqEntry.Locale != locale || reqEntry.FullStartDate <= lastEventID {
+			continue
+		}
+		if theme, ok := app.cachedThemeForDate(locale, reqEntry.StartDate); ok {
+			themes = append(themes, theme)
+		}
+	}
+	return themes
+}
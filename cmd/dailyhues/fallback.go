@@ -0,0 +1,51 @@
+package main
+
+import (
+	"github.com/mgabor3141/dailyhues/internal/bing"
+	"github.com/mgabor3141/dailyhues/internal/cache"
+	"github.com/mgabor3141/dailyhues/internal/palette"
+)
+
+// Fallback sources reported in ColorTheme.Fallback
+const (
+	fallbackCached = "cached" // An analysis of the same image made with another prompt addendum
+	fallbackLocal  = "local"  // Colors picked from the image without the AI
+)
+
+// fallbackAnalysis returns an analysis to serve for an image while OpenRouter is unavailable, and its fallback source
+// The pipeline caches neither, so the image is analyzed as usual once OpenRouter recovers
+func (app *App) fallbackAnalysis(imageData []byte, imageHash string, info *bing.WallpaperInfo) (cache.AnalysisEntry, string, error) {
+	if entry := app.sameImageAnalysis(imageHash); entry != nil {
+		return *entry, fallbackCached, nil
+	}
+
+	histogram, err := imageHistogram(imageData)
+	if err != nil {
+		return cache.AnalysisEntry{}, "", err
+	}
+	gradient := palette.FallbackGradient(histogram)
+	entry := cache.AnalysisEntry{
+		ImageHash: imageHash,
+		Colors: loadNormalizePolicy().Apply(map[string]interface{}{
+			"gradient_from":  gradient.From,
+			"gradient_to":    gradient.To,
+			"gradient_angle": gradient.Angle,
+		}),
+		Source: analysisSource(info),
+	}
+	if regions, err := imageRegions(imageData); err == nil {
+		entry.Regions = regions
+	}
+	return entry, fallbackLocal, nil
+}
+
+// sameImageAnalysis returns a cached analysis of the image behind an analysis key, whatever its prompt addendum
+func (app *App) sameImageAnalysis(imageHash string) *cache.AnalysisEntry {
+	imageOnly := cache.ImageHashOf(imageHash)
+	for _, entry := range app.analysisCache.Entries() {
+		if cache.ImageHashOf(entry.ImageHash) == imageOnly {
+			return &entry
+		}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/mgabor3141/dailyhues/internal/ai"
+	"github.com/mgabor3141/dailyhues/internal/cache"
+	"github.com/mgabor3141/dailyhues/internal/pipeline"
+)
+
+// fullPaletteAnalysis returns a day's analysis with its colors replaced by the complete semantic palette, for the pipeline
+// The full palette is analyzed on the first request for an image, and kept in the image's analysis entry
+func (app *App) fullPaletteAnalysis(base pipeline.Palette) (cache.AnalysisEntry, error) {
+	if base.Fallback != "" {
+		return cache.AnalysisEntry{}, fmt.Errorf("Full palette is unavailable while AI analysis is paused")
+	}
+
+	palette, err := app.fullPalette(&base.Request)
+	if err != nil {
+		return cache.AnalysisEntry{}, err
+	}
+
+	analysisEntry := base.Analysis
+	analysisEntry.Colors = palette.Colors
+	analysisEntry.Model = palette.Model
+	return analysisEntry, nil
+}
+
+// fullPalette returns the full palette of a request entry's image, analyzing it if it is not cached yet
+func (app *App) fullPalette(reqEntry *cache.RequestEntry) (*cache.PaletteEntry, error) {
+	imageHash := reqEntry.ImageHash
+	if entry := app.analysisCache.Get(imageHash); entry != nil && entry.Palettes[cache.PaletteKindFull] != nil {
+		return entry.Palettes[cache.PaletteKindFull], nil
+	}
+
+	// One analysis per image, as for the gradient
+	imageMutex := app.analysisCache.GetMutex(imageHash)
+	imageMutex.Lock()
+	defer imageMutex.Unlock()
+	defer app.analysisCache.ReleaseMutex(imageHash)
+
+	entry := app.analysisCache.Get(imageHash)
+	if entry == nil {
+		return nil, fmt.Errorf("Failed to resolve analysis for full palette")
+	}
+	if palette := entry.Palettes[cache.PaletteKindFull]; palette != nil {
+		return palette, nil
+	}
+
+	info, imageData, err := app.downloadCachedWallpaper(reqEntry)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to download wallpaper: %w", err)
+	}
+	if hash := cache.HashImage(imageData); hash != cache.ImageHashOf(imageHash) {
+		return nil, fmt.Errorf("Failed to download wallpaper: image changed since it was analyzed (now %s)", hash)
+	}
+
+	slog.Info("Starting full palette analysis for image hash", "hash", imageHash)
+	imageData, _ = app.imageToAnalyze(context.Background(), info, imageData)
+	full, usage, err := app.aiAnalyzer.AnalyzeFullPalette(imageData, imageHash, info.Title)
+	usageKey := imageHash + "/" + cache.PaletteKindFull
+	if err != nil {
+		app.recordFailedUsage(usageKey, usage)
+		return nil, fmt.Errorf("Failed to analyze full palette: %w", err)
+	}
+	usage = app.failedUsage.take(usageKey, usage)
+
+	palette := cache.PaletteEntry{
+		Colors:           fullPaletteColors(full),
+		Model:            usage.Model,
+		PromptVersion:    ai.FullPalettePromptVersion,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		Cost:             usage.Cost,
+		AnalyzedAt:       time.Now().UTC(),
+	}
+	if err := app.analysisCache.SetEntry(entry.WithPalette(cache.PaletteKindFull, palette)); err != nil {
+		slog.Info("Failed to cache full palette", "error", err)
+	}
+	return &palette, nil
+}
+
+// fullPaletteColors converts a full palette to response colors
+// Swatches are stored as a generic list, the type they have once the cache is read back from disk
+func fullPaletteColors(full ai.FullPalette) map[string]interface{} {
+	swatches := make([]interface{}, len(full.Swatches))
+	for i, swatch := range full.Swatches {
+		swatches[i] = swatch
+	}
+	return map[string]interface{}{
+		"background": full.Background,
+		"foreground": full.Foreground,
+		"accent":     full.Accent,
+		"muted":      full.Muted,
+		"error":      full.Error,
+		"success":    full.Success,
+		"swatches":   swatches,
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mgabor3141/dailyhues/internal/palette"
+)
+
+// validateGamut parses the ?gamut= parameter, a comma separated list of wide-gamut color spaces
+func validateGamut(param string) ([]string, error) {
+	var spaces []string
+	for _, space := range splitList(param) {
+		if !slices.Contains(palette.WideGamutSpaces, space) {
+			return nil, fmt.Errorf("invalid gamut %q. Supported gamuts: %s", space, strings.Join(palette.WideGamutSpaces, ", "))
+		}
+		if !slices.Contains(spaces, space) {
+			spaces = append(spaces, space)
+		}
+	}
+	return spaces, nil
+}
+
+// withWideGamut adds the theme's colors and CSS gradient in each requested color space,
+// as CSS color() values for clients on wide-gamut displays
+func withWideGamut(theme ColorTheme, spaces []string) ColorTheme {
+	if len(spaces) == 0 {
+		return theme
+	}
+
+	colors := make(map[string]string)
+	collectColors(colors, "", theme.Colors)
+	if _, ok := colors["inactive_border"]; !ok && theme.InactiveBorder != "" {
+		colors["inactive_border"] = theme.InactiveBorder
+	}
+	gradient, gradientErr := palette.GradientFromColors(theme.Colors)
+
+	theme.WideGamut = make(map[string]map[string]string, len(spaces))
+	for _, space := range spaces {
+		converted := make(map[string]string, len(colors)+1)
+		for name, hex := range colors {
+			// Colors with an alpha channel or other notations are left out
+			if css, err := palette.CSSColor(hex, space); err == nil {
+				converted[name] = css
+			}
+		}
+		if gradientErr == nil {
+			if css, err := gradient.CSSIn(space); err == nil {
+				converted["css_gradient"] = css
+			}
+		}
+		theme.WideGamut[space] = converted
+	}
+	return theme
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mgabor3141/dailyhues/internal/palette"
+)
+
+// historyCSVHeader lists the columns of /api/history.csv
+var historyCSVHeader = []string{"date", "locale", "title", "gradient_from", "gradient_to", "angle", "model", "tokens", "cost"}
+
+// HistoryRecord is one archived palette for a day and locale
+// Locales sharing an image each get a record, repeating the image's model and cost
+type HistoryRecord struct {
+	Date         string  `json:"date"`
+	Locale       string  `json:"locale"`
+	Title        string  `json:"title"`
+	GradientFrom string  `json:"gradient_from"`
+	GradientTo   string  `json:"gradient_to"`
+	Angle        float64 `json:"gradient_angle"`
+	Hue          float64 `json:"hue"`
+	Lightness    float64 `json:"lightness"`
+	Chroma       float64 `json:"chroma"`
+	Model        string  `json:"model,omitempty"` // Empty for analyses made before usage was recorded
+	Tokens       int     `json:"tokens,omitempty"`
+	Cost         float64 `json:"cost,omitempty"`
+
+	hasColor bool // false if the analysis had no usable gradient
+}
+
+// HistoryResponse is the body of /api/history
+type HistoryResponse struct {
+	Count   int             `json:"count"`
+	Records []HistoryRecord `json:"records"`
+}
+
+// historyQuery filters and orders history records
+type historyQuery struct {
+	locale                     string
+	after, before              string // YYYY-MM-DD, inclusive
+	hueMin, hueMax             float64
+	hasHue                     bool
+	minLightness, maxLightness float64
+	sort                       string
+	limit                      int
+}
+
+// historySorts maps the sort parameter to a less function; a leading "-" reverses it
+var historySorts = map[string]func(a, b HistoryRecord) bool{
+	"date":      func(a, b HistoryRecord) bool { return a.Date < b.Date },
+	"hue":       func(a, b HistoryRecord) bool { return a.Hue < b.Hue },
+	"lightness": func(a, b HistoryRecord) bool { return a.Lightness < b.Lightness },
+	"chroma":    func(a, b HistoryRecord) bool { return a.Chroma < b.Chroma },
+}
+
+// handleHistory returns the filtered palette history in any of the response encodings
+func (app *App) handleHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	query, err := parseHistoryQuery(r.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	encoding, err := negotiateEncoding(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if encoding.themeOnly {
+		respondWithError(w, http.StatusBadRequest, "format is only supported for single palette responses")
+		return
+	}
+
+	records := app.queryHistory(query)
+	respondEncoded(w, http.StatusOK, encoding, HistoryResponse{Count: len(records), Records: records})
+}
+
+// handleHistoryCSV exports the filtered palette history as CSV, oldest first by default
+func (app *App) handleHistoryCSV(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	query, err := parseHistoryQuery(r.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="dailyhues-history.csv"`)
+	w.WriteHeader(http.StatusOK)
+
+	writer := csv.NewWriter(w)
+	writer.Write(historyCSVHeader)
+
+	for _, record := range app.queryHistory(query) {
+		var angle string
+		if record.hasColor {
+			angle = strconv.FormatFloat(record.Angle, 'f', -1, 64)
+		}
+
+		// Usage was not recorded for older analyses, so leave those cells empty rather than zero
+		var tokens, cost string
+		if record.Model != "" {
+			tokens = strconv.Itoa(record.Tokens)
+			cost = strconv.FormatFloat(record.Cost, 'f', -1, 64)
+		}
+
+		writer.Write([]string{record.Date, record.Locale, record.Title, record.GradientFrom, record.GradientTo, angle, record.Model, tokens, cost})
+	}
+
+	writer.Flush()
+}
+
+// parseHistoryQuery validates the history filter and sort parameters
+func parseHistoryQuery(params url.Values) (historyQuery, error) {
+	query := historyQuery{maxLightness: 1, sort: "date"}
+
+	if locale := params.Get("locale"); locale != "" {
+		var err error
+		if query.locale, err = validateLocale(locale); err != nil {
+			return historyQuery{}, err
+		}
+	}
+
+	for _, bound := range []struct {
+		name   string
+		target *string
+	}{{"after", &query.after}, {"before", &query.before}} {
+		if value := params.Get(bound.name); value != "" {
+			if _, err := time.Parse("2006-01-02", value); err != nil {
+				return historyQuery{}, fmt.Errorf("invalid %s parameter. Must be a date like 2025-01-31", bound.name)
+			}
+			*bound.target = value
+		}
+	}
+
+	if hue := params.Get("hue"); hue != "" {
+		lowText, highText, ok := strings.Cut(hue, "-")
+		low, errLow := strconv.ParseFloat(lowText, 64)
+		high, errHigh := strconv.ParseFloat(highText, 64)
+		if !ok || errLow != nil || errHigh != nil || low < 0 || low > 360 || high < 0 || high > 360 {
+			return historyQuery{}, fmt.Errorf("invalid hue parameter. Must be a range like 200-260 within 0-360")
+		}
+		query.hueMin, query.hueMax, query.hasHue = low, high, true
+	}
+
+	for _, bound := range []struct {
+		name   string
+		target *float64
+	}{{"minLightness", &query.minLightness}, {"maxLightness", &query.maxLightness}} {
+		if value := params.Get(bound.name); value != "" {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil || parsed < 0 || parsed > 1 {
+				return historyQuery{}, fmt.Errorf("invalid %s parameter. Must be between 0 and 1", bound.name)
+			}
+			*bound.target = parsed
+		}
+	}
+
+	if value := params.Get("sort"); value != "" {
+		if _, ok := historySorts[strings.TrimPrefix(value, "-")]; !ok {
+			return historyQuery{}, fmt.Errorf("invalid sort parameter. Must be one of date, hue, lightness, chroma, optionally prefixed with -")
+		}
+		query.sort = value
+	}
+
+	if value := params.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			return historyQuery{}, fmt.Errorf("invalid limit parameter. Must be a positive integer")
+		}
+		query.limit = limit
+	}
+
+	return query, nil
+}
+
+// queryHistory returns the archived palettes matching the query in the requested order
+func (app *App) queryHistory(query historyQuery) []HistoryRecord {
+	records := []HistoryRecord{}
+	for _, record := range app.historyRecords() {
+		if query.matches(record) {
+			records = append(records, record)
+		}
+	}
+
+	less := historySorts[strings.TrimPrefix(query.sort, "-")]
+	if strings.HasPrefix(query.sort, "-") {
+		sort.SliceStable(records, func(i, j int) bool { return less(records[j], records[i]) })
+	} else {
+		sort.SliceStable(records, func(i, j int) bool { return less(records[i], records[j]) })
+	}
+
+	if query.limit > 0 && len(records) > query.limit {
+		records = records[:query.limit]
+	}
+	return records
+}
+
+// matches reports whether a record passes every filter of the query
+func (q historyQuery) matches(record HistoryRecord) bool {
+	if q.locale != "" && record.Locale != q.locale {
+		return false
+	}
+	if (q.after != "" && record.Date < q.after) || (q.before != "" && record.Date > q.before) {
+		return false
+	}
+
+	// Color filters only apply to records with a usable palette
+	colorFiltered := q.hasHue || q.minLightness > 0 || q.maxLightness < 1
+	if !record.hasColor {
+		return !colorFiltered
+	}
+
+	if q.hasHue {
+		// A range like 330-30 wraps around red
+		if q.hueMin <= q.hueMax && (record.Hue < q.hueMin || record.Hue > q.hueMax) {
+			return false
+		}
+		if q.hueMin > q.hueMax && record.Hue < q.hueMin && record.Hue > q.hueMax {
+			return false
+		}
+	}
+
+	return record.Lightness >= q.minLightness && record.Lightness <= q.maxLightness
+}
+
+// historyRecords builds a record for every cached day and locale with an analysis, oldest first
+func (app *App) historyRecords() []HistoryRecord {
+	var records []HistoryRecord
+	for _, entry := range app.requestCache.Entries() {
+		analysis := app.analysisCache.Get(entry.ImageHash)
+		if analysis == nil {
+			continue
+		}
+
+		record := HistoryRecord{
+			Date:   entry.StartDate,
+			Locale: entry.Locale,
+			Title:  entry.Title,
+			Model:  analysis.Model,
+			Tokens: analysis.PromptTokens + analysis.CompletionTokens,
+			Cost:   analysis.Cost,
+		}
+		if parsed, err := time.Parse("20060102", entry.StartDate); err == nil {
+			record.Date = parsed.Format("2006-01-02")
+		}
+
+		if gradient, err := palette.GradientFromColors(analysis.Colors); err == nil {
+			record.GradientFrom, record.GradientTo, record.Angle = gradient.From, gradient.To, gradient.Angle
+		}
+		if color, ok := paletteColor(analysis.Colors); ok {
+			record.Hue = round(color.H, 1)
+			record.Lightness = round(color.L, 3)
+			record.Chroma = round(color.C, 3)
+			record.hasColor = true
+		}
+
+		records = append(records, record)
+	}
+	return records
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mgabor3141/dailyhues/internal/bing"
+)
+
+const (
+	// imageCheckTimeout bounds the HEAD requests made for one response
+	imageCheckTimeout = 5 * time.Second
+
+	// maxImageChecks bounds the check memo; a week of wallpapers for a few locales fits easily
+	maxImageChecks = 4096
+
+	// imageMissing marks a URL Bing has no image for in the check memo
+	imageMissing = -1
+)
+
+// imageCheckMemo remembers the size of the image behind each checked URL, or imageMissing
+// Bing never changes the image behind a URL, so results are kept until the memo is full; failed checks are not kept
+var imageCheckMemo = struct {
+	sync.Mutex
+	sizes map[string]int64
+}{sizes: make(map[string]int64)}
+
+// verifyImagesEnabled reports whether VERIFY_IMAGE_URLS is set, so only existing resolutions are returned
+func verifyImagesEnabled() bool {
+	return os.Getenv("VERIFY_IMAGE_URLS") == "true"
+}
+
+// withVerifiedImages drops the resolutions Bing does not have from a theme's images and fills in the size of the rest
+// Resolutions that could not be checked are kept without a size, so a slow or failing Bing does not hide them
+func (app *App) withVerifiedImages(ctx context.Context, theme ColorTheme) ColorTheme {
+	if len(theme.Images) == 0 {
+		return theme
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, imageCheckTimeout)
+	defer cancel()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	images := make(map[string]string, len(theme.Images))
+	sizes := make(map[string]int64, len(theme.Images))
+
+	for resolution, url := range theme.Images {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			size, err := app.imageSize(ctx, url)
+			if errors.Is(err, bing.ErrImageMissing) {
+				return
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			images[resolution] = url
+			if err != nil {
+				slog.Info("Failed to check image", "url", url, "error", err)
+			} else if size > 0 {
+				sizes[resolution] = size
+			}
+		}()
+	}
+	wg.Wait()
+
+	// The cached maps are shared, so the theme gets new ones
+	theme.Images = images
+	theme.Orientations = bing.Orientations(images)
+	if len(sizes) > 0 {
+		theme.ImageSizes = sizes
+	}
+	return theme
+}
+
+// imageSize returns the size of the image at url, or bing.ErrImageMissing, checking Bing only once per URL
+func (app *App) imageSize(ctx context.Context, url string) (int64, error) {
+	imageCheckMemo.Lock()
+	size, ok := imageCheckMemo.sizes[url]
+	imageCheckMemo.Unlock()
+	if ok {
+		if size == imageMissing {
+			return 0, bing.ErrImageMissing
+		}
+		return size, nil
+	}
+
+	size, err := app.bingClient.ImageSize(ctx, url)
+	switch {
+	case errors.Is(err, bing.ErrImageMissing):
+		size = imageMissing
+	case err != nil:
+		return 0, err
+	}
+
+	imageCheckMemo.Lock()
+	if len(imageCheckMemo.sizes) >= maxImageChecks {
+		clear(imageCheckMemo.sizes)
+	}
+	imageCheckMemo.sizes[url] = size
+	imageCheckMemo.Unlock()
+
+	return max(size, 0), err
+}
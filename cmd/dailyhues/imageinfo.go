@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"github.com/mgabor3141/dailyhues/internal/imageinfo"
+)
+
+const (
+	// imageHeaderBytes is how much of each image is fetched to read its metadata
+	// Enough for EXIF thumbnails and ICC profiles, a fraction of a UHD wallpaper
+	imageHeaderBytes = 256 << 10
+
+	// maxImageInfoEntries bounds the metadata memo; a week of wallpapers for a few locales fits easily
+	maxImageInfoEntries = 1024
+)
+
+// ImageInfoResponse lists technical metadata for each resolution of a day's wallpaper
+type ImageInfoResponse struct {
+	Locale    string                  `json:"locale"`
+	StartDate string                  `json:"startdate"`
+	Title     string                  `json:"title"`
+	Images    map[string]ImageDetails `json:"images"`
+}
+
+// ImageDetails is the metadata of a single resolution
+// If it could not be read, Error is set and only the URL is filled in
+type ImageDetails struct {
+	URL string `json:"url"`
+	imageinfo.Info
+	Error string `json:"error,omitempty"`
+}
+
+// imageInfoMemo remembers metadata by URL; Bing never changes the image behind a URL
+var imageInfoMemo = struct {
+	sync.Mutex
+	entries map[string]imageinfo.Info
+}{entries: make(map[string]imageinfo.Info)}
+
+// handleImageInfo returns dimensions, color profile, JPEG quality and file size for each resolution of a wallpaper
+func (app *App) handleImageInfo(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	query := r.URL.Query()
+
+	daysAgo, err := validateDaysAgo(query.Get("daysAgo"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	locale, err := validateLocale(query.Get("locale"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	encoding, err := negotiateEncoding(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if encoding.themeOnly {
+		respondWithError(w, http.StatusBadRequest, "format is only supported for palette responses")
+		return
+	}
+
+	response := ImageInfoResponse{Locale: locale}
+	var imageURLs map[string]string
+
+	// Only the wallpaper's URLs are needed, so no analysis is triggered
+	if reqEntry := app.requestCache.Get(locale, daysAgo); reqEntry != nil {
+		response.StartDate, response.Title, imageURLs = reqEntry.StartDate, reqEntry.Title, reqEntry.ImageURLs
+	} else {
+		info, err := app.bingClient.WithLocale(locale).GetWallpaperInfoByDaysAgo(r.Context(), daysAgo)
+		if err != nil {
+			slog.InfoContext(r.Context(), "Failed to fetch wallpaper metadata", "error", err)
+			respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to fetch wallpaper metadata: %v", err))
+			return
+		}
+		response.StartDate, response.Title, imageURLs = info.StartDate, info.Title, info.ImageURLs
+	}
+
+	response.Images = app.imageDetails(r.Context(), imageURLs)
+	respondEncoded(w, http.StatusOK, encoding, response)
+}
+
+// imageDetails reads the metadata of every resolution in parallel
+func (app *App) imageDetails(ctx context.Context, imageURLs map[string]string) map[string]ImageDetails {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	details := make(map[string]ImageDetails, len(imageURLs))
+
+	for size, url := range imageURLs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			detail := ImageDetails{URL: url}
+			if info, err := app.imageInfo(ctx, url); err != nil {
+				slog.Info("Failed to read image metadata", "url", url, "error", err)
+				detail.Error = err.Error()
+			} else {
+				detail.Info = info
+			}
+
+			mu.Lock()
+			details[size] = detail
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	return details
+}
+
+// imageInfo returns the metadata of the image at url, downloading only its headers
+func (app *App) imageInfo(ctx context.Context, url string) (imageinfo.Info, error) {
+	imageInfoMemo.Lock()
+	info, ok := imageInfoMemo.entries[url]
+	imageInfoMemo.Unlock()
+	if ok {
+		return info, nil
+	}
+
+	data, size, err := app.bingClient.DownloadHeaders(ctx, url, imageHeaderBytes)
+	if err != nil {
+		return imageinfo.Info{}, err
+	}
+
+	info, err = imageinfo.ParseJPEG(data, size)
+	if err != nil {
+		return imageinfo.Info{}, err
+	}
+
+	imageInfoMemo.Lock()
+	if len(imageInfoMemo.entries) >= maxImageInfoEntries {
+		clear(imageInfoMemo.entries)
+	}
+	imageInfoMemo.entries[url] = info
+	imageInfoMemo.Unlock()
+
+	return info, nil
+}
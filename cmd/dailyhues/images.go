@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+
+	"github.com/mgabor3141/dailyhues/internal/palette"
+)
+
+// imageRegions decodes an image and averages the regions where bars and widgets usually sit
+func imageRegions(data []byte) (map[string]string, error) {
+	img, err := decodeImage(data)
+	if err != nil {
+		return nil, err
+	}
+
+	regions := palette.RegionAverages(img)
+	return map[string]string{
+		"top":    regions.Top,
+		"bottom": regions.Bottom,
+		"center": regions.Center,
+	}, nil
+}
+
+// decodeImage decodes a downloaded wallpaper
+func decodeImage(data []byte) (image.Image, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	return img, nil
+}
+
+// imageResolution reads an image's dimensions as "WIDTHxHEIGHT" without decoding the pixels
+func imageResolution(data []byte) (string, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to read image size: %w", err)
+	}
+	return fmt.Sprintf("%dx%d", config.Width, config.Height), nil
+}
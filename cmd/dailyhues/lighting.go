@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mgabor3141/dailyhues/internal/notify"
+	"github.com/mgabor3141/dailyhues/internal/palette"
+)
+
+// hueTransitionTime fades lights to the new scene over 4 seconds, in Hue's tenths of a second
+const hueTransitionTime = 40
+
+// lightingColors converts a lighting scene to response colors, one {hex, xy, bri} object per light
+func lightingColors(lights []palette.Light) []interface{} {
+	scene := make([]interface{}, len(lights))
+	for i, light := range lights {
+		scene[i] = map[string]interface{}{
+			"hex": light.Hex,
+			"xy":  []interface{}{light.XY[0], light.XY[1]},
+			"bri": light.Brightness,
+		}
+	}
+	return scene
+}
+
+// setHueScene sets each light to the palette's lighting scene, taking the scene's lights in turn
+// All lights are attempted even if one fails
+func setHueScene(apiURL string, lightIDs []string, theme ColorTheme) error {
+	gradient, err := palette.GradientFromColors(theme.Colors)
+	if err != nil {
+		return fmt.Errorf("palette has no gradient for a lighting scene: %w", err)
+	}
+	scene, err := gradient.Lighting()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for i, id := range lightIDs {
+		light := scene[i%len(scene)]
+		state := notify.HueLightState{On: true, XY: light.XY, Brightness: light.Brightness, TransitionTime: hueTransitionTime}
+		if err := notify.SetHueLight(apiURL, id, state); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// routeLimits are the timeouts and response size cap applied to one route
+// A zero ReadTimeout means no read deadline, and a zero MaxResponseBytes unlimited
+type routeLimits struct {
+	ReadTimeout      time.Duration `json:"read_timeout"`
+	WriteTimeout     time.Duration `json:"write_timeout"`
+	MaxResponseBytes int64         `json:"max_response_bytes,omitempty"`
+}
+
+// defaultRouteLimits apply to routes without their own entry
+var defaultRouteLimits = routeLimits{ReadTimeout: 15 * time.Second, WriteTimeout: 30 * time.Second}
+
+// builtinRouteLimits give routes that analyze images synchronously more time, and cheap probes less
+var builtinRouteLimits = map[string]routeLimits{
+	"/api/colors":     {ReadTimeout: 15 * time.Second, WriteTimeout: 2 * time.Minute},
+	"/api/transition": {ReadTimeout: 15 * time.Second, WriteTimeout: 3 * time.Minute},
+	"/api/week":       {ReadTimeout: 15 * time.Second, WriteTimeout: 10 * time.Minute},
+	"/api/batch":      {ReadTimeout: 15 * time.Second, WriteTimeout: batchTaskTimeout + time.Minute},
+	"/api/image-info": {ReadTimeout: 15 * time.Second, WriteTimeout: time.Minute},
+	"/admin/warm":     {ReadTimeout: 15 * time.Second, WriteTimeout: warmRequestTimeout},
+	"/api/events":     {WriteTimeout: eventsWriteTimeout}, // A stream, so no read deadline; handleEvents extends the write deadline per event
+	"/health":         {ReadTimeout: 5 * time.Second, WriteTimeout: 5 * time.Second},
+}
+
+// Route limits in effect (overridden from env on startup and reload)
+var (
+	routeLimitsConfig   = builtinRouteLimits
+	routeLimitsFallback = defaultRouteLimits
+	routeLimitsConfigMu sync.RWMutex
+)
+
+// loadRouteLimits sets the per-route limits from ROUTE_READ_TIMEOUTS, ROUTE_WRITE_TIMEOUTS and
+// ROUTE_MAX_RESPONSE_BYTES, each a comma separated list of route=value ("*" sets the default)
+func loadRouteLimits() {
+	limits := make(map[string]routeLimits, len(builtinRouteLimits))
+	for route, l := range builtinRouteLimits {
+		limits[route] = l
+	}
+	fallback := defaultRouteLimits
+
+	// apply parses one variable's entries; limits left unset are filled in from the default below
+	apply := func(variable string, set func(*routeLimits, string) bool) {
+		for _, entry := range splitList(os.Getenv(variable)) {
+			route, value, ok := strings.Cut(entry, "=")
+			route = strings.TrimSpace(route)
+			if !ok || route == "" {
+				slog.Info("Ignoring invalid route limit", "variable", variable, "entry", entry)
+				continue
+			}
+
+			if route == "*" {
+				if !set(&fallback, strings.TrimSpace(value)) {
+					slog.Info("Ignoring invalid route limit", "variable", variable, "entry", entry)
+				}
+				continue
+			}
+
+			l := limits[route]
+			if !set(&l, strings.TrimSpace(value)) {
+				slog.Info("Ignoring invalid route limit", "variable", variable, "entry", entry)
+				continue
+			}
+			limits[route] = l
+		}
+	}
+
+	apply("ROUTE_READ_TIMEOUTS", func(l *routeLimits, value string) bool {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return false
+		}
+		l.ReadTimeout = d
+		return true
+	})
+	apply("ROUTE_WRITE_TIMEOUTS", func(l *routeLimits, value string) bool {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return false
+		}
+		l.WriteTimeout = d
+		return true
+	})
+	apply("ROUTE_MAX_RESPONSE_BYTES", func(l *routeLimits, value string) bool {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || n < 0 {
+			return false
+		}
+		l.MaxResponseBytes = n
+		return true
+	})
+
+	// Routes only configured for some limits take the rest from the default; built-in routes set all of theirs
+	for route, l := range limits {
+		if _, ok := builtinRouteLimits[route]; ok {
+			continue
+		}
+		if l.ReadTimeout == 0 {
+			l.ReadTimeout = fallback.ReadTimeout
+		}
+		if l.WriteTimeout == 0 {
+			l.WriteTimeout = fallback.WriteTimeout
+		}
+		limits[route] = l
+	}
+
+	routeLimitsConfigMu.Lock()
+	defer routeLimitsConfigMu.Unlock()
+	routeLimitsConfig = limits
+	routeLimitsFallback = fallback
+}
+
+// limitsForPath returns the limits of the route serving path
+// Routes ending in "/" (other than the landing page) also match the paths below them
+func limitsForPath(path string) routeLimits {
+	routeLimitsConfigMu.RLock()
+	defer routeLimitsConfigMu.RUnlock()
+
+	if l, ok := routeLimitsConfig[path]; ok {
+		return l
+	}
+
+	best, bestLen := routeLimitsFallback, 0
+	for route, l := range routeLimitsConfig {
+		if route != "/" && strings.HasSuffix(route, "/") && strings.HasPrefix(path, route) && len(route) > bestLen {
+			best, bestLen = l, len(route)
+		}
+	}
+	return best
+}
+
+// withRouteLimits applies each route's body read and write deadlines and response size cap
+// The server itself only bounds reading headers, so these deadlines are the effective timeouts.
+// The read deadline only covers the request body: once it is consumed, net/http keeps reading the
+// connection in the background to notice clients going away, and a deadline left in place would fail
+// that read and cancel the request's context while the handler is still within its write timeout
+func withRouteLimits(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limits := limitsForPath(r.URL.Path)
+
+		controller := http.NewResponseController(w)
+		now := time.Now()
+		if limits.ReadTimeout > 0 && r.Body != nil && r.Body != http.NoBody {
+			if err := controller.SetReadDeadline(now.Add(limits.ReadTimeout)); err != nil {
+				slog.Debug("Failed to set read deadline", "path", r.URL.Path, "error", err)
+			}
+			r.Body = &deadlineBody{ReadCloser: r.Body, controller: controller}
+		}
+		if err := controller.SetWriteDeadline(now.Add(limits.WriteTimeout)); err != nil {
+			slog.Debug("Failed to set write deadline", "path", r.URL.Path, "error", err)
+		}
+
+		if limits.MaxResponseBytes > 0 {
+			w = &limitedResponseWriter{ResponseWriter: w, path: r.URL.Path, remaining: limits.MaxResponseBytes}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// deadlineBody clears the connection's read deadline once the request body has been read to the end
+type deadlineBody struct {
+	io.ReadCloser
+	controller *http.ResponseController
+	cleared    bool
+}
+
+func (b *deadlineBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && !b.cleared {
+		b.cleared = true
+		if err := b.controller.SetReadDeadline(time.Time{}); err != nil {
+			slog.Debug("Failed to clear read deadline", "error", err)
+		}
+	}
+	return n, err
+}
+
+// limitedResponseWriter aborts the response once it grows past the route's limit,
+// so the client sees a failed transfer instead of a silently truncated body
+type limitedResponseWriter struct {
+	http.ResponseWriter
+	path      string
+	remaining int64
+}
+
+func (w *limitedResponseWriter) Write(p []byte) (int, error) {
+	if int64(len(p)) > w.remaining {
+		slog.Error("Response exceeded size limit, aborting", "path", w.path)
+		panic(http.ErrAbortHandler)
+	}
+	w.remaining -= int64(len(p))
+	return w.ResponseWriter.Write(p)
+}
+
+// Unwrap lets http.ResponseController reach the underlying connection
+func (w *limitedResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -1,40 +1,95 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"html/template"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/mgabor3141/dailyhues/internal/ai"
+	"github.com/mgabor3141/dailyhues/internal/audit"
+	"github.com/mgabor3141/dailyhues/internal/auth"
 	"github.com/mgabor3141/dailyhues/internal/bing"
 	"github.com/mgabor3141/dailyhues/internal/cache"
+	"github.com/mgabor3141/dailyhues/internal/config"
+	"github.com/mgabor3141/dailyhues/internal/logging"
+	"github.com/mgabor3141/dailyhues/internal/palette"
+	"github.com/mgabor3141/dailyhues/internal/pipeline"
+	"github.com/mgabor3141/dailyhues/internal/version"
 )
 
 const (
 	defaultCacheDir = "./cache_data"
-	defaultLocale   = "en-US"
+	fallbackLocale  = "en-US"
 	defaultPort     = "8080"
-	maxDaysBack     = 7
+	maxDaysBack     = pipeline.MaxBingDaysAgo
+
+	// maxArchivedDaysBack bounds daysAgo for days served from the caches after Bing has forgotten them
+	maxArchivedDaysBack = 3660
 )
 
-// Allowed locales for Bing wallpaper API (initialized in main from env or defaults)
-var allowedLocales []string
+// defaultAllowedLocales are the markets available from Bing
+var defaultAllowedLocales = bing.Markets
+
+// Allowed locales for Bing wallpaper API and the locale used when none is requested
+// (overridden from env on startup and reload)
+var (
+	allowedLocales   = defaultAllowedLocales
+	defaultLocale    = fallbackLocale
+	allowedLocalesMu sync.RWMutex
+)
 
 // ColorTheme represents the response with extracted colors from a wallpaper
 type ColorTheme struct {
-	StartDate     string                 `json:"startdate"`
-	FullStartDate string                 `json:"fullstartdate"`
-	EndDate       string                 `json:"enddate"`
-	Images        map[string]string      `json:"images"`
-	Colors        map[string]interface{} `json:"colors"`
-	Title         string                 `json:"title"`
-	Copyright     string                 `json:"copyright"`
-	CopyrightLink string                 `json:"copyright_link"`
-	CachedAt      string                 `json:"cached_at"`
+	StartDate        string                 `json:"startdate"`
+	FullStartDate    string                 `json:"fullstartdate"`
+	EndDate          string                 `json:"enddate"`
+	Images           map[string]string      `json:"images"`
+	Orientations     map[string]string      `json:"image_orientations,omitempty"` // "landscape" or "portrait" per resolution in images
+	ImageSizes       map[string]int64       `json:"image_sizes,omitempty"`        // Bytes per resolution in images, only with VERIFY_IMAGE_URLS=true
+	ArchivedImages   map[string]string      `json:"archived_images,omitempty"`    // Locally stored copies that outlive Bing's retention
+	Videos           map[string]string      `json:"videos,omitempty"`             // Video background URLs by format, on days Bing serves one
+	Colors           map[string]interface{} `json:"colors"`
+	CSSGradient      string                 `json:"css_gradient,omitempty"`
+	HyprlandGradient string                 `json:"hyprland_gradient,omitempty"`
+	InactiveBorder   string                 `json:"inactive_border,omitempty"` // Unfocused window border; derived from the gradient for older analyses
+	Regions          map[string]string      `json:"regions,omitempty"`         // Average colors of the wallpaper's top, bottom and center
+	Title            string                 `json:"title"`
+	Copyright        string                 `json:"copyright"`
+	CopyrightLink    string                 `json:"copyright_link"`
+	Attribution      *Attribution           `json:"attribution,omitempty"` // Copyright parsed into a photo credit
+	Pinned           bool                   `json:"pinned,omitempty"`      // Colors were set by an operator instead of the AI
+	Model            string                 `json:"model,omitempty"`       // OpenRouter model of the analysis; empty for analyses made before it was recorded
+	Fallback         string                 `json:"fallback,omitempty"`    // "cached" or "local" when served while OpenRouter is unavailable
+	Reused           bool                   `json:"reused,omitempty"`      // Colors are an earlier day's, kept by ?stability= because the day's own were too similar
+	ReusedFrom       string                 `json:"reused_from,omitempty"` // Start date of the day whose colors are reused
+	Analysis         *AnalysisMetadata      `json:"analysis,omitempty"`    // Only with ?verbose=true
+	CachedAt         string                 `json:"cached_at"`
+
+	// WideGamut holds the colors as CSS color() values by color space, only with ?gamut=
+	WideGamut map[string]map[string]string `json:"wide_gamut,omitempty"`
+
+	// NextUpdateAt is when Bing is expected to publish the next wallpaper, changing this palette
+	NextUpdateAt       string `json:"next_update_at,omitempty"`
+	SecondsUntilUpdate int64  `json:"seconds_until_update"`
+
+	imageHash string            // Identifies the analyzed image for ETags; not serialized
+	version   paletteVersion    // Identifies the analyzed or pinned colors for ETags; not serialized
+	analysis  *AnalysisMetadata // Copied to Analysis for verbose responses; not serialized
 }
 
 // ErrorResponse represents an API error
@@ -48,120 +103,146 @@ type App struct {
 	analysisCache *cache.AnalysisCache
 	bingClient    *bing.Client
 	aiAnalyzer    *ai.Analyzer
+	authenticator *auth.Authenticator
+	auditLog      *audit.Log
+	renderCache   *renderCache
+	archiving     sync.WaitGroup     // Background wallpaper archiving started by archiveImagesAsync
+	canaries      sync.WaitGroup     // Background candidate model analyses started by runAnalysis
+	pipeline      *pipeline.Pipeline // Resolves palettes; use palettes(), which sets it up
+	pipelineOnce  sync.Once          // Sets up pipeline on first use
+	changes       changeFeed         // Current palette per locale, and subscribers to its changes
+	profileCaches profileCaches      // Analyses made with the prompt profiles, by profile
+	failedUsage   failedUsage        // Billed usage of failed analyses, added to the next successful one
 }
 
 func main() {
 	if os.Getenv("LOG_FORMAT") == "json" {
-		logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+		logger := slog.New(logging.NewHandler(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: logLevel})))
 		slog.SetDefault(logger)
 	}
 
-	// Initialize allowed locales from environment or use defaults
-	localesEnv := os.Getenv("ALLOWED_LOCALES")
-	if localesEnv != "" {
-		allowedLocales = strings.Split(localesEnv, ",")
-		// Trim spaces from each locale
-		for i := range allowedLocales {
-			allowedLocales[i] = strings.TrimSpace(allowedLocales[i])
+	// Apply the optional config file on top of the environment
+	if configFile := os.Getenv("CONFIG_FILE"); configFile != "" {
+		if _, err := config.Apply(configFile); err != nil {
+			slog.Error("Failed to load config file", "file", configFile, "error", err)
+		} else {
+			slog.Info("Loaded config file", "file", configFile)
 		}
-		slog.Info("Using custom allowed locales from env", "locales", allowedLocales)
-	} else {
-		allowedLocales = []string{
-			"en-US", "en-GB", "en-CA", "en-AU", "en-IN",
-			"ja-JP", "zh-CN", "zh-TW", "de-DE", "fr-FR",
-			"es-ES", "it-IT", "pt-BR", "ru-RU", "ko-KR",
-		}
-		slog.Info("Using default allowed locales", "locales", allowedLocales)
 	}
 
-	// Get cache directory from environment or use default
-	cacheDataDir := os.Getenv("CACHE_DIR")
-	if cacheDataDir == "" {
-		cacheDataDir = defaultCacheDir
-	}
-	slog.Info("Using cache directory", "dir", cacheDataDir)
+	loadLogLevel()
 
-	// Get OpenRouter API key from environment
-	apiKey := os.Getenv("OPENROUTER_API_KEY")
-	if apiKey == "" {
-		slog.Error("OPENROUTER_API_KEY environment variable is required")
+	// Initialize allowed locales from environment or use defaults
+	loadAllowedLocales()
+	loadRouteLimits()
+
+	// Subcommands run instead of the server
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "warm":
+			os.Exit(runWarm(os.Args[2:]))
+		case "cache":
+			os.Exit(runCache(os.Args[2:]))
+		}
 	}
 
-	// Initialize caches
-	requestCache, err := cache.NewRequestCache(cacheDataDir)
-	if err != nil {
-		slog.Error("Failed to initialize request cache", "error", err)
-	}
+	app := newApp(cacheDir())
 
-	analysisCache, err := cache.NewAnalysisCache(cacheDataDir)
-	if err != nil {
-		slog.Error("Failed to initialize analysis cache", "error", err)
-	}
+	// One summary of everything in effect, so misconfiguration is obvious from the first log line
+	slog.Info("Startup configuration", "config", app.configSummary())
 
-	// Load all existing cache files into memory on startup
-	if err := requestCache.LoadAll(); err != nil {
-		slog.Error("Failed to load request cache", "error", err)
-	}
-	if err := analysisCache.LoadAll(); err != nil {
-		slog.Error("Failed to load analysis cache", "error", err)
+	// Give a freshly deployed instance some history instead of an empty archive
+	if locales, interval := loadBackfillConfig(); len(locales) > 0 {
+		if app.requestCache.Len() == 0 {
+			go app.backfill(locales, interval)
+		} else {
+			slog.Info("Skipping backfill, request cache is not empty")
+		}
 	}
 
-	// Initialize app
-	app := &App{
-		requestCache:  requestCache,
-		analysisCache: analysisCache,
-		bingClient:    bing.NewClient(defaultLocale),
-		aiAnalyzer:    ai.NewAnalyzer(apiKey),
-	}
+	// Keep archived palettes in step with prompt and model improvements (no-op unless ANALYSIS_MAX_AGE is set)
+	go app.watchStaleAnalyses()
+
+	// Store wallpapers cached before ARCHIVE_IMAGES was enabled (no-op unless it is set)
+	go app.archiveCachedImages()
+
+	// Send one daily summary of all digest locales (no-op unless a DIGEST_* target is set)
+	go app.watchDigest(cacheDir())
+
+	// Deliver palette changes to webhooks and MQTT (no-op unless a CHANGE_* target is set)
+	go app.watchPaletteChanges()
 
-	// Set up routes
-	http.HandleFunc("/", handleLandingPage)
-	http.HandleFunc("/api/colors", app.handleGetColors)
-	http.HandleFunc("/health", handleHealth)
+	// Reload configuration on SIGHUP without dropping the in-memory caches
+	go app.watchReloadSignal()
+
+	// Toggle verbose diagnostics on SIGUSR1 without losing in-flight analyses
+	go watchVerboseSignal()
 
 	// Start server
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = defaultPort
-	}
+	port := serverPort()
 
+	bannerLocale, _ := validateLocale("")
 	slog.Info(fmt.Sprintf(`
 
-dailyhues starting on port %s
+dailyhues %s starting on port %s
 Endpoints:
     GET /
     GET /api/colors?locale=%s&daysAgo=0
+    GET /api/transition?from=yesterday&to=today&steps=30
+    GET /api/week?locale=%s
+    GET /api/batch?locales=%s,ja-JP&daysAgo=0
+    GET /api/events?locale=%s (server-sent events)
+    GET /api/image-info?locale=%s&daysAgo=0
+    GET /api/contrast?fg=%%23000000&locale=%s
+    GET /api/stats/palettes
+    GET /api/trends.svg?days=90
+    GET /api/history?after=2025-01-01&hue=200-260&minLightness=0.4&sort=-date
+    GET /api/history.csv
+    GET /api/search?q=lighthouse&locale=%s
+    GET /api/estimate?width=1920&height=1080
+    GET /archive/images/{hash}/{size}.jpg
     GET /health
-
-`, port, defaultLocale))
-
-	server := &http.Server{
-		Addr:         ":" + port,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 30 * time.Second,
-		IdleTimeout:  60 * time.Second,
-	}
-
-	if err := server.ListenAndServe(); err != nil {
+    GET /metrics
+    GET /version
+    GET /admin/whoami (authenticated)
+    POST /admin/reload (authenticated)
+    GET|POST /admin/runtime (authenticated)
+    GET /admin/config (authenticated)
+    POST /admin/warm?locales=%s&days=0-7 (authenticated)
+    GET|POST|DELETE /admin/pins (authenticated)
+    GET /admin/audit?actor=&action=&since=2025-01-01T00:00:00Z (authenticated)
+    GET /admin/canary (authenticated)
+    GET /admin/debug (authenticated)
+
+`, version.Get(), port, bannerLocale, bannerLocale, bannerLocale, bannerLocale, bannerLocale, bannerLocale, bannerLocale, bannerLocale))
+
+	// Stop accepting requests on SIGINT/SIGTERM and let in-flight ones finish
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	listener, err := net.Listen("tcp", ":"+port)
+	if err != nil {
 		slog.Error("Server failed to start", "error", err)
-	}
-}
-
-// handleLandingPage returns a simple HTML landing page
-func handleLandingPage(w http.ResponseWriter, r *http.Request) {
-	// Only handle root path
-	if r.URL.Path != "/" {
-		http.NotFound(w, r)
 		return
 	}
+	if err := app.serve(ctx, listener); err != nil {
+		slog.Error("Server failed", "error", err)
+	}
+}
 
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	html := `<!DOCTYPE html>
+// landingPage is the HTML landing page, filled in with the deployment's Branding
+var landingPage = template.Must(template.New("landing").Parse(`<!DOCTYPE html>
 <html lang="en">
 <head>
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>dailyhues - Bing Wallpaper Color Palette API</title>
+    <title>{{.Name}} - Bing Wallpaper Color Palette API</title>
+    <meta name="description" content="AI-extracted color palettes from Bing's daily wallpaper">
+    <meta property="og:type" content="website">
+    <meta property="og:site_name" content="{{.Name}}">
+    <meta property="og:title" content="{{.Name}} - Bing Wallpaper Color Palette API">
+    <meta property="og:description" content="AI-extracted color palettes from Bing's daily wallpaper">
+    <meta property="og:url" content="{{.BaseURL}}/">
     <style>
         body {
             font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, Oxygen, Ubuntu, Cantarell, sans-serif;
@@ -221,20 +302,31 @@ func handleLandingPage(w http.ResponseWriter, r *http.Request) {
             color: #3fb950;
         }
         .links { margin-top: 2rem; }
+        .trends {
+            display: block;
+            width: 100%;
+            height: 4rem;
+            border-radius: 6px;
+        }
     </style>
 </text>
 </head>
 <body>
-    <h1>dailyhues</h1>
+    <h1>{{.Name}}</h1>
     <p class="subtitle">AI-extracted color palettes from Bing's daily wallpaper</p>
 
     <div class="code-block">
         <button class="copy-btn" onclick="copyCode()">Copy</button>
-        <pre><code>curl <a href="https://dailyhues.up.railway.app/api/colors">https://dailyhues.up.railway.app/api/colors</a></code></pre>
+        <pre><code>curl <a href="{{.BaseURL}}/api/colors">{{.BaseURL}}/api/colors</a></code></pre>
     </div>
 
+    <img class="trends" src="/api/trends.svg?days=90" alt="Palette history of the last 90 days">
+
     <div class="links">
-        <p><a href="https://github.com/mgabor3141/dailyhues">View on GitHub</a> for full documentation and examples</p>
+        <p><a href="{{.SourceURL}}">View on GitHub</a> for full documentation and examples</p>
+        {{- if .ContactURL}}
+        <p><a href="{{.ContactURL}}">Contact</a> the operators of this instance</p>
+        {{- end}}
     </div>
 
     <script>
@@ -252,8 +344,23 @@ func handleLandingPage(w http.ResponseWriter, r *http.Request) {
         }
     </script>
 </body>
-</html>`
-	fmt.Fprint(w, html)
+</html>`))
+
+// handleLandingPage returns a simple HTML landing page
+func handleLandingPage(w http.ResponseWriter, r *http.Request) {
+	// Only handle root path
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	branding := loadBranding()
+	branding.BaseURL = branding.baseURL(r)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := landingPage.Execute(w, branding); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to render landing page", "error", err)
+	}
 }
 
 // handleHealth returns a simple health check response
@@ -265,16 +372,107 @@ func handleHealth(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// newApp loads the caches from cacheDataDir and sets up the clients from the environment
+func newApp(cacheDataDir string) *App {
+	// Get OpenRouter API key from environment
+	apiKey := os.Getenv("OPENROUTER_API_KEY")
+	if apiKey == "" {
+		slog.Error("OPENROUTER_API_KEY environment variable is required")
+	}
+
+	// Initialize caches
+	requestCache, err := cache.NewRequestCache(cacheDataDir)
+	if err != nil {
+		slog.Error("Failed to initialize request cache", "error", err)
+	}
+
+	analysisCache, err := cache.NewAnalysisCache(cacheDataDir)
+	if err != nil {
+		slog.Error("Failed to initialize analysis cache", "error", err)
+	}
+
+	// Load all existing cache files into memory on startup
+	if err := requestCache.LoadAll(); err != nil {
+		slog.Error("Failed to load request cache", "error", err)
+	}
+	if err := analysisCache.LoadAll(); err != nil {
+		slog.Error("Failed to load analysis cache", "error", err)
+	}
+
+	auditLog, err := audit.Open(cacheDataDir)
+	if err != nil {
+		slog.Error("Failed to open audit log", "error", err)
+	}
+
+	return &App{
+		requestCache:  requestCache,
+		analysisCache: analysisCache,
+		bingClient:    bing.NewClient(defaultLocale),
+		aiAnalyzer:    ai.NewAnalyzer(apiKey),
+		authenticator: auth.NewAuthenticator(loadAuthConfig()),
+		auditLog:      auditLog,
+		renderCache:   newRenderCache(loadRenderCacheSize()),
+		profileCaches: profileCaches{dir: cacheDataDir},
+	}
+}
+
+// handleVersion returns the build information of the running server
+func handleVersion(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, version.Get())
+}
+
+// withVersionHeader adds the X-Dailyhues-Version header to every response
+func withVersionHeader(next http.Handler) http.Handler {
+	header := version.Get().String()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Dailyhues-Version", header)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withRequestID tags each request with an ID, echoed in X-Request-ID and added to its logs in JSON mode
+// A well-formed X-Request-ID from a proxy is kept so log lines can be matched across services
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(logging.WithRequestID(r.Context(), id)))
+	})
+}
+
+// validRequestID accepts up to 64 letters, digits, dashes, underscores and dots
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns a random 16 character hex ID
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
 // handleGetColors is the main endpoint for getting wallpaper colors
 func (app *App) handleGetColors(w http.ResponseWriter, r *http.Request) {
-	// Only allow GET requests
-	if r.Method != http.MethodGet {
+	// Only allow GET and HEAD requests
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
 		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
 		return
 	}
 
-	// Validate and parse daysAgo parameter
-	daysAgo, err := validateDaysAgo(r.URL.Query().Get("daysAgo"))
+	// Validate and parse daysAgo parameter; days older than Bing keeps are served from the archive
+	daysAgo, err := validateDaysAgoUpTo(r.URL.Query().Get("daysAgo"), maxArchivedDaysBack)
 	if err != nil {
 		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
@@ -287,109 +485,308 @@ func (app *App) handleGetColors(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Step 1: Check request cache (with TTL validation)
-	if reqEntry := app.requestCache.Get(locale, daysAgo); reqEntry != nil {
-		// Check if cache is still valid (not past the expiration time)
-		if time.Now().Before(reqEntry.ExpiresAt) {
-			// Request cached, now check if we have the analysis
-			if analysisEntry := app.analysisCache.Get(reqEntry.ImageHash); analysisEntry != nil {
-				response := buildColorTheme(reqEntry, analysisEntry)
-				respondWithJSON(w, http.StatusOK, response)
-				return
-			}
+	// A date selects the day by its start date instead of daysAgo
+	if date := r.URL.Query().Get("date"); date != "" {
+		if r.URL.Query().Get("daysAgo") != "" {
+			respondWithError(w, http.StatusBadRequest, "daysAgo and date cannot be combined")
+			return
+		}
+		if daysAgo, err = app.daysAgoOfDate(locale, date, time.Now()); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
 		}
 	}
 
-	// Step 2: Download wallpaper metadata and image from Bing
-	app.bingClient.SetLocale(locale)
-	imageData, info, err := app.bingClient.GetWallpaperByDaysAgo(daysAgo)
+	profile, prompt, err := validateProfile(r.URL.Query().Get("profile"))
 	if err != nil {
-		slog.Info("Failed to download wallpaper", "error", err)
-		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to download wallpaper: %v", err))
+		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	slog.Info("Downloaded wallpaper", "title", info.Title, "bytes", len(imageData))
+	angles, err := validateAngles(r.URL.Query().Get("angles"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	// Step 3: Generate image hash (this is our unique identifier)
-	imageHash := cache.HashImage(imageData)
-	slog.Info("Image hash", "hash", imageHash)
+	gamut, err := validateGamut(r.URL.Query().Get("gamut"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	stability, err := validateStability(r.URL.Query().Get("stability"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if stability > 0 && (prompt != "" || profile == profileFull) {
+		respondWithError(w, http.StatusBadRequest, "stability is not supported with profile "+profile)
+		return
+	}
 
-	// Step 4: Check analysis cache by image hash
-	if analysisEntry := app.analysisCache.Get(imageHash); analysisEntry != nil {
-		// Analysis exists! Just cache the request metadata and return
-		slog.Info("Analysis cache hit for image hash", "hash", imageHash)
+	verbose := r.URL.Query().Get("verbose") == "true"
 
-		expiresAt := getNextHourBoundary()
-		if err := app.requestCache.Set(locale, daysAgo, imageHash, info.ImageURLs, info.Title, info.Copyright, info.CopyrightLink, info.StartDate, info.FullStartDate, info.EndDate, expiresAt); err != nil {
-			slog.Info("Failed to cache request", "error", err)
+	// Another model than AI_MODEL can only be requested by admins, since each such request is a new analysis
+	model, err := validateModel(r.URL.Query().Get("model"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if model != "" {
+		if r.Method == http.MethodHead {
+			respondWithError(w, http.StatusBadRequest, "model is not supported for HEAD requests")
+			return
 		}
+		if prompt != "" || profile == profileFull {
+			respondWithError(w, http.StatusBadRequest, "model is not supported with profile "+profile)
+			return
+		}
+		if stability > 0 {
+			respondWithError(w, http.StatusBadRequest, "model is not supported with stability")
+			return
+		}
+		if _, ok := app.authenticateAdmin(w, r); !ok {
+			return
+		}
+	}
 
-		response := buildColorThemeFromInfo(info, analysisEntry)
-		respondWithJSON(w, http.StatusOK, response)
+	// Validate the requested output format before doing any expensive work
+	encoding, err := negotiateEncoding(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	// Step 5: Acquire mutex for this image hash (prevents duplicate analysis)
-	imageMutex := app.analysisCache.GetMutex(imageHash)
-	imageMutex.Lock()
-	defer imageMutex.Unlock()
-	defer app.analysisCache.ReleaseMutex(imageHash)
+	// HEAD only reports what is already cached, so pollers never trigger Bing or AI work
+	if r.Method == http.MethodHead {
+		if prompt != "" || profile == profileFull {
+			respondWithError(w, http.StatusBadRequest, "profile "+profile+" is not supported for HEAD requests")
+			return
+		}
+		if stability > 0 {
+			respondWithError(w, http.StatusBadRequest, "stability is not supported for HEAD requests")
+			return
+		}
+		app.handleHeadColors(w, r, locale, daysAgo, encoding)
+		return
+	}
 
-	// Step 6: Double-check analysis cache (another goroutine might have completed)
-	if analysisEntry := app.analysisCache.Get(imageHash); analysisEntry != nil {
-		slog.Info("Analysis completed by another request for image hash", "hash", imageHash)
+	var response ColorTheme
+	if model != "" {
+		response, err = app.colorThemeWithModel(r.Context(), locale, daysAgo, model)
+	} else {
+		response, err = app.paletteTheme(r.Context(), locale, daysAgo, analysisProfile(profile))
+	}
+	if err != nil {
+		respondWithResolveError(w, err)
+		return
+	}
 
-		expiresAt := getNextHourBoundary()
-		if err := app.requestCache.Set(locale, daysAgo, imageHash, info.ImageURLs, info.Title, info.Copyright, info.CopyrightLink, info.StartDate, info.FullStartDate, info.EndDate, expiresAt); err != nil {
-			slog.Info("Failed to cache request", "error", err)
-		}
+	if response.Fallback == "" {
+		response = app.withStability(response, locale, daysAgo, stability)
+	}
+	response, err = withProfile(withAllowedAngles(response, angles), profile)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
 
-		response := buildColorThemeFromInfo(info, analysisEntry)
-		respondWithJSON(w, http.StatusOK, response)
+	if verbose {
+		response.Analysis = response.analysis
+	}
+	response = withWideGamut(response, gamut)
+	if verifyImagesEnabled() {
+		response = app.withVerifiedImages(r.Context(), response)
+	}
+
+	// Palettes from another model or a fallback are not the cached palette, so they are not stored anywhere either
+	if model != "" || response.Fallback != "" {
+		w.Header().Set("Cache-Control", "private, no-store")
+		app.respondTheme(w, encoding, response, "")
+		return
+	}
+
+	setCacheHeaders(w, nextUpdateTime(response.StartDate, response.FullStartDate, daysAgo), time.Now())
+	if setValidators(w, r, response.imageHash, response.version, cache.StartTime(response.StartDate, response.FullStartDate)) {
+		return
+	}
+
+	app.respondTheme(w, encoding, response, "profile="+profile+"&angles="+anglesOption(angles)+"&gamut="+strings.Join(gamut, ",")+
+		"&stability="+strconv.FormatFloat(stability, 'f', -1, 64))
+}
+
+// handleHeadColors answers HEAD requests from the caches without building a body
+func (app *App) handleHeadColors(w http.ResponseWriter, r *http.Request, locale string, daysAgo int, encoding responseEncoding) {
+	reqEntry := app.requestCache.Get(locale, daysAgo)
+	if reqEntry == nil {
+		// Nothing cached yet; a GET is needed to generate the palette
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	analysisEntry := app.analysisCache.Get(reqEntry.ImageHash)
+	if analysisEntry == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	setCacheHeaders(w, nextUpdateTime(reqEntry.StartDate, reqEntry.FullStartDate, daysAgo), time.Now())
+	if setValidators(w, r, reqEntry.ImageHash, versionOf(analysisEntry), cache.StartTime(reqEntry.StartDate, reqEntry.FullStartDate)) {
 		return
 	}
 
-	// Step 7: Analyze colors with AI (image already downloaded)
-	slog.Info("Starting AI analysis for image hash", "hash", imageHash)
-	colors, err := app.aiAnalyzer.AnalyzeColors(imageData, imageHash, info.Title, info.Copyright)
+	w.Header().Set("Content-Type", encoding.contentType)
+	w.Header().Add("Vary", "Accept")
+	w.WriteHeader(http.StatusOK)
+}
+
+// getColorTheme resolves the default palette for a locale and day, including when it will next change
+func (app *App) getColorTheme(locale string, daysAgo int) (ColorTheme, error) {
+	return app.paletteTheme(context.Background(), locale, daysAgo, "")
+}
+
+// paletteTheme resolves a locale's palette for a day and profile through the pipeline, including when it will next change
+// The default palette of today is also recorded as the locale's current one, publishing changes
+func (app *App) paletteTheme(ctx context.Context, locale string, daysAgo int, profile string) (ColorTheme, error) {
+	palette, err := app.palettes().GetPalette(ctx, sourceBing, locale, daysAgo, profile)
+	if err != nil {
+		return ColorTheme{}, err
+	}
+	theme := withUpdateSchedule(themeFromPalette(palette), daysAgo, time.Now())
+	if profile == "" && daysAgo == 0 && theme.Fallback == "" {
+		app.changes.observe(locale, theme)
+	}
+	return theme, nil
+}
+
+// palettes returns the palette pipeline, set up from the app's caches and clients on first use
+func (app *App) palettes() *pipeline.Pipeline {
+	app.pipelineOnce.Do(func() {
+		app.pipeline = &pipeline.Pipeline{
+			Requests:       app.requestCache,
+			Analyses:       app.analysisCache,
+			Bing:           app.bingClient,
+			PromptAddendum: loadPromptAddendum,
+			AnalysisImage:  app.analysisImage,
+			Analyze:        app.runAnalysis,
+			Fallback:       app.fallbackAnalysis,
+			Profile:        app.profileAnalysis,
+			Stored: func(entry cache.RequestEntry) {
+				app.archiveImagesAsync(entry.ImageHash, entry.ImageURLs)
+			},
+		}
+	})
+	return app.pipeline
+}
+
+// themeFromPalette builds the response for a palette resolved by the pipeline
+func themeFromPalette(palette pipeline.Palette) ColorTheme {
+	theme := buildColorTheme(&palette.Request, &palette.Analysis)
+	// A profile's own analysis is keyed by its prompt, so validators and rendered bodies differ from the default palette's
+	theme.imageHash = palette.Analysis.ImageHash
+	if palette.Fallback != "" {
+		// Without an image hash the response skips the render cache and validators, which are keyed by image
+		theme.Fallback = palette.Fallback
+		theme.imageHash = ""
+	}
+	return theme
+}
+
+// runAnalysis asks the AI for the image's colors and builds the analysis entry, including derived image statistics
+func (app *App) runAnalysis(imageData []byte, imageHash, promptAddendum string, info *bing.WallpaperInfo) (cache.AnalysisEntry, error) {
+	slog.Info("Starting AI analysis for image hash", "hash", imageHash, "prompt_addendum", promptAddendum != "")
+	colors, usage, err := app.aiAnalyzer.AnalyzeColors(imageData, imageHash, info.Title, info.Copyright, promptAddendum)
 	if err != nil {
 		slog.Info("Failed to analyze colors", "error", err)
-		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to analyze colors: %v", err))
-		return
+		app.recordFailedUsage(imageHash, usage)
+		return cache.AnalysisEntry{}, fmt.Errorf("Failed to analyze colors: %w", err)
 	}
+	usage = app.failedUsage.take(imageHash, usage)
 
-	slog.Info("Extracted colors for image hash", "hash", imageHash, "colors", colors)
+	colors = loadNormalizePolicy().Apply(colors)
+	slog.Info("Extracted colors for image hash", "hash", imageHash, "colors", colors, "model", usage.Model, "cost", usage.Cost)
 
-	// Step 8: Store analysis in cache (shared across all locales with this image)
-	if err := app.analysisCache.Set(imageHash, colors); err != nil {
-		slog.Info("Failed to cache analysis", "error", err)
+	analysisEntry := cache.AnalysisEntry{
+		ImageHash:        imageHash,
+		PromptAddendum:   promptAddendum,
+		Colors:           colors,
+		Model:            usage.Model,
+		PromptVersion:    ai.PromptVersion,
+		Source:           analysisSource(info),
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		Cost:             usage.Cost,
 	}
 
-	// Step 9: Store request metadata in cache
-	expiresAt := getNextHourBoundary()
-	if err := app.requestCache.Set(locale, daysAgo, imageHash, info.ImageURLs, info.Title, info.Copyright, info.CopyrightLink, info.StartDate, info.FullStartDate, info.EndDate, expiresAt); err != nil {
-		slog.Info("Failed to cache request", "error", err)
+	if resolution, err := imageResolution(imageData); err != nil {
+		slog.Info("Failed to read source resolution", "error", err)
+	} else {
+		analysisEntry.SourceResolution = resolution
 	}
 
-	// Step 10: Return response
-	response := ColorTheme{
-		StartDate:     info.StartDate,
-		FullStartDate: info.FullStartDate,
-		EndDate:       info.EndDate,
-		Images:        info.ImageURLs,
-		Colors:        colors,
-		Title:         info.Title,
-		Copyright:     info.Copyright,
-		CopyrightLink: info.CopyrightLink,
-		CachedAt:      time.Now().Format(time.RFC3339),
+	// Average the screen edges and center so clients can pick bar backgrounds
+	if regions, err := imageRegions(imageData); err != nil {
+		slog.Info("Failed to compute region colors", "error", err)
+	} else {
+		analysisEntry.Regions = regions
+	}
+
+	// Optionally verify the downscaled image still represents the UHD original
+	// Video frames have no UHD original to compare against
+	if enabled, threshold := loadResizeCheck(); enabled && !info.HasVideo() {
+		divergence, err := app.checkResizeConsistency(info, imageData, threshold)
+		if err != nil {
+			slog.Info("Resize consistency check failed", "error", err)
+		} else {
+			analysisEntry.ResizeDivergence = divergence
+		}
+	}
+
+	app.startCanary(imageData, analysisEntry, info)
+
+	return analysisEntry, nil
+}
+
+// loadNormalizePolicy reads the color normalization settings applied to new analyses
+// Read per analysis so changes from a config reload take effect immediately
+func loadNormalizePolicy() palette.NormalizePolicy {
+	policy := palette.NormalizePolicy{
+		StripAlpha: os.Getenv("COLOR_STRIP_ALPHA") == "true",
+	}
+
+	switch value := os.Getenv("COLOR_HEX_CASE"); value {
+	case "", palette.HexLower, palette.HexUpper:
+		policy.HexCase = value
+	default:
+		slog.Info("Ignoring invalid COLOR_HEX_CASE", "value", value)
+	}
+
+	switch value := os.Getenv("COLOR_HEX_LENGTH"); value {
+	case "", palette.HexLong, palette.HexShort:
+		policy.HexLength = value
+	default:
+		slog.Info("Ignoring invalid COLOR_HEX_LENGTH", "value", value)
+	}
+
+	if value := os.Getenv("COLOR_ANGLE_STEP"); value != "" {
+		step, err := strconv.ParseFloat(value, 64)
+		if err != nil || step < 0 || step > 360 {
+			slog.Info("Ignoring invalid COLOR_ANGLE_STEP", "value", value)
+		} else {
+			policy.AngleStep = step
+		}
 	}
 
-	respondWithJSON(w, http.StatusOK, response)
+	return policy
 }
 
-// validateDaysAgo validates the daysAgo parameter
+// validateDaysAgo validates the daysAgo parameter of endpoints that need Bing to still have the day
 func validateDaysAgo(daysAgoParam string) (int, error) {
+	return validateDaysAgoUpTo(daysAgoParam, maxDaysBack)
+}
+
+// validateDaysAgoUpTo validates a daysAgo parameter of at most maxDaysAgo
+func validateDaysAgoUpTo(daysAgoParam string, maxDaysAgo int) (int, error) {
 	// Default to today (0 days ago) if not provided
 	if daysAgoParam == "" {
 		return 0, nil
@@ -407,25 +804,30 @@ func validateDaysAgo(daysAgoParam string) (int, error) {
 		return 0, fmt.Errorf("daysAgo cannot be negative")
 	}
 
-	if daysAgo > maxDaysBack {
+	if daysAgo > maxDaysAgo && maxDaysAgo == maxDaysBack {
 		return 0, fmt.Errorf("daysAgo too large. Bing only keeps wallpapers for the last %d days", maxDaysBack)
 	}
+	if daysAgo > maxDaysAgo {
+		return 0, fmt.Errorf("daysAgo too large. At most %d is supported", maxDaysAgo)
+	}
 
 	return daysAgo, nil
 }
 
-// validateLocale validates the locale parameter
+// validateLocale validates the locale parameter and returns the canonical Bing market for it
+// Region-less or unlisted regional locales fall back to their language's primary market
 func validateLocale(locale string) (string, error) {
-	// Default to en-US if not provided
+	allowedLocalesMu.RLock()
+	defer allowedLocalesMu.RUnlock()
+
+	// Default to DEFAULT_LOCALE if not provided
 	if locale == "" {
 		return defaultLocale, nil
 	}
 
-	// Check if locale is allowed
-	for _, allowed := range allowedLocales {
-		if locale == allowed {
-			return locale, nil
-		}
+	market, _, err := bing.ResolveMarket(locale)
+	if err == nil && slices.Contains(allowedLocales, market) {
+		return market, nil
 	}
 
 	return "", fmt.Errorf("invalid locale. Supported locales: %s", strings.Join(allowedLocales, ", "))
@@ -433,38 +835,128 @@ func validateLocale(locale string) (string, error) {
 
 // buildColorTheme creates a ColorTheme response from cache entries
 func buildColorTheme(reqEntry *cache.RequestEntry, analysisEntry *cache.AnalysisEntry) ColorTheme {
-	return ColorTheme{
-		StartDate:     reqEntry.StartDate,
-		FullStartDate: reqEntry.FullStartDate,
-		EndDate:       reqEntry.EndDate,
-		Images:        reqEntry.ImageURLs,
-		Colors:        analysisEntry.Colors,
-		Title:         reqEntry.Title,
-		Copyright:     reqEntry.Copyright,
-		CopyrightLink: reqEntry.CopyrightLink,
-		CachedAt:      time.Now().Format(time.RFC3339),
-	}
+	return withDerivedColors(ColorTheme{
+		StartDate:      reqEntry.StartDate,
+		FullStartDate:  reqEntry.FullStartDate,
+		EndDate:        reqEntry.EndDate,
+		Images:         reqEntry.ImageURLs,
+		Orientations:   bing.Orientations(reqEntry.ImageURLs),
+		ArchivedImages: archivedImageURLs(reqEntry.ImageHash),
+		Videos:         reqEntry.VideoURLs,
+		Colors:         analysisEntry.Colors,
+		Regions:        analysisEntry.Regions,
+		Title:          reqEntry.Title,
+		Copyright:      reqEntry.Copyright,
+		CopyrightLink:  reqEntry.CopyrightLink,
+		Attribution:    requestAttribution(reqEntry),
+		CachedAt:       time.Now().Format(time.RFC3339),
+		Pinned:         analysisEntry.Pin != nil,
+		Model:          analysisEntry.Model,
+		imageHash:      reqEntry.ImageHash,
+		version:        versionOf(analysisEntry),
+		analysis:       analysisMetadata(analysisEntry),
+	})
 }
 
 // buildColorThemeFromInfo creates a ColorTheme response from wallpaper info and analysis
 func buildColorThemeFromInfo(info *bing.WallpaperInfo, analysisEntry *cache.AnalysisEntry) ColorTheme {
-	return ColorTheme{
+	return withDerivedColors(ColorTheme{
 		StartDate:     info.StartDate,
 		FullStartDate: info.FullStartDate,
 		EndDate:       info.EndDate,
 		Images:        info.ImageURLs,
+		Orientations:  bing.Orientations(info.ImageURLs),
+		Videos:        info.VideoURLs,
 		Colors:        analysisEntry.Colors,
+		Regions:       analysisEntry.Regions,
 		Title:         info.Title,
 		Copyright:     info.Copyright,
 		CopyrightLink: info.CopyrightLink,
+		Attribution:   newAttribution(info.Photographer, info.Agency, info.CopyrightLink),
 		CachedAt:      time.Now().Format(time.RFC3339),
+		Pinned:        analysisEntry.Pin != nil,
+		Model:         analysisEntry.Model,
+		imageHash:     analysisEntry.ImageHash,
+		version:       versionOf(analysisEntry),
+		analysis:      analysisMetadata(analysisEntry),
+	})
+}
+
+// withDerivedColors fills in the ready-to-use gradient strings, inactive border, notification colors and
+// lighting scene derived from the analyzed colors
+func withDerivedColors(theme ColorTheme) ColorTheme {
+	gradient, err := palette.GradientFromColors(theme.Colors)
+	if err != nil {
+		return theme
+	}
+
+	theme.CSSGradient = gradient.CSS()
+	theme.HyprlandGradient = gradient.Hyprland()
+
+	// Parsed like the gradient stops, as the cached value is in the normalized display form
+	if inactive, ok := theme.Colors["inactive_border"].(string); ok {
+		if parsed, err := palette.ParseHex(inactive); err == nil {
+			theme.InactiveBorder = parsed.Hex()
+		}
+	} else if inactive, err := gradient.InactiveBorder(); err == nil {
+		theme.InactiveBorder = inactive
+	}
+
+	// Copy so the cached analysis map is never modified
+	colors := make(map[string]interface{}, len(theme.Colors)+2)
+	for key, value := range theme.Colors {
+		colors[key] = value
+	}
+	if _, ok := colors["notifications"]; !ok {
+		if notifications, err := gradient.Notifications(); err == nil {
+			colors["notifications"] = map[string]interface{}{
+				"low":      notifications.Low,
+				"normal":   notifications.Normal,
+				"critical": notifications.Critical,
+			}
+		}
+	}
+	if _, ok := colors["lighting"]; !ok {
+		if lights, err := gradient.Lighting(); err == nil {
+			colors["lighting"] = lightingColors(lights)
+		}
+	}
+	theme.Colors = colors
+	return theme
+}
+
+// withUpdateSchedule fills in when the palette for daysAgo will next change
+// All days shift by one at the locale's rollover, so a theme from daysAgo days back changes daysAgo rollovers after its own
+// A rollover that has already passed (Bing publishing late) is reported as due now
+func withUpdateSchedule(theme ColorTheme, daysAgo int, now time.Time) ColorTheme {
+	next := nextUpdateTime(theme.StartDate, theme.FullStartDate, daysAgo)
+	if next.IsZero() {
+		return theme
+	}
+
+	theme.NextUpdateAt = next.UTC().Format(time.RFC3339)
+	theme.SecondsUntilUpdate = max(0, int64(next.Sub(now).Seconds()))
+	return theme
+}
+
+// nextUpdateTime returns when the palette of a wallpaper served as daysAgo will next change, or the zero time if unknown
+func nextUpdateTime(startDate, fullStartDate string, daysAgo int) time.Time {
+	rollover := cache.RolloverTime(startDate, fullStartDate)
+	if rollover.IsZero() {
+		return time.Time{}
 	}
+	return rollover.AddDate(0, 0, daysAgo)
 }
 
-// getNextHourBoundary returns the time at the start of the next hour
-func getNextHourBoundary() time.Time {
-	now := time.Now()
-	return now.Truncate(time.Hour).Add(time.Hour)
+// splitList splits a comma separated setting, trimming spaces and dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
 
 // respondWithJSON is a helper to send JSON responses
@@ -478,3 +970,19 @@ func respondWithJSON(w http.ResponseWriter, statusCode int, data interface{}) {
 func respondWithError(w http.ResponseWriter, statusCode int, message string) {
 	respondWithJSON(w, statusCode, ErrorResponse{Error: message})
 }
+
+// respondWithResolveError sends the error of a palette that could not be resolved
+// Analyses turned away by the AI queue are answered with 503 and a Retry-After of the queue timeout
+func respondWithResolveError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ai.ErrBusy) {
+		_, _, timeout := ai.QueueSettings()
+		w.Header().Set("Retry-After", strconv.Itoa(int((timeout+time.Second-1)/time.Second)))
+		respondWithError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	if errors.Is(err, pipeline.ErrNotArchived) {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	respondWithError(w, http.StatusInternalServerError, err.Error())
+}
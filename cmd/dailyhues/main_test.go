@@ -1,12 +1,40 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log/slog"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/mgabor3141/dailyhues/internal/ai"
+	"github.com/mgabor3141/dailyhues/internal/audit"
+	"github.com/mgabor3141/dailyhues/internal/auth"
 	"github.com/mgabor3141/dailyhues/internal/bing"
 	"github.com/mgabor3141/dailyhues/internal/cache"
+	"github.com/mgabor3141/dailyhues/internal/logging"
+	"github.com/mgabor3141/dailyhues/internal/metrics"
+	"github.com/mgabor3141/dailyhues/internal/notify"
+	"github.com/mgabor3141/dailyhues/internal/palette"
+	"github.com/mgabor3141/dailyhues/internal/pipeline"
+	"github.com/mgabor3141/dailyhues/internal/version"
 )
 
 // TestHandleGetColors_InvalidDaysAgo tests invalid daysAgo values
@@ -27,7 +55,7 @@ func TestHandleGetColors_InvalidDaysAgo(t *testing.T) {
 	}{
 		{"Not a number", "invalid"},
 		{"Negative", "-1"},
-		{"Too large", "10"},
+		{"Too large", "100000"},
 	}
 
 	for _, tt := range tests {
@@ -56,7 +84,7 @@ func TestHandleGetColors_DaysAgoTooLarge(t *testing.T) {
 		bingClient:    bing.NewClient(defaultLocale),
 	}
 
-	req := httptest.NewRequest("GET", "/api/colors?daysAgo=8", nil)
+	req := httptest.NewRequest("GET", "/api/colors?daysAgo=100000", nil)
 	w := httptest.NewRecorder()
 
 	app.handleGetColors(w, req)
@@ -64,6 +92,13 @@ func TestHandleGetColors_DaysAgoTooLarge(t *testing.T) {
 	if w.Code != http.StatusBadRequest {
 		t.Errorf("Expected status 400 for daysAgo too large, got %d", w.Code)
 	}
+
+	// Older than Bing keeps, and never archived
+	w = httptest.NewRecorder()
+	app.handleGetColors(w, httptest.NewRequest("GET", "/api/colors?daysAgo=8", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a day that is not archived, got %d", w.Code)
+	}
 }
 
 // TestHandleGetColors_ValidDaysAgo tests valid daysAgo values
@@ -244,10 +279,8 @@ func TestConcurrency_TwoLevelCacheSystem(t *testing.T) {
 	title := "Test Title"
 	copyright := "Test Copyright © Photographer"
 	copyrightLink := "https://example.com/test"
-	startDate := "20251019"
-	fullStartDate := "202510190700"
-	endDate := "20251020"
-	expiresAt := getNextHourBoundary()
+	startDate, fullStartDate, endDate := testWallpaperDates(daysAgo)
+	expiresAt := time.Now().Add(time.Hour)
 
 	// Store analysis once (shared)
 	err = analysisCache.Set(imageHash, colors)
@@ -287,3 +320,3382 @@ func TestConcurrency_TwoLevelCacheSystem(t *testing.T) {
 		t.Error("Expected both locales to get same analysis instance")
 	}
 }
+
+// TestHandleTransition_InvalidParams tests validation of transition parameters
+func TestHandleTransition_InvalidParams(t *testing.T) {
+	tmpDir := t.TempDir()
+	requestCache, _ := cache.NewRequestCache(tmpDir)
+	analysisCache, _ := cache.NewAnalysisCache(tmpDir)
+
+	app := &App{
+		requestCache:  requestCache,
+		analysisCache: analysisCache,
+		bingClient:    bing.NewClient(defaultLocale),
+	}
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"Unknown from", "from=tomorrow"},
+		{"Too old to", "to=8"},
+		{"Too few steps", "steps=1"},
+		{"Too many steps", "steps=1000"},
+		{"Steps not a number", "steps=many"},
+		{"Invalid locale", "locale=xx-XX"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/transition?"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			app.handleTransition(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("Expected status 400, got %d", w.Code)
+			}
+		})
+	}
+}
+
+// TestHandleTransition_FromCache tests interpolation between two cached days
+func TestHandleTransition_FromCache(t *testing.T) {
+	tmpDir := t.TempDir()
+	requestCache, _ := cache.NewRequestCache(tmpDir)
+	analysisCache, _ := cache.NewAnalysisCache(tmpDir)
+
+	app := &App{
+		requestCache:  requestCache,
+		analysisCache: analysisCache,
+		bingClient:    bing.NewClient(defaultLocale),
+	}
+
+	expiresAt := time.Now().Add(time.Hour)
+	days := []struct {
+		daysAgo int
+		hash    string
+		colors  map[string]interface{}
+	}{
+		{1, "yesterday0123456789012345678901234567890123456789012345678901", map[string]interface{}{"gradient_from": "#c67d3a", "gradient_to": "#6b8d7d", "gradient_angle": float64(135)}},
+		{0, "today012345678901234567890123456789012345678901234567890123456", map[string]interface{}{"gradient_from": "#3a7dc6", "gradient_to": "#7d6b8d", "gradient_angle": float64(180)}},
+	}
+	for _, d := range days {
+		startDate, fullStartDate, endDate := testWallpaperDates(d.daysAgo)
+		analysisCache.Set(d.hash, d.colors)
+		requestCache.Set(defaultLocale, d.daysAgo, d.hash, nil, "Title", "Copyright", "", startDate, fullStartDate, endDate, expiresAt)
+	}
+
+	req := httptest.NewRequest("GET", "/api/transition?from=yesterday&to=today&steps=5", nil)
+	w := httptest.NewRecorder()
+
+	app.handleTransition(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp TransitionResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if len(resp.Steps) != 5 {
+		t.Fatalf("Expected 5 steps, got %d", len(resp.Steps))
+	}
+
+	if resp.Steps[0].From != "#c67d3a" || resp.Steps[4].From != "#3a7dc6" {
+		t.Errorf("Unexpected endpoints: %+v ... %+v", resp.Steps[0], resp.Steps[4])
+	}
+
+	if resp.Steps[2].Angle != 157.5 {
+		t.Errorf("Expected midpoint angle 157.5, got %v", resp.Steps[2].Angle)
+	}
+}
+
+// TestBuildColorTheme_GradientStrings tests that gradient strings are derived from the colors
+func TestBuildColorTheme_GradientStrings(t *testing.T) {
+	reqEntry := &cache.RequestEntry{Locale: "en-US", StartDate: "20251019"}
+	analysisEntry := &cache.AnalysisEntry{
+		Colors: map[string]interface{}{"gradient_from": "#c67d3a", "gradient_to": "#6b8d7d", "gradient_angle": float64(135)},
+	}
+
+	theme := buildColorTheme(reqEntry, analysisEntry)
+
+	if theme.CSSGradient != "linear-gradient(135deg, #c67d3a, #6b8d7d)" {
+		t.Errorf("Unexpected css_gradient: %s", theme.CSSGradient)
+	}
+
+	if theme.HyprlandGradient != "rgba(c67d3aff) rgba(6b8d7dff) 135deg" {
+		t.Errorf("Unexpected hyprland_gradient: %s", theme.HyprlandGradient)
+	}
+
+	// Older analyses without inactive_border get one derived from the gradient
+	if theme.InactiveBorder == "" {
+		t.Error("Expected derived inactive_border")
+	}
+
+	if _, ok := theme.Colors["notifications"].(map[string]interface{}); !ok {
+		t.Errorf("Expected derived notification colors, got %v", theme.Colors)
+	}
+	if _, ok := analysisEntry.Colors["notifications"]; ok {
+		t.Error("Expected cached analysis colors to be left unmodified")
+	}
+
+	analysisEntry.Colors["inactive_border"] = "#4a4f4c"
+	if theme := buildColorTheme(reqEntry, analysisEntry); theme.InactiveBorder != "#4a4f4c" {
+		t.Errorf("Expected analyzed inactive_border, got %s", theme.InactiveBorder)
+	}
+}
+
+// TestBuildColorTheme_Attribution tests that the copyright string is parsed into a photo credit
+func TestBuildColorTheme_Attribution(t *testing.T) {
+	reqEntry := &cache.RequestEntry{
+		StartDate:     "20251019",
+		Copyright:     "Aerial view of peatland in Martimoaapa Mire Reserve, Finland (© romikatarina/Shutterstock)",
+		CopyrightLink: "https://www.bing.com/search?q=Martimoaapa",
+	}
+
+	theme := buildColorTheme(reqEntry, &cache.AnalysisEntry{})
+	if theme.Attribution == nil {
+		t.Fatal("Expected attribution")
+	}
+	if theme.Attribution.Credit != "© romikatarina/Shutterstock" || theme.Attribution.Photographer != "romikatarina" || theme.Attribution.Agency != "Shutterstock" {
+		t.Errorf("Unexpected attribution: %+v", theme.Attribution)
+	}
+	if theme.Attribution.Link != reqEntry.CopyrightLink || theme.Attribution.License == "" {
+		t.Errorf("Expected link and license note, got %+v", theme.Attribution)
+	}
+
+	// Credits stored at fetch time take precedence over parsing the copyright string
+	reqEntry.Photographer, reqEntry.Agency = "Jane Doe", "Alamy"
+	if theme := buildColorTheme(reqEntry, &cache.AnalysisEntry{}); theme.Attribution.Credit != "© Jane Doe/Alamy" {
+		t.Errorf("Expected stored credit, got %+v", theme.Attribution)
+	}
+
+	reqEntry.Photographer, reqEntry.Agency = "", ""
+	reqEntry.Copyright = "Copyright"
+	if theme := buildColorTheme(reqEntry, &cache.AnalysisEntry{}); theme.Attribution != nil {
+		t.Errorf("Expected no attribution without a credit, got %+v", theme.Attribution)
+	}
+}
+
+// TestHandleGetColors_AcceptEncodings tests binary response encodings chosen via the Accept header
+func TestHandleGetColors_AcceptEncodings(t *testing.T) {
+	tmpDir := t.TempDir()
+	requestCache, _ := cache.NewRequestCache(tmpDir)
+	analysisCache, _ := cache.NewAnalysisCache(tmpDir)
+
+	app := &App{
+		requestCache:  requestCache,
+		analysisCache: analysisCache,
+		bingClient:    bing.NewClient(defaultLocale),
+	}
+
+	imageHash := "accept0123456789012345678901234567890123456789012345678901234"
+	analysisCache.Set(imageHash, map[string]interface{}{"gradient_from": "#c67d3a", "gradient_to": "#6b8d7d", "gradient_angle": float64(135)})
+	startDate, fullStartDate, endDate := testWallpaperDates(0)
+	requestCache.Set(defaultLocale, 0, imageHash, nil, "Title", "Copyright", "", startDate, fullStartDate, endDate, time.Now().Add(time.Hour))
+
+	tests := []struct {
+		accept      string
+		contentType string
+		firstByte   byte
+	}{
+		{"application/msgpack", "application/msgpack", 0x80},
+		{"application/cbor", "application/cbor", 0xa0},
+		{"", "application/json", '{'},
+		{"application/msgpack;q=0, application/json", "application/json", '{'},
+		{"application/json;q=0.5, application/cbor", "application/cbor", 0xa0},
+		{"application/cbor; q=0.8, application/msgpack", "application/msgpack", 0x80},
+		{"text/html, application/msgpack;q=0.9, */*;q=0.8", "application/msgpack", 0x80},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/colors", nil)
+			req.Header.Set("Accept", tt.accept)
+			w := httptest.NewRecorder()
+
+			app.handleGetColors(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d", w.Code)
+			}
+
+			if got := w.Header().Get("Content-Type"); got != tt.contentType {
+				t.Errorf("Expected Content-Type %s, got %s", tt.contentType, got)
+			}
+
+			// Maps start with a type byte whose high bits identify the encoding
+			if got := w.Body.Bytes()[0]; got&0xf0 != tt.firstByte&0xf0 {
+				t.Errorf("Unexpected first byte %x", got)
+			}
+		})
+	}
+}
+
+// newCachedTestApp creates an app whose caches already hold today's en-US palette
+func newCachedTestApp(t *testing.T) *App {
+	t.Helper()
+
+	tmpDir := t.TempDir()
+	requestCache, _ := cache.NewRequestCache(tmpDir)
+	analysisCache, _ := cache.NewAnalysisCache(tmpDir)
+
+	imageHash := "cached0123456789012345678901234567890123456789012345678901234"
+	analysisCache.Set(imageHash, map[string]interface{}{"gradient_from": "#c67d3a", "gradient_to": "#6b8d7d", "gradient_angle": float64(135)})
+	startDate, fullStartDate, endDate := testWallpaperDates(0)
+	requestCache.Set(defaultLocale, 0, imageHash, map[string]string{"UHD": "https://bing.com/uhd.jpg"}, "Title", "Copyright", "", startDate, fullStartDate, endDate, time.Now().Add(time.Hour))
+
+	return &App{
+		requestCache:  requestCache,
+		analysisCache: analysisCache,
+		bingClient:    bing.NewClient(defaultLocale),
+	}
+}
+
+// TestHandleGetColors_FormatParam tests text serializations selected with ?format=
+func TestHandleGetColors_FormatParam(t *testing.T) {
+	app := newCachedTestApp(t)
+
+	tests := []struct {
+		format      string
+		contentType string
+		contains    string
+	}{
+		{"yaml", "application/yaml", "  gradient_from: \"#c67d3a\"\n"},
+		{"toml", "application/toml", "[colors]\n"},
+		{"json", "application/json", `"gradient_from":"#c67d3a"`},
+		{"txt", "text/plain; charset=utf-8", "gradient_from #c67d3a\n"},
+		{"env", "text/plain; charset=utf-8", "DAILYHUES_GRADIENT_FROM=#c67d3a\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/colors?format="+tt.format, nil)
+			w := httptest.NewRecorder()
+
+			app.handleGetColors(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d", w.Code)
+			}
+
+			if got := w.Header().Get("Content-Type"); got != tt.contentType {
+				t.Errorf("Expected Content-Type %s, got %s", tt.contentType, got)
+			}
+
+			if !strings.Contains(w.Body.String(), tt.contains) {
+				t.Errorf("Expected body to contain %q, got:\n%s", tt.contains, w.Body.String())
+			}
+		})
+	}
+}
+
+// TestHandleGetColors_InvalidFormat tests that unknown formats are rejected
+func TestHandleGetColors_InvalidFormat(t *testing.T) {
+	app := newCachedTestApp(t)
+
+	req := httptest.NewRequest("GET", "/api/colors?format=xml", nil)
+	w := httptest.NewRecorder()
+
+	app.handleGetColors(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
+
+// TestHandleTransition_ThemeOnlyFormat tests that palette-only formats are rejected for transitions
+func TestHandleTransition_ThemeOnlyFormat(t *testing.T) {
+	app := newCachedTestApp(t)
+
+	req := httptest.NewRequest("GET", "/api/transition?format=env", nil)
+	w := httptest.NewRecorder()
+
+	app.handleTransition(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
+
+// TestHandleGetColors_Head tests that HEAD reports the cached fingerprint without a body
+func TestHandleGetColors_Head(t *testing.T) {
+	app := newCachedTestApp(t)
+
+	req := httptest.NewRequest("HEAD", "/api/colors", nil)
+	w := httptest.NewRecorder()
+
+	app.handleGetColors(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	imageHash := w.Header().Get("X-Dailyhues-Image-Hash")
+	if imageHash == "" {
+		t.Error("Expected image hash header")
+	}
+
+	if etag := w.Header().Get("ETag"); !strings.HasPrefix(etag, `W/"`+imageHash+"-") {
+		t.Errorf("Unexpected ETag %s", etag)
+	}
+
+	if w.Body.Len() != 0 {
+		t.Errorf("Expected empty body, got %q", w.Body.String())
+	}
+}
+
+// TestHandleGetColors_HeadUncached tests that HEAD never triggers downloads for uncached palettes
+func TestHandleGetColors_HeadUncached(t *testing.T) {
+	app := newCachedTestApp(t)
+
+	req := httptest.NewRequest("HEAD", "/api/colors?daysAgo=3", nil)
+	w := httptest.NewRecorder()
+
+	app.handleGetColors(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
+
+// TestHandleGetColors_IfNoneMatch tests conditional GET with a current ETag
+func TestHandleGetColors_IfNoneMatch(t *testing.T) {
+	app := newCachedTestApp(t)
+
+	req := httptest.NewRequest("GET", "/api/colors", nil)
+	w := httptest.NewRecorder()
+	app.handleGetColors(w, req)
+
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("Expected ETag on GET response")
+	}
+
+	req = httptest.NewRequest("GET", "/api/colors", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	app.handleGetColors(w, req)
+
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Expected status 304, got %d", w.Code)
+	}
+}
+
+// TestHandleGetColors_IfModifiedSince tests time-based conditional GET from the wallpaper's start time
+func TestHandleGetColors_IfModifiedSince(t *testing.T) {
+	app := newCachedTestApp(t)
+
+	// Analyzed before the wallpaper went live, as when an earlier day showed the same image
+	entry := app.analysisCache.Get(app.requestCache.Get(defaultLocale, 0).ImageHash)
+	entry.AnalyzedAt = time.Now().AddDate(0, 0, -3)
+	app.analysisCache.SetEntry(*entry)
+
+	w := httptest.NewRecorder()
+	app.handleGetColors(w, httptest.NewRequest("GET", "/api/colors", nil))
+
+	lastModified, err := http.ParseTime(w.Header().Get("Last-Modified"))
+	if err != nil {
+		t.Fatalf("Expected Last-Modified on GET response: %v", err)
+	}
+	_, fullStartDate, _ := testWallpaperDates(0)
+	if got := lastModified.Format("200601021504"); got != fullStartDate {
+		t.Errorf("Expected Last-Modified from fullstartdate %s, got %s", fullStartDate, got)
+	}
+
+	tests := []struct {
+		name        string
+		since       time.Time
+		ifNoneMatch string
+		want        int
+	}{
+		{"Unchanged", lastModified, "", http.StatusNotModified},
+		{"Later", lastModified.Add(time.Hour), "", http.StatusNotModified},
+		{"Earlier", lastModified.Add(-time.Hour), "", http.StatusOK},
+		{"ETag takes precedence", lastModified, `W/"other"`, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/colors", nil)
+			req.Header.Set("If-Modified-Since", tt.since.Format(http.TimeFormat))
+			if tt.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			w := httptest.NewRecorder()
+			app.handleGetColors(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("Expected status %d, got %d", tt.want, w.Code)
+			}
+		})
+	}
+}
+
+// TestHandleGetColors_Reanalyzed tests that replacing the colors under the same image hash changes both validators
+func TestHandleGetColors_Reanalyzed(t *testing.T) {
+	app := newCachedTestApp(t)
+	imageHash := app.requestCache.Get(defaultLocale, 0).ImageHash
+
+	w := httptest.NewRecorder()
+	app.handleGetColors(w, httptest.NewRequest("GET", "/api/colors", nil))
+	etag, lastModified := w.Header().Get("ETag"), w.Header().Get("Last-Modified")
+
+	// What reanalyze stores
+	app.analysisCache.SetEntry(cache.AnalysisEntry{
+		ImageHash:  imageHash,
+		Colors:     map[string]interface{}{"gradient_from": "#3a7dc6", "gradient_to": "#7d6b8d", "gradient_angle": float64(45)},
+		Model:      "newer/model",
+		AnalyzedAt: time.Now().Add(time.Minute),
+	})
+	app.renderCache.invalidate(imageHash)
+
+	for _, header := range []string{"If-None-Match", "If-Modified-Since"} {
+		t.Run(header, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/colors", nil)
+			if header == "If-None-Match" {
+				req.Header.Set(header, etag)
+			} else {
+				req.Header.Set(header, lastModified)
+			}
+			w := httptest.NewRecorder()
+			app.handleGetColors(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status 200 after a re-analysis, got %d", w.Code)
+			}
+			if w.Header().Get("ETag") == etag {
+				t.Errorf("Expected a new ETag, got %s again", etag)
+			}
+			if !strings.Contains(w.Body.String(), "#3a7dc6") {
+				t.Errorf("Expected the re-analyzed colors, got %s", w.Body.String())
+			}
+		})
+	}
+}
+
+// TestRequireAdmin tests admin route protection
+func TestRequireAdmin(t *testing.T) {
+	tests := []struct {
+		name   string
+		config auth.Config
+		token  string
+		want   int
+	}{
+		{"Disabled", auth.Config{}, "anything", http.StatusForbidden},
+		{"Missing token", auth.Config{APIKeys: []string{"key"}}, "", http.StatusUnauthorized},
+		{"Wrong token", auth.Config{APIKeys: []string{"key"}}, "nope", http.StatusUnauthorized},
+		{"Valid token", auth.Config{APIKeys: []string{"key"}}, "key", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &App{authenticator: auth.NewAuthenticator(tt.config)}
+
+			req := httptest.NewRequest("GET", "/admin/whoami", nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+
+			app.requireAdmin(handleWhoami)(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("Expected status %d, got %d", tt.want, w.Code)
+			}
+		})
+	}
+}
+
+// TestReloadConfig tests that a config file reload changes the allowed locales
+func TestReloadConfig(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "dailyhues.env")
+	os.WriteFile(configFile, []byte("ALLOWED_LOCALES=de-DE, fr-FR\nADMIN_API_KEYS=reloaded\n"), 0644)
+
+	// Registered first so it runs after the environment has been restored
+	t.Cleanup(loadAllowedLocales)
+	t.Setenv("CONFIG_FILE", configFile)
+	t.Setenv("ALLOWED_LOCALES", "")
+	t.Setenv("ADMIN_API_KEYS", "")
+
+	app := &App{authenticator: auth.NewAuthenticator(auth.Config{})}
+	if err := app.reloadConfig("test"); err != nil {
+		t.Fatalf("Failed to reload: %v", err)
+	}
+
+	if _, err := validateLocale("fr-FR"); err != nil {
+		t.Errorf("Expected fr-FR to be allowed after reload: %v", err)
+	}
+
+	if _, err := validateLocale("ja-JP"); err == nil {
+		t.Error("Expected ja-JP to be rejected after reload")
+	}
+
+	if !app.authenticator.Enabled() {
+		t.Error("Expected admin API to be enabled after reload")
+	}
+}
+
+// TestHandleVersion tests the build info endpoint and version header
+func TestHandleVersion(t *testing.T) {
+	req := httptest.NewRequest("GET", "/version", nil)
+	w := httptest.NewRecorder()
+
+	withVersionHeader(http.HandlerFunc(handleVersion)).ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+
+	var info version.Info
+	if err := json.Unmarshal(w.Body.Bytes(), &info); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if info.Version == "" || info.Commit == "" {
+		t.Errorf("Expected version and commit, got %+v", info)
+	}
+
+	if header := w.Header().Get("X-Dailyhues-Version"); !strings.HasPrefix(header, info.Version) {
+		t.Errorf("Unexpected version header %q", header)
+	}
+}
+
+// testWallpaperDates returns Bing-style start/full start/end dates for the wallpaper live daysAgo days ago (UTC)
+func testWallpaperDates(daysAgo int) (startDate, fullStartDate, endDate string) {
+	day := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -daysAgo)
+	return day.Format("20060102"), day.Format("20060102") + "0000", day.AddDate(0, 0, 1).Format("20060102")
+}
+
+// TestWithUpdateSchedule tests that the next update is derived from the wallpaper's rollover
+func TestWithUpdateSchedule(t *testing.T) {
+	now := time.Date(2025, 10, 19, 12, 0, 0, 0, time.UTC)
+
+	today := withUpdateSchedule(ColorTheme{StartDate: "20251019", FullStartDate: "202510190700"}, 0, now)
+	if today.NextUpdateAt != "2025-10-20T07:00:00Z" {
+		t.Errorf("Expected next update at 2025-10-20T07:00:00Z, got %s", today.NextUpdateAt)
+	}
+	if today.SecondsUntilUpdate != 19*3600 {
+		t.Errorf("Expected %d seconds until update, got %d", 19*3600, today.SecondsUntilUpdate)
+	}
+
+	// Yesterday's palette moves to daysAgo=2 at the same rollover as today's
+	yesterday := withUpdateSchedule(ColorTheme{StartDate: "20251018", FullStartDate: "202510180700"}, 1, now)
+	if yesterday.NextUpdateAt != today.NextUpdateAt {
+		t.Errorf("Expected yesterday to update with today, got %s", yesterday.NextUpdateAt)
+	}
+
+	// Bing publishing late means the update is due now, never negative
+	late := withUpdateSchedule(ColorTheme{StartDate: "20251018", FullStartDate: "202510180700"}, 0, now)
+	if late.SecondsUntilUpdate != 0 {
+		t.Errorf("Expected overdue update to report 0 seconds, got %d", late.SecondsUntilUpdate)
+	}
+
+	if unknown := withUpdateSchedule(ColorTheme{}, 0, now); unknown.NextUpdateAt != "" {
+		t.Errorf("Expected no schedule without dates, got %s", unknown.NextUpdateAt)
+	}
+}
+
+// TestHandleWeek_InvalidParams tests validation of week archive parameters
+func TestHandleWeek_InvalidParams(t *testing.T) {
+	app := &App{bingClient: bing.NewClient(defaultLocale)}
+
+	for _, query := range []string{"locale=xx-XX", "format=env", "format=bogus"} {
+		req := httptest.NewRequest("GET", "/api/week?"+query, nil)
+		w := httptest.NewRecorder()
+
+		app.handleWeek(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", query, w.Code)
+		}
+	}
+}
+
+// TestGetWeekDays_FromCache tests that cached days are answered without downloading or analyzing
+func TestGetWeekDays_FromCache(t *testing.T) {
+	tmpDir := t.TempDir()
+	requestCache, _ := cache.NewRequestCache(tmpDir)
+	analysisCache, _ := cache.NewAnalysisCache(tmpDir)
+	app := &App{requestCache: requestCache, analysisCache: analysisCache}
+
+	var infos []*bing.WallpaperInfo
+	for daysAgo, hash := range []string{"today", "yesterday"} {
+		startDate, fullStartDate, endDate := testWallpaperDates(daysAgo)
+		analysisCache.Set(hash, map[string]interface{}{"gradient_from": "#c67d3a", "gradient_to": "#6b8d7d", "gradient_angle": float64(135)})
+		requestCache.Set(defaultLocale, daysAgo, hash, nil, hash, "Copyright", "", startDate, fullStartDate, endDate, time.Now().Add(time.Hour))
+		infos = append(infos, &bing.WallpaperInfo{StartDate: startDate, FullStartDate: fullStartDate, EndDate: endDate})
+	}
+
+	days := app.getWeekDays(defaultLocale, infos, time.Now())
+
+	if len(days) != 2 {
+		t.Fatalf("Expected 2 days, got %d", len(days))
+	}
+
+	for i, want := range []string{"today", "yesterday"} {
+		if days[i].DaysAgo != i || days[i].Title != want || days[i].Error != "" {
+			t.Errorf("Unexpected day %d: %+v", i, days[i])
+		}
+		if days[i].CSSGradient == "" || days[i].NextUpdateAt == "" {
+			t.Errorf("Expected gradient strings and schedule for day %d", i)
+		}
+	}
+}
+
+// TestLoadBackfillConfig tests parsing of the backfill locales and interval
+func TestLoadBackfillConfig(t *testing.T) {
+	t.Setenv("BACKFILL_LOCALES", "en-US, xx-XX,ja-JP")
+	t.Setenv("BACKFILL_INTERVAL", "5s")
+
+	locales, interval := loadBackfillConfig()
+	if strings.Join(locales, ",") != "en-US,ja-JP" {
+		t.Errorf("Expected disallowed locale to be dropped, got %v", locales)
+	}
+	if interval != 5*time.Second {
+		t.Errorf("Expected 5s interval, got %v", interval)
+	}
+
+	t.Setenv("BACKFILL_LOCALES", "")
+	t.Setenv("BACKFILL_INTERVAL", "soon")
+
+	locales, interval = loadBackfillConfig()
+	if len(locales) != 0 {
+		t.Errorf("Expected backfill to be disabled, got %v", locales)
+	}
+	if interval != defaultBackfillInterval {
+		t.Errorf("Expected default interval for invalid value, got %v", interval)
+	}
+}
+
+// TestHandlePaletteStats tests palette families and monthly averages over the archive
+func TestHandlePaletteStats(t *testing.T) {
+	tmpDir := t.TempDir()
+	requestCache, _ := cache.NewRequestCache(tmpDir)
+	analysisCache, _ := cache.NewAnalysisCache(tmpDir)
+	app := &App{requestCache: requestCache, analysisCache: analysisCache}
+
+	archive := []struct {
+		locale, hash, startDate, from, to string
+	}{
+		{"en-US", "warm1", "20250930", "#c67d3a", "#d08a40"},
+		{"en-US", "warm2", "20251001", "#c0703a", "#c88044"},
+		{"ja-JP", "warm2", "20251001", "#c0703a", "#c88044"}, // shared image counts once
+		{"en-US", "cool1", "20251002", "#3a7dc6", "#4080d0"},
+	}
+	for _, a := range archive {
+		analysisCache.Set(a.hash, map[string]interface{}{"gradient_from": a.from, "gradient_to": a.to, "gradient_angle": float64(135)})
+		requestCache.Set(a.locale, 0, a.hash, nil, "Title", "Copyright", "", a.startDate, a.startDate+"0700", "", time.Now())
+	}
+
+	req := httptest.NewRequest("GET", "/api/stats/palettes?families=2", nil)
+	w := httptest.NewRecorder()
+
+	app.handlePaletteStats(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var stats PaletteStats
+	if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if stats.Palettes != 3 {
+		t.Errorf("Expected 3 palettes, got %d", stats.Palettes)
+	}
+
+	if len(stats.Families) != 2 || stats.Families[0].Palettes != 2 || stats.Families[1].Palettes != 1 {
+		t.Errorf("Expected a warm family of 2 and a cool family of 1, got %+v", stats.Families)
+	}
+
+	if len(stats.Months) != 2 || stats.Months[0].Month != "2025-09" || stats.Months[1].Month != "2025-10" || stats.Months[1].Palettes != 2 {
+		t.Errorf("Unexpected months: %+v", stats.Months)
+	}
+
+	for _, query := range []string{"families=0", "families=13", "locale=xx-XX", "format=txt"} {
+		w := httptest.NewRecorder()
+		app.handlePaletteStats(w, httptest.NewRequest("GET", "/api/stats/palettes?"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", query, w.Code)
+		}
+	}
+}
+
+// TestHandleTrendsSVG tests that archived days become gradient stripes and missing days are left out
+func TestHandleTrendsSVG(t *testing.T) {
+	tmpDir := t.TempDir()
+	requestCache, _ := cache.NewRequestCache(tmpDir)
+	analysisCache, _ := cache.NewAnalysisCache(tmpDir)
+	app := &App{requestCache: requestCache, analysisCache: analysisCache}
+
+	startDate, fullStartDate, endDate := testWallpaperDates(0)
+	analysisCache.Set("today", map[string]interface{}{"gradient_from": "#c67d3a", "gradient_to": "#6b8d7d", "gradient_angle": float64(135)})
+	requestCache.Set(defaultLocale, 0, "today", nil, "Fish & Chips", "Copyright", "", startDate, fullStartDate, endDate, time.Now())
+
+	req := httptest.NewRequest("GET", "/api/trends.svg?days=3", nil)
+	w := httptest.NewRecorder()
+
+	app.handleTrendsSVG(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if ct := w.Header().Get("Content-Type"); ct != "image/svg+xml" {
+		t.Errorf("Expected image/svg+xml, got %s", ct)
+	}
+
+	body := w.Body.String()
+	if strings.Count(body, "<rect") != 1 {
+		t.Errorf("Expected a single stripe, got %s", body)
+	}
+	if !strings.Contains(body, `x="16"`) || !strings.Contains(body, `stop-color="#c67d3a"`) {
+		t.Errorf("Expected today's stripe last with its gradient, got %s", body)
+	}
+	if !strings.Contains(body, "Fish &amp; Chips") {
+		t.Errorf("Expected escaped title, got %s", body)
+	}
+
+	for _, query := range []string{"days=0", "days=abc", "locale=xx-XX"} {
+		w := httptest.NewRecorder()
+		app.handleTrendsSVG(w, httptest.NewRequest("GET", "/api/trends.svg?"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", query, w.Code)
+		}
+	}
+}
+
+// TestHandleHistoryCSV tests the CSV export of archived palettes and their usage
+func TestHandleHistoryCSV(t *testing.T) {
+	tmpDir := t.TempDir()
+	requestCache, _ := cache.NewRequestCache(tmpDir)
+	analysisCache, _ := cache.NewAnalysisCache(tmpDir)
+	app := &App{requestCache: requestCache, analysisCache: analysisCache}
+
+	colors := map[string]interface{}{"gradient_from": "#c67d3a", "gradient_to": "#6b8d7d", "gradient_angle": float64(157.5)}
+	analysisCache.SetEntry(cache.AnalysisEntry{ImageHash: "new", Colors: colors, Model: "anthropic/claude-sonnet-4.5", PromptTokens: 1000, CompletionTokens: 234, Cost: 0.0123})
+	analysisCache.Set("legacy", colors)
+	requestCache.Set("en-US", 0, "new", nil, "Title, with comma", "Copyright", "", "20251020", "202510200700", "", time.Now())
+	requestCache.Set("en-US", 0, "legacy", nil, "Old", "Copyright", "", "20251019", "202510190700", "", time.Now())
+	requestCache.Set("ja-JP", 0, "new", nil, "Title", "Copyright", "", "20251020", "202510191500", "", time.Now())
+
+	req := httptest.NewRequest("GET", "/api/history.csv?locale=en-US", nil)
+	w := httptest.NewRecorder()
+
+	app.handleHistoryCSV(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	want := "date,locale,title,gradient_from,gradient_to,angle,model,tokens,cost\n" +
+		"2025-10-19,en-US,Old,#c67d3a,#6b8d7d,157.5,,,\n" +
+		"2025-10-20,en-US,\"Title, with comma\",#c67d3a,#6b8d7d,157.5,anthropic/claude-sonnet-4.5,1234,0.0123\n"
+	if got := w.Body.String(); got != want {
+		t.Errorf("Unexpected CSV:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// TestHandleHistory_Filters tests date, hue and lightness filters plus sorting and limits
+func TestHandleHistory_Filters(t *testing.T) {
+	tmpDir := t.TempDir()
+	requestCache, _ := cache.NewRequestCache(tmpDir)
+	analysisCache, _ := cache.NewAnalysisCache(tmpDir)
+	app := &App{requestCache: requestCache, analysisCache: analysisCache}
+
+	archive := []struct {
+		hash, startDate, color string
+	}{
+		{"red", "20250101", "#d04040"},
+		{"blue", "20250102", "#3a7dc6"},
+		{"darkblue", "20250103", "#102040"},
+		{"green", "20250104", "#40a040"},
+	}
+	for _, a := range archive {
+		analysisCache.Set(a.hash, map[string]interface{}{"gradient_from": a.color, "gradient_to": a.color, "gradient_angle": float64(135)})
+		requestCache.Set("en-US", 0, a.hash, nil, a.hash, "Copyright", "", a.startDate, a.startDate+"0700", "", time.Now())
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"red", "blue", "darkblue", "green"}},
+		{"after=2025-01-02&before=2025-01-03", []string{"blue", "darkblue"}},
+		{"hue=200-270", []string{"blue", "darkblue"}},
+		{"hue=200-270&minLightness=0.4", []string{"blue"}},
+		{"hue=330-40", []string{"red"}},
+		{"sort=-date&limit=2", []string{"green", "darkblue"}},
+		{"sort=lightness&limit=1", []string{"darkblue"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			app.handleHistory(w, httptest.NewRequest("GET", "/api/history?"+tt.query, nil))
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+			}
+
+			var response HistoryResponse
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+
+			var got []string
+			for _, record := range response.Records {
+				got = append(got, record.Title)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") || response.Count != len(tt.want) {
+				t.Errorf("Expected %v, got %v (count %d)", tt.want, got, response.Count)
+			}
+		})
+	}
+
+	for _, query := range []string{"after=yesterday", "hue=200", "hue=10-400", "minLightness=2", "sort=title", "limit=0", "format=env"} {
+		w := httptest.NewRecorder()
+		app.handleHistory(w, httptest.NewRequest("GET", "/api/history?"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", query, w.Code)
+		}
+	}
+}
+
+// TestLoadNormalizePolicy tests reading the normalization settings and ignoring invalid values
+func TestLoadNormalizePolicy(t *testing.T) {
+	t.Setenv("COLOR_HEX_CASE", "upper")
+	t.Setenv("COLOR_HEX_LENGTH", "long")
+	t.Setenv("COLOR_STRIP_ALPHA", "true")
+	t.Setenv("COLOR_ANGLE_STEP", "15")
+
+	want := palette.NormalizePolicy{HexCase: palette.HexUpper, HexLength: palette.HexLong, StripAlpha: true, AngleStep: 15}
+	if got := loadNormalizePolicy(); got != want {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+
+	t.Setenv("COLOR_HEX_CASE", "title")
+	t.Setenv("COLOR_HEX_LENGTH", "medium")
+	t.Setenv("COLOR_STRIP_ALPHA", "")
+	t.Setenv("COLOR_ANGLE_STEP", "-5")
+
+	if got := loadNormalizePolicy(); got != (palette.NormalizePolicy{}) {
+		t.Errorf("Expected invalid settings to be ignored, got %+v", got)
+	}
+}
+
+// TestWithDerivedColors_NormalizePolicy tests that derived colors and formats are built from the parsed colors,
+// whatever display form the normalization policy cached them in
+func TestWithDerivedColors_NormalizePolicy(t *testing.T) {
+	colors := map[string]interface{}{
+		"gradient_from":   "#aabbccff",
+		"gradient_to":     "#668844ee",
+		"gradient_angle":  135.0,
+		"inactive_border": "#334455",
+	}
+
+	policies := []palette.NormalizePolicy{
+		{},
+		{HexLength: palette.HexShort},
+		{HexLength: palette.HexShort, StripAlpha: true},
+		{HexLength: palette.HexShort, HexCase: palette.HexUpper, StripAlpha: true},
+		{HexLength: palette.HexLong, HexCase: palette.HexUpper},
+	}
+
+	for _, policy := range policies {
+		t.Run(fmt.Sprintf("%+v", policy), func(t *testing.T) {
+			theme := withDerivedColors(ColorTheme{Colors: policy.Apply(colors)})
+
+			if want := "rgba(aabbccff) rgba(668844ff) 135deg"; theme.HyprlandGradient != want {
+				t.Errorf("Expected Hyprland gradient %q, got %q", want, theme.HyprlandGradient)
+			}
+			if want := "linear-gradient(135deg, #aabbcc, #668844)"; theme.CSSGradient != want {
+				t.Errorf("Expected CSS gradient %q, got %q", want, theme.CSSGradient)
+			}
+			if theme.InactiveBorder != "#334455" {
+				t.Errorf("Expected inactive border #334455, got %q", theme.InactiveBorder)
+			}
+			if theme.Colors["notifications"] == nil || theme.Colors["lighting"] == nil {
+				t.Errorf("Expected notification and lighting colors, got %v", theme.Colors)
+			}
+
+			for name, marshal := range map[string]func(interface{}) ([]byte, error){"nvim": marshalNvim, "rofi": marshalRofi} {
+				if _, err := marshal(theme); err != nil {
+					t.Errorf("Expected format=%s to render, got %v", name, err)
+				}
+			}
+		})
+	}
+}
+
+// TestHandleDebugResponses tests listing and viewing saved debug responses
+func TestHandleDebugResponses(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DEBUG_AI_DIR", dir)
+	os.WriteFile(filepath.Join(dir, "2025-10-19_Title_abc.json"), []byte(`{"model": "test"}`), 0644)
+
+	w := httptest.NewRecorder()
+	handleDebugResponses(w, httptest.NewRequest("GET", "/admin/debug", nil))
+
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "2025-10-19_Title_abc.json") {
+		t.Errorf("Expected listing with the saved file, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	handleDebugResponses(w, httptest.NewRequest("GET", "/admin/debug/2025-10-19_Title_abc.json", nil))
+
+	if w.Code != http.StatusOK || w.Body.String() != `{"model": "test"}` {
+		t.Errorf("Expected saved response, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	handleDebugResponses(w, httptest.NewRequest("GET", "/admin/debug/missing.json", nil))
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
+
+// TestHandleRuntimeSettings tests reading and changing the log level and AI response capture
+func TestHandleRuntimeSettings(t *testing.T) {
+	t.Setenv("DEBUG_AI_RESPONSES", "false")
+	t.Cleanup(func() { setLogLevel(slog.LevelInfo) })
+	setLogLevel(slog.LevelInfo)
+
+	w := httptest.NewRecorder()
+	(&App{}).handleRuntimeSettings(w, httptest.NewRequest("POST", "/admin/runtime", strings.NewReader(`{"log_level": "DEBUG", "debug_ai_responses": true}`)))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var settings RuntimeSettings
+	json.NewDecoder(w.Body).Decode(&settings)
+	if settings.LogLevel != "debug" || !settings.DebugAIResponses || os.Getenv("DEBUG_AI_RESPONSES") != "true" {
+		t.Errorf("Expected debug settings, got %+v", settings)
+	}
+
+	// An invalid level must not apply the rest of the update
+	w = httptest.NewRecorder()
+	(&App{}).handleRuntimeSettings(w, httptest.NewRequest("POST", "/admin/runtime", strings.NewReader(`{"log_level": "loud", "debug_ai_responses": false}`)))
+
+	if w.Code != http.StatusBadRequest || os.Getenv("DEBUG_AI_RESPONSES") != "true" {
+		t.Errorf("Expected rejected update to change nothing, got %d", w.Code)
+	}
+}
+
+// TestToggleVerboseMode tests that SIGUSR1's toggle restores the previous settings
+func TestToggleVerboseMode(t *testing.T) {
+	t.Setenv("DEBUG_AI_RESPONSES", "")
+	t.Cleanup(func() { setLogLevel(slog.LevelInfo) })
+	setLogLevel(slog.LevelWarn)
+
+	if settings := toggleVerboseMode(); settings.LogLevel != "debug" || !settings.DebugAIResponses {
+		t.Errorf("Expected verbose settings, got %+v", settings)
+	}
+
+	if settings := toggleVerboseMode(); settings.LogLevel != "warn" || settings.DebugAIResponses {
+		t.Errorf("Expected previous settings restored, got %+v", settings)
+	}
+}
+
+// TestMetricsEndpoint tests that stage latencies from the pipeline are exposed
+func TestMetricsEndpoint(t *testing.T) {
+	app := newCachedTestApp(t)
+	if _, err := app.getColorTheme(defaultLocale, 0); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	metrics.Handler(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	if !strings.Contains(w.Body.String(), `dailyhues_stage_duration_seconds_count{stage="cache_lookup"}`) {
+		t.Errorf("Expected cache_lookup stage in metrics, got %s", w.Body.String())
+	}
+}
+
+// TestParseWarmRequest tests locale and day range parsing for cache warming
+func TestParseWarmRequest(t *testing.T) {
+	locales, from, to, err := parseWarmRequest("en-US, de-DE", "0-7")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(locales) != 2 || locales[1] != "de-DE" || from != 0 || to != 7 {
+		t.Errorf("Unexpected result: %v %d-%d", locales, from, to)
+	}
+
+	if _, from, to, _ := parseWarmRequest("", "3"); from != 3 || to != 3 {
+		t.Errorf("Expected single day 3, got %d-%d", from, to)
+	}
+
+	for _, days := range []string{"", "7-0", "0-8", "-1", "a-b"} {
+		if _, _, _, err := parseWarmRequest("", days); err == nil {
+			t.Errorf("Expected error for days %q", days)
+		}
+	}
+
+	if _, _, _, err := parseWarmRequest("xx-XX", "0"); err == nil {
+		t.Error("Expected error for invalid locale")
+	}
+}
+
+// TestHandleWarm tests that warming fills the cache and reports each day
+func TestHandleWarm(t *testing.T) {
+	app := newCachedTestApp(t)
+
+	w := httptest.NewRecorder()
+	app.handleWarm(w, httptest.NewRequest("POST", "/admin/warm?locales=en-US&days=0", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var results []WarmResult
+	json.NewDecoder(w.Body).Decode(&results)
+	if len(results) != 1 || results[0].Error != "" || results[0].StartDate == "" {
+		t.Errorf("Unexpected results: %+v", results)
+	}
+
+	w = httptest.NewRecorder()
+	app.handleWarm(w, httptest.NewRequest("GET", "/admin/warm", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", w.Code)
+	}
+}
+
+// TestValidateLocale_Markets tests canonicalization and fallback to Bing's market table
+func TestValidateLocale_Markets(t *testing.T) {
+	tests := map[string]string{
+		"ROW":   "ROW",
+		"en-us": "en-US",
+		"de":    "de-DE",
+		"fr-LU": "fr-FR",
+	}
+
+	for input, want := range tests {
+		if got, err := validateLocale(input); err != nil || got != want {
+			t.Errorf("validateLocale(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+}
+
+// TestLoadAllowedLocales tests that configured locales are canonicalized and unknown ones dropped
+func TestLoadAllowedLocales(t *testing.T) {
+	t.Cleanup(func() {
+		os.Unsetenv("ALLOWED_LOCALES")
+		loadAllowedLocales()
+	})
+	t.Setenv("ALLOWED_LOCALES", "en-us,de,ROW")
+	loadAllowedLocales()
+
+	if strings.Join(allowedLocales, ",") != "en-US,ROW" {
+		t.Errorf("Expected en-US and ROW, got %v", allowedLocales)
+	}
+
+	if _, err := validateLocale("de-DE"); err == nil {
+		t.Error("Expected locale outside ALLOWED_LOCALES to be rejected")
+	}
+}
+
+// TestLoadResizeCheck tests the resize consistency check settings
+func TestLoadResizeCheck(t *testing.T) {
+	t.Setenv("RESIZE_CHECK", "true")
+	t.Setenv("RESIZE_CHECK_THRESHOLD", "0.35")
+
+	if enabled, threshold := loadResizeCheck(); !enabled || threshold != 0.35 {
+		t.Errorf("Expected enabled with threshold 0.35, got %v %v", enabled, threshold)
+	}
+
+	t.Setenv("RESIZE_CHECK", "")
+	t.Setenv("RESIZE_CHECK_THRESHOLD", "2")
+
+	if enabled, threshold := loadResizeCheck(); enabled || threshold != defaultResizeCheckThreshold {
+		t.Errorf("Expected disabled with default threshold, got %v %v", enabled, threshold)
+	}
+}
+
+// TestHandleGetColors_LockscreenProfile tests that ?profile=lockscreen returns the lock screen palette
+func TestHandleGetColors_LockscreenProfile(t *testing.T) {
+	app := newCachedTestApp(t)
+
+	w := httptest.NewRecorder()
+	app.handleGetColors(w, httptest.NewRequest("GET", "/api/colors?profile=lockscreen", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var theme ColorTheme
+	json.NewDecoder(w.Body).Decode(&theme)
+	for _, key := range []string{"background_tint", "background_opacity", "clock", "input_inner", "input_outer", "input_text", "input_check", "input_fail"} {
+		if _, ok := theme.Colors[key]; !ok {
+			t.Errorf("Expected %s in lockscreen colors, got %v", key, theme.Colors)
+		}
+	}
+	if theme.Colors["input_outer"] != "#c67d3a" {
+		t.Errorf("Expected input_outer to be the gradient start, got %v", theme.Colors["input_outer"])
+	}
+
+	w = httptest.NewRecorder()
+	app.handleGetColors(w, httptest.NewRequest("GET", "/api/colors?profile=poster", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for unknown profile, got %d", w.Code)
+	}
+}
+
+// TestImageRegions tests region averages computed from an encoded wallpaper
+func TestImageRegions(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 40, 40))
+	for y := 0; y < 40; y++ {
+		for x := 0; x < 40; x++ {
+			img.Set(x, y, color.RGBA{R: 20, G: 20, B: 20, A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	jpeg.Encode(&buf, img, &jpeg.Options{Quality: 100})
+
+	regions, err := imageRegions(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, key := range []string{"top", "bottom", "center"} {
+		if !strings.HasPrefix(regions[key], "#1") {
+			t.Errorf("Expected a dark %s color, got %q", key, regions[key])
+		}
+	}
+
+	if _, err := imageRegions([]byte("not an image")); err == nil {
+		t.Error("Expected error for invalid image data")
+	}
+}
+
+// TestReanalyzeStale tests that re-analysis respects the budget and skips images without metadata
+func TestReanalyzeStale(t *testing.T) {
+	app := newCachedTestApp(t)
+	now := time.Now()
+	app.analysisCache.SetEntry(cache.AnalysisEntry{ImageHash: "orphan", AnalyzedAt: now.AddDate(0, -6, 0)})
+
+	if attempted := app.reanalyzeStale(now, 90*24*time.Hour, 0); attempted != 0 {
+		t.Errorf("Expected no attempts without budget, got %d", attempted)
+	}
+
+	// The fixture entry is fresh, so only the orphan is stale; it fails without touching Bing or the AI
+	if attempted := app.reanalyzeStale(now, 90*24*time.Hour, 5); attempted != 1 {
+		t.Errorf("Expected one attempt, got %d", attempted)
+	}
+	entry := app.analysisCache.Get("orphan")
+	if !entry.AnalyzedAt.Before(now.AddDate(0, -5, 0)) {
+		t.Error("Expected failed re-analysis to leave the analysis time unchanged")
+	}
+	if entry.Reanalysis == nil || entry.Reanalysis.Failures != 1 || !entry.Reanalysis.At.Equal(now) {
+		t.Errorf("Expected the failed attempt to be recorded, got %+v", entry.Reanalysis)
+	}
+
+	// Failed entries wait a day, then twice as long after each further failure
+	tests := []struct {
+		at       time.Time
+		attempts int
+	}{
+		{now.Add(time.Hour), 0},
+		{now.Add(25 * time.Hour), 1},
+		{now.Add(50 * time.Hour), 0},
+		{now.Add(74 * time.Hour), 1},
+	}
+	for _, tt := range tests {
+		if attempted := app.reanalyzeStale(tt.at, 90*24*time.Hour, 5); attempted != tt.attempts {
+			t.Errorf("Expected %d attempts at %v, got %d", tt.attempts, tt.at.Sub(now), attempted)
+		}
+	}
+	if entry := app.analysisCache.Get("orphan"); entry.Reanalysis.Failures != 3 {
+		t.Errorf("Expected 3 recorded failures, got %+v", entry.Reanalysis)
+	}
+}
+
+// TestReanalysisBackoff tests that the delay after failed re-analyses doubles up to its cap
+func TestReanalysisBackoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{1, 24 * time.Hour},
+		{2, 48 * time.Hour},
+		{5, 16 * 24 * time.Hour},
+		{6, reanalysisMaxRetryDelay},
+		{100, reanalysisMaxRetryDelay},
+	}
+	for _, tt := range tests {
+		if got := reanalysisBackoff(tt.failures); got != tt.want {
+			t.Errorf("Expected %v after %d failures, got %v", tt.want, tt.failures, got)
+		}
+	}
+}
+
+// TestFailedUsage tests that billed failures are added to the next successful analysis of the same key, once
+func TestFailedUsage(t *testing.T) {
+	app := &App{}
+	app.recordFailedUsage("hash", ai.Usage{})
+	app.recordFailedUsage("hash", ai.Usage{Model: "failed/model", PromptTokens: 100, CompletionTokens: 10, Cost: 0.5})
+	app.recordFailedUsage("hash", ai.Usage{Model: "failed/model", PromptTokens: 200, Cost: 1})
+
+	success := ai.Usage{Model: "current/model", PromptTokens: 50, CompletionTokens: 5, Cost: 0.25}
+	want := ai.Usage{Model: "current/model", PromptTokens: 350, CompletionTokens: 15, Cost: 1.75}
+	if got := app.failedUsage.take("hash", success); got != want {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+	if got := app.failedUsage.take("hash", success); got != success {
+		t.Errorf("Expected the failed usage to be added only once, got %+v", got)
+	}
+	if got := app.failedUsage.take("other", success); got != success {
+		t.Errorf("Expected no usage from other keys, got %+v", got)
+	}
+}
+
+// TestLoadReanalysisConfig tests parsing of the re-analysis settings
+func TestLoadReanalysisConfig(t *testing.T) {
+	t.Setenv("ANALYSIS_MAX_AGE", "2160h")
+	t.Setenv("REANALYSIS_DAILY_BUDGET", "3")
+
+	if maxAge, budget := loadReanalysisConfig(); maxAge != 90*24*time.Hour || budget != 3 {
+		t.Errorf("Expected 2160h and 3, got %v and %d", maxAge, budget)
+	}
+
+	t.Setenv("ANALYSIS_MAX_AGE", "")
+	t.Setenv("REANALYSIS_DAILY_BUDGET", "lots")
+
+	if maxAge, budget := loadReanalysisConfig(); maxAge != 0 || budget != defaultReanalysisDailyBudget {
+		t.Errorf("Expected disabled with default budget, got %v and %d", maxAge, budget)
+	}
+}
+
+// TestHandleGetColors_PrettyAndJSONP tests indented JSON and the flag-gated JSONP wrapper
+func TestHandleGetColors_PrettyAndJSONP(t *testing.T) {
+	app := newCachedTestApp(t)
+
+	w := httptest.NewRecorder()
+	app.handleGetColors(w, httptest.NewRequest("GET", "/api/colors?pretty=true", nil))
+	if !strings.Contains(w.Body.String(), "\n  \"startdate\": ") {
+		t.Errorf("Expected indented JSON, got %s", w.Body.String())
+	}
+
+	t.Setenv("ENABLE_JSONP", "")
+	w = httptest.NewRecorder()
+	app.handleGetColors(w, httptest.NewRequest("GET", "/api/colors?callback=onPalette", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 with JSONP disabled, got %d", w.Code)
+	}
+
+	t.Setenv("ENABLE_JSONP", "true")
+	w = httptest.NewRecorder()
+	app.handleGetColors(w, httptest.NewRequest("GET", "/api/colors?callback=kiosk.onPalette", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/javascript" {
+		t.Fatalf("Expected JavaScript response, got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	if body := w.Body.String(); !strings.HasPrefix(body, "/**/kiosk.onPalette({") || !strings.HasSuffix(body, "});\n") {
+		t.Errorf("Unexpected JSONP body: %s", body)
+	}
+
+	for _, query := range []string{"callback=alert(1)", "callback=cb&format=yaml"} {
+		w = httptest.NewRecorder()
+		app.handleGetColors(w, httptest.NewRequest("GET", "/api/colors?"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", query, w.Code)
+		}
+	}
+}
+
+// TestHandleLandingPage_Branding tests that the instance name, base URL and contact link are rendered
+func TestHandleLandingPage_Branding(t *testing.T) {
+	t.Setenv("INSTANCE_NAME", "")
+	t.Setenv("BASE_URL", "")
+	t.Setenv("CONTACT_URL", "")
+
+	w := httptest.NewRecorder()
+	handleLandingPage(w, httptest.NewRequest("GET", "http://hues.example.org/", nil))
+	body := w.Body.String()
+	if !strings.Contains(body, "<h1>dailyhues</h1>") || !strings.Contains(body, `content="http://hues.example.org/"`) {
+		t.Errorf("Expected default name and request URL, got:\n%s", body)
+	}
+	if strings.Contains(body, "Contact") {
+		t.Error("Expected no contact link without CONTACT_URL")
+	}
+
+	t.Setenv("INSTANCE_NAME", "Office Hues")
+	t.Setenv("BASE_URL", "https://hues.example.com/")
+	t.Setenv("CONTACT_URL", "mailto:ops@example.com")
+
+	w = httptest.NewRecorder()
+	handleLandingPage(w, httptest.NewRequest("GET", "/", nil))
+	body = w.Body.String()
+	for _, want := range []string{"<h1>Office Hues</h1>", `content="Office Hues"`, "https://hues.example.com/api/colors", `href="mailto:ops@example.com"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %q in landing page", want)
+		}
+	}
+}
+
+// TestPickDefaultLocale tests that DEFAULT_LOCALE must be allowed
+func TestPickDefaultLocale(t *testing.T) {
+	tests := []struct {
+		configured string
+		allowed    []string
+		want       string
+	}{
+		{"", []string{"en-US", "de-DE"}, "en-US"},
+		{"de-de", []string{"en-US", "de-DE"}, "de-DE"},
+		{"ja-JP", []string{"en-US", "de-DE"}, "en-US"},
+		{"", []string{"fr-FR", "de-DE"}, "fr-FR"},
+	}
+
+	for _, tt := range tests {
+		if got := pickDefaultLocale(tt.configured, tt.allowed); got != tt.want {
+			t.Errorf("pickDefaultLocale(%q, %v) = %s, want %s", tt.configured, tt.allowed, got, tt.want)
+		}
+	}
+}
+
+// TestHandleConfigSummary tests that the configuration summary reports settings without leaking secrets
+func TestHandleConfigSummary(t *testing.T) {
+	t.Setenv("OPENROUTER_API_KEY", "sk-secret-value")
+	t.Setenv("ADMIN_API_KEYS", "")
+	t.Setenv("ENABLE_JSONP", "true")
+
+	app := newCachedTestApp(t)
+	app.authenticator = auth.NewAuthenticator(auth.Config{})
+
+	w := httptest.NewRecorder()
+	app.handleConfigSummary(w, httptest.NewRequest("GET", "/admin/config", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if strings.Contains(w.Body.String(), "sk-secret-value") {
+		t.Fatal("Expected the API key to be redacted")
+	}
+
+	var summary ConfigSummary
+	json.NewDecoder(w.Body).Decode(&summary)
+	if !summary.Secrets["OPENROUTER_API_KEY"] || summary.Secrets["ADMIN_API_KEYS"] {
+		t.Errorf("Unexpected secrets summary: %v", summary.Secrets)
+	}
+	if summary.CachedRequests != 1 || summary.CachedAnalyses != 1 || !summary.Features.JSONP || summary.Model == "" {
+		t.Errorf("Unexpected summary: %+v", summary)
+	}
+}
+
+// TestHandleImageInfo tests reading metadata of each resolution of a cached wallpaper with range requests
+func TestHandleImageInfo(t *testing.T) {
+	var buf bytes.Buffer
+	jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 320, 180)), &jpeg.Options{Quality: 85})
+	imageData := buf.Bytes()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing.jpg" {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeContent(w, r, "wallpaper.jpg", time.Time{}, bytes.NewReader(imageData))
+	}))
+	defer server.Close()
+
+	app := newCachedTestApp(t)
+	startDate, fullStartDate, endDate := testWallpaperDates(0)
+	app.requestCache.Set(defaultLocale, 0, "imageinfo", map[string]string{
+		"320x180":   server.URL + "/imageinfo.jpg",
+		"1920x1080": server.URL + "/missing.jpg",
+	}, "Title", "Copyright", "", startDate, fullStartDate, endDate, time.Now().Add(time.Hour))
+
+	w := httptest.NewRecorder()
+	app.handleImageInfo(w, httptest.NewRequest("GET", "/api/image-info", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var response ImageInfoResponse
+	json.NewDecoder(w.Body).Decode(&response)
+
+	small := response.Images["320x180"]
+	if small.Width != 320 || small.Height != 180 || small.FileSize != int64(len(imageData)) || small.Quality != 85 {
+		t.Errorf("Unexpected image info: %+v", small)
+	}
+	if response.Images["1920x1080"].Error == "" {
+		t.Error("Expected an error for the missing resolution")
+	}
+}
+
+// TestHandlePins tests pinning a palette by date, serving it, and restoring the AI result
+func TestHandlePins(t *testing.T) {
+	app := newCachedTestApp(t)
+	startDate, _, _ := testWallpaperDates(0)
+	date := startDate[:4] + "-" + startDate[4:6] + "-" + startDate[6:]
+
+	getColors := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		app.handleGetColors(w, httptest.NewRequest("GET", "/api/colors", nil))
+		return w
+	}
+	originalETag := getColors().Header().Get("ETag")
+
+	body := `{"date": "` + date + `", "colors": {"gradient_from": "#112233", "gradient_to": "#445566", "gradient_angle": 90}, "reason": "muddy gradient"}`
+	req := httptest.NewRequest("POST", "/admin/pins", strings.NewReader(body))
+	req = req.WithContext(auth.WithSubject(req.Context(), "operator"))
+	w := httptest.NewRecorder()
+	app.handlePins(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = getColors()
+	var theme ColorTheme
+	json.NewDecoder(w.Body).Decode(&theme)
+	if theme.Colors["gradient_from"] != "#112233" || !theme.Pinned {
+		t.Errorf("Expected pinned colors, got %v (pinned %v)", theme.Colors, theme.Pinned)
+	}
+	if w.Header().Get("ETag") == originalETag {
+		t.Error("Expected pinning to change the ETag")
+	}
+
+	w = httptest.NewRecorder()
+	app.handlePins(w, httptest.NewRequest("GET", "/admin/pins", nil))
+	if !strings.Contains(w.Body.String(), `"by":"operator"`) || !strings.Contains(w.Body.String(), `"reason":"muddy gradient"`) {
+		t.Errorf("Expected pin provenance in listing, got %s", w.Body.String())
+	}
+
+	// Pinned entries are not re-analyzed
+	if stale := app.analysisCache.AnalyzedBefore(time.Now().Add(time.Hour)); len(stale) != 0 {
+		t.Errorf("Expected pinned entry to be skipped by re-analysis, got %d", len(stale))
+	}
+
+	w = httptest.NewRecorder()
+	app.handlePins(w, httptest.NewRequest("DELETE", "/admin/pins?date="+date, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = getColors()
+	theme = ColorTheme{}
+	json.NewDecoder(w.Body).Decode(&theme)
+	if theme.Colors["gradient_from"] != "#c67d3a" || theme.Pinned || w.Header().Get("ETag") != originalETag {
+		t.Errorf("Expected the AI palette to be restored, got %v", theme.Colors)
+	}
+}
+
+// TestHandlePins_InvalidRequests tests validation of pin targets and colors
+func TestHandlePins_InvalidRequests(t *testing.T) {
+	app := newCachedTestApp(t)
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"no target", `{"colors": {"gradient_from": "#112233", "gradient_to": "#445566", "gradient_angle": 90}}`},
+		{"path in hash", `{"image_hash": "../../etc/passwd", "colors": {"gradient_from": "#112233", "gradient_to": "#445566", "gradient_angle": 90}}`},
+		{"uncached date", `{"date": "2001-01-01", "colors": {"gradient_from": "#112233", "gradient_to": "#445566", "gradient_angle": 90}}`},
+		{"bad color", `{"image_hash": "` + strings.Repeat("ab", 32) + `", "colors": {"gradient_from": "red", "gradient_to": "#445566", "gradient_angle": 90}}`},
+		{"missing angle", `{"image_hash": "` + strings.Repeat("ab", 32) + `", "colors": {"gradient_from": "#112233", "gradient_to": "#445566"}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			app.handlePins(w, httptest.NewRequest("POST", "/admin/pins", strings.NewReader(tt.body)))
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("Expected status 400, got %d", w.Code)
+			}
+		})
+	}
+}
+
+// TestLoadPromptAddendum tests combining source and locale prompt addenda
+func TestLoadPromptAddendum(t *testing.T) {
+	t.Setenv("PROMPT_ADDENDUM_SOURCE_BING", "Avoid pure grays.")
+	t.Setenv("PROMPT_ADDENDUM_LOCALE_JA_JP", "Prefer cooler tones.")
+
+	if got := loadPromptAddendum(sourceBing, "ja-JP"); got != "Avoid pure grays.\nPrefer cooler tones." {
+		t.Errorf("Expected combined addendum, got %q", got)
+	}
+	if got := loadPromptAddendum(sourceBing, "en-US"); got != "Avoid pure grays." {
+		t.Errorf("Expected source addendum only, got %q", got)
+	}
+
+	t.Setenv("PROMPT_ADDENDUM_SOURCE_BING", "")
+	if got := loadPromptAddendum(sourceBing, "en-US"); got != "" {
+		t.Errorf("Expected no addendum, got %q", got)
+	}
+}
+
+// TestBuildDigest tests waiting for the rollover and the deadline after which the digest is sent anyway
+func TestBuildDigest(t *testing.T) {
+	app := newCachedTestApp(t)
+	startDate, _, _ := testWallpaperDates(0)
+	morning := time.Now().UTC().Truncate(24 * time.Hour).Add(6 * time.Hour)
+
+	digest, ready := app.buildDigest([]string{defaultLocale}, startDate, morning)
+	if !ready || len(digest.Locales) != 1 || digest.Locales[0].Colors["gradient_from"] != "#c67d3a" {
+		t.Fatalf("Expected a ready digest with the cached palette, got %+v (ready %v)", digest, ready)
+	}
+
+	// The cached wallpaper is a day behind, so the digest waits until the deadline
+	nextDay := cache.StartTime(startDate, "").AddDate(0, 0, 1).Format("20060102")
+	if _, ready := app.buildDigest([]string{defaultLocale}, nextDay, morning); ready {
+		t.Error("Expected the digest to wait for the rollover")
+	}
+
+	afternoon := morning.Add(8 * time.Hour)
+	digest, ready = app.buildDigest([]string{defaultLocale}, nextDay, afternoon)
+	if !ready || digest.Locales[0].Error == "" {
+		t.Errorf("Expected the digest to be sent with an error after the deadline, got %+v", digest)
+	}
+}
+
+// TestSendDigest tests delivering one digest to a webhook and a Discord webhook
+func TestSendDigest(t *testing.T) {
+	bodies := make(map[string]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies[r.URL.Path] = string(body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	app := newCachedTestApp(t)
+	startDate, _, _ := testWallpaperDates(0)
+	digest, _ := app.buildDigest([]string{defaultLocale}, startDate, time.Now())
+
+	targets := digestTargets{Webhook: server.URL + "/hook", Discord: server.URL + "/discord"}
+	if !sendDigest(digest, targets) {
+		t.Fatal("Expected the digest to be delivered")
+	}
+
+	if !strings.Contains(bodies["/hook"], `"date":"`+startDate+`"`) || !strings.Contains(bodies["/hook"], `"locale":"`+defaultLocale+`"`) {
+		t.Errorf("Unexpected webhook body: %s", bodies["/hook"])
+	}
+
+	var message struct {
+		Content string `json:"content"`
+		Embeds  []struct {
+			Color int `json:"color"`
+		} `json:"embeds"`
+	}
+	json.Unmarshal([]byte(bodies["/discord"]), &message)
+	if len(message.Embeds) != 1 || message.Embeds[0].Color != 0xc67d3a || !strings.HasPrefix(message.Content, "Daily wallpapers for ") {
+		t.Errorf("Unexpected Discord message: %s", bodies["/discord"])
+	}
+}
+
+// TestMigrateCache tests copying a file cache to a new directory without losing analysis metadata
+func TestMigrateCache(t *testing.T) {
+	fromDir, toDir := t.TempDir(), t.TempDir()
+
+	requestCache, _ := cache.NewRequestCache(fromDir)
+	analysisCache, _ := cache.NewAnalysisCache(fromDir)
+	analyzedAt := time.Date(2025, 10, 19, 8, 0, 0, 0, time.UTC)
+	analysisCache.SetEntry(cache.AnalysisEntry{ImageHash: "abc", Colors: map[string]interface{}{"gradient_from": "#c67d3a"}, Model: "test-model", AnalyzedAt: analyzedAt})
+	requestCache.Set("en-US", 0, "abc", nil, "Title", "Copyright", "", "20251019", "202510190700", "20251020", time.Now())
+
+	result, err := migrateCache(fromDir, toDir, false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Requests != 1 || result.Analyses != 1 {
+		t.Errorf("Expected one request and one analysis copied, got %+v", result)
+	}
+
+	migrated, _ := cache.NewAnalysisCache(toDir)
+	migrated.LoadAll()
+	if entry := migrated.Get("abc"); entry == nil || entry.Model != "test-model" || !entry.AnalyzedAt.Equal(analyzedAt) {
+		t.Errorf("Expected analysis metadata to be preserved, got %+v", entry)
+	}
+
+	// A second run finds everything in place
+	if result, _ := migrateCache(fromDir, toDir, false); result.SkippedRequests != 1 || result.SkippedAnalyses != 1 {
+		t.Errorf("Expected existing entries to be skipped, got %+v", result)
+	}
+}
+
+// TestParseCacheBackend tests cache location parsing and rejection of unavailable backends
+func TestParseCacheBackend(t *testing.T) {
+	for location, want := range map[string]string{"file://cache_data": "cache_data", "file:///var/lib/dailyhues": "/var/lib/dailyhues", "cache_data": "cache_data"} {
+		if dir, err := parseCacheBackend(location); err != nil || dir != want {
+			t.Errorf("Expected %s for %s, got %s (%v)", want, location, dir, err)
+		}
+	}
+
+	for _, location := range []string{"", "redis://localhost:6379", "s3://bucket/prefix", "sqlite://cache.db"} {
+		if _, err := parseCacheBackend(location); err == nil {
+			t.Errorf("Expected error for %q", location)
+		}
+	}
+}
+
+// TestHandleAudit tests that admin mutations are recorded and can be filtered
+func TestHandleAudit(t *testing.T) {
+	app := newCachedTestApp(t)
+	auditLog, err := audit.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open audit log: %v", err)
+	}
+	app.auditLog = auditLog
+
+	hash := strings.Repeat("ab", 32)
+	body := `{"image_hash": "` + hash + `", "colors": {"gradient_from": "#112233", "gradient_to": "#445566", "gradient_angle": 90}, "reason": "test"}`
+	req := httptest.NewRequest("POST", "/admin/pins", strings.NewReader(body))
+	req = req.WithContext(auth.WithSubject(req.Context(), "alice"))
+	app.handlePins(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest("DELETE", "/admin/pins?image_hash="+hash, nil)
+	req = req.WithContext(auth.WithSubject(req.Context(), "bob"))
+	app.handlePins(httptest.NewRecorder(), req)
+
+	w := httptest.NewRecorder()
+	app.handleAudit(w, httptest.NewRequest("GET", "/admin/audit?key="+hash, nil))
+	var response struct {
+		Events []audit.Event `json:"events"`
+	}
+	json.NewDecoder(w.Body).Decode(&response)
+	if len(response.Events) != 2 {
+		t.Fatalf("Expected 2 events, got %+v", response.Events)
+	}
+	if response.Events[0].Action != "pin.remove" || response.Events[0].Actor != "bob" || response.Events[0].Details["analysis"] != "deleted" {
+		t.Errorf("Expected newest event to be bob's pin removal, got %+v", response.Events[0])
+	}
+	if response.Events[1].Action != "pin.set" || response.Events[1].Actor != "alice" {
+		t.Errorf("Expected alice's pin first, got %+v", response.Events[1])
+	}
+
+	w = httptest.NewRecorder()
+	app.handleAudit(w, httptest.NewRequest("GET", "/admin/audit?actor=alice", nil))
+	if strings.Contains(w.Body.String(), "bob") {
+		t.Errorf("Expected only alice's events, got %s", w.Body.String())
+	}
+
+	for _, query := range []string{"since=yesterday", "limit=0", "limit=abc"} {
+		w = httptest.NewRecorder()
+		app.handleAudit(w, httptest.NewRequest("GET", "/admin/audit?"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", query, w.Code)
+		}
+	}
+}
+
+// TestLoadRouteLimits tests per-route overrides on top of the built-in limits
+func TestLoadRouteLimits(t *testing.T) {
+	t.Setenv("ROUTE_WRITE_TIMEOUTS", "/api/colors=5m, *=45s, /health=oops")
+	t.Setenv("ROUTE_READ_TIMEOUTS", "/admin/debug/=2s")
+	t.Setenv("ROUTE_MAX_RESPONSE_BYTES", "/api/history.csv=1000")
+	loadRouteLimits()
+	t.Cleanup(func() {
+		os.Unsetenv("ROUTE_WRITE_TIMEOUTS")
+		os.Unsetenv("ROUTE_READ_TIMEOUTS")
+		os.Unsetenv("ROUTE_MAX_RESPONSE_BYTES")
+		loadRouteLimits()
+	})
+
+	tests := []struct {
+		path string
+		want routeLimits
+	}{
+		{"/api/colors", routeLimits{ReadTimeout: 15 * time.Second, WriteTimeout: 5 * time.Minute}},
+		{"/health", builtinRouteLimits["/health"]},
+		{"/api/history", routeLimits{ReadTimeout: 15 * time.Second, WriteTimeout: 45 * time.Second}},
+		{"/api/history.csv", routeLimits{ReadTimeout: 15 * time.Second, WriteTimeout: 45 * time.Second, MaxResponseBytes: 1000}},
+		{"/admin/debug/response.json", routeLimits{ReadTimeout: 2 * time.Second, WriteTimeout: 45 * time.Second}},
+	}
+
+	for _, tt := range tests {
+		if got := limitsForPath(tt.path); got != tt.want {
+			t.Errorf("limitsForPath(%q) = %+v, want %+v", tt.path, got, tt.want)
+		}
+	}
+}
+
+// TestWithRouteLimits_MaxResponseBytes tests that oversized responses are aborted
+func TestWithRouteLimits_MaxResponseBytes(t *testing.T) {
+	t.Setenv("ROUTE_MAX_RESPONSE_BYTES", "/big=10")
+	loadRouteLimits()
+	t.Cleanup(func() {
+		os.Unsetenv("ROUTE_MAX_RESPONSE_BYTES")
+		loadRouteLimits()
+	})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/big", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("x", 100)))
+	})
+	mux.HandleFunc("/small", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("x", 100)))
+	})
+	server := httptest.NewServer(withRouteLimits(mux))
+	defer server.Close()
+
+	if resp, err := http.Get(server.URL + "/big"); err == nil {
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err == nil && len(body) == 100 {
+			t.Error("Expected oversized response to be aborted")
+		}
+	}
+
+	resp, err := http.Get(server.URL + "/small")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if len(body) != 100 {
+		t.Errorf("Expected full response for unlimited route, got %d bytes", len(body))
+	}
+}
+
+// TestWithRouteLimits_ReadTimeout tests that handlers outlive their route's read timeout once the request is read
+// The read deadline must not be left on the connection, where it would cancel the request's context
+func TestWithRouteLimits_ReadTimeout(t *testing.T) {
+	t.Setenv("ROUTE_READ_TIMEOUTS", "/api/events=50ms")
+	loadRouteLimits()
+	t.Cleanup(func() {
+		os.Unsetenv("ROUTE_READ_TIMEOUTS")
+		loadRouteLimits()
+	})
+
+	app := newCachedTestApp(t)
+	server := httptest.NewServer(app.handler())
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/api/events", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	reader := bufio.NewReader(resp.Body)
+	readEvent(t, reader) // Retry hint
+	current := readEvent(t, reader)
+	if !strings.Contains(current, "event: palette\n") {
+		t.Fatalf("Expected the current palette, got %q", current)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	app.changes.observe(defaultLocale, ColorTheme{StartDate: "99991231", imageHash: strings.Repeat("b", 64) + "-0123456789ab"})
+
+	// A handler whose context was cancelled at the read timeout has returned and ended the stream
+	if event := readEvent(t, reader); !strings.HasPrefix(event, "event: "+paletteChangedEvent+"\n") {
+		t.Errorf("Expected a palette change after the read timeout, got %q", event)
+	}
+}
+
+// readEvent reads one server-sent event (or comment) block from the stream
+func readEvent(t *testing.T, reader *bufio.Reader) string {
+	t.Helper()
+	var lines []string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Failed to read event: %v", err)
+		}
+		if line == "\n" {
+			return strings.Join(lines, "")
+		}
+		lines = append(lines, line)
+	}
+}
+
+// TestHandleEvents tests palette events and resuming from Last-Event-ID
+func TestHandleEvents(t *testing.T) {
+	app := newCachedTestApp(t)
+	_, fullStartDate, _ := testWallpaperDates(0)
+	server := httptest.NewServer(app.handler())
+	defer server.Close()
+
+	tests := []struct {
+		name        string
+		lastEventID string
+		wantPalette bool
+	}{
+		{"new client", "", true},
+		{"missed palette", "200001010000", true},
+		{"up to date", fullStartDate, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/api/events", nil)
+			if tt.lastEventID != "" {
+				req.Header.Set("Last-Event-ID", tt.lastEventID)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+				t.Errorf("Expected text/event-stream, got %q", ct)
+			}
+
+			reader := bufio.NewReader(resp.Body)
+			if event := readEvent(t, reader); !strings.HasPrefix(event, "retry: ") {
+				t.Errorf("Expected retry hint first, got %q", event)
+			}
+			if !tt.wantPalette {
+				// Nothing else is sent until the next check
+				time.AfterFunc(200*time.Millisecond, cancel)
+				if line, err := reader.ReadString('\n'); err == nil {
+					t.Errorf("Expected no event for an up to date client, got %q", line)
+				}
+				return
+			}
+
+			event := readEvent(t, reader)
+			if !strings.HasPrefix(event, "id: "+fullStartDate+"\nevent: palette\ndata: ") || !strings.Contains(event, "#c67d3a") {
+				t.Errorf("Expected palette event with id %s, got %q", fullStartDate, event)
+			}
+		})
+	}
+}
+
+// TestHandleEvents_ReadTimeout tests that streams have no read deadline, even when the default read timeout is short,
+// so a resumed stream keeps delivering changes through the full middleware chain
+func TestHandleEvents_ReadTimeout(t *testing.T) {
+	t.Setenv("ROUTE_READ_TIMEOUTS", "*=50ms")
+	loadRouteLimits()
+	t.Cleanup(func() {
+		os.Unsetenv("ROUTE_READ_TIMEOUTS")
+		loadRouteLimits()
+	})
+	if limits := limitsForPath("/api/events"); limits.ReadTimeout != 0 {
+		t.Fatalf("Expected no read timeout for /api/events, got %s", limits.ReadTimeout)
+	}
+
+	app := newCachedTestApp(t)
+	_, fullStartDate, _ := testWallpaperDates(0)
+	server := httptest.NewServer(app.handler())
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/api/events", nil)
+	req.Header.Set("Last-Event-ID", "200001010000")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	reader := bufio.NewReader(resp.Body)
+	readEvent(t, reader) // Retry hint
+	if event := readEvent(t, reader); !strings.HasPrefix(event, "id: "+fullStartDate+"\nevent: palette\n") {
+		t.Fatalf("Expected the missed palette, got %q", event)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	app.changes.observe(defaultLocale, ColorTheme{StartDate: "99991231", imageHash: strings.Repeat("b", 64) + "-0123456789ab"})
+	if event := readEvent(t, reader); !strings.HasPrefix(event, "event: "+paletteChangedEvent+"\n") {
+		t.Errorf("Expected a palette change after the default read timeout, got %q", event)
+	}
+}
+
+// TestSetCacheHeaders tests that palettes are cached until their next update, capped by CACHE_MAX_AGE
+func TestSetCacheHeaders(t *testing.T) {
+	now := time.Date(2025, 10, 19, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		maxAge     string
+		nextUpdate time.Time
+		want       string
+	}{
+		{"capped", "", now.Add(5 * time.Hour), "max-age=3600, stale-while-revalidate=60, stale-if-error=86400"},
+		{"until update", "", now.Add(10 * time.Minute), "max-age=600, stale-while-revalidate=60, stale-if-error=86400"},
+		{"overdue", "", now.Add(-time.Minute), "max-age=0, stale-while-revalidate=60, stale-if-error=86400"},
+		{"configured", "24h", time.Time{}, "max-age=86400, stale-while-revalidate=60, stale-if-error=86400"},
+		{"invalid", "soon", time.Time{}, "max-age=3600, stale-while-revalidate=60, stale-if-error=86400"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CACHE_MAX_AGE", tt.maxAge)
+			w := httptest.NewRecorder()
+			setCacheHeaders(w, tt.nextUpdate, now)
+
+			if got := w.Header().Get("Surrogate-Control"); got != tt.want {
+				t.Errorf("Surrogate-Control = %q, want %q", got, tt.want)
+			}
+			if got := w.Header().Get("Cache-Control"); got != "public, "+tt.want {
+				t.Errorf("Cache-Control = %q, want %q", got, "public, "+tt.want)
+			}
+		})
+	}
+}
+
+// TestHandleGetColors_CacheHeaders tests that cached palettes and 304s carry caching directives
+func TestHandleGetColors_CacheHeaders(t *testing.T) {
+	app := newCachedTestApp(t)
+
+	w := httptest.NewRecorder()
+	app.handleGetColors(w, httptest.NewRequest("GET", "/api/colors", nil))
+	if !strings.Contains(w.Header().Get("Cache-Control"), "stale-while-revalidate=") || w.Header().Get("CDN-Cache-Control") == "" {
+		t.Errorf("Expected CDN caching directives, got %v", w.Header())
+	}
+
+	req := httptest.NewRequest("GET", "/api/colors", nil)
+	req.Header.Set("If-None-Match", w.Header().Get("ETag"))
+	w = httptest.NewRecorder()
+	app.handleGetColors(w, req)
+	if w.Code != http.StatusNotModified || w.Header().Get("Surrogate-Control") == "" {
+		t.Errorf("Expected 304 with Surrogate-Control, got %d %v", w.Code, w.Header())
+	}
+}
+
+// TestWithCanonicalQuery tests redirecting API requests to a sorted query without tracking parameters
+func TestWithCanonicalQuery(t *testing.T) {
+	handler := withCanonicalQuery(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name      string
+		normalize string
+		target    string
+		location  string
+	}{
+		{"disabled", "", "/api/colors?locale=de-DE&daysAgo=1", ""},
+		{"canonical", "true", "/api/colors?daysAgo=1&locale=de-DE", ""},
+		{"unsorted", "true", "/api/colors?locale=de-DE&daysAgo=1", "/api/colors?daysAgo=1&locale=de-DE"},
+		{"tracking and empty", "true", "/api/colors?utm_source=x&locale=&fbclid=y", "/api/colors"},
+		{"not api", "true", "/admin/pins?locale=de-DE&date=2025-10-19", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CACHE_KEY_NORMALIZE", tt.normalize)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("GET", tt.target, nil))
+
+			if tt.location == "" {
+				if w.Code != http.StatusOK {
+					t.Errorf("Expected request to pass through, got %d", w.Code)
+				}
+				return
+			}
+			if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != tt.location {
+				t.Errorf("Expected redirect to %s, got %d %s", tt.location, w.Code, w.Header().Get("Location"))
+			}
+		})
+	}
+}
+
+// TestRenderCache tests reuse, LRU eviction and invalidation of rendered bodies
+func TestRenderCache(t *testing.T) {
+	c := newRenderCache(2)
+	renders := 0
+	render := func(body string) func() ([]byte, error) {
+		return func() ([]byte, error) {
+			renders++
+			return []byte(body), nil
+		}
+	}
+
+	a := renderKey{Subject: "aaa", Format: "txt"}
+	b := renderKey{Subject: "bbb-pinned-1", Format: "txt"}
+	d := renderKey{Subject: "ddd", Format: "txt"}
+
+	c.render(a, render("a"))
+	if body, _ := c.render(a, render("changed")); string(body) != "a" || renders != 1 {
+		t.Errorf("Expected cached body, got %q after %d renders", body, renders)
+	}
+
+	// a was used most recently, so adding d evicts b
+	c.render(b, render("b"))
+	c.render(a, render("a"))
+	c.render(d, render("d"))
+	if body, _ := c.render(b, render("b2")); string(body) != "b2" {
+		t.Errorf("Expected b to be evicted, got %q", body)
+	}
+
+	c.invalidate("bbb")
+	if body, _ := c.render(b, render("b3")); string(body) != "b3" {
+		t.Errorf("Expected pinned render of invalidated image to be dropped, got %q", body)
+	}
+
+	if newRenderCache(0) != nil {
+		t.Error("Expected size 0 to disable the cache")
+	}
+	var disabled *renderCache
+	if body, _ := disabled.render(a, render("fresh")); string(body) != "fresh" {
+		t.Errorf("Expected nil cache to render, got %q", body)
+	}
+}
+
+// TestHandleGetColors_RenderCache tests that text palettes are rendered once per image and re-rendered after re-analysis
+func TestHandleGetColors_RenderCache(t *testing.T) {
+	app := newCachedTestApp(t)
+	app.renderCache = newRenderCache(8)
+
+	getText := func() string {
+		w := httptest.NewRecorder()
+		app.handleGetColors(w, httptest.NewRequest("GET", "/api/colors?format=txt", nil))
+		return w.Body.String()
+	}
+
+	first := getText()
+	if !strings.Contains(first, "#c67d3a") {
+		t.Fatalf("Expected palette text, got %q", first)
+	}
+
+	reqEntry := app.requestCache.Get("en-US", 0)
+	entry := *app.analysisCache.Get(reqEntry.ImageHash)
+	entry.Colors = map[string]interface{}{"gradient_from": "#000000", "gradient_to": "#ffffff", "gradient_angle": 90.0}
+	app.analysisCache.SetEntry(entry)
+
+	if got := getText(); got != first {
+		t.Errorf("Expected cached render, got %q", got)
+	}
+
+	app.renderCache.invalidate(reqEntry.ImageHash)
+	if got := getText(); !strings.Contains(got, "#000000") {
+		t.Errorf("Expected new render after invalidation, got %q", got)
+	}
+}
+
+// TestArchiveImages tests storing wallpapers locally and serving them from /archive/images/
+func TestArchiveImages(t *testing.T) {
+	t.Setenv("CACHE_DIR", t.TempDir())
+	t.Setenv("BING_MIN_INTERVAL", "0s")
+	t.Setenv("BASE_URL", "https://hues.example.com/")
+
+	bingServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing.jpg" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("jpeg data for " + r.URL.Path))
+	}))
+	defer bingServer.Close()
+
+	app := &App{bingClient: bing.NewClient("en-US")}
+	hash := strings.Repeat("ab", 32)
+	app.archiveImages(hash+"-0123456789ab", map[string]string{
+		"1920x1080": bingServer.URL + "/fhd.jpg",
+		"UHD":       bingServer.URL + "/missing.jpg",
+		"800x600":   bingServer.URL + "/small.jpg",
+	})
+
+	urls := archivedImageURLs(hash)
+	if len(urls) != 1 || urls["1920x1080"] != "https://hues.example.com/archive/images/"+hash+"/1920x1080.jpg" {
+		t.Errorf("Expected only the full HD image to be archived, got %v", urls)
+	}
+
+	w := httptest.NewRecorder()
+	handleArchivedImage(w, httptest.NewRequest("GET", "/archive/images/"+hash+"/1920x1080.jpg", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "jpeg data for /fhd.jpg" {
+		t.Errorf("Expected archived image, got %d %q", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Header().Get("Cache-Control"), "immutable") {
+		t.Errorf("Expected immutable caching, got %q", w.Header().Get("Cache-Control"))
+	}
+
+	for _, path := range []string{
+		"/archive/images/" + hash + "/UHD.jpg",
+		"/archive/images/" + hash + "/800x600.jpg",
+		"/archive/images/" + hash + "-0123456789ab/1920x1080.jpg",
+		"/archive/images/../../etc/passwd",
+	} {
+		req := httptest.NewRequest("GET", "/archive/images/x", nil)
+		req.URL.Path = path
+		w := httptest.NewRecorder()
+		handleArchivedImage(w, req)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected 404 for %s, got %d", path, w.Code)
+		}
+	}
+}
+
+// TestHandleGetColors_Verbose tests that analysis provenance is only returned with ?verbose=true
+func TestHandleGetColors_Verbose(t *testing.T) {
+	app := newCachedTestApp(t)
+
+	reqEntry := app.requestCache.Get("en-US", 0)
+	entry := *app.analysisCache.Get(reqEntry.ImageHash)
+	entry.Model = "test-model"
+	entry.PromptVersion = 1
+	entry.PromptTokens = 1200
+	entry.CompletionTokens = 80
+	entry.Source = "image"
+	entry.SourceResolution = "1920x1080"
+	app.analysisCache.SetEntry(entry)
+
+	w := httptest.NewRecorder()
+	app.handleGetColors(w, httptest.NewRequest("GET", "/api/colors", nil))
+	if strings.Contains(w.Body.String(), `"analysis"`) {
+		t.Errorf("Expected no analysis block without verbose, got %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	app.handleGetColors(w, httptest.NewRequest("GET", "/api/colors?verbose=true", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var response struct {
+		Analysis *AnalysisMetadata `json:"analysis"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Analysis == nil {
+		t.Fatalf("Expected analysis block, got %s", w.Body.String())
+	}
+	if response.Analysis.Model != "test-model" || response.Analysis.PromptVersion != 1 ||
+		response.Analysis.PromptTokens != 1200 || response.Analysis.SourceResolution != "1920x1080" {
+		t.Errorf("Unexpected analysis block: %+v", response.Analysis)
+	}
+	if response.Analysis.AnalyzedAt.IsZero() {
+		t.Error("Expected analyzed_at to be set")
+	}
+}
+
+// TestRunBounded tests that tasks run with bounded parallelism and fail individually
+func TestRunBounded(t *testing.T) {
+	var running, peak atomic.Int32
+	values, errs := runBounded(context.Background(), 2, time.Second, 6, func(_ context.Context, i int) (int, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			old := peak.Load()
+			if n <= old || peak.CompareAndSwap(old, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		if i == 3 {
+			return 0, fmt.Errorf("task %d failed", i)
+		}
+		return i * 10, nil
+	})
+
+	if got := peak.Load(); got > 2 {
+		t.Errorf("Expected at most 2 tasks at once, got %d", got)
+	}
+	for i := range 6 {
+		if i == 3 {
+			if errs[i] == nil {
+				t.Errorf("Expected task 3 to fail")
+			}
+			continue
+		}
+		if errs[i] != nil || values[i] != i*10 {
+			t.Errorf("Task %d: expected %d, got %d (error %v)", i, i*10, values[i], errs[i])
+		}
+	}
+
+	// A task that outlives its timeout is reported as failed without holding up the batch
+	release := make(chan struct{})
+	defer close(release)
+	_, errs = runBounded(context.Background(), 1, 20*time.Millisecond, 1, func(ctx context.Context, _ int) (int, error) {
+		<-release
+		return 0, nil
+	})
+	if !errors.Is(errs[0], context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, got %v", errs[0])
+	}
+}
+
+// TestHandleBatch tests palettes for several locales in one request
+func TestHandleBatch(t *testing.T) {
+	app := newCachedTestApp(t)
+
+	w := httptest.NewRecorder()
+	app.handleBatch(w, httptest.NewRequest("GET", "/api/batch?locales=en-US&daysAgo=0", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var response BatchResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(response.Locales) != 1 || response.Locales[0].Locale != "en-US" || response.Locales[0].ColorTheme == nil {
+		t.Fatalf("Unexpected response: %s", w.Body.String())
+	}
+	if response.Locales[0].Colors["gradient_from"] != "#c67d3a" {
+		t.Errorf("Expected cached palette, got %v", response.Locales[0].Colors)
+	}
+
+	for _, query := range []string{"", "locales=xx-XX", "locales=en-US&daysAgo=99"} {
+		w := httptest.NewRecorder()
+		app.handleBatch(w, httptest.NewRequest("GET", "/api/batch?"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected status 400, got %d", query, w.Code)
+		}
+	}
+}
+
+// TestValidateAngles tests the angles parameter and its GRADIENT_ANGLES default
+func TestValidateAngles(t *testing.T) {
+	t.Setenv("GRADIENT_ANGLES", "0, 90,180,270")
+
+	if got, err := validateAngles(""); err != nil || anglesOption(got) != "0,90,180,270" {
+		t.Errorf("Expected configured angles, got %v (error %v)", got, err)
+	}
+	if got, err := validateAngles("45,225"); err != nil || anglesOption(got) != "45,225" {
+		t.Errorf("Expected query to override config, got %v (error %v)", got, err)
+	}
+	if got, err := validateAngles("any"); err != nil || got != nil {
+		t.Errorf("Expected any to lift the constraint, got %v (error %v)", got, err)
+	}
+	for _, param := range []string{"north", "360", "-90", ","} {
+		if _, err := validateAngles(param); err == nil {
+			t.Errorf("Expected %q to be rejected", param)
+		}
+	}
+
+	t.Setenv("GRADIENT_ANGLES", "diagonal")
+	if got, _ := validateAngles(""); got != nil {
+		t.Errorf("Expected invalid config to be ignored, got %v", got)
+	}
+}
+
+// TestHandleGetColors_Angles tests that gradient angles are moved to the nearest allowed angle
+func TestHandleGetColors_Angles(t *testing.T) {
+	app := newCachedTestApp(t)
+
+	w := httptest.NewRecorder()
+	app.handleGetColors(w, httptest.NewRequest("GET", "/api/colors?angles=0,180", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var response ColorTheme
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Colors["gradient_angle"] != float64(180) {
+		t.Errorf("Expected angle 180, got %v", response.Colors["gradient_angle"])
+	}
+	if !strings.Contains(response.CSSGradient, "180deg") {
+		t.Errorf("Expected CSS gradient to use the new angle, got %q", response.CSSGradient)
+	}
+
+	// The cached analysis keeps the model's angle
+	reqEntry := app.requestCache.Get("en-US", 0)
+	if got := app.analysisCache.Get(reqEntry.ImageHash).Colors["gradient_angle"]; got != float64(135) {
+		t.Errorf("Expected cached angle to stay 135, got %v", got)
+	}
+}
+
+// TestHandleContrast tests contrast ratios of the palette against a supplied color
+func TestHandleContrast(t *testing.T) {
+	app := newCachedTestApp(t)
+
+	w := httptest.NewRecorder()
+	app.handleContrast(w, httptest.NewRequest("GET", "/api/contrast?fg=%23000000", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var response ContrastResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Against != "#000000" || response.Role != "foreground" {
+		t.Errorf("Unexpected header fields: %+v", response)
+	}
+
+	found := false
+	for _, c := range response.Colors {
+		if c.Name == "gradient_from" {
+			found = true
+			// #c67d3a on black is about 6.5:1
+			if c.Ratio < 6 || c.Ratio > 7 || !c.AA || c.AAA {
+				t.Errorf("Unexpected contrast for gradient_from: %+v", c)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("Expected gradient_from in %+v", response.Colors)
+	}
+
+	for _, query := range []string{"", "fg=%23000000&bg=%23ffffff", "bg=black"} {
+		w := httptest.NewRecorder()
+		app.handleContrast(w, httptest.NewRequest("GET", "/api/contrast?"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected status 400, got %d", query, w.Code)
+		}
+	}
+}
+
+// TestHandleGetColors_Gamut tests wide-gamut color values requested with ?gamut=
+func TestHandleGetColors_Gamut(t *testing.T) {
+	app := newCachedTestApp(t)
+
+	w := httptest.NewRecorder()
+	app.handleGetColors(w, httptest.NewRequest("GET", "/api/colors?gamut=display-p3,rec2020", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var response ColorTheme
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	for _, space := range []string{"display-p3", "rec2020"} {
+		colors := response.WideGamut[space]
+		if !strings.HasPrefix(colors["gradient_from"], "color("+space+" ") {
+			t.Errorf("Expected %s gradient_from, got %q", space, colors["gradient_from"])
+		}
+		if !strings.HasPrefix(colors["css_gradient"], "linear-gradient(135deg, color("+space) {
+			t.Errorf("Expected %s css_gradient, got %q", space, colors["css_gradient"])
+		}
+	}
+
+	w = httptest.NewRecorder()
+	app.handleGetColors(w, httptest.NewRequest("GET", "/api/colors", nil))
+	if strings.Contains(w.Body.String(), "wide_gamut") {
+		t.Error("Expected no wide_gamut without ?gamut=")
+	}
+
+	w = httptest.NewRecorder()
+	app.handleGetColors(w, httptest.NewRequest("GET", "/api/colors?gamut=srgb", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for unknown gamut, got %d", w.Code)
+	}
+}
+
+// TestCanarySampled tests that sampling is deterministic per image and follows the percentage
+func TestCanarySampled(t *testing.T) {
+	if canarySampled("00000000abcdef", 0) {
+		t.Error("Expected 0% to sample nothing")
+	}
+	if !canarySampled("ffffffffabcdef", 100) {
+		t.Error("Expected 100% to sample everything")
+	}
+
+	sampled := 0
+	for i := range 1000 {
+		if canarySampled(fmt.Sprintf("%08x", i*4294967), 10) {
+			sampled++
+		}
+	}
+	if sampled < 50 || sampled > 150 {
+		t.Errorf("Expected about 100 of 1000 sampled at 10%%, got %d", sampled)
+	}
+}
+
+// TestHandleCanary tests the comparison report of stored canary results
+func TestHandleCanary(t *testing.T) {
+	t.Setenv("CACHE_DIR", t.TempDir())
+
+	primary := map[string]interface{}{"gradient_from": "#c67d3a", "gradient_to": "#6b8d7d", "gradient_angle": float64(135), "inactive_border": "#555555"}
+	results := []CanaryResult{
+		{
+			ImageHash:  "aaaa",
+			AnalyzedAt: time.Now().Add(-time.Hour),
+			Primary:    CanaryAnalysis{Model: "primary", Colors: primary, Cost: 0.01},
+			Candidate: CanaryAnalysis{Model: "candidate", Cost: 0.002,
+				Colors: map[string]interface{}{"gradient_from": "#c67d3a", "gradient_to": "#6b8d7d", "gradient_angle": float64(315), "inactive_border": "#555555"}},
+		},
+		{
+			ImageHash:  "bbbb",
+			AnalyzedAt: time.Now(),
+			Primary:    CanaryAnalysis{Model: "primary", Colors: primary, Cost: 0.01},
+			Candidate:  CanaryAnalysis{Model: "candidate", Error: "no response from AI model"},
+		},
+	}
+	for _, result := range results {
+		result.Comparison = compareCanary(result.Primary.Colors, result.Candidate.Colors)
+		if err := saveCanaryResult(result); err != nil {
+			t.Fatalf("Failed to save canary result: %v", err)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	handleCanary(w, httptest.NewRequest("GET", "/admin/canary", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var report CanaryReport
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatalf("Failed to decode report: %v", err)
+	}
+	if len(report.Candidates) != 1 {
+		t.Fatalf("Expected one candidate, got %+v", report.Candidates)
+	}
+	candidate := report.Candidates[0]
+	if candidate.Model != "candidate" || candidate.Samples != 2 || candidate.Failures != 1 {
+		t.Errorf("Unexpected counts: %+v", candidate)
+	}
+	if candidate.MeanGradientFromDelta != 0 || candidate.MeanAngleDelta != 180 {
+		t.Errorf("Expected identical colors and opposite angles, got %+v", candidate)
+	}
+	if candidate.Results[0].ImageHash != "bbbb" {
+		t.Errorf("Expected newest result first, got %s", candidate.Results[0].ImageHash)
+	}
+}
+
+// TestWithRequestID tests that request IDs are generated, or kept from a proxy when well-formed
+func TestWithRequestID(t *testing.T) {
+	var seen string
+	handler := withRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = logging.RequestID(r.Context())
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/health", nil))
+	if len(seen) != 16 || w.Header().Get("X-Request-ID") != seen {
+		t.Errorf("Expected a generated ID in context and header, got %q and %q", seen, w.Header().Get("X-Request-ID"))
+	}
+
+	req := httptest.NewRequest("GET", "/health", nil)
+	req.Header.Set("X-Request-ID", "proxy-123")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if seen != "proxy-123" {
+		t.Errorf("Expected proxy ID to be kept, got %q", seen)
+	}
+
+	req = httptest.NewRequest("GET", "/health", nil)
+	req.Header.Set("X-Request-ID", "bad id\n")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if seen == "bad id\n" || len(seen) != 16 {
+		t.Errorf("Expected malformed ID to be replaced, got %q", seen)
+	}
+}
+
+// TestServe tests serving the routes through the middleware and shutting down cleanly when the context ends
+func TestServe(t *testing.T) {
+	app := newCachedTestApp(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- app.serve(ctx, listener)
+	}()
+
+	resp, err := http.Get("http://" + listener.Addr().String() + "/api/colors?locale=en-US")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", resp.StatusCode)
+	}
+	if resp.Header.Get("X-Request-ID") == "" || resp.Header.Get("X-Dailyhues-Version") == "" {
+		t.Errorf("Expected the middleware headers, got %v", resp.Header)
+	}
+
+	cancel()
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("Expected a clean shutdown, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Server did not shut down")
+	}
+}
+
+// TestChangeFeed tests that a new image for a locale is published once, with both palettes, to that locale's subscribers
+func TestChangeFeed(t *testing.T) {
+	var feed changeFeed
+	enUS, unsubscribeEnUS := feed.subscribe("en-US")
+	defer unsubscribeEnUS()
+	all, unsubscribeAll := feed.subscribe("")
+	defer unsubscribeAll()
+	jaJP, unsubscribeJaJP := feed.subscribe("ja-JP")
+	defer unsubscribeJaJP()
+
+	monday := ColorTheme{StartDate: "20251019", imageHash: strings.Repeat("a", 64)}
+	tuesday := ColorTheme{StartDate: "20251020", imageHash: strings.Repeat("b", 64)}
+
+	// The first palette and repeats of it are not changes, even with another prompt addendum
+	feed.observe("en-US", monday)
+	feed.observe("en-US", monday)
+	feed.observe("en-US", ColorTheme{StartDate: "20251019", imageHash: strings.Repeat("a", 64) + "-0123456789ab"})
+	feed.observe("en-US", tuesday)
+	// A request racing the rollover must not flip back to the older wallpaper
+	feed.observe("en-US", monday)
+
+	for name, changes := range map[string]<-chan PaletteChange{"en-US": enUS, "all": all} {
+		select {
+		case change := <-changes:
+			if change.Type != paletteChangedEvent || change.Locale != "en-US" ||
+				change.Previous.StartDate != "20251019" || change.Current.StartDate != "20251020" {
+				t.Errorf("%s: unexpected change %+v", name, change)
+			}
+		default:
+			t.Fatalf("%s: expected a change", name)
+		}
+		select {
+		case change := <-changes:
+			t.Errorf("%s: expected a single change, also got %+v", name, change)
+		default:
+		}
+	}
+
+	select {
+	case change := <-jaJP:
+		t.Errorf("Expected no change for ja-JP subscribers, got %+v", change)
+	default:
+	}
+}
+
+// TestHandleSearch tests case-insensitive search over titles and copyrights, newest first
+func TestHandleSearch(t *testing.T) {
+	app := newCachedTestApp(t)
+	imageHash := "cached0123456789012345678901234567890123456789012345678901234"
+	app.requestCache.SetEntry(cache.RequestEntry{Locale: "ja-JP", ImageHash: imageHash, StartDate: "20250901", Title: "Lighthouse at dusk", Copyright: "Peggy's Cove, Nova Scotia (© Example/Getty Images)"})
+	app.requestCache.SetEntry(cache.RequestEntry{Locale: "en-US", ImageHash: imageHash, StartDate: "20250902", Title: "Guiding light", Copyright: "Lighthouse in Nova Scotia (© Example/Alamy)"})
+	app.requestCache.SetEntry(cache.RequestEntry{Locale: "en-US", ImageHash: "unanalyzed", StartDate: "20250903", Title: "Lighthouse without a palette"})
+
+	tests := []struct {
+		query   string
+		status  int
+		locales []string
+		dates   []string
+	}{
+		{"q=LIGHTHOUSE", http.StatusOK, []string{"en-US", "ja-JP"}, []string{"20250902", "20250901"}},
+		{"q=lighthouse+nova&locale=ja-JP", http.StatusOK, []string{"ja-JP"}, []string{"20250901"}},
+		{"q=lighthouse+alamy", http.StatusOK, []string{"en-US"}, []string{"20250902"}},
+		{"q=lighthouse&limit=1", http.StatusOK, []string{"en-US"}, []string{"20250902"}},
+		{"q=aurora", http.StatusOK, nil, nil},
+		{"q=+", http.StatusBadRequest, nil, nil},
+		{"q=lighthouse&limit=0", http.StatusBadRequest, nil, nil},
+		{"q=lighthouse&locale=xx-XX", http.StatusBadRequest, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			app.handleSearch(w, httptest.NewRequest("GET", "/api/search?"+tt.query, nil))
+			if w.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			var response SearchResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to parse response: %v", err)
+			}
+			if response.Count != len(tt.locales) || len(response.Results) != len(tt.locales) {
+				t.Fatalf("Expected %d results, got %+v", len(tt.locales), response)
+			}
+			for i, result := range response.Results {
+				if result.Locale != tt.locales[i] || result.StartDate != tt.dates[i] {
+					t.Errorf("Result %d: expected %s %s, got %s %s", i, tt.locales[i], tt.dates[i], result.Locale, result.StartDate)
+				}
+				if result.Colors["gradient_from"] != "#c67d3a" {
+					t.Errorf("Result %d: expected the palette, got %v", i, result.Colors)
+				}
+			}
+		})
+	}
+}
+
+// TestHandleEstimate tests parameter validation and that completion tokens come from the model's past analyses
+func TestHandleEstimate(t *testing.T) {
+	app := newCachedTestApp(t)
+	app.analysisCache.SetEntry(cache.AnalysisEntry{
+		ImageHash:        "usage0123456789012345678901234567890123456789012345678901234",
+		Colors:           map[string]interface{}{"gradient_from": "#c67d3a"},
+		Model:            "example/model",
+		PromptTokens:     900,
+		CompletionTokens: 700,
+		Cost:             0.01,
+	})
+
+	history := app.usageHistory("example/model")
+	if history == nil || history.Samples != 1 || history.MeanCompletionTokens != 700 || history.MeanPromptTokens != 900 {
+		t.Errorf("Unexpected history: %+v", history)
+	}
+	if history := app.usageHistory(ai.Model()); history != nil {
+		t.Errorf("Expected no history for entries without usage, got %+v", history)
+	}
+
+	for _, query := range []string{"width=0", "height=abc", "width=20000", "locale=xx-XX", "format=css"} {
+		t.Run(query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			app.handleEstimate(w, httptest.NewRequest("GET", "/api/estimate?"+query, nil))
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("Expected status 400, got %d: %s", w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+// TestHandleGetColors_ModelParam tests that only admins may request another model than AI_MODEL
+func TestHandleGetColors_ModelParam(t *testing.T) {
+	t.Setenv("AI_MODEL", "example/configured-model")
+	app := newCachedTestApp(t)
+
+	tests := []struct {
+		query  string
+		status int
+	}{
+		{"model=example/configured-model", http.StatusOK}, // The configured model is no override
+		{"model=example/other-model", http.StatusForbidden},
+		{"model=not+a+model", http.StatusBadRequest},
+		{"model=" + strings.Repeat("a", 60) + "/" + strings.Repeat("b", 60), http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			app.handleGetColors(w, httptest.NewRequest("GET", "/api/colors?"+tt.query, nil))
+			if w.Code != tt.status {
+				t.Errorf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+// TestColorThemeModel tests that responses name the model of their analysis
+func TestColorThemeModel(t *testing.T) {
+	theme := buildColorTheme(&cache.RequestEntry{ImageHash: "abc"}, &cache.AnalysisEntry{Colors: map[string]interface{}{}, Model: "example/model"})
+	if theme.Model != "example/model" {
+		t.Errorf("Expected the analysis model, got %q", theme.Model)
+	}
+
+	data, err := json.Marshal(buildColorTheme(&cache.RequestEntry{ImageHash: "abc"}, &cache.AnalysisEntry{Colors: map[string]interface{}{}}))
+	if err != nil {
+		t.Fatalf("Failed to marshal: %v", err)
+	}
+	if strings.Contains(string(data), `"model"`) {
+		t.Errorf("Expected no model for analyses without one, got %s", data)
+	}
+}
+
+// TestFallbackAnalysis tests the palettes served while OpenRouter is unavailable
+func TestFallbackAnalysis(t *testing.T) {
+	app := newCachedTestApp(t)
+	info := &bing.WallpaperInfo{StartDate: "20251019", Title: "Title"}
+
+	var buf bytes.Buffer
+	jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 40, 40)), &jpeg.Options{Quality: 85})
+
+	// Another prompt addendum's analysis of the same image is preferred
+	cachedHash := "cached0123456789012345678901234567890123456789012345678901234"
+	entry, kind, err := app.fallbackAnalysis(buf.Bytes(), cache.AnalysisKey(cachedHash, "Prefer cooler tones."), info)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if kind != fallbackCached || entry.Colors["gradient_from"] != "#c67d3a" {
+		t.Errorf("Expected the cached analysis of the image, got %s %v", kind, entry.Colors)
+	}
+
+	entry, kind, err = app.fallbackAnalysis(buf.Bytes(), strings.Repeat("ab", 32), info)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	theme := themeFromPalette(pipeline.Palette{Request: cache.RequestEntry{StartDate: info.StartDate}, Analysis: entry, Fallback: kind})
+	if theme.Fallback != fallbackLocal || theme.CSSGradient == "" || theme.imageHash != "" {
+		t.Errorf("Expected a locally picked gradient outside the render cache, got %+v", theme)
+	}
+}
+
+// TestLoadPromptProfiles tests prompt profiles from PROMPT_PROFILES_DIR and PROMPT_PROFILE_* variables
+func TestLoadPromptProfiles(t *testing.T) {
+	dir := t.TempDir()
+	for name, prompt := range map[string]string{
+		"terminal-theme.txt": "Design a terminal theme.\n",
+		"full-palette.txt":   "Pick eight colors.",
+		"lockscreen.txt":     "Built-in profiles cannot be replaced.",
+		"Mixed_Case.txt":     "Not a valid profile name.",
+		"blank.txt":          "  \n",
+		"notes.md":           "Not a prompt.",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(prompt), 0644); err != nil {
+			t.Fatalf("Failed to write prompt: %v", err)
+		}
+	}
+	t.Setenv("PROMPT_PROFILES_DIR", dir)
+	t.Setenv("PROMPT_PROFILE_FULL_PALETTE", "Pick sixteen colors.")
+
+	profiles := loadPromptProfiles()
+	if len(profiles) != 2 {
+		t.Errorf("Expected 2 profiles, got %v", profiles)
+	}
+	if profiles["terminal-theme"] != "Design a terminal theme." {
+		t.Errorf("Expected the trimmed file prompt, got %q", profiles["terminal-theme"])
+	}
+	if profiles["full-palette"] != "Pick sixteen colors." {
+		t.Errorf("Expected the variable to replace the file, got %q", profiles["full-palette"])
+	}
+
+	if profile, prompt, err := validateProfile("terminal-theme"); err != nil || profile != "terminal-theme" || prompt == "" {
+		t.Errorf("Expected the terminal-theme profile, got %q %q %v", profile, prompt, err)
+	}
+	if _, prompt, err := validateProfile("lockscreen"); err != nil || prompt != "" {
+		t.Errorf("Expected the built-in lockscreen profile, got %q %v", prompt, err)
+	}
+	if _, _, err := validateProfile("poster"); err == nil || !strings.Contains(err.Error(), "full-palette, terminal-theme") {
+		t.Errorf("Expected an error listing the prompt profiles, got %v", err)
+	}
+}
+
+// TestHandleGetColors_PromptProfile tests that prompt profiles are served from their own analysis cache
+func TestHandleGetColors_PromptProfile(t *testing.T) {
+	t.Setenv("PROMPT_PROFILE_TERMINAL_THEME", "Design a terminal theme.")
+	app := newCachedTestApp(t)
+	app.profileCaches.dir = t.TempDir()
+
+	analyses, err := app.profileCaches.get("terminal-theme")
+	if err != nil {
+		t.Fatalf("Failed to open profile cache: %v", err)
+	}
+	key := cache.AnalysisKey("cached0123456789012345678901234567890123456789012345678901234", "Design a terminal theme.")
+	analyses.SetEntry(cache.AnalysisEntry{ImageHash: key, Prompt: "Design a terminal theme.", Colors: map[string]interface{}{"background": "#1d2021"}})
+
+	w := httptest.NewRecorder()
+	app.handleGetColors(w, httptest.NewRequest("GET", "/api/colors?profile=terminal-theme", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var response ColorTheme
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Colors["background"] != "#1d2021" || response.Title != "Title" {
+		t.Errorf("Expected the profile's colors with the day's metadata, got %+v", response)
+	}
+	profileETag := w.Header().Get("ETag")
+
+	w = httptest.NewRecorder()
+	app.handleGetColors(w, httptest.NewRequest("GET", "/api/colors", nil))
+	if etag := w.Header().Get("ETag"); etag == "" || etag == profileETag {
+		t.Errorf("Expected different validators for the default palette, got %q and %q", etag, profileETag)
+	}
+	if app.analysisCache.Get(key) != nil {
+		t.Error("Expected the profile analysis to stay out of the default cache")
+	}
+
+	w = httptest.NewRecorder()
+	app.handleGetColors(w, httptest.NewRequest("HEAD", "/api/colors?profile=terminal-theme", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for HEAD, got %d", w.Code)
+	}
+}
+
+// TestHandleGetColors_FullProfile tests that ?profile=full returns the full palette kept in the analysis entry
+func TestHandleGetColors_FullProfile(t *testing.T) {
+	app := newCachedTestApp(t)
+	imageHash := "cached0123456789012345678901234567890123456789012345678901234"
+	entry := app.analysisCache.Get(imageHash)
+	app.analysisCache.SetEntry(entry.WithPalette(cache.PaletteKindFull, cache.PaletteEntry{
+		Colors: fullPaletteColors(ai.FullPalette{
+			Background: "#1d2021", Foreground: "#ebdbb2", Accent: "#d79921", Muted: "#928374", Error: "#cc241d", Success: "#98971a",
+			Swatches: []string{"#282828", "#3c3836", "#504945", "#665c54", "#7c6f64", "#a89984", "#bdae93", "#d5c4a1"},
+		}),
+		Model: "example/model",
+	}))
+
+	w := httptest.NewRecorder()
+	app.handleGetColors(w, httptest.NewRequest("GET", "/api/colors?profile=full", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var response ColorTheme
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Colors["background"] != "#1d2021" || response.Model != "example/model" {
+		t.Errorf("Expected the full palette, got %+v", response)
+	}
+	if _, ok := response.Colors["gradient_from"]; ok {
+		t.Error("Expected the gradient to be replaced")
+	}
+
+	w = httptest.NewRecorder()
+	app.handleGetColors(w, httptest.NewRequest("GET", "/api/colors?profile=full&format=env", nil))
+	if !strings.Contains(w.Body.String(), "DAILYHUES_SWATCHES_8=#d5c4a1\n") {
+		t.Errorf("Expected numbered swatches, got %s", w.Body.String())
+	}
+
+	// The gradient is still served from the same entry
+	w = httptest.NewRecorder()
+	app.handleGetColors(w, httptest.NewRequest("GET", "/api/colors", nil))
+	if !strings.Contains(w.Body.String(), `"gradient_from":"#c67d3a"`) {
+		t.Errorf("Expected the default palette, got %s", w.Body.String())
+	}
+}
+
+// TestValidateStability tests parsing of the stability threshold
+func TestValidateStability(t *testing.T) {
+	if threshold, err := validateStability(""); err != nil || threshold != 0 {
+		t.Errorf("Expected stability off by default, got %v (error %v)", threshold, err)
+	}
+	if threshold, err := validateStability("2.5"); err != nil || threshold != 2.5 {
+		t.Errorf("Expected 2.5, got %v (error %v)", threshold, err)
+	}
+	for _, param := range []string{"low", "-1", "101", "NaN"} {
+		if _, err := validateStability(param); err == nil {
+			t.Errorf("Expected %q to be rejected", param)
+		}
+	}
+}
+
+// TestHandleGetColors_Stability tests that a palette too close to the one shown the day before is not adopted
+func TestHandleGetColors_Stability(t *testing.T) {
+	app := newCachedTestApp(t)
+	for daysAgo, colors := range map[int][2]string{
+		1: {"#c47b39", "#6b8d7e"}, // Barely different from today's #c67d3a to #6b8d7d
+		2: {"#c27a38", "#6a8c7c"}, // Barely different from yesterday's
+		3: {"#2e5c8a", "#8a2e5c"},
+	} {
+		imageHash := fmt.Sprintf("day%d", daysAgo)
+		app.analysisCache.Set(imageHash, map[string]interface{}{"gradient_from": colors[0], "gradient_to": colors[1], "gradient_angle": float64(90)})
+		startDate, fullStartDate, endDate := testWallpaperDates(daysAgo)
+		app.requestCache.Set(defaultLocale, daysAgo, imageHash, map[string]string{"UHD": "https://bing.com/uhd.jpg"}, "Title", "Copyright", "", startDate, fullStartDate, endDate, time.Now().Add(time.Hour))
+	}
+
+	get := func(query string) (*httptest.ResponseRecorder, ColorTheme) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		app.handleGetColors(w, httptest.NewRequest("GET", "/api/colors?"+query, nil))
+		var response ColorTheme
+		if w.Code == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+		}
+		return w, response
+	}
+
+	w, own := get("")
+	if own.Reused {
+		t.Error("Expected no reuse without stability")
+	}
+
+	reusedW, reused := get("stability=3")
+	if !reused.Reused || reused.Colors["gradient_from"] != "#c27a38" {
+		t.Fatalf("Expected the palette first shown two days ago, got %+v", reused)
+	}
+	if startDate, _, _ := testWallpaperDates(2); reused.ReusedFrom != startDate {
+		t.Errorf("Expected reused_from %s, got %s", startDate, reused.ReusedFrom)
+	}
+	if reused.Title != own.Title || reused.StartDate != own.StartDate {
+		t.Error("Expected the day's own metadata")
+	}
+	if reusedW.Header().Get("ETag") == w.Header().Get("ETag") {
+		t.Error("Expected different validators for the reused palette")
+	}
+
+	if _, strict := get("stability=0.1"); strict.Reused {
+		t.Error("Expected a low threshold to adopt the new palette")
+	}
+
+	if w, _ := get("stability=high"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid threshold, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	app.handleGetColors(w, httptest.NewRequest("HEAD", "/api/colors?stability=3", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for HEAD, got %d", w.Code)
+	}
+}
+
+// TestHandleGetColors_Eink tests the e-paper palette with its dithered swatch image
+func TestHandleGetColors_Eink(t *testing.T) {
+	app := newCachedTestApp(t)
+
+	w := httptest.NewRecorder()
+	app.handleGetColors(w, httptest.NewRequest("GET", "/api/colors?format=eink&levels=2", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var eink EinkPalette
+	if err := json.Unmarshal(w.Body.Bytes(), &eink); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if strings.Join(eink.Grays, ",") != "#000000,#ffffff" {
+		t.Errorf("Expected black and white, got %v", eink.Grays)
+	}
+	if from := eink.Colors["gradient_from"]; from.Hex != "#c67d3a" || from.Gray != "#ffffff" || from.Level != 1 {
+		t.Errorf("Expected orange to map to white, got %+v", from)
+	}
+
+	data, ok := strings.CutPrefix(eink.Swatch, "data:image/png;base64,")
+	if !ok {
+		t.Fatalf("Expected a PNG data URI, got %.40s", eink.Swatch)
+	}
+	raw, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		t.Fatalf("Failed to decode swatch: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("Failed to decode PNG: %v", err)
+	}
+	paletted, ok := img.(*image.Paletted)
+	if !ok || len(paletted.Palette) != 2 {
+		t.Fatalf("Expected a 1-bit paletted image, got %T", img)
+	}
+	// The gradient strip plus gradient_from, gradient_to and the derived inactive_border and notification colors
+	if rows := img.Bounds().Dy() / einkSwatchHeight; rows != len(eink.Colors)+1 {
+		t.Errorf("Expected %d swatch rows, got %d", len(eink.Colors)+1, rows)
+	}
+
+	w = httptest.NewRecorder()
+	app.handleGetColors(w, httptest.NewRequest("GET", "/api/colors?format=eink&levels=3", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for unsupported levels, got %d", w.Code)
+	}
+}
+
+// TestRespondWithResolveError tests that analyses turned away by the AI queue are answered with 503 and Retry-After
+func TestRespondWithResolveError(t *testing.T) {
+	t.Setenv("AI_QUEUE_TIMEOUT", "1500ms")
+
+	w := httptest.NewRecorder()
+	respondWithResolveError(w, fmt.Errorf("Failed to analyze colors: %w", ai.ErrBusy))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, got %d", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Expected Retry-After 2, got %q", got)
+	}
+
+	w = httptest.NewRecorder()
+	respondWithResolveError(w, errors.New("Failed to download wallpaper"))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, got %d", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "" {
+		t.Errorf("Expected no Retry-After, got %q", got)
+	}
+}
+
+// TestHandleGetColors_WLED tests that format=wled splits the strip into segments colored from gradient_from to gradient_to
+func TestHandleGetColors_WLED(t *testing.T) {
+	app := newCachedTestApp(t)
+
+	w := httptest.NewRecorder()
+	app.handleGetColors(w, httptest.NewRequest("GET", "/api/colors?format=wled&leds=10&segments=3", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var state WLEDState
+	if err := json.Unmarshal(w.Body.Bytes(), &state); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !state.On || len(state.Segments) != 3 {
+		t.Fatalf("Expected 3 segments, got %+v", state)
+	}
+	for i, bounds := range [][2]int{{0, 3}, {3, 6}, {6, 10}} {
+		if segment := state.Segments[i]; segment.ID != i || segment.Start != bounds[0] || segment.Stop != bounds[1] {
+			t.Errorf("Expected segment %d to cover %v, got %+v", i, bounds, segment)
+		}
+	}
+	if first := state.Segments[0].Colors[0]; first != [3]int{0xc6, 0x7d, 0x3a} {
+		t.Errorf("Expected the first segment in gradient_from, got %v", first)
+	}
+	if last := state.Segments[2].Colors[0]; last != [3]int{0x6b, 0x8d, 0x7d} {
+		t.Errorf("Expected the last segment in gradient_to, got %v", last)
+	}
+
+	w = httptest.NewRecorder()
+	app.handleGetColors(w, httptest.NewRequest("GET", "/api/colors?format=wled&segments=33", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for too many segments, got %d", w.Code)
+	}
+}
+
+// TestSendChange_WLED tests that palette changes are pushed to a WLED device, but only for the locale it follows
+func TestSendChange_WLED(t *testing.T) {
+	var states []WLEDState
+	device := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/json/state" {
+			t.Errorf("Expected a POST to /json/state, got %s %s", r.Method, r.URL.Path)
+		}
+		var state WLEDState
+		if err := json.NewDecoder(r.Body).Decode(&state); err != nil {
+			t.Errorf("Failed to decode state: %v", err)
+		}
+		states = append(states, state)
+		fmt.Fprint(w, `{"success":true}`)
+	}))
+	defer device.Close()
+
+	t.Setenv("CHANGE_WLED_URL", device.URL+"/")
+	t.Setenv("CHANGE_WLED_LEDS", "60")
+	t.Setenv("CHANGE_WLED_SEGMENTS", "4")
+	t.Setenv("CHANGE_WLED_LOCALE", "en-US")
+	_, targets := loadChangeConfig()
+	if !targets.enabled() {
+		t.Fatal("Expected a WLED URL to enable change delivery")
+	}
+
+	current := ColorTheme{Colors: map[string]interface{}{"gradient_from": "#c67d3a", "gradient_to": "#6b8d7d", "gradient_angle": 135.0}}
+	sendChange(PaletteChange{Locale: "de-DE", Current: current}, targets)
+	if len(states) != 0 {
+		t.Fatalf("Expected no push for another locale, got %d", len(states))
+	}
+
+	sendChange(PaletteChange{Locale: "en-US", Current: current}, targets)
+	if len(states) != 1 {
+		t.Fatalf("Expected one push, got %d", len(states))
+	}
+	if segments := states[0].Segments; len(segments) != 4 || segments[3].Stop != 60 {
+		t.Errorf("Expected 4 segments over 60 LEDs, got %+v", segments)
+	}
+}
+
+// TestSendChange_Hue tests that palette changes set the Hue lights to the lighting scene served in colors.lighting
+func TestSendChange_Hue(t *testing.T) {
+	states := make(map[string]notify.HueLightState)
+	bridge := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, ok := strings.CutPrefix(r.URL.Path, "/api/key/lights/")
+		id, ok2 := strings.CutSuffix(id, "/state")
+		if r.Method != http.MethodPut || !ok || !ok2 {
+			t.Errorf("Expected a PUT to a light state, got %s %s", r.Method, r.URL.Path)
+		}
+		var state notify.HueLightState
+		if err := json.NewDecoder(r.Body).Decode(&state); err != nil {
+			t.Errorf("Failed to decode state: %v", err)
+		}
+		states[id] = state
+		fmt.Fprint(w, `[{"success": {}}]`)
+	}))
+	defer bridge.Close()
+
+	t.Setenv("CHANGE_HUE_URL", bridge.URL+"/api/key")
+	t.Setenv("CHANGE_HUE_LIGHTS", "3, 7")
+	_, targets := loadChangeConfig()
+	if !targets.enabled() {
+		t.Fatal("Expected a Hue bridge with lights to enable change delivery")
+	}
+
+	current := withDerivedColors(ColorTheme{Colors: map[string]interface{}{"gradient_from": "#c67d3a", "gradient_to": "#6b8d7d", "gradient_angle": 135.0}})
+	scene, ok := current.Colors["lighting"].([]interface{})
+	if !ok || len(scene) != 4 {
+		t.Fatalf("Expected a lighting scene of 4 lights in colors, got %v", current.Colors["lighting"])
+	}
+
+	sendChange(PaletteChange{Locale: "en-US", Current: current}, targets)
+	if len(states) != 2 {
+		t.Fatalf("Expected 2 lights to be set, got %v", states)
+	}
+	for i, id := range []string{"3", "7"} {
+		light := scene[i].(map[string]interface{})
+		if state := states[id]; !state.On || state.Brightness != light["bri"] || state.XY[0] != light["xy"].([]interface{})[0] {
+			t.Errorf("Expected light %s to match %v, got %+v", id, light, state)
+		}
+	}
+}
+
+// TestHandleGetColors_OBS tests that format=obs returns overlay colors with their contrast and matching CSS properties
+func TestHandleGetColors_OBS(t *testing.T) {
+	app := newCachedTestApp(t)
+
+	w := httptest.NewRecorder()
+	app.handleGetColors(w, httptest.NewRequest("GET", "/api/colors?format=obs", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var obs OBSTheme
+	if err := json.Unmarshal(w.Body.Bytes(), &obs); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if obs.Colors.Gradient != "linear-gradient(135deg, #c67d3a, #6b8d7d)" {
+		t.Errorf("Expected the day's gradient, got %q", obs.Colors.Gradient)
+	}
+	if obs.Contrast["text"] < 7 || obs.Contrast["text_muted"] < 4.5 || obs.Contrast["accent"] < 4.5 || obs.Contrast["accent_text"] < 4.5 {
+		t.Errorf("Expected verified contrast, got %v", obs.Contrast)
+	}
+	for _, property := range []string{"--dailyhues-text: " + obs.Colors.Text + ";", "--dailyhues-accent: " + obs.Colors.Accent + ";", "--dailyhues-background: rgba("} {
+		if !strings.Contains(obs.CSS, property) {
+			t.Errorf("Expected CSS to declare %q, got:\n%s", property, obs.CSS)
+		}
+	}
+}
+
+// TestHandleGetColors_VerifyImages tests that VERIFY_IMAGE_URLS drops missing resolutions, adds sizes and checks each URL once
+func TestHandleGetColors_VerifyImages(t *testing.T) {
+	var heads atomic.Int32
+	bingServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		heads.Add(1)
+		switch r.URL.Path {
+		case "/verify_UHD.jpg":
+			w.Header().Set("Content-Length", "3245678")
+		case "/verify_1920x1080.jpg":
+			w.Header().Set("Content-Length", "320000")
+		case "/verify_800x600.jpg":
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer bingServer.Close()
+
+	app := newCachedTestApp(t)
+	startDate, fullStartDate, endDate := testWallpaperDates(0)
+	app.requestCache.Set(defaultLocale, 0, "cached0123456789012345678901234567890123456789012345678901234", map[string]string{
+		"UHD":       bingServer.URL + "/verify_UHD.jpg",
+		"1920x1200": bingServer.URL + "/verify_1920x1200.jpg",
+		"1920x1080": bingServer.URL + "/verify_1920x1080.jpg",
+		"800x600":   bingServer.URL + "/verify_800x600.jpg",
+	}, "Title", "Copyright", "", startDate, fullStartDate, endDate, time.Now().Add(time.Hour))
+
+	t.Setenv("VERIFY_IMAGE_URLS", "true")
+	t.Setenv("BING_RETRIES", "0") // Count each check once
+	for range 2 {
+		w := httptest.NewRecorder()
+		app.handleGetColors(w, httptest.NewRequest("GET", "/api/colors", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var theme ColorTheme
+		if err := json.Unmarshal(w.Body.Bytes(), &theme); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+
+		if _, ok := theme.Images["1920x1200"]; ok {
+			t.Error("Expected the missing resolution to be dropped")
+		}
+		if _, ok := theme.Images["800x600"]; !ok {
+			t.Error("Expected a resolution that could not be checked to be kept")
+		}
+		if len(theme.Images) != 3 {
+			t.Errorf("Expected 3 resolutions, got %v", theme.Images)
+		}
+		if theme.ImageSizes["UHD"] != 3245678 || theme.ImageSizes["1920x1080"] != 320000 || len(theme.ImageSizes) != 2 {
+			t.Errorf("Expected sizes of the existing resolutions, got %v", theme.ImageSizes)
+		}
+	}
+
+	// The failed check is repeated, the others are remembered
+	if n := heads.Load(); n != 5 {
+		t.Errorf("Expected 5 HEAD requests, got %d", n)
+	}
+	if entry := app.requestCache.Get(defaultLocale, 0); len(entry.ImageURLs) != 4 {
+		t.Errorf("Expected the cached URLs to be left alone, got %v", entry.ImageURLs)
+	}
+}
+
+// TestHandleGetColors_PromptThemes tests the starship and oh-my-posh themes and that they share the palette's colors
+func TestHandleGetColors_PromptThemes(t *testing.T) {
+	app := newCachedTestApp(t)
+
+	w := httptest.NewRecorder()
+	app.handleGetColors(w, httptest.NewRequest("GET", "/api/colors?format=starship", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != "application/toml" {
+		t.Errorf("Expected application/toml, got %q", contentType)
+	}
+	starship := w.Body.String()
+	for _, line := range []string{`palette = "dailyhues"`, "[palettes.dailyhues]", `segment_1 = "#c67d3a"`, `segment_3 = "#6b8d7d"`, `style = "bg:segment_2 fg:segment_2_fg"`} {
+		if !strings.Contains(starship, line) {
+			t.Errorf("Expected starship config to contain %q, got:\n%s", line, starship)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	app.handleGetColors(w, httptest.NewRequest("GET", "/api/colors?format=ohmyposh", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var theme ohMyPoshTheme
+	if err := json.Unmarshal(w.Body.Bytes(), &theme); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if theme.Palette["segment_1"] != "#c67d3a" || theme.Palette["segment_3"] != "#6b8d7d" {
+		t.Errorf("Expected segments along the gradient, got %v", theme.Palette)
+	}
+	for _, segment := range theme.Blocks[0].Segments {
+		if theme.Palette[strings.TrimPrefix(segment.Foreground, "p:")] == "" {
+			t.Errorf("Expected %s segment text to use a palette color, got %q", segment.Type, segment.Foreground)
+		}
+	}
+	if !strings.Contains(starship, `segment_1_fg = "`+theme.Palette["segment_1_fg"]+`"`) {
+		t.Errorf("Expected both themes to use the same segment text color %s", theme.Palette["segment_1_fg"])
+	}
+}
+
+// TestHandleGetColors_Nvim tests the Lua module's colors and highlight groups
+func TestHandleGetColors_Nvim(t *testing.T) {
+	app := newCachedTestApp(t)
+
+	w := httptest.NewRecorder()
+	app.handleGetColors(w, httptest.NewRequest("GET", "/api/colors?format=nvim", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if contentType := w.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/x-lua") {
+		t.Errorf("Expected a Lua content type, got %q", contentType)
+	}
+
+	module := w.Body.String()
+	for _, line := range []string{
+		`border = "#c67d3a",`,
+		`vim.api.nvim_set_hl(0, "WinSeparator", { fg = c.inactive_border })`,
+		`vim.api.nvim_set_hl(0, "StatusLine", { fg = c.statusline_fg, bg = c.statusline })`,
+		`vim.api.nvim_set_hl(0, "CursorLine", { bg = c.cursorline })`,
+		"return M\n",
+	} {
+		if !strings.Contains(module, line) {
+			t.Errorf("Expected module to contain %q, got:\n%s", line, module)
+		}
+	}
+}
+
+// TestHandleGetColors_Rofi tests the .rasi theme's variables and that its contrast is reported
+func TestHandleGetColors_Rofi(t *testing.T) {
+	app := newCachedTestApp(t)
+
+	w := httptest.NewRecorder()
+	app.handleGetColors(w, httptest.NewRequest("GET", "/api/colors?format=rofi", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	rasi := w.Body.String()
+	overlay, _ := palette.Gradient{From: "#c67d3a", To: "#6b8d7d", Angle: 135}.Overlay()
+	for _, line := range []string{
+		"    foreground:       " + overlay.Text + ";",
+		"    background:       " + overlay.Background + "cc;",
+		"    border-color:     #c67d3a;",
+		"element selected.normal {",
+		"/* Contrast: text ",
+	} {
+		if !strings.Contains(rasi, line) {
+			t.Errorf("Expected theme to contain %q, got:\n%s", line, rasi)
+		}
+	}
+}
+
+// TestHandleGetColors_AppConfigs tests the zathura and qutebrowser settings and that both use the overlay colors
+func TestHandleGetColors_AppConfigs(t *testing.T) {
+	app := newCachedTestApp(t)
+	overlay, _ := palette.Gradient{From: "#c67d3a", To: "#6b8d7d", Angle: 135}.Overlay()
+
+	for _, tc := range []struct {
+		format string
+		lines  []string
+	}{
+		{"zathura", []string{
+			"set recolor true\n",
+			`set recolor-lightcolor "` + overlay.Background + `"`,
+			`set recolor-darkcolor "` + overlay.Text + `"`,
+			`set index-active-bg "` + overlay.Accent + `"`,
+		}},
+		{"qutebrowser", []string{
+			`    "accent": "` + overlay.Accent + `",`,
+			`    "gradient_to": "#6b8d7d",`,
+			`c.colors.tabs.selected.even.bg = dailyhues["accent"]`,
+			`c.colors.statusbar.normal.fg = dailyhues["text"]`,
+		}},
+	} {
+		w := httptest.NewRecorder()
+		app.handleGetColors(w, httptest.NewRequest("GET", "/api/colors?format="+tc.format, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d: %s", tc.format, w.Code, w.Body.String())
+		}
+		for _, line := range tc.lines {
+			if !strings.Contains(w.Body.String(), line) {
+				t.Errorf("%s: expected %q, got:\n%s", tc.format, line, w.Body.String())
+			}
+		}
+	}
+}
+
+// TestAnalysisImage tests that ANALYSIS_IMAGE_SIZE picks the analyzed size, falling back to the default image
+func TestAnalysisImage(t *testing.T) {
+	t.Setenv("BING_MIN_INTERVAL", "0s")
+	t.Setenv("BING_RETRIES", "0")
+	bingServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/small_800x600.jpg":
+			fmt.Fprint(w, "small")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer bingServer.Close()
+
+	app := newCachedTestApp(t)
+	infoFor := func(name string) *bing.WallpaperInfo {
+		return &bing.WallpaperInfo{
+			URL: bingServer.URL + "/" + name + "_1920x1080.jpg",
+			ImageURLs: map[string]string{
+				"1920x1080": bingServer.URL + "/" + name + "_1920x1080.jpg",
+				"800x600":   bingServer.URL + "/" + name + "_800x600.jpg",
+			},
+		}
+	}
+
+	for _, tc := range []struct {
+		setting, name, wantData, wantSize string
+	}{
+		{"", "small", "default", ""},
+		{"800x600", "small", "small", "800x600"},
+		{"800x600", "missing", "default", ""},
+		{"1920x1080", "small", "default", ""},
+		{"tiny", "small", "default", ""},
+	} {
+		t.Setenv("ANALYSIS_IMAGE_SIZE", tc.setting)
+		data, size := app.imageToAnalyze(context.Background(), infoFor(tc.name), []byte("default"))
+		if string(data) != tc.wantData || size != tc.wantSize {
+			t.Errorf("%q, %s: expected %q at %q, got %q at %q", tc.setting, tc.name, tc.wantData, tc.wantSize, data, size)
+		}
+	}
+}
+
+// TestHandleGetColors_Archive tests that days Bing has forgotten are served from the caches, by daysAgo or date
+func TestHandleGetColors_Archive(t *testing.T) {
+	app := newCachedTestApp(t)
+	archivedHash := "archived012345678901234567890123456789012345678901234567890123"
+	app.analysisCache.Set(archivedHash, map[string]interface{}{"gradient_from": "#203040", "gradient_to": "#405060", "gradient_angle": float64(90)})
+	startDate, fullStartDate, endDate := testWallpaperDates(30)
+	app.requestCache.Set(defaultLocale, 30, archivedHash, map[string]string{"UHD": "https://bing.com/old.jpg"}, "Archived", "Copyright", "", startDate, fullStartDate, endDate, time.Now().Add(-29*24*time.Hour))
+
+	day, _ := time.Parse("20060102", startDate)
+	for _, query := range []string{"daysAgo=30", "date=" + day.Format("2006-01-02")} {
+		w := httptest.NewRecorder()
+		app.handleGetColors(w, httptest.NewRequest("GET", "/api/colors?"+query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d: %s", query, w.Code, w.Body.String())
+		}
+		var theme ColorTheme
+		if err := json.Unmarshal(w.Body.Bytes(), &theme); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if theme.Title != "Archived" || theme.StartDate != startDate {
+			t.Errorf("%s: expected the archived day %s, got %q from %s", query, startDate, theme.Title, theme.StartDate)
+		}
+	}
+
+	for query, want := range map[string]int{
+		"daysAgo=20": http.StatusNotFound,
+		"date=" + day.Format("2006-01-02") + "&daysAgo=30": http.StatusBadRequest,
+		"date=2025-13-01": http.StatusBadRequest,
+		"date=" + time.Now().AddDate(0, 0, 2).Format("2006-01-02"): http.StatusBadRequest,
+	} {
+		w := httptest.NewRecorder()
+		app.handleGetColors(w, httptest.NewRequest("GET", "/api/colors?"+query, nil))
+		if w.Code != want {
+			t.Errorf("%s: expected status %d, got %d: %s", query, want, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/mgabor3141/dailyhues/internal/cache"
+)
+
+// MigrateResult counts what a cache migration copied and skipped
+type MigrateResult struct {
+	Requests        int
+	Analyses        int
+	SkippedRequests int // Already present in the destination
+	SkippedAnalyses int
+}
+
+// runCache handles the cache subcommands: dailyhues cache migrate --from file://cache_data --to file://new_cache
+func runCache(args []string) int {
+	if len(args) == 0 || args[0] != "migrate" {
+		fmt.Fprintln(os.Stderr, "usage: dailyhues cache migrate --from file://cache_data --to file://new_cache")
+		return 2
+	}
+
+	flags := flag.NewFlagSet("cache migrate", flag.ContinueOnError)
+	fromFlag := flags.String("from", "file://"+cacheDir(), "cache to copy from")
+	toFlag := flags.String("to", "", "cache to copy into")
+	overwriteFlag := flags.Bool("overwrite", false, "replace entries that already exist in the destination")
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	fromDir, err := parseCacheBackend(*fromFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "invalid --from:", err)
+		return 2
+	}
+	toDir, err := parseCacheBackend(*toFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "invalid --to:", err)
+		return 2
+	}
+
+	result, err := migrateCache(fromDir, toDir, *overwriteFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	fmt.Printf("requests\t%d copied\t%d skipped\n", result.Requests, result.SkippedRequests)
+	fmt.Printf("analyses\t%d copied\t%d skipped\n", result.Analyses, result.SkippedAnalyses)
+	return 0
+}
+
+// parseCacheBackend resolves a cache location to a directory
+// Only the file backend exists; other schemes are rejected until a backend for them is implemented
+func parseCacheBackend(location string) (string, error) {
+	if location == "" {
+		return "", fmt.Errorf("a cache location is required, e.g. file://cache_data")
+	}
+
+	scheme, rest, found := strings.Cut(location, "://")
+	if !found {
+		// A bare path is a file cache
+		return location, nil
+	}
+	if scheme != "file" {
+		return "", fmt.Errorf("unsupported cache backend %q (only file:// is available)", scheme)
+	}
+
+	path, err := url.PathUnescape(rest)
+	if err != nil || path == "" {
+		return "", fmt.Errorf("invalid file cache location %q", location)
+	}
+	return path, nil
+}
+
+// migrateCache copies every request and analysis entry from one cache directory to another
+// Entries keep their timestamps, pins and usage data, so nothing needs to be re-analyzed
+func migrateCache(fromDir, toDir string, overwrite bool) (MigrateResult, error) {
+	if fromDir == toDir {
+		return MigrateResult{}, fmt.Errorf("source and destination are the same cache")
+	}
+
+	fromRequests, err := cache.NewRequestCache(fromDir)
+	if err != nil {
+		return MigrateResult{}, err
+	}
+	fromAnalyses, err := cache.NewAnalysisCache(fromDir)
+	if err != nil {
+		return MigrateResult{}, err
+	}
+	if err := fromRequests.LoadAll(); err != nil {
+		return MigrateResult{}, err
+	}
+	if err := fromAnalyses.LoadAll(); err != nil {
+		return MigrateResult{}, err
+	}
+
+	toRequests, err := cache.NewRequestCache(toDir)
+	if err != nil {
+		return MigrateResult{}, err
+	}
+	toAnalyses, err := cache.NewAnalysisCache(toDir)
+	if err != nil {
+		return MigrateResult{}, err
+	}
+	if err := toRequests.LoadAll(); err != nil {
+		return MigrateResult{}, err
+	}
+	if err := toAnalyses.LoadAll(); err != nil {
+		return MigrateResult{}, err
+	}
+
+	var result MigrateResult
+
+	// Analyses first, so the destination never has request entries pointing at missing analyses
+	for _, entry := range fromAnalyses.Entries() {
+		if !overwrite && toAnalyses.Get(entry.ImageHash) != nil {
+			result.SkippedAnalyses++
+			continue
+		}
+		if err := toAnalyses.SetEntry(entry); err != nil {
+			return result, fmt.Errorf("failed to copy analysis %s: %w", entry.ImageHash, err)
+		}
+		result.Analyses++
+	}
+
+	for _, entry := range fromRequests.Entries() {
+		if !overwrite && toRequests.GetByDate(entry.Locale, entry.StartDate) != nil {
+			result.SkippedRequests++
+			continue
+		}
+		if err := toRequests.SetEntry(entry); err != nil {
+			return result, fmt.Errorf("failed to copy request %s %s: %w", entry.Locale, entry.StartDate, err)
+		}
+		result.Requests++
+	}
+
+	return result, nil
+}
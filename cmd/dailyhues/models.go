@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"time"
+
+	"github.com/mgabor3141/dailyhues/internal/ai"
+	"github.com/mgabor3141/dailyhues/internal/cache"
+)
+
+// maxModelLength keeps model parameters to a sensible size
+const maxModelLength = 100
+
+// modelPattern matches OpenRouter model IDs, e.g. "anthropic/claude-sonnet-4.5" or "meta-llama/llama-3.2-11b-vision-instruct:free"
+var modelPattern = regexp.MustCompile(`^[a-zA-Z0-9._-]+/[a-zA-Z0-9._:-]+$`)
+
+// validateModel validates the model parameter
+// Returns "" when no other model than the configured one was requested
+func validateModel(modelParam string) (string, error) {
+	if modelParam == "" || modelParam == ai.Model() {
+		return "", nil
+	}
+	if len(modelParam) > maxModelLength || !modelPattern.MatchString(modelParam) {
+		return "", fmt.Errorf("invalid model parameter. Must be an OpenRouter model ID such as %s", ai.Model())
+	}
+	return modelParam, nil
+}
+
+// colorThemeWithModel analyzes a day's wallpaper on another model, bypassing the caches in both directions
+// Served palettes never depend on which models admins have tried, and every call is a new, billed analysis
+func (app *App) colorThemeWithModel(ctx context.Context, locale string, daysAgo int, model string) (ColorTheme, error) {
+	info, err := app.bingClient.WithLocale(locale).GetWallpaperInfoByDaysAgo(ctx, daysAgo)
+	if err != nil {
+		return ColorTheme{}, fmt.Errorf("Failed to download wallpaper: %w", err)
+	}
+	imageData, err := app.bingClient.DownloadFrame(ctx, info)
+	if err != nil {
+		return ColorTheme{}, fmt.Errorf("Failed to download wallpaper: %w", err)
+	}
+
+	promptAddendum := loadPromptAddendum(sourceBing, locale)
+	imageHash := cache.AnalysisKey(cache.HashImage(imageData), promptAddendum)
+	imageData, _ = app.imageToAnalyze(ctx, info, imageData)
+
+	slog.InfoContext(ctx, "Starting AI analysis with another model", "hash", imageHash, "model", model)
+	colors, usage, err := app.aiAnalyzer.AnalyzeColorsWithModel(model, imageData, imageHash, info.Title, info.Copyright, promptAddendum)
+	if err != nil {
+		// Never cached, so there is no later analysis to add the usage to
+		if usage.Billed() {
+			slog.InfoContext(ctx, "Failed analysis with another model was billed", "hash", imageHash, "model", usage.Model,
+				"prompt_tokens", usage.PromptTokens, "completion_tokens", usage.CompletionTokens, "cost", usage.Cost)
+		}
+		return ColorTheme{}, fmt.Errorf("Failed to analyze colors: %w", err)
+	}
+
+	theme := buildColorThemeFromInfo(info, &cache.AnalysisEntry{
+		ImageHash:        imageHash,
+		PromptAddendum:   promptAddendum,
+		Colors:           loadNormalizePolicy().Apply(colors),
+		Model:            usage.Model,
+		PromptVersion:    ai.PromptVersion,
+		Source:           analysisSource(info),
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		Cost:             usage.Cost,
+		AnalyzedAt:       time.Now().UTC(),
+	})
+	// Without an image hash the response skips the render cache and validators, which are keyed by image
+	theme.imageHash = ""
+	return withUpdateSchedule(theme, daysAgo, time.Now()), nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mgabor3141/dailyhues/internal/palette"
+)
+
+// nvimHighlights are the highlight groups format=nvim sets, with the colors each one uses
+// Only these groups are changed, so the module goes on top of any colorscheme
+var nvimHighlights = []struct{ group, spec string }{
+	{"WinSeparator", "{ fg = c.inactive_border }"},
+	{"VertSplit", "{ fg = c.inactive_border }"}, // Before Neovim 0.7
+	{"FloatBorder", "{ fg = c.border }"},
+	{"FloatTitle", "{ fg = c.border, bold = true }"},
+	{"StatusLine", "{ fg = c.statusline_fg, bg = c.statusline }"},
+	{"StatusLineNC", "{ fg = c.statusline_nc_fg, bg = c.statusline_nc }"},
+	{"CursorLine", "{ bg = c.cursorline }"},
+	{"CursorLineNr", "{ fg = c.cursorline_nr, bold = true }"},
+}
+
+// marshalNvim renders a palette as a Lua module for Neovim (format=nvim)
+// The module exposes the colors and an apply() that sets the highlight groups, to call after loading a colorscheme
+func marshalNvim(data interface{}) ([]byte, error) {
+	theme, ok := data.(ColorTheme)
+	if !ok {
+		return nil, fmt.Errorf("format is only supported for single palette responses")
+	}
+	gradient, err := palette.GradientFromColors(theme.Colors)
+	if err != nil {
+		return nil, fmt.Errorf("palette has no gradient for an editor theme: %w", err)
+	}
+	colors, err := gradient.Editor()
+	if err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	title := strings.ReplaceAll(theme.Title, "\n", " ")
+	fmt.Fprintf(&b, "-- dailyhues highlights for %s (%s)\n", title, theme.StartDate)
+	b.WriteString("-- Save as lua/dailyhues.lua and call require(\"dailyhues\").apply() after your colorscheme\n")
+	b.WriteString("local M = {}\n\nM.colors = {\n")
+	for _, color := range [][2]string{
+		{"border", colors.Border},
+		{"inactive_border", colors.InactiveBorder},
+		{"statusline", colors.StatusLine},
+		{"statusline_fg", colors.StatusLineText},
+		{"statusline_nc", colors.StatusLineNC},
+		{"statusline_nc_fg", colors.StatusLineNCText},
+		{"cursorline", colors.CursorLine},
+		{"cursorline_nr", colors.CursorLineNr},
+	} {
+		fmt.Fprintf(&b, "  %s = %s,\n", color[0], strconv.Quote(color[1]))
+	}
+	b.WriteString("}\n\nfunction M.apply()\n  local c = M.colors\n")
+	for _, highlight := range nvimHighlights {
+		fmt.Fprintf(&b, "  vim.api.nvim_set_hl(0, %q, %s)\n", highlight.group, highlight.spec)
+	}
+	b.WriteString("end\n\nreturn M\n")
+	return []byte(b.String()), nil
+}
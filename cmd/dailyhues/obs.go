@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/mgabor3141/dailyhues/internal/palette"
+)
+
+// OBSTheme is a palette for streaming overlays (format=obs), such as OBS browser sources
+// CSS declares the colors as custom properties, ready to paste into a browser source's custom CSS
+type OBSTheme struct {
+	StartDate string             `json:"startdate"`
+	Title     string             `json:"title"`
+	Colors    OBSColors          `json:"colors"`
+	Contrast  map[string]float64 `json:"contrast"` // WCAG ratio of each text color against the background (accent_text: against the accent)
+	CSS       string             `json:"css"`
+}
+
+// OBSColors are the overlay's colors
+type OBSColors struct {
+	Background        string  `json:"background"`
+	BackgroundOpacity float64 `json:"background_opacity"`
+	Text              string  `json:"text"`
+	TextMuted         string  `json:"text_muted"`
+	Accent            string  `json:"accent"`
+	AccentText        string  `json:"accent_text"`
+	Gradient          string  `json:"gradient"` // The day's gradient as CSS, for borders and progress bars
+}
+
+// marshalOBS renders a palette as an overlay theme, encoding it with marshal
+func marshalOBS(marshal func(interface{}) ([]byte, error)) func(interface{}) ([]byte, error) {
+	return func(data interface{}) ([]byte, error) {
+		theme, ok := data.(ColorTheme)
+		if !ok {
+			return nil, fmt.Errorf("format is only supported for single palette responses")
+		}
+		obs, err := obsTheme(theme)
+		if err != nil {
+			return nil, err
+		}
+		return marshal(obs)
+	}
+}
+
+// themeOverlay derives the overlay colors of a theme's gradient, which the app themes built on a dark background share
+// use names what they are for in the error
+func themeOverlay(theme ColorTheme, use string) (palette.Gradient, palette.OverlayColors, error) {
+	gradient, err := palette.GradientFromColors(theme.Colors)
+	if err != nil {
+		return palette.Gradient{}, palette.OverlayColors{}, fmt.Errorf("palette has no gradient for %s: %w", use, err)
+	}
+	overlay, err := gradient.Overlay()
+	return gradient, overlay, err
+}
+
+// obsTheme derives the overlay colors from a theme's gradient and reports their contrast
+func obsTheme(theme ColorTheme) (OBSTheme, error) {
+	gradient, overlay, err := themeOverlay(theme, "an overlay")
+	if err != nil {
+		return OBSTheme{}, err
+	}
+
+	colors := OBSColors{
+		Background:        overlay.Background,
+		BackgroundOpacity: overlay.BackgroundOpacity,
+		Text:              overlay.Text,
+		TextMuted:         overlay.TextMuted,
+		Accent:            overlay.Accent,
+		AccentText:        overlay.AccentText,
+		Gradient:          gradient.CSS(),
+	}
+	return OBSTheme{
+		StartDate: theme.StartDate,
+		Title:     theme.Title,
+		Colors:    colors,
+		Contrast: map[string]float64{
+			"text":        hexContrast(colors.Text, colors.Background),
+			"text_muted":  hexContrast(colors.TextMuted, colors.Background),
+			"accent":      hexContrast(colors.Accent, colors.Background),
+			"accent_text": hexContrast(colors.AccentText, colors.Accent),
+		},
+		CSS: obsCSS(colors),
+	}, nil
+}
+
+// hexContrast returns the WCAG contrast ratio of two hex colors, rounded like /api/contrast
+func hexContrast(a, b string) float64 {
+	ca, _ := palette.ParseHex(a)
+	cb, _ := palette.ParseHex(b)
+	return math.Round(palette.ContrastRatio(ca, cb)*100) / 100
+}
+
+// obsCSS declares the overlay colors as --dailyhues-* custom properties on :root
+// The background is declared with its opacity applied, so it can be used as is
+func obsCSS(colors OBSColors) string {
+	background, _ := palette.ParseHex(colors.Background)
+	r, g, b := background.Bytes()
+
+	var css strings.Builder
+	css.WriteString(":root {\n")
+	for _, property := range [][2]string{
+		{"background", fmt.Sprintf("rgba(%d, %d, %d, %g)", r, g, b, colors.BackgroundOpacity)},
+		{"text", colors.Text},
+		{"text-muted", colors.TextMuted},
+		{"accent", colors.Accent},
+		{"accent-text", colors.AccentText},
+		{"gradient", colors.Gradient},
+	} {
+		fmt.Fprintf(&css, "  --dailyhues-%s: %s;\n", property[0], property[1])
+	}
+	css.WriteString("}\n")
+	return css.String()
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/mgabor3141/dailyhues/internal/auth"
+	"github.com/mgabor3141/dailyhues/internal/cache"
+	"github.com/mgabor3141/dailyhues/internal/palette"
+)
+
+// pinRequest is the body of POST /admin/pins
+// The target is either an image hash, or a date (YYYY-MM-DD) and locale of a cached wallpaper
+type pinRequest struct {
+	ImageHash string                 `json:"image_hash"`
+	Date      string                 `json:"date"`
+	Locale    string                 `json:"locale"`
+	Colors    map[string]interface{} `json:"colors"`
+	Reason    string                 `json:"reason"`
+}
+
+// handlePins lists (GET), sets (POST) or removes (DELETE) operator pinned palettes
+func (app *App) handlePins(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		respondWithJSON(w, http.StatusOK, map[string]interface{}{"pins": app.analysisCache.Pinned()})
+	case http.MethodPost:
+		app.handleSetPin(w, r)
+	case http.MethodDelete:
+		app.handleRemovePin(w, r)
+	default:
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// handleSetPin replaces the AI palette of an image with the operator's colors
+func (app *App) handleSetPin(w http.ResponseWriter, r *http.Request) {
+	var req pinRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+
+	imageHash, err := app.resolvePinTarget(req.ImageHash, req.Date, req.Locale)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	colors, err := validatePinColors(req.Colors)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	entry, err := app.pinColors(imageHash, colors, auth.SubjectFromContext(r.Context()), req.Reason)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Pinned palette", "hash", imageHash, "by", entry.Pin.By, "reason", entry.Pin.Reason)
+	app.audit(r, "pin.set", []string{imageHash}, map[string]string{
+		"reason":        req.Reason,
+		"gradient_from": fmt.Sprint(colors["gradient_from"]),
+		"gradient_to":   fmt.Sprint(colors["gradient_to"]),
+	})
+	respondWithJSON(w, http.StatusOK, entry)
+}
+
+// handleRemovePin restores the AI palette of a pinned image
+func (app *App) handleRemovePin(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	imageHash, err := app.resolvePinTarget(query.Get("image_hash"), query.Get("date"), query.Get("locale"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	imageMutex := app.analysisCache.GetMutex(imageHash)
+	imageMutex.Lock()
+	defer imageMutex.Unlock()
+	defer app.analysisCache.ReleaseMutex(imageHash)
+
+	existing := app.analysisCache.Get(imageHash)
+	if existing == nil || existing.Pin == nil {
+		respondWithError(w, http.StatusNotFound, "No pinned palette for this image")
+		return
+	}
+
+	// Images pinned before they were ever analyzed are analyzed on the next request
+	if existing.Pin.AIColors == nil {
+		if err := app.analysisCache.Delete(imageHash); err != nil {
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		slog.InfoContext(r.Context(), "Removed pinned palette", "hash", imageHash, "by", auth.SubjectFromContext(r.Context()))
+		app.audit(r, "pin.remove", []string{imageHash}, map[string]string{"analysis": "deleted"})
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	entry := *existing
+	entry.Colors = existing.Pin.AIColors
+	entry.Pin = nil
+	if err := app.analysisCache.SetEntry(entry); err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Removed pinned palette", "hash", imageHash, "by", auth.SubjectFromContext(r.Context()))
+	app.audit(r, "pin.remove", []string{imageHash}, map[string]string{"analysis": "restored"})
+	respondWithJSON(w, http.StatusOK, entry)
+}
+
+// resolvePinTarget returns the image hash to pin, looking it up from the request cache when a date is given
+func (app *App) resolvePinTarget(imageHash, date, locale string) (string, error) {
+	if imageHash != "" {
+		if !cache.ValidHash(imageHash) {
+			return "", fmt.Errorf("invalid image_hash")
+		}
+		return imageHash, nil
+	}
+
+	if date == "" {
+		return "", fmt.Errorf("image_hash or date is required")
+	}
+
+	day, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return "", fmt.Errorf("invalid date. Use YYYY-MM-DD")
+	}
+
+	locale, err = validateLocale(locale)
+	if err != nil {
+		return "", err
+	}
+
+	reqEntry := app.requestCache.GetByDate(locale, day.Format("20060102"))
+	if reqEntry == nil {
+		return "", fmt.Errorf("no cached wallpaper for %s on %s", locale, date)
+	}
+	return reqEntry.ImageHash, nil
+}
+
+// validatePinColors checks that the pinned colors contain a usable gradient and normalizes them
+// like an AI result
+func validatePinColors(colors map[string]interface{}) (map[string]interface{}, error) {
+	gradient, err := palette.GradientFromColors(colors)
+	if err != nil {
+		return nil, fmt.Errorf("invalid colors: %w", err)
+	}
+	if _, err := palette.ParseHex(gradient.From); err != nil {
+		return nil, fmt.Errorf("invalid colors: %w", err)
+	}
+	if _, err := palette.ParseHex(gradient.To); err != nil {
+		return nil, fmt.Errorf("invalid colors: %w", err)
+	}
+
+	return loadNormalizePolicy().Apply(colors), nil
+}
+
+// pinColors stores the operator's colors for an image, keeping the AI result so the pin can be removed
+func (app *App) pinColors(imageHash string, colors map[string]interface{}, by, reason string) (cache.AnalysisEntry, error) {
+	imageMutex := app.analysisCache.GetMutex(imageHash)
+	imageMutex.Lock()
+	defer imageMutex.Unlock()
+	defer app.analysisCache.ReleaseMutex(imageHash)
+
+	entry := cache.AnalysisEntry{ImageHash: imageHash}
+	var aiColors map[string]interface{}
+	if existing := app.analysisCache.Get(imageHash); existing != nil {
+		entry = *existing
+		aiColors = existing.Colors
+		if existing.Pin != nil {
+			// Re-pinning keeps the original AI result
+			aiColors = existing.Pin.AIColors
+		}
+	}
+
+	entry.Colors = colors
+	entry.Pin = &cache.Pin{
+		By:       by,
+		Reason:   reason,
+		At:       time.Now().UTC(),
+		AIColors: aiColors,
+	}
+
+	if err := app.analysisCache.SetEntry(entry); err != nil {
+		return cache.AnalysisEntry{}, fmt.Errorf("failed to store pinned palette: %w", err)
+	}
+	return entry, nil
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mgabor3141/dailyhues/internal/cache"
+	"github.com/mgabor3141/dailyhues/internal/palette"
+	"github.com/mgabor3141/dailyhues/internal/pipeline"
+)
+
+// Palette profiles selectable with ?profile=
+const (
+	profileDefault    = "default"
+	profileLockscreen = "lockscreen"
+	profileFull       = "full"
+)
+
+// builtinProfiles are the profiles prompt profiles cannot replace
+var builtinProfiles = []string{profileDefault, profileLockscreen, profileFull}
+
+// validateProfile validates the profile parameter, defaulting to the window border palette
+// For a prompt profile from loadPromptProfiles the prompt is returned as well; it is "" for the built-in profiles
+func validateProfile(param string) (profile, prompt string, err error) {
+	switch param {
+	case "", profileDefault:
+		return profileDefault, "", nil
+	case profileLockscreen, profileFull:
+		return param, "", nil
+	}
+
+	profiles := loadPromptProfiles()
+	if prompt, ok := profiles[param]; ok {
+		return param, prompt, nil
+	}
+	supported := append(slices.Clone(builtinProfiles), slices.Sorted(maps.Keys(profiles))...)
+	return "", "", fmt.Errorf("invalid profile. Supported profiles: %s", strings.Join(supported, ", "))
+}
+
+// withProfile replaces the theme's colors with those of the requested profile
+func withProfile(theme ColorTheme, profile string) (ColorTheme, error) {
+	if profile != profileLockscreen {
+		return theme, nil
+	}
+
+	gradient, err := palette.GradientFromColors(theme.Colors)
+	if err != nil {
+		return ColorTheme{}, fmt.Errorf("Invalid palette for lockscreen profile: %w", err)
+	}
+
+	lock, err := gradient.Lockscreen()
+	if err != nil {
+		return ColorTheme{}, fmt.Errorf("Invalid palette for lockscreen profile: %w", err)
+	}
+
+	theme.Colors = map[string]interface{}{
+		"background_tint":    lock.BackgroundTint,
+		"background_opacity": lock.BackgroundOpacity,
+		"clock":              lock.Clock,
+		"input_inner":        lock.InputInner,
+		"input_outer":        lock.InputOuter,
+		"input_text":         lock.InputText,
+		"input_check":        lock.InputCheck,
+		"input_fail":         lock.InputFail,
+	}
+	return theme, nil
+}
+
+// profileCaches holds the analysis cache of each prompt profile, opened on first use
+type profileCaches struct {
+	mu     sync.Mutex
+	dir    string // Cache directory the profiles are stored under; CACHE_DIR if empty
+	caches map[string]*cache.AnalysisCache
+}
+
+// get returns the analysis cache of a prompt profile, loading its entries the first time
+func (p *profileCaches) get(profile string) (*cache.AnalysisCache, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if analyses, ok := p.caches[profile]; ok {
+		return analyses, nil
+	}
+
+	dir := p.dir
+	if dir == "" {
+		dir = cacheDir()
+	}
+	analyses, err := cache.NewProfileAnalysisCache(dir, profile)
+	if err != nil {
+		return nil, err
+	}
+	if err := analyses.LoadAll(); err != nil {
+		return nil, err
+	}
+
+	if p.caches == nil {
+		p.caches = make(map[string]*cache.AnalysisCache)
+	}
+	p.caches[profile] = analyses
+	return analyses, nil
+}
+
+// analysisProfile returns the pipeline profile a palette profile is resolved with
+// The lockscreen colors are derived from the default analysis by withProfile, so they need no analysis of their own
+func analysisProfile(profile string) string {
+	if profile == profileDefault || profile == profileLockscreen {
+		return ""
+	}
+	return profile
+}
+
+// profileAnalysis returns the analysis of a day's wallpaper for the full or a prompt profile, for the pipeline
+// Prompt profiles analyze the wallpaper again with the profile's prompt. Results are kept in the profile's own cache,
+// keyed by the image and the prompt, so editing a prompt leads to a new analysis
+func (app *App) profileAnalysis(profile string, base pipeline.Palette) (cache.AnalysisEntry, error) {
+	if profile == profileFull {
+		return app.fullPaletteAnalysis(base)
+	}
+
+	prompt, ok := loadPromptProfiles()[profile]
+	if !ok {
+		return cache.AnalysisEntry{}, fmt.Errorf("Unknown profile %s", profile)
+	}
+	if base.Fallback != "" {
+		return cache.AnalysisEntry{}, fmt.Errorf("Failed to resolve wallpaper for profile %s", profile)
+	}
+
+	analyses, err := app.profileCaches.get(profile)
+	if err != nil {
+		return cache.AnalysisEntry{}, fmt.Errorf("Failed to open cache for profile %s: %w", profile, err)
+	}
+
+	key := cache.AnalysisKey(cache.ImageHashOf(base.Request.ImageHash), prompt)
+	if analysisEntry := analyses.Get(key); analysisEntry != nil {
+		return *analysisEntry, nil
+	}
+
+	analysisEntry, err := app.analyzeProfile(&base.Request, key, prompt)
+	if err != nil {
+		return cache.AnalysisEntry{}, err
+	}
+	if err := analyses.SetEntry(analysisEntry); err != nil {
+		slog.Info("Failed to cache profile analysis", "profile", profile, "error", err)
+	}
+	return analysisEntry, nil
+}
+
+// analyzeProfile downloads the image of a request entry and analyzes it with a profile's prompt
+func (app *App) analyzeProfile(reqEntry *cache.RequestEntry, key, prompt string) (cache.AnalysisEntry, error) {
+	info, imageData, err := app.downloadCachedWallpaper(reqEntry)
+	if err != nil {
+		return cache.AnalysisEntry{}, fmt.Errorf("Failed to download wallpaper: %w", err)
+	}
+	if hash := cache.HashImage(imageData); hash != cache.ImageHashOf(key) {
+		return cache.AnalysisEntry{}, fmt.Errorf("Failed to download wallpaper: image changed since it was analyzed (now %s)", hash)
+	}
+
+	slog.Info("Starting AI analysis for prompt profile", "hash", key)
+	imageData, analyzedSize := app.imageToAnalyze(context.Background(), info, imageData)
+	colors, usage, err := app.aiAnalyzer.AnalyzeWithPrompt(prompt, imageData, key, info.Title)
+	if err != nil {
+		app.recordFailedUsage(key, usage)
+		return cache.AnalysisEntry{}, fmt.Errorf("Failed to analyze colors: %w", err)
+	}
+	usage = app.failedUsage.take(key, usage)
+
+	return cache.AnalysisEntry{
+		ImageHash:        key,
+		Prompt:           prompt,
+		Colors:           colors,
+		Model:            usage.Model,
+		Source:           analysisSource(info),
+		AnalyzedSize:     analyzedSize,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		Cost:             usage.Cost,
+		AnalyzedAt:       time.Now().UTC(),
+	}, nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mgabor3141/dailyhues/internal/palette"
+)
+
+const (
+	// promptSegments is how many colored segments the prompt themes have: directory, git branch and command duration
+	promptSegments = 3
+
+	ohMyPoshSchema = "https://raw.githubusercontent.com/JanDeDobbeleer/oh-my-posh/main/themes/schema.json"
+	// powerlineSymbol separates oh-my-posh segments; it needs a Nerd Font, as oh-my-posh's own themes do
+	powerlineSymbol = "\ue0b0"
+)
+
+// promptColors derives the prompt colors of a theme's gradient
+func promptColors(theme ColorTheme) (palette.PromptColors, error) {
+	gradient, err := palette.GradientFromColors(theme.Colors)
+	if err != nil {
+		return palette.PromptColors{}, fmt.Errorf("palette has no gradient for a prompt theme: %w", err)
+	}
+	return gradient.Prompt(promptSegments)
+}
+
+// promptPalette names the prompt colors as both themes refer to them: segment_1, segment_1_fg, ..., accent and error
+func promptPalette(colors palette.PromptColors) [][2]string {
+	var names [][2]string
+	for i, segment := range colors.Segments {
+		names = append(names,
+			[2]string{fmt.Sprintf("segment_%d", i+1), segment.Background},
+			[2]string{fmt.Sprintf("segment_%d_fg", i+1), segment.Foreground},
+		)
+	}
+	return append(names, [2]string{"accent", colors.Accent}, [2]string{"error", colors.Error})
+}
+
+// marshalStarship renders a palette as a starship.toml (format=starship)
+// The palette is declared as [palettes.dailyhues], so its colors can also be used by name in other modules
+func marshalStarship(data interface{}) ([]byte, error) {
+	theme, ok := data.(ColorTheme)
+	if !ok {
+		return nil, fmt.Errorf("format is only supported for single palette responses")
+	}
+	colors, err := promptColors(theme)
+	if err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# dailyhues prompt for %s (%s)\n", theme.Title, theme.StartDate)
+	b.WriteString(`palette = "dailyhues"` + "\n")
+	b.WriteString(`format = "$directory$git_branch$cmd_duration$line_break$character"` + "\n")
+
+	modules := []struct{ name, format string }{
+		{"directory", "[ $path ]($style)"},
+		{"git_branch", "[ $symbol$branch ]($style)"},
+		{"cmd_duration", "[ $duration ]($style)"},
+	}
+	for i, module := range modules {
+		fmt.Fprintf(&b, "\n[%s]\n", module.name)
+		fmt.Fprintf(&b, "style = %s\n", strconv.Quote(fmt.Sprintf("bg:segment_%d fg:segment_%d_fg", i+1, i+1)))
+		fmt.Fprintf(&b, "format = %s\n", strconv.Quote(module.format))
+	}
+
+	b.WriteString("\n[character]\n")
+	b.WriteString(`success_symbol = "[❯](bold accent)"` + "\n")
+	b.WriteString(`error_symbol = "[❯](bold error)"` + "\n")
+
+	b.WriteString("\n[palettes.dailyhues]\n")
+	for _, color := range promptPalette(colors) {
+		fmt.Fprintf(&b, "%s = %s\n", color[0], strconv.Quote(color[1]))
+	}
+	return []byte(b.String()), nil
+}
+
+// ohMyPoshTheme is an oh-my-posh theme file, limited to the fields format=ohmyposh sets
+type ohMyPoshTheme struct {
+	Schema     string            `json:"$schema"`
+	Version    int               `json:"version"`
+	FinalSpace bool              `json:"final_space"`
+	Palette    map[string]string `json:"palette"`
+	Blocks     []ohMyPoshBlock   `json:"blocks"`
+}
+
+type ohMyPoshBlock struct {
+	Type      string            `json:"type"`
+	Alignment string            `json:"alignment"`
+	Newline   bool              `json:"newline,omitempty"`
+	Segments  []ohMyPoshSegment `json:"segments"`
+}
+
+type ohMyPoshSegment struct {
+	Type                string   `json:"type"`
+	Style               string   `json:"style"`
+	PowerlineSymbol     string   `json:"powerline_symbol,omitempty"`
+	Background          string   `json:"background,omitempty"`
+	Foreground          string   `json:"foreground"`
+	ForegroundTemplates []string `json:"foreground_templates,omitempty"`
+	Template            string   `json:"template"`
+}
+
+// marshalOhMyPosh renders a palette as an oh-my-posh theme (format=ohmyposh), indented like the themes oh-my-posh ships
+// Colors are declared in the theme's palette and referenced as p:<name>, so they can be reused in other segments
+func marshalOhMyPosh(data interface{}) ([]byte, error) {
+	theme, ok := data.(ColorTheme)
+	if !ok {
+		return nil, fmt.Errorf("format is only supported for single palette responses")
+	}
+	colors, err := promptColors(theme)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]string)
+	for _, color := range promptPalette(colors) {
+		names[color[0]] = color[1]
+	}
+
+	var segments []ohMyPoshSegment
+	for i, segment := range []struct{ kind, template string }{
+		{"path", " {{ .Path }} "},
+		{"git", " {{ .HEAD }} "},
+		{"executiontime", " {{ .FormattedMs }} "},
+	} {
+		segments = append(segments, ohMyPoshSegment{
+			Type:            segment.kind,
+			Style:           "powerline",
+			PowerlineSymbol: powerlineSymbol,
+			Background:      fmt.Sprintf("p:segment_%d", i+1),
+			Foreground:      fmt.Sprintf("p:segment_%d_fg", i+1),
+			Template:        segment.template,
+		})
+	}
+
+	body, err := json.MarshalIndent(ohMyPoshTheme{
+		Schema:     ohMyPoshSchema,
+		Version:    3,
+		FinalSpace: true,
+		Palette:    names,
+		Blocks: []ohMyPoshBlock{
+			{Type: "prompt", Alignment: "left", Segments: segments},
+			{Type: "prompt", Alignment: "left", Newline: true, Segments: []ohMyPoshSegment{{
+				Type:                "text",
+				Style:               "plain",
+				Foreground:          "p:accent",
+				ForegroundTemplates: []string{"{{ if gt .Code 0 }}p:error{{ end }}"},
+				Template:            "❯",
+			}}},
+		},
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(body, '\n'), nil
+}
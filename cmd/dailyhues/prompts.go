@@ -0,0 +1,104 @@
+package main
+
+import (
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// sourceBing names Bing's daily wallpaper as an image source in prompt addendum settings
+const sourceBing = "bing"
+
+// loadPromptAddendum returns the operator's extra prompt instructions for an image source and locale
+// PROMPT_ADDENDUM_SOURCE_BING applies to every Bing wallpaper and PROMPT_ADDENDUM_LOCALE_JA_JP to one market;
+// when both are set they are combined. Read per analysis so a config reload takes effect immediately
+func loadPromptAddendum(source, locale string) string {
+	var parts []string
+	for _, name := range []string{
+		"PROMPT_ADDENDUM_SOURCE_" + envSuffix(source),
+		"PROMPT_ADDENDUM_LOCALE_" + envSuffix(locale),
+	} {
+		if value := strings.TrimSpace(os.Getenv(name)); value != "" {
+			parts = append(parts, value)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// promptAddendumSettings lists the names of the prompt addendum variables that are set, sorted
+func promptAddendumSettings() []string {
+	var names []string
+	for _, entry := range os.Environ() {
+		name, value, _ := strings.Cut(entry, "=")
+		if strings.HasPrefix(name, "PROMPT_ADDENDUM_") && strings.TrimSpace(value) != "" {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// promptProfilePattern limits prompt profile names to what is safe as a cache directory name
+var promptProfilePattern = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+// loadPromptProfiles returns the operator's named prompts, selectable with ?profile=
+// Each <name>.txt file in PROMPT_PROFILES_DIR is a profile, and PROMPT_PROFILE_<NAME> variables (e.g. from the
+// config file) add or replace one: PROMPT_PROFILE_TERMINAL_THEME is the terminal-theme profile.
+// Read per request so new or edited prompts take effect without a restart
+func loadPromptProfiles() map[string]string {
+	profiles := make(map[string]string)
+
+	if dir := os.Getenv("PROMPT_PROFILES_DIR"); dir != "" {
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			slog.Info("Failed to read prompt profiles", "dir", dir, "error", err)
+		}
+		for _, file := range files {
+			name, isText := strings.CutSuffix(file.Name(), ".txt")
+			if file.IsDir() || !isText {
+				continue
+			}
+			data, err := os.ReadFile(filepath.Join(dir, file.Name()))
+			if err != nil {
+				slog.Info("Failed to read prompt profile", "file", file.Name(), "error", err)
+				continue
+			}
+			addPromptProfile(profiles, name, string(data))
+		}
+	}
+
+	for _, entry := range os.Environ() {
+		name, value, _ := strings.Cut(entry, "=")
+		if suffix, ok := strings.CutPrefix(name, "PROMPT_PROFILE_"); ok {
+			addPromptProfile(profiles, strings.ToLower(strings.ReplaceAll(suffix, "_", "-")), value)
+		}
+	}
+	return profiles
+}
+
+// addPromptProfile adds a prompt under a profile name, skipping empty prompts and names that are invalid or built in
+func addPromptProfile(profiles map[string]string, name, prompt string) {
+	prompt = strings.TrimSpace(prompt)
+	if prompt == "" {
+		return
+	}
+	if !promptProfilePattern.MatchString(name) || slices.Contains(builtinProfiles, name) {
+		slog.Info("Ignoring invalid prompt profile name", "value", name)
+		return
+	}
+	profiles[name] = prompt
+}
+
+// promptProfileNames lists the prompt profiles that are configured, sorted
+func promptProfileNames() []string {
+	return slices.Sorted(maps.Keys(loadPromptProfiles()))
+}
+
+// envSuffix turns a source or locale into an environment variable suffix ("ja-JP" -> "JA_JP")
+func envSuffix(s string) string {
+	return strings.ToUpper(strings.ReplaceAll(s, "-", "_"))
+}
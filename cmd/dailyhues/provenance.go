@@ -0,0 +1,48 @@
+package main
+
+import (
+	"time"
+
+	"github.com/mgabor3141/dailyhues/internal/bing"
+	"github.com/mgabor3141/dailyhues/internal/cache"
+)
+
+// AnalysisMetadata describes the pipeline that produced a palette, returned with ?verbose=true
+// Fields the analysis predates are omitted
+type AnalysisMetadata struct {
+	Model            string    `json:"model,omitempty"`
+	PromptVersion    int       `json:"prompt_version,omitempty"`
+	CustomPrompt     bool      `json:"custom_prompt"` // A PROMPT_ADDENDUM_* setting was added to the prompt
+	AnalyzedAt       time.Time `json:"analyzed_at"`
+	PromptTokens     int       `json:"prompt_tokens,omitempty"`
+	CompletionTokens int       `json:"completion_tokens,omitempty"`
+	Source           string    `json:"source,omitempty"`
+	SourceResolution string    `json:"source_resolution,omitempty"`
+	PinnedBy         string    `json:"pinned_by,omitempty"` // Set when an operator replaced the AI colors
+}
+
+// analysisSource names what was analyzed: the still image, or the video's frame on video days
+func analysisSource(info *bing.WallpaperInfo) string {
+	if info.HasVideo() {
+		return "video_frame"
+	}
+	return "image"
+}
+
+// analysisMetadata summarizes an analysis entry's provenance
+func analysisMetadata(entry *cache.AnalysisEntry) *AnalysisMetadata {
+	metadata := &AnalysisMetadata{
+		Model:            entry.Model,
+		PromptVersion:    entry.PromptVersion,
+		CustomPrompt:     entry.PromptAddendum != "" || entry.Prompt != "",
+		AnalyzedAt:       entry.AnalyzedAt,
+		PromptTokens:     entry.PromptTokens,
+		CompletionTokens: entry.CompletionTokens,
+		Source:           entry.Source,
+		SourceResolution: entry.SourceResolution,
+	}
+	if entry.Pin != nil {
+		metadata.PinnedBy = entry.Pin.By
+	}
+	return metadata
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// qutebrowserSettings maps qutebrowser color settings to the palette entry each one uses
+var qutebrowserSettings = [][2]string{
+	{"statusbar.normal.bg", "background"},
+	{"statusbar.normal.fg", "text"},
+	{"statusbar.command.bg", "background"},
+	{"statusbar.command.fg", "text"},
+	{"statusbar.insert.bg", "accent"},
+	{"statusbar.insert.fg", "accent_text"},
+	{"statusbar.url.fg", "text_muted"},
+	{"statusbar.url.success.https.fg", "accent"},
+	{"tabs.bar.bg", "background"},
+	{"tabs.even.bg", "background"},
+	{"tabs.odd.bg", "background"},
+	{"tabs.even.fg", "text_muted"},
+	{"tabs.odd.fg", "text_muted"},
+	{"tabs.selected.even.bg", "accent"},
+	{"tabs.selected.odd.bg", "accent"},
+	{"tabs.selected.even.fg", "accent_text"},
+	{"tabs.selected.odd.fg", "accent_text"},
+	{"tabs.indicator.start", "accent"},
+	{"tabs.indicator.stop", "gradient_to"},
+	{"completion.fg", "text"},
+	{"completion.even.bg", "background"},
+	{"completion.odd.bg", "background"},
+	{"completion.category.bg", "background"},
+	{"completion.category.fg", "accent"},
+	{"completion.item.selected.bg", "accent"},
+	{"completion.item.selected.fg", "accent_text"},
+	{"completion.item.selected.border.top", "accent"},
+	{"completion.item.selected.border.bottom", "accent"},
+	{"completion.match.fg", "accent"},
+	{"hints.bg", "accent"},
+	{"hints.fg", "accent_text"},
+}
+
+// marshalQutebrowser renders a palette as a config.py snippet for qutebrowser (format=qutebrowser)
+// The colors are the overlay's, so tab, status bar and completion text meet their contrast minimums
+func marshalQutebrowser(data interface{}) ([]byte, error) {
+	theme, ok := data.(ColorTheme)
+	if !ok {
+		return nil, fmt.Errorf("format is only supported for single palette responses")
+	}
+	gradient, colors, err := themeOverlay(theme, "a browser theme")
+	if err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# dailyhues colors for %s (%s)\n", strings.ReplaceAll(theme.Title, "\n", " "), theme.StartDate)
+	b.WriteString("# Load from config.py with config.source('dailyhues.py')\n")
+	b.WriteString("dailyhues = {\n")
+	for _, color := range [][2]string{
+		{"background", colors.Background},
+		{"text", colors.Text},
+		{"text_muted", colors.TextMuted},
+		{"accent", colors.Accent},
+		{"accent_text", colors.AccentText},
+		{"gradient_to", gradient.To},
+	} {
+		fmt.Fprintf(&b, "    %q: %q,\n", color[0], color[1])
+	}
+	b.WriteString("}\n\n")
+	for _, setting := range qutebrowserSettings {
+		fmt.Fprintf(&b, "c.colors.%s = dailyhues[%q]\n", setting[0], setting[1])
+	}
+	return []byte(b.String()), nil
+}
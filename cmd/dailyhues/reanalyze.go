@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/mgabor3141/dailyhues/internal/audit"
+	"github.com/mgabor3141/dailyhues/internal/bing"
+	"github.com/mgabor3141/dailyhues/internal/cache"
+)
+
+const (
+	reanalysisCheckInterval      = time.Hour
+	defaultReanalysisDailyBudget = 5
+	// reanalysisImageSize is the resolution originally analyzed (the wallpaper's default URL)
+	reanalysisImageSize = "1920x1080"
+
+	// An entry whose re-analysis failed is skipped for a day, doubling with each further failure up to a month,
+	// so images that can no longer be downloaded do not use up every day's budget
+	reanalysisRetryDelay    = 24 * time.Hour
+	reanalysisMaxRetryDelay = 30 * 24 * time.Hour
+)
+
+// loadReanalysisConfig reads ANALYSIS_MAX_AGE (0 or unset disables re-analysis) and REANALYSIS_DAILY_BUDGET
+func loadReanalysisConfig() (time.Duration, int) {
+	var maxAge time.Duration
+	if value := os.Getenv("ANALYSIS_MAX_AGE"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed < 0 {
+			slog.Info("Ignoring invalid ANALYSIS_MAX_AGE", "value", value)
+		} else {
+			maxAge = parsed
+		}
+	}
+
+	budget := defaultReanalysisDailyBudget
+	if value := os.Getenv("REANALYSIS_DAILY_BUDGET"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			slog.Info("Invalid REANALYSIS_DAILY_BUDGET, using default", "value", value, "default", defaultReanalysisDailyBudget)
+		} else {
+			budget = parsed
+		}
+	}
+
+	return maxAge, budget
+}
+
+// watchStaleAnalyses periodically re-runs analyses older than ANALYSIS_MAX_AGE with the current prompt and model
+// At most REANALYSIS_DAILY_BUDGET analyses are attempted per UTC day; settings are re-read on every check
+func (app *App) watchStaleAnalyses() {
+	var day string
+	used := 0
+
+	ticker := time.NewTicker(reanalysisCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		if maxAge, budget := loadReanalysisConfig(); maxAge > 0 {
+			now := time.Now()
+			if today := now.UTC().Format("2006-01-02"); today != day {
+				day, used = today, 0
+			}
+			used += app.reanalyzeStale(now, maxAge, budget-used)
+		}
+
+		<-ticker.C
+	}
+}
+
+// reanalyzeStale re-analyzes up to limit entries older than maxAge, oldest first, skipping recent failures
+// Returns the number of attempts, which all count against the budget whether or not they succeeded
+func (app *App) reanalyzeStale(now time.Time, maxAge time.Duration, limit int) int {
+	if limit <= 0 {
+		return 0
+	}
+
+	var stale []cache.AnalysisEntry
+	for _, entry := range app.analysisCache.AnalyzedBefore(now.Add(-maxAge)) {
+		if entry.Reanalysis == nil || !now.Before(entry.Reanalysis.At.Add(reanalysisBackoff(entry.Reanalysis.Failures))) {
+			stale = append(stale, entry)
+		}
+	}
+
+	attempted := 0
+	for _, entry := range stale[:min(len(stale), limit)] {
+		attempted++
+		if err := app.reanalyze(entry); err != nil {
+			slog.Error("Re-analysis failed", "hash", entry.ImageHash, "error", err)
+			app.recordReanalysisFailure(entry, now, err)
+			continue
+		}
+		slog.Info("Re-analyzed stale palette", "hash", entry.ImageHash, "previously_analyzed_at", entry.AnalyzedAt)
+		app.renderCache.invalidate(entry.ImageHash)
+		app.recordAudit(audit.SystemActor, "analysis.reanalyze", []string{entry.ImageHash}, map[string]string{
+			"previously_analyzed_at": entry.AnalyzedAt.UTC().Format(time.RFC3339),
+		})
+	}
+
+	if len(stale) > attempted {
+		slog.Info("Re-analysis budget exhausted", "remaining", len(stale)-attempted)
+	}
+	return attempted
+}
+
+// reanalysisBackoff returns how long to skip an entry after its re-analysis failed the given number of times
+func reanalysisBackoff(failures int) time.Duration {
+	if failures > 5 {
+		return reanalysisMaxRetryDelay
+	}
+	return min(reanalysisRetryDelay<<max(failures-1, 0), reanalysisMaxRetryDelay)
+}
+
+// recordReanalysisFailure stores a failed re-analysis with the entry, unless it was replaced or pinned in the meantime
+func (app *App) recordReanalysisFailure(entry cache.AnalysisEntry, now time.Time, err error) {
+	imageMutex := app.analysisCache.GetMutex(entry.ImageHash)
+	imageMutex.Lock()
+	defer imageMutex.Unlock()
+	defer app.analysisCache.ReleaseMutex(entry.ImageHash)
+
+	current := app.analysisCache.Get(entry.ImageHash)
+	if current == nil || current.Pin != nil || !current.AnalyzedAt.Equal(entry.AnalyzedAt) {
+		return
+	}
+
+	updated := *current
+	failure := cache.ReanalysisFailure{At: now, Failures: 1, Error: err.Error()}
+	if current.Reanalysis != nil {
+		failure.Failures = current.Reanalysis.Failures + 1
+	}
+	updated.Reanalysis = &failure
+	if err := app.analysisCache.SetEntry(updated); err != nil {
+		slog.Error("Failed to record re-analysis failure", "hash", entry.ImageHash, "error", err)
+	}
+}
+
+// reanalyze downloads the image of an analysis entry again and replaces the entry with a fresh analysis
+func (app *App) reanalyze(entry cache.AnalysisEntry) error {
+	reqEntry := app.requestEntryForImage(entry.ImageHash)
+	if reqEntry == nil {
+		return fmt.Errorf("no wallpaper metadata for image")
+	}
+
+	info, imageData, err := app.downloadCachedWallpaper(reqEntry)
+	if err != nil {
+		return err
+	}
+	if hash := cache.HashImage(imageData); hash != cache.ImageHashOf(entry.ImageHash) {
+		return fmt.Errorf("image changed since it was analyzed (now %s)", hash)
+	}
+
+	imageMutex := app.analysisCache.GetMutex(entry.ImageHash)
+	imageMutex.Lock()
+	defer imageMutex.Unlock()
+	defer app.analysisCache.ReleaseMutex(entry.ImageHash)
+
+	// An operator may have pinned the colors while the image was downloading
+	if current := app.analysisCache.Get(entry.ImageHash); current != nil && current.Pin != nil {
+		return fmt.Errorf("colors were pinned by %s", current.Pin.By)
+	}
+
+	// Keep the addendum the entry was analyzed with, since it is part of the entry's key
+	analysisData, analyzedSize := app.imageToAnalyze(context.Background(), info, imageData)
+	analysisEntry, err := app.runAnalysis(analysisData, entry.ImageHash, entry.PromptAddendum, info)
+	if err != nil {
+		return err
+	}
+	analysisEntry.AnalyzedSize = analyzedSize
+	return app.analysisCache.SetEntry(analysisEntry)
+}
+
+// downloadCachedWallpaper downloads the image hashed for a request entry again, so callers can check it is unchanged
+func (app *App) downloadCachedWallpaper(reqEntry *cache.RequestEntry) (*bing.WallpaperInfo, []byte, error) {
+	info := &bing.WallpaperInfo{
+		URL:           reqEntry.ImageURLs[reanalysisImageSize],
+		ImageID:       reqEntry.ImageID,
+		ImageURLs:     reqEntry.ImageURLs,
+		Title:         reqEntry.Title,
+		Copyright:     reqEntry.Copyright,
+		StartDate:     reqEntry.StartDate,
+		VideoURLs:     reqEntry.VideoURLs,
+		VideoFrameURL: reqEntry.VideoFrameURL,
+	}
+	if info.URL == "" {
+		return nil, nil, fmt.Errorf("no %s image URL", reanalysisImageSize)
+	}
+
+	imageData, err := app.bingClient.DownloadFrame(context.Background(), info)
+	if err != nil {
+		return nil, nil, err
+	}
+	return info, imageData, nil
+}
+
+// requestEntryForImage returns the most recent request entry that resolved to the image
+func (app *App) requestEntryForImage(imageHash string) *cache.RequestEntry {
+	entries := app.requestCache.Entries()
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].ImageHash == imageHash {
+			return &entries[i]
+		}
+	}
+	return nil
+}
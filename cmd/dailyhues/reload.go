@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"slices"
+	"syscall"
+
+	"github.com/mgabor3141/dailyhues/internal/auth"
+	"github.com/mgabor3141/dailyhues/internal/bing"
+	"github.com/mgabor3141/dailyhues/internal/config"
+)
+
+// loadAllowedLocales sets the allowed locales from ALLOWED_LOCALES, or the defaults if unset
+// Entries are canonicalized against Bing's market table and unknown ones are ignored
+func loadAllowedLocales() {
+	var locales []string
+	for _, locale := range splitList(os.Getenv("ALLOWED_LOCALES")) {
+		market, exact, err := bing.ResolveMarket(locale)
+		if err != nil || !exact {
+			slog.Info("Ignoring unknown locale in ALLOWED_LOCALES", "locale", locale)
+			continue
+		}
+		locales = append(locales, market)
+	}
+
+	allowedLocalesMu.Lock()
+	defer allowedLocalesMu.Unlock()
+
+	if len(locales) > 0 {
+		allowedLocales = locales
+		slog.Info("Using custom allowed locales from env", "locales", allowedLocales)
+	} else {
+		allowedLocales = defaultAllowedLocales
+		slog.Info("Using default allowed locales", "locales", allowedLocales)
+	}
+
+	defaultLocale = pickDefaultLocale(os.Getenv("DEFAULT_LOCALE"), allowedLocales)
+}
+
+// pickDefaultLocale returns the configured default locale if it is allowed,
+// otherwise en-US or the first allowed locale
+func pickDefaultLocale(configured string, allowed []string) string {
+	if configured != "" {
+		if market, _, err := bing.ResolveMarket(configured); err == nil && slices.Contains(allowed, market) {
+			return market
+		}
+		slog.Info("Ignoring DEFAULT_LOCALE that is not allowed", "locale", configured)
+	}
+
+	if slices.Contains(allowed, fallbackLocale) || len(allowed) == 0 {
+		return fallbackLocale
+	}
+	return allowed[0]
+}
+
+// reloadConfig re-reads CONFIG_FILE and applies the settings that can change at runtime
+// Caches and in-flight requests are left untouched; PORT and CACHE_DIR still require a restart
+func (app *App) reloadConfig(actor string) error {
+	previousAuth := loadAuthConfig()
+	if configFile := os.Getenv("CONFIG_FILE"); configFile != "" {
+		if _, err := config.Apply(configFile); err != nil {
+			return fmt.Errorf("failed to reload config file: %w", err)
+		}
+	}
+
+	loadLogLevel()
+	loadAllowedLocales()
+	loadRouteLimits()
+	authConfig := loadAuthConfig()
+	app.authenticator.Update(authConfig)
+
+	slog.Info("Configuration reloaded")
+	app.recordAudit(actor, "config.reload", nil, map[string]string{
+		"admin_credentials_changed": fmt.Sprint(!reflect.DeepEqual(previousAuth, authConfig)),
+	})
+	return nil
+}
+
+// watchReloadSignal reloads the configuration whenever the process receives SIGHUP
+func (app *App) watchReloadSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	for range signals {
+		if err := app.reloadConfig("SIGHUP"); err != nil {
+			slog.Error("Failed to reload configuration", "error", err)
+		}
+	}
+}
+
+// handleReload reloads the configuration on request from an admin
+func (app *App) handleReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if err := app.reloadConfig(auth.SubjectFromContext(r.Context())); err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "reloaded"})
+}
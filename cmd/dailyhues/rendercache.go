@@ -0,0 +1,136 @@
+package main
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// defaultRenderCacheSize is the number of rendered bodies kept by default
+const defaultRenderCacheSize = 512
+
+// renderKey identifies one rendered body: what was rendered, in which format, with which options
+type renderKey struct {
+	Subject string // Image hash (plus pin time) for palettes, or a fingerprint of the rendered data
+	Format  string
+	Options string
+}
+
+// renderEntry is a cached body in the LRU list
+type renderEntry struct {
+	key  renderKey
+	body []byte
+}
+
+// renderCache keeps recently rendered non-JSON bodies so popular formats are not re-rendered on every request
+// Least recently used bodies are evicted first; a nil *renderCache renders every time
+type renderCache struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[renderKey]*list.Element
+	order    *list.List // Front is most recently used
+}
+
+// newRenderCache creates a render cache holding up to capacity bodies, or nil if capacity is 0
+func newRenderCache(capacity int) *renderCache {
+	if capacity <= 0 {
+		return nil
+	}
+	return &renderCache{
+		capacity: capacity,
+		entries:  make(map[renderKey]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// loadRenderCacheSize reads RENDER_CACHE_SIZE (0 disables the cache)
+func loadRenderCacheSize() int {
+	value := os.Getenv("RENDER_CACHE_SIZE")
+	if value == "" {
+		return defaultRenderCacheSize
+	}
+	size, err := strconv.Atoi(value)
+	if err != nil || size < 0 {
+		slog.Info("Invalid RENDER_CACHE_SIZE, using default", "value", value, "default", defaultRenderCacheSize)
+		return defaultRenderCacheSize
+	}
+	return size
+}
+
+// render returns the cached body for key, or calls render and caches its result
+// Concurrent misses for the same key may both render; the body is the same either way
+func (c *renderCache) render(key renderKey, render func() ([]byte, error)) ([]byte, error) {
+	if c == nil {
+		return render()
+	}
+
+	c.mu.Lock()
+	if element, ok := c.entries[key]; ok {
+		c.order.MoveToFront(element)
+		body := element.Value.(*renderEntry).body
+		c.mu.Unlock()
+		return body, nil
+	}
+	c.mu.Unlock()
+
+	body, err := render()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		c.order.MoveToFront(element)
+		return body, nil
+	}
+	c.entries[key] = c.order.PushFront(&renderEntry{key: key, body: body})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*renderEntry).key)
+	}
+	return body, nil
+}
+
+// invalidate drops every body rendered from an image, e.g. after it was re-analyzed
+func (c *renderCache) invalidate(imageHash string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, element := range c.entries {
+		if key.Subject == imageHash || strings.HasPrefix(key.Subject, imageHash+"-") {
+			c.order.Remove(element)
+			delete(c.entries, key)
+		}
+	}
+}
+
+// themeRenderSubject identifies a palette's colors the same way its ETag does
+func themeRenderSubject(theme ColorTheme) string {
+	return theme.version.subject(theme.imageHash)
+}
+
+// trendRenderSubject fingerprints the days of a trend chart, so the SVG is re-rendered when any stripe changes
+func trendRenderSubject(days []trendDay) string {
+	hash := sha256.New()
+	for _, day := range days {
+		fmt.Fprintf(hash, "%s|%s|", day.date.Format("20060102"), day.title)
+		if day.gradient != nil {
+			fmt.Fprintf(hash, "%s|%s|%v", day.gradient.From, day.gradient.To, day.gradient.Angle)
+		}
+		hash.Write([]byte{'\n'})
+	}
+	return "trends-" + hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// rofiLayout is the fixed part of the format=rofi theme; only the variables in * change from day to day
+const rofiLayout = `
+window {
+    background-color: @background;
+    border:           2px;
+    border-color:     @border-color;
+    border-radius:    8px;
+    width:            40%;
+}
+
+mainbox {
+    padding: 12px;
+}
+
+inputbar {
+    children:         [ prompt, entry ];
+    spacing:          8px;
+    padding:          8px;
+    background-color: @background-solid;
+    border-radius:    6px;
+}
+
+prompt {
+    text-color: @accent;
+}
+
+entry {
+    placeholder-color: @muted;
+}
+
+listview {
+    lines:   8;
+    spacing: 4px;
+    margin:  8px 0 0 0;
+}
+
+element {
+    padding:       6px 8px;
+    border-radius: 6px;
+}
+
+element normal.normal, element alternate.normal {
+    background-color: transparent;
+    text-color:       @foreground;
+}
+
+element selected.normal {
+    background-color: @accent;
+    text-color:       @accent-text;
+}
+
+element-text, element-icon {
+    background-color: inherit;
+    text-color:       inherit;
+}
+
+message, textbox {
+    text-color: @muted;
+}
+`
+
+// marshalRofi renders a palette as a rofi .rasi theme (format=rofi)
+// The colors are the overlay's, so every text color meets its contrast minimum on the background, and the selection on the accent
+func marshalRofi(data interface{}) ([]byte, error) {
+	theme, ok := data.(ColorTheme)
+	if !ok {
+		return nil, fmt.Errorf("format is only supported for single palette responses")
+	}
+	gradient, colors, err := themeOverlay(theme, "a launcher theme")
+	if err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	title := strings.ReplaceAll(theme.Title, "*/", "* /")
+	fmt.Fprintf(&b, "/* dailyhues launcher theme for %s (%s) */\n", title, theme.StartDate)
+	fmt.Fprintf(&b, "/* Contrast: text %.2f:1, muted %.2f:1, accent %.2f:1, selected text %.2f:1 */\n\n",
+		hexContrast(colors.Text, colors.Background),
+		hexContrast(colors.TextMuted, colors.Background),
+		hexContrast(colors.Accent, colors.Background),
+		hexContrast(colors.AccentText, colors.Accent))
+
+	b.WriteString("* {\n")
+	for _, variable := range [][2]string{
+		{"background", colors.Background + fmt.Sprintf("%02x", int(math.Round(colors.BackgroundOpacity*255)))},
+		{"background-solid", colors.Background},
+		{"foreground", colors.Text},
+		{"muted", colors.TextMuted},
+		{"accent", colors.Accent},
+		{"accent-text", colors.AccentText},
+		{"border-color", gradient.From},
+	} {
+		fmt.Fprintf(&b, "    %-17s %s;\n", variable[0]+":", variable[1])
+	}
+	b.WriteString("\n    background-color: transparent;\n    text-color:       @foreground;\n}\n")
+	b.WriteString(rofiLayout)
+	return []byte(b.String()), nil
+}
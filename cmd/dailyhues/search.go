@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// defaultSearchLimit and maxSearchLimit bound the results of one /api/search request
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+	// maxSearchQueryLength keeps queries to a sensible size
+	maxSearchQueryLength = 200
+)
+
+// SearchResponse lists the archived wallpapers matching a search, newest first
+type SearchResponse struct {
+	Query   string         `json:"query"`
+	Count   int            `json:"count"`
+	Results []SearchResult `json:"results"`
+}
+
+// SearchResult is one matching day and locale with its palette
+type SearchResult struct {
+	Locale string `json:"locale"`
+	ColorTheme
+}
+
+// handleSearch finds archived wallpapers whose title or copyright contains every word of ?q=, case-insensitively
+// Only wallpapers with a cached palette are searched, so nothing is fetched or analyzed
+func (app *App) handleSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	query := r.URL.Query()
+
+	q := strings.TrimSpace(query.Get("q"))
+	if q == "" {
+		respondWithError(w, http.StatusBadRequest, "q is required, e.g. q=lighthouse")
+		return
+	}
+	if len(q) > maxSearchQueryLength {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("q must be at most %d characters", maxSearchQueryLength))
+		return
+	}
+
+	var locale string
+	if param := query.Get("locale"); param != "" {
+		var err error
+		if locale, err = validateLocale(param); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	limit := defaultSearchLimit
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxSearchLimit {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("invalid limit parameter. Must be between 1 and %d", maxSearchLimit))
+			return
+		}
+		limit = parsed
+	}
+
+	encoding, err := negotiateEncoding(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if encoding.themeOnly {
+		respondWithError(w, http.StatusBadRequest, "format is only supported for single palette responses")
+		return
+	}
+
+	results := app.search(strings.Fields(strings.ToLower(q)), locale, limit)
+	respondEncoded(w, http.StatusOK, encoding, SearchResponse{Query: q, Count: len(results), Results: results})
+}
+
+// search returns up to limit cached wallpapers whose title or copyright contains every term, newest first
+// Terms must be lowercase; an empty locale searches all locales
+func (app *App) search(terms []string, locale string, limit int) []SearchResult {
+	results := []SearchResult{}
+	entries := app.requestCache.Entries()
+	for i := len(entries) - 1; i >= 0 && len(results) < limit; i-- {
+		reqEntry := entries[i]
+		if locale != "" && reqEntry.Locale != locale {
+			continue
+		}
+
+		text := strings.ToLower(reqEntry.Title + "\n" + reqEntry.Copyright)
+		if !containsAll(text, terms) {
+			continue
+		}
+
+		analysisEntry := app.analysisCache.Get(reqEntry.ImageHash)
+		if analysisEntry == nil {
+			continue
+		}
+		results = append(results, SearchResult{Locale: reqEntry.Locale, ColorTheme: buildColorTheme(&reqEntry, analysisEntry)})
+	}
+	return results
+}
+
+// containsAll reports whether text contains every term
+func containsAll(text string, terms []string) bool {
+	for _, term := range terms {
+		if !strings.Contains(text, term) {
+			return false
+		}
+	}
+	return true
+}
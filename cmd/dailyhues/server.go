@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/mgabor3141/dailyhues/internal/metrics"
+)
+
+// shutdownTimeout bounds how long in-flight requests may take to finish once the server is stopping
+// Long-running routes (batch, warm, events) are cut off after it
+const shutdownTimeout = 30 * time.Second
+
+// routes registers every endpoint on a mux of its own, so servers can be built without global state
+func (app *App) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", handleLandingPage)
+	mux.HandleFunc("/api/colors", app.handleGetColors)
+	mux.HandleFunc("/api/transition", app.handleTransition)
+	mux.HandleFunc("/api/week", app.handleWeek)
+	mux.HandleFunc("/api/batch", app.handleBatch)
+	mux.HandleFunc("/api/events", app.handleEvents)
+	mux.HandleFunc("/api/image-info", app.handleImageInfo)
+	mux.HandleFunc("/api/contrast", app.handleContrast)
+	mux.HandleFunc("/api/stats/palettes", app.handlePaletteStats)
+	mux.HandleFunc("/api/trends.svg", app.handleTrendsSVG)
+	mux.HandleFunc("/api/history", app.handleHistory)
+	mux.HandleFunc("/api/history.csv", app.handleHistoryCSV)
+	mux.HandleFunc("/api/search", app.handleSearch)
+	mux.HandleFunc("/api/estimate", app.handleEstimate)
+	mux.HandleFunc("/archive/images/", handleArchivedImage)
+	mux.HandleFunc("/health", handleHealth)
+	mux.HandleFunc("/metrics", metrics.Handler)
+	mux.HandleFunc("/version", handleVersion)
+	mux.HandleFunc("/admin/whoami", app.requireAdmin(handleWhoami))
+	mux.HandleFunc("/admin/reload", app.requireAdmin(app.handleReload))
+	mux.HandleFunc("/admin/runtime", app.requireAdmin(app.handleRuntimeSettings))
+	mux.HandleFunc("/admin/config", app.requireAdmin(app.handleConfigSummary))
+	mux.HandleFunc("/admin/warm", app.requireAdmin(app.handleWarm))
+	mux.HandleFunc("/admin/pins", app.requireAdmin(app.handlePins))
+	mux.HandleFunc("/admin/audit", app.requireAdmin(app.handleAudit))
+	mux.HandleFunc("/admin/canary", app.requireAdmin(handleCanary))
+	mux.HandleFunc("/admin/debug", app.requireAdmin(handleDebugResponses))
+	mux.HandleFunc("/admin/debug/", app.requireAdmin(handleDebugResponses))
+	return mux
+}
+
+// handler wraps the routes in the middleware every response passes through
+func (app *App) handler() http.Handler {
+	return withRequestID(withVersionHeader(withRouteLimits(withCanonicalQuery(app.routes()))))
+}
+
+// serve answers requests on listener until ctx is done, then waits up to shutdownTimeout for in-flight requests
+// Returns nil after a clean shutdown
+func (app *App) serve(ctx context.Context, listener net.Listener) error {
+	server := &http.Server{
+		Handler:           app.handler(),
+		ReadHeaderTimeout: 15 * time.Second, // Body read and write deadlines are set per route by withRouteLimits
+		IdleTimeout:       60 * time.Second,
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("Shutting down, waiting for in-flight requests", "timeout", shutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/mgabor3141/dailyhues/internal/palette"
+)
+
+// reusesSeparator joins the fingerprints of a day's image and the image whose palette it reuses
+const reusesSeparator = "-reuses-"
+
+// maxStability bounds the stability parameter; ΔEOK×100 between black and white is 100
+const maxStability = 100
+
+// validateStability validates the stability parameter, a ΔE threshold below which a new palette is not adopted
+// Returns 0, which never reuses a palette, when the parameter is absent
+func validateStability(param string) (float64, error) {
+	if param == "" {
+		return 0, nil
+	}
+	threshold, err := strconv.ParseFloat(param, 64)
+	if err != nil || threshold < 0 || threshold > maxStability || math.IsNaN(threshold) {
+		return 0, fmt.Errorf("invalid stability parameter. Must be a ΔE threshold from 0 to %d", maxStability)
+	}
+	return threshold, nil
+}
+
+// withStability re-serves the palette shown for the previous day if the day's own palette is within threshold of it
+// Previous days are only read from the caches, so stability never causes Bing or AI work for them
+func (app *App) withStability(theme ColorTheme, locale string, daysAgo int, threshold float64) ColorTheme {
+	if threshold <= 0 {
+		return theme
+	}
+	if previous, ok := app.shownCachedTheme(locale, daysAgo+1, threshold); ok && paletteDistance(theme.Colors, previous.Colors) <= threshold {
+		return reusedTheme(theme, previous)
+	}
+	return theme
+}
+
+// shownCachedTheme returns the cached palette shown for a day under the threshold, which may itself be reused
+// from an earlier day. Returns false if the day is not cached
+func (app *App) shownCachedTheme(locale string, daysAgo int, threshold float64) (ColorTheme, bool) {
+	if daysAgo > maxDaysBack {
+		return ColorTheme{}, false
+	}
+	reqEntry := app.requestCache.Get(locale, daysAgo)
+	if reqEntry == nil {
+		return ColorTheme{}, false
+	}
+	analysisEntry := app.analysisCache.Get(reqEntry.ImageHash)
+	if analysisEntry == nil {
+		return ColorTheme{}, false
+	}
+	return app.withStability(buildColorTheme(reqEntry, analysisEntry), locale, daysAgo, threshold), true
+}
+
+// paletteDistance returns the larger ΔE of the two gradient stops; palettes without a gradient are infinitely apart
+func paletteDistance(a, b map[string]interface{}) float64 {
+	ga, errA := palette.GradientFromColors(a)
+	gb, errB := palette.GradientFromColors(b)
+	if errA != nil || errB != nil {
+		return math.Inf(1)
+	}
+
+	distance := 0.0
+	for _, stops := range [][2]string{{ga.From, gb.From}, {ga.To, gb.To}} {
+		ca, errA := palette.ParseHex(stops[0])
+		cb, errB := palette.ParseHex(stops[1])
+		if errA != nil || errB != nil {
+			return math.Inf(1)
+		}
+		distance = max(distance, palette.DeltaE(ca, cb))
+	}
+	return distance
+}
+
+// reusedTheme returns the day's theme with the colors of an earlier one
+// The fingerprint names both images, so validators and rendered bodies differ from either day's own palette
+func reusedTheme(theme, earlier ColorTheme) ColorTheme {
+	theme.Colors = earlier.Colors
+	theme.CSSGradient = earlier.CSSGradient
+	theme.HyprlandGradient = earlier.HyprlandGradient
+	theme.InactiveBorder = earlier.InactiveBorder
+	theme.Pinned = earlier.Pinned
+	theme.Model = earlier.Model
+	theme.version = earlier.version
+	theme.analysis = earlier.analysis
+	theme.Reused = true
+	if earlier.ReusedFrom != "" {
+		theme.ReusedFrom = earlier.ReusedFrom
+	} else {
+		theme.ReusedFrom = earlier.StartDate
+	}
+
+	// When the earlier day reuses a palette too, the image that palette came from is named instead of the earlier day's
+	source := earlier.imageHash
+	if _, reused, ok := strings.Cut(source, reusesSeparator); ok {
+		source = reused
+	}
+	theme.imageHash += reusesSeparator + source
+	return theme
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/mgabor3141/dailyhues/internal/palette"
+)
+
+const (
+	defaultPaletteFamilies = 5
+	maxPaletteFamilies     = 12
+)
+
+// PaletteStats summarizes all archived palettes
+type PaletteStats struct {
+	Palettes int             `json:"palettes"`
+	Families []PaletteFamily `json:"families"`
+	Months   []MonthStats    `json:"months"`
+}
+
+// PaletteFamily is a cluster of similar palettes
+type PaletteFamily struct {
+	Color     string  `json:"color"`
+	Hue       float64 `json:"hue"`
+	Lightness float64 `json:"lightness"`
+	Chroma    float64 `json:"chroma"`
+	Palettes  int     `json:"palettes"`
+	Share     float64 `json:"share"`
+}
+
+// MonthStats holds the average color of a month's palettes
+type MonthStats struct {
+	Month            string  `json:"month"`
+	Palettes         int     `json:"palettes"`
+	AverageHue       float64 `json:"average_hue"`
+	AverageLightness float64 `json:"average_lightness"`
+	AverageChroma    float64 `json:"average_chroma"`
+}
+
+// handlePaletteStats clusters archived palettes into families and reports per-month averages
+func (app *App) handlePaletteStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	query := r.URL.Query()
+
+	k, err := validateFamilies(query.Get("families"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// An empty locale covers the whole archive
+	locale := query.Get("locale")
+	if locale != "" {
+		if locale, err = validateLocale(locale); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	encoding, err := negotiateEncoding(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if encoding.themeOnly {
+		respondWithError(w, http.StatusBadRequest, "format is only supported for single palette responses")
+		return
+	}
+
+	respondEncoded(w, http.StatusOK, encoding, app.paletteStats(locale, k))
+}
+
+// archivedPalette is a palette from the archive reduced to a single representative color
+type archivedPalette struct {
+	startDate string
+	color     palette.OKLCH
+}
+
+// archivedPalettes returns one entry per analyzed image, oldest first, optionally limited to a locale
+// Locales sharing an image count once, so widely shared wallpapers do not skew the statistics
+func (app *App) archivedPalettes(locale string) []archivedPalette {
+	var palettes []archivedPalette
+	seen := make(map[string]bool)
+
+	for _, entry := range app.requestCache.Entries() {
+		if (locale != "" && entry.Locale != locale) || seen[entry.ImageHash] || len(entry.StartDate) < 6 {
+			continue
+		}
+
+		analysis := app.analysisCache.Get(entry.ImageHash)
+		if analysis == nil {
+			continue
+		}
+
+		color, ok := paletteColor(analysis.Colors)
+		if !ok {
+			continue
+		}
+
+		seen[entry.ImageHash] = true
+		palettes = append(palettes, archivedPalette{startDate: entry.StartDate, color: color})
+	}
+
+	return palettes
+}
+
+// paletteColor blends a palette's gradient endpoints into its representative color
+func paletteColor(colors map[string]interface{}) (palette.OKLCH, bool) {
+	gradient, err := palette.GradientFromColors(colors)
+	if err != nil {
+		return palette.OKLCH{}, false
+	}
+
+	from, err := palette.ParseHex(gradient.From)
+	if err != nil {
+		return palette.OKLCH{}, false
+	}
+	to, err := palette.ParseHex(gradient.To)
+	if err != nil {
+		return palette.OKLCH{}, false
+	}
+
+	return palette.InterpolateOKLCH(from.OKLCH(), to.OKLCH(), 0.5), true
+}
+
+// paletteStats computes the palette families and monthly averages for the archive
+func (app *App) paletteStats(locale string, k int) PaletteStats {
+	palettes := app.archivedPalettes(locale)
+
+	colors := make([]palette.OKLCH, len(palettes))
+	for i, p := range palettes {
+		colors[i] = p.color
+	}
+
+	stats := PaletteStats{
+		Palettes: len(palettes),
+		Families: []PaletteFamily{},
+		Months:   []MonthStats{},
+	}
+
+	for _, family := range palette.Cluster(colors, k) {
+		stats.Families = append(stats.Families, PaletteFamily{
+			Color:     family.Center.RGB().Hex(),
+			Hue:       round(family.Center.H, 1),
+			Lightness: round(family.Center.L, 3),
+			Chroma:    round(family.Center.C, 3),
+			Palettes:  family.Size,
+			Share:     round(float64(family.Size)/float64(len(palettes)), 3),
+		})
+	}
+
+	// Palettes are ordered by start date, so each month is a contiguous run
+	for start := 0; start < len(palettes); {
+		month := palettes[start].startDate[:6]
+		end := start
+		var hues []float64
+		var lightness, chroma float64
+		for ; end < len(palettes) && palettes[end].startDate[:6] == month; end++ {
+			hues = append(hues, palettes[end].color.H)
+			lightness += palettes[end].color.L
+			chroma += palettes[end].color.C
+		}
+
+		count := float64(end - start)
+		stats.Months = append(stats.Months, MonthStats{
+			Month:            month[:4] + "-" + month[4:],
+			Palettes:         end - start,
+			AverageHue:       round(palette.MeanHue(hues), 1),
+			AverageLightness: round(lightness/count, 3),
+			AverageChroma:    round(chroma/count, 3),
+		})
+		start = end
+	}
+
+	return stats
+}
+
+// validateFamilies validates the number of palette families to cluster into
+func validateFamilies(param string) (int, error) {
+	if param == "" {
+		return defaultPaletteFamilies, nil
+	}
+
+	k, err := strconv.Atoi(param)
+	if err != nil || k < 1 || k > maxPaletteFamilies {
+		return 0, fmt.Errorf("families must be between 1 and %d", maxPaletteFamilies)
+	}
+	return k, nil
+}
+
+// round rounds v to the given number of decimal places
+func round(v float64, places int) float64 {
+	scale := math.Pow(10, float64(places))
+	return math.Round(v*scale) / scale
+}
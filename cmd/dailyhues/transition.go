@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/mgabor3141/dailyhues/internal/palette"
+)
+
+const (
+	defaultTransitionSteps = 30
+	maxTransitionSteps     = 240
+)
+
+// TransitionResponse contains interpolated gradients between two days' palettes
+type TransitionResponse struct {
+	From  ColorTheme         `json:"from"`
+	To    ColorTheme         `json:"to"`
+	Steps []palette.Gradient `json:"steps"`
+}
+
+// handleTransition returns gradient stops for animating between two days' palettes
+func (app *App) handleTransition(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	query := r.URL.Query()
+
+	fromDaysAgo, err := validateRelativeDay(query.Get("from"), 1)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("invalid from parameter: %v", err))
+		return
+	}
+
+	toDaysAgo, err := validateRelativeDay(query.Get("to"), 0)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("invalid to parameter: %v", err))
+		return
+	}
+
+	steps, err := validateSteps(query.Get("steps"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	locale, err := validateLocale(query.Get("locale"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	encoding, err := negotiateEncoding(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if encoding.themeOnly {
+		respondWithError(w, http.StatusBadRequest, "format is only supported for single palette responses")
+		return
+	}
+
+	fromTheme, err := app.getColorTheme(locale, fromDaysAgo)
+	if err != nil {
+		respondWithResolveError(w, err)
+		return
+	}
+
+	toTheme, err := app.getColorTheme(locale, toDaysAgo)
+	if err != nil {
+		respondWithResolveError(w, err)
+		return
+	}
+
+	fromGradient, err := palette.GradientFromColors(fromTheme.Colors)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Invalid palette for from day: %v", err))
+		return
+	}
+
+	toGradient, err := palette.GradientFromColors(toTheme.Colors)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Invalid palette for to day: %v", err))
+		return
+	}
+
+	stops, err := palette.Interpolate(fromGradient, toGradient, steps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to interpolate palettes: %v", err))
+		return
+	}
+
+	respondEncoded(w, http.StatusOK, encoding, TransitionResponse{
+		From:  fromTheme,
+		To:    toTheme,
+		Steps: stops,
+	})
+}
+
+// validateRelativeDay parses "today", "yesterday", or a daysAgo number
+func validateRelativeDay(param string, defaultDaysAgo int) (int, error) {
+	switch param {
+	case "":
+		return defaultDaysAgo, nil
+	case "today":
+		return 0, nil
+	case "yesterday":
+		return 1, nil
+	}
+
+	return validateDaysAgo(param)
+}
+
+// validateSteps validates the number of interpolation steps
+func validateSteps(stepsParam string) (int, error) {
+	if stepsParam == "" {
+		return defaultTransitionSteps, nil
+	}
+
+	var steps int
+	if _, err := fmt.Sscanf(stepsParam, "%d", &steps); err != nil {
+		return 0, fmt.Errorf("invalid steps parameter. Must be an integer")
+	}
+
+	if steps < 2 || steps > maxTransitionSteps {
+		return 0, fmt.Errorf("steps must be between 2 and %d", maxTransitionSteps)
+	}
+
+	return steps, nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mgabor3141/dailyhues/internal/palette"
+)
+
+const (
+	defaultTrendDays = 90
+	maxTrendDays     = 366
+
+	trendStripeWidth = 8
+	trendHeight      = 120
+)
+
+// trendDay is one stripe of the trend chart; gradient is nil for days without a palette
+type trendDay struct {
+	date     time.Time
+	title    string
+	gradient *palette.Gradient
+}
+
+// handleTrendsSVG renders the palette history of a locale as an SVG timeline of gradient stripes
+func (app *App) handleTrendsSVG(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	query := r.URL.Query()
+
+	days, err := validateTrendDays(query.Get("days"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	locale, err := validateLocale(query.Get("locale"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	trend := app.trendDays(locale, days, time.Now())
+	body, _ := app.renderCache.render(renderKey{Subject: trendRenderSubject(trend), Format: "svg"}, func() ([]byte, error) {
+		return renderTrendsSVG(trend), nil
+	})
+
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// trendDays collects the last days days of a locale's archive, oldest first, ending at its newest wallpaper
+func (app *App) trendDays(locale string, days int, now time.Time) []trendDay {
+	// Locales ahead of UTC may already be on tomorrow's wallpaper
+	end := now.UTC().Truncate(24 * time.Hour)
+	for _, entry := range app.requestCache.Entries() {
+		if entry.Locale != locale {
+			continue
+		}
+		if date, err := time.Parse("20060102", entry.StartDate); err == nil && date.After(end) {
+			end = date
+		}
+	}
+
+	result := make([]trendDay, days)
+	for i := range result {
+		date := end.AddDate(0, 0, i-days+1)
+		result[i].date = date
+
+		reqEntry := app.requestCache.GetByDate(locale, date.Format("20060102"))
+		if reqEntry == nil {
+			continue
+		}
+		analysis := app.analysisCache.Get(reqEntry.ImageHash)
+		if analysis == nil {
+			continue
+		}
+		if gradient, err := palette.GradientFromColors(analysis.Colors); err == nil {
+			result[i].title = reqEntry.Title
+			result[i].gradient = &gradient
+		}
+	}
+
+	return result
+}
+
+// renderTrendsSVG draws each day as a vertical gradient stripe, leaving gaps for missing days
+func renderTrendsSVG(days []trendDay) []byte {
+	var b strings.Builder
+	width := len(days) * trendStripeWidth
+
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" preserveAspectRatio="none">`+"\n", width, trendHeight, width, trendHeight)
+
+	b.WriteString("<defs>\n")
+	for i, day := range days {
+		if day.gradient == nil {
+			continue
+		}
+		fmt.Fprintf(&b, `<linearGradient id="d%d" x1="0" y1="0" x2="0" y2="1"><stop offset="0" stop-color="%s"/><stop offset="1" stop-color="%s"/></linearGradient>`+"\n", i, day.gradient.From, day.gradient.To)
+	}
+	b.WriteString("</defs>\n")
+
+	for i, day := range days {
+		if day.gradient == nil {
+			continue
+		}
+		label := day.date.Format("2006-01-02")
+		if day.title != "" {
+			label += " " + day.title
+		}
+		fmt.Fprintf(&b, `<rect x="%d" y="0" width="%d" height="%d" fill="url(#d%d)"><title>%s</title></rect>`+"\n", i*trendStripeWidth, trendStripeWidth, trendHeight, i, escapeXML(label))
+	}
+
+	b.WriteString("</svg>\n")
+	return []byte(b.String())
+}
+
+// escapeXML escapes text for use in SVG element content
+func escapeXML(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// validateTrendDays validates the number of days shown in the trend chart
+func validateTrendDays(param string) (int, error) {
+	if param == "" {
+		return defaultTrendDays, nil
+	}
+
+	days, err := strconv.Atoi(param)
+	if err != nil || days < 1 || days > maxTrendDays {
+		return 0, fmt.Errorf("days must be between 1 and %d", maxTrendDays)
+	}
+	return days, nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"log/slog"
+	"sync"
+
+	"github.com/mgabor3141/dailyhues/internal/ai"
+)
+
+// failedUsage holds what failed analyses were billed, by analysis key, until a successful analysis of the same key
+// adds it to the usage cached with its palette, so history and estimates count every request an analysis took
+type failedUsage struct {
+	mu    sync.Mutex
+	usage map[string]ai.Usage
+}
+
+// add records the usage of a failed analysis of key
+func (f *failedUsage) add(key string, usage ai.Usage) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.usage == nil {
+		f.usage = make(map[string]ai.Usage)
+	}
+	f.usage[key] = f.usage[key].Plus(usage)
+}
+
+// take returns usage plus that of earlier failed analyses of key, which are then forgotten
+func (f *failedUsage) take(key string, usage ai.Usage) ai.Usage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	failed, ok := f.usage[key]
+	if !ok {
+		return usage
+	}
+	delete(f.usage, key)
+	return usage.Plus(failed)
+}
+
+// recordFailedUsage logs what a failed analysis of key was billed and keeps it for the next successful analysis
+func (app *App) recordFailedUsage(key string, usage ai.Usage) {
+	if !usage.Billed() {
+		return
+	}
+	slog.Info("Failed analysis was billed", "hash", key, "model", usage.Model,
+		"prompt_tokens", usage.PromptTokens, "completion_tokens", usage.CompletionTokens, "cost", usage.Cost)
+	app.failedUsage.add(key, usage)
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// logLevel is shared by the JSON handler; the default text logger is updated alongside it
+var logLevel = new(slog.LevelVar)
+
+// verboseMode remembers the settings to restore when SIGUSR1 switches verbose mode off
+var verboseMode struct {
+	mu               sync.Mutex
+	enabled          bool
+	savedLevel       slog.Level
+	savedDebugAIResp string
+}
+
+// RuntimeSettings are the diagnostics settings that can be changed without a restart
+type RuntimeSettings struct {
+	LogLevel         string `json:"log_level"`
+	DebugAIResponses bool   `json:"debug_ai_responses"`
+}
+
+// runtimeSettingsUpdate is a partial update; omitted fields are left unchanged
+type runtimeSettingsUpdate struct {
+	LogLevel         *string `json:"log_level"`
+	DebugAIResponses *bool   `json:"debug_ai_responses"`
+}
+
+// setLogLevel changes the level of both the default and the JSON logger
+func setLogLevel(level slog.Level) {
+	logLevel.Set(level)
+	slog.SetLogLoggerLevel(level)
+}
+
+// parseLogLevel accepts debug, info, warn or error in any case
+func parseLogLevel(value string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(value)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q. Must be debug, info, warn or error", value)
+	}
+	return level, nil
+}
+
+// loadLogLevel applies LOG_LEVEL, defaulting to info
+func loadLogLevel() {
+	level := slog.LevelInfo
+	if value := os.Getenv("LOG_LEVEL"); value != "" {
+		parsed, err := parseLogLevel(value)
+		if err != nil {
+			slog.Error("Ignoring LOG_LEVEL", "error", err)
+		} else {
+			level = parsed
+		}
+	}
+	setLogLevel(level)
+}
+
+// currentRuntimeSettings reports the active diagnostics settings
+func currentRuntimeSettings() RuntimeSettings {
+	return RuntimeSettings{
+		LogLevel:         strings.ToLower(logLevel.Level().String()),
+		DebugAIResponses: os.Getenv("DEBUG_AI_RESPONSES") == "true",
+	}
+}
+
+// toggleVerboseMode switches between debug logging with AI response capture and the previous settings
+func toggleVerboseMode() RuntimeSettings {
+	verboseMode.mu.Lock()
+	defer verboseMode.mu.Unlock()
+
+	if verboseMode.enabled {
+		setLogLevel(verboseMode.savedLevel)
+		os.Setenv("DEBUG_AI_RESPONSES", verboseMode.savedDebugAIResp)
+		verboseMode.enabled = false
+	} else {
+		verboseMode.savedLevel = logLevel.Level()
+		verboseMode.savedDebugAIResp = os.Getenv("DEBUG_AI_RESPONSES")
+		setLogLevel(slog.LevelDebug)
+		os.Setenv("DEBUG_AI_RESPONSES", "true")
+		verboseMode.enabled = true
+	}
+
+	settings := currentRuntimeSettings()
+	slog.Info("Toggled verbose mode", "enabled", verboseMode.enabled, "log_level", settings.LogLevel, "debug_ai_responses", settings.DebugAIResponses)
+	return settings
+}
+
+// watchVerboseSignal toggles verbose mode whenever the process receives SIGUSR1
+func watchVerboseSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1)
+
+	for range signals {
+		toggleVerboseMode()
+	}
+}
+
+// handleRuntimeSettings reports (GET) or changes (POST) the log level and AI response capture
+func (app *App) handleRuntimeSettings(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		respondWithJSON(w, http.StatusOK, currentRuntimeSettings())
+		return
+	case http.MethodPost:
+	default:
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var update runtimeSettingsUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+
+	// Validate everything before changing anything
+	var level slog.Level
+	if update.LogLevel != nil {
+		var err error
+		if level, err = parseLogLevel(*update.LogLevel); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	if update.LogLevel != nil {
+		setLogLevel(level)
+	}
+	if update.DebugAIResponses != nil {
+		os.Setenv("DEBUG_AI_RESPONSES", fmt.Sprint(*update.DebugAIResponses))
+	}
+
+	settings := currentRuntimeSettings()
+	slog.InfoContext(r.Context(), "Updated runtime settings", "log_level", settings.LogLevel, "debug_ai_responses", settings.DebugAIResponses)
+	app.audit(r, "runtime.update", nil, map[string]string{
+		"log_level":          settings.LogLevel,
+		"debug_ai_responses": fmt.Sprint(settings.DebugAIResponses),
+	})
+	respondWithJSON(w, http.StatusOK, settings)
+}
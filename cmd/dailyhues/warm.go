@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// warmRequestTimeout bounds a remote warm call, which analyzes every requested day before responding
+const warmRequestTimeout = 30 * time.Minute
+
+// warmTaskTimeout bounds one locale and day of a warm run, longer than batchTaskTimeout since nobody is waiting on a page
+const warmTaskTimeout = 5 * time.Minute
+
+// WarmResult reports the outcome of generating one locale and day
+type WarmResult struct {
+	Locale    string `json:"locale"`
+	DaysAgo   int    `json:"days_ago"`
+	StartDate string `json:"startdate,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// warm runs the full pipeline for each locale and day, so the caches are filled before traffic arrives
+// Up to batchConcurrency days are generated at once; results are listed by locale, then day
+func (app *App) warm(ctx context.Context, locales []string, fromDaysAgo, toDaysAgo int) []WarmResult {
+	var results []WarmResult
+	for _, locale := range locales {
+		for daysAgo := fromDaysAgo; daysAgo <= toDaysAgo; daysAgo++ {
+			results = append(results, WarmResult{Locale: locale, DaysAgo: daysAgo})
+		}
+	}
+
+	themes, errs := runBounded(ctx, batchConcurrency, warmTaskTimeout, len(results), func(_ context.Context, i int) (ColorTheme, error) {
+		return app.getColorTheme(results[i].Locale, results[i].DaysAgo)
+	})
+	for i := range results {
+		if errs[i] != nil {
+			results[i].Error = errs[i].Error()
+		} else {
+			results[i].StartDate = themes[i].StartDate
+		}
+	}
+	return results
+}
+
+// parseWarmRequest validates the comma separated locales and the "from-to" (or single) daysAgo range
+func parseWarmRequest(localesParam, daysParam string) ([]string, int, int, error) {
+	var locales []string
+	for _, param := range splitList(localesParam) {
+		locale, err := validateLocale(param)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		locales = append(locales, locale)
+	}
+	if len(locales) == 0 {
+		locale, _ := validateLocale("")
+		locales = []string{locale}
+	}
+
+	fromParam, toParam, isRange := strings.Cut(daysParam, "-")
+	if !isRange {
+		toParam = fromParam
+	}
+	from, errFrom := strconv.Atoi(fromParam)
+	to, errTo := strconv.Atoi(toParam)
+	if errFrom != nil || errTo != nil || from < 0 || to > maxDaysBack || from > to {
+		return nil, 0, 0, fmt.Errorf("invalid days %q. Must be a daysAgo or range like 0-%d", daysParam, maxDaysBack)
+	}
+
+	return locales, from, to, nil
+}
+
+// handleWarm runs the warm pipeline on the server for the requested locales and days
+func (app *App) handleWarm(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	query := r.URL.Query()
+	days := query.Get("days")
+	if days == "" {
+		days = "0"
+	}
+
+	locales, from, to, err := parseWarmRequest(query.Get("locales"), days)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	app.audit(r, "cache.warm", locales, map[string]string{"days": days})
+	respondWithJSON(w, http.StatusOK, app.warm(r.Context(), locales, from, to))
+}
+
+// runWarm implements `dailyhues warm`, returning the process exit code
+func runWarm(args []string) int {
+	flags := flag.NewFlagSet("warm", flag.ContinueOnError)
+	localesFlag := flags.String("locales", defaultLocale, "comma separated locales to warm")
+	daysFlag := flags.String("days", "0", "daysAgo or range to warm, e.g. 0-7")
+	cacheDirFlag := flags.String("cache-dir", cacheDir(), "cache directory to fill")
+	serverFlag := flags.String("server", "", "warm a running server through its admin API instead, e.g. https://dailyhues.example.com")
+	tokenFlag := flags.String("token", os.Getenv("ADMIN_TOKEN"), "admin API key or JWT for -server (default $ADMIN_TOKEN)")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	locales, from, to, err := parseWarmRequest(*localesFlag, *daysFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	var results []WarmResult
+	if *serverFlag != "" {
+		results, err = warmRemote(*serverFlag, *tokenFlag, *localesFlag, *daysFlag)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	} else {
+		app := newApp(*cacheDirFlag)
+		results = app.warm(context.Background(), locales, from, to)
+		app.archiving.Wait()
+		app.canaries.Wait()
+	}
+
+	failed := 0
+	for _, result := range results {
+		if result.Error != "" {
+			failed++
+			fmt.Printf("%s\tdaysAgo=%d\tFAILED\t%s\n", result.Locale, result.DaysAgo, result.Error)
+		} else {
+			fmt.Printf("%s\tdaysAgo=%d\tok\t%s\n", result.Locale, result.DaysAgo, result.StartDate)
+		}
+	}
+
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "%d of %d failed\n", failed, len(results))
+		return 1
+	}
+	return 0
+}
+
+// warmRemote asks a running server to warm its caches via POST /admin/warm
+func warmRemote(server, token, locales, days string) ([]WarmResult, error) {
+	endpoint := strings.TrimSuffix(server, "/") + "/admin/warm?" + url.Values{"locales": {locales}, "days": {days}}.Encode()
+
+	req, err := http.NewRequest(http.MethodPost, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server URL: %w", err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := (&http.Client{Timeout: warmRequestTimeout}).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach server: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read server response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var results []WarmResult
+	if err := json.Unmarshal(body, &results); err != nil {
+		return nil, fmt.Errorf("failed to parse server response: %w", err)
+	}
+	return results, nil
+}
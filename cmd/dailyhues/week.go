@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mgabor3141/dailyhues/internal/bing"
+	"github.com/mgabor3141/dailyhues/internal/cache"
+)
+
+// maxWeekAnalyses caps how many uncached days a single week request analyzes at once
+const maxWeekAnalyses = 2
+
+// WeekResponse lists the palettes of every day Bing still serves for a locale, newest first
+type WeekResponse struct {
+	Locale string    `json:"locale"`
+	Days   []WeekDay `json:"days"`
+}
+
+// WeekDay is a single day of a WeekResponse
+// If the palette could not be generated, Error is set and only the wallpaper metadata is filled in
+type WeekDay struct {
+	DaysAgo int `json:"days_ago"`
+	ColorTheme
+	Error string `json:"error,omitempty"`
+}
+
+// handleWeek returns all currently available days for a locale with their palettes
+func (app *App) handleWeek(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	locale, err := validateLocale(r.URL.Query().Get("locale"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	angles, err := validateAngles(r.URL.Query().Get("angles"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	encoding, err := negotiateEncoding(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if encoding.themeOnly {
+		respondWithError(w, http.StatusBadRequest, "format is only supported for single palette responses")
+		return
+	}
+
+	infos, err := app.bingClient.WithLocale(locale).GetRecentWallpaperInfos(r.Context())
+	if err != nil {
+		slog.InfoContext(r.Context(), "Failed to fetch wallpaper archive", "locale", locale, "error", err)
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to fetch wallpaper archive: %v", err))
+		return
+	}
+
+	days := app.getWeekDays(locale, infos, time.Now())
+	for i := range days {
+		days[i].ColorTheme = withAllowedAngles(days[i].ColorTheme, angles)
+	}
+
+	respondEncoded(w, http.StatusOK, encoding, WeekResponse{
+		Locale: locale,
+		Days:   days,
+	})
+}
+
+// getWeekDays resolves the palette for each archive entry, analyzing at most maxWeekAnalyses images in parallel
+func (app *App) getWeekDays(locale string, infos []*bing.WallpaperInfo, now time.Time) []WeekDay {
+	days := make([]WeekDay, len(infos))
+	slots := make(chan struct{}, maxWeekAnalyses)
+
+	var wg sync.WaitGroup
+	for daysAgo, info := range infos {
+		// Cached days are answered directly without taking an analysis slot
+		if theme, ok := app.cachedThemeForDate(locale, info.StartDate); ok {
+			days[daysAgo] = WeekDay{DaysAgo: daysAgo, ColorTheme: withUpdateSchedule(theme, daysAgo, now)}
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			palette, err := app.palettes().ResolveWallpaper(context.Background(), sourceBing, locale, daysAgo, info)
+			if err != nil {
+				days[daysAgo] = WeekDay{
+					DaysAgo:    daysAgo,
+					ColorTheme: buildColorThemeFromInfo(info, &cache.AnalysisEntry{}),
+					Error:      err.Error(),
+				}
+				return
+			}
+			days[daysAgo] = WeekDay{DaysAgo: daysAgo, ColorTheme: withUpdateSchedule(themeFromPalette(palette), daysAgo, now)}
+		}()
+	}
+	wg.Wait()
+
+	return days
+}
+
+// cachedThemeForDate returns the palette for a locale's wallpaper on startDate if both caches have it
+func (app *App) cachedThemeForDate(locale, startDate string) (ColorTheme, bool) {
+	reqEntry := app.requestCache.GetByDate(locale, startDate)
+	if reqEntry == nil {
+		return ColorTheme{}, false
+	}
+
+	analysisEntry := app.analysisCache.Get(reqEntry.ImageHash)
+	if analysisEntry == nil {
+		return ColorTheme{}, false
+	}
+
+	return buildColorTheme(reqEntry, analysisEntry), true
+}
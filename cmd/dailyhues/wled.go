@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+
+	"github.com/mgabor3141/dailyhues/internal/palette"
+)
+
+const (
+	defaultWLEDLEDs     = 30
+	defaultWLEDSegments = 8
+	maxWLEDLEDs         = 8192
+	maxWLEDSegments     = 32 // WLED's segment limit on ESP32 boards
+)
+
+// wledLayout is the strip a WLED state is made for: its LED count, split into that many segments of the gradient
+type wledLayout struct {
+	LEDs     int
+	Segments int
+}
+
+var defaultWLEDLayout = wledLayout{LEDs: defaultWLEDLEDs, Segments: defaultWLEDSegments}
+
+// WLEDState is a state for WLED's JSON API (format=wled): it can be saved as a preset, or POSTed to /json/state
+// The strip is split into solid segments colored along the gradient, from gradient_from at the first LED to gradient_to at the last
+type WLEDState struct {
+	On       bool          `json:"on"`
+	Segments []WLEDSegment `json:"seg"`
+}
+
+// WLEDSegment is a run of LEDs in one solid color
+type WLEDSegment struct {
+	ID     int      `json:"id"`
+	Start  int      `json:"start"`
+	Stop   int      `json:"stop"` // Exclusive
+	On     bool     `json:"on"`
+	Colors [][3]int `json:"col"` // Only the primary color is set
+	Effect int      `json:"fx"`  // 0 is Solid
+}
+
+// validateWLEDLayout validates the leds and segments parameters of format=wled
+func validateWLEDLayout(ledsParam, segmentsParam string) (wledLayout, error) {
+	layout := defaultWLEDLayout
+	if ledsParam != "" {
+		leds, err := strconv.Atoi(ledsParam)
+		if err != nil || leds < 1 || leds > maxWLEDLEDs {
+			return wledLayout{}, fmt.Errorf("invalid leds parameter. Must be from 1 to %d", maxWLEDLEDs)
+		}
+		layout.LEDs = leds
+	}
+	if segmentsParam != "" {
+		segments, err := strconv.Atoi(segmentsParam)
+		if err != nil || segments < 1 || segments > maxWLEDSegments {
+			return wledLayout{}, fmt.Errorf("invalid segments parameter. Must be from 1 to %d", maxWLEDSegments)
+		}
+		layout.Segments = segments
+	}
+	return layout, nil
+}
+
+// loadWLEDLayout reads CHANGE_WLED_LEDS and CHANGE_WLED_SEGMENTS, the strip palette changes are pushed to
+func loadWLEDLayout() wledLayout {
+	layout, err := validateWLEDLayout(os.Getenv("CHANGE_WLED_LEDS"), os.Getenv("CHANGE_WLED_SEGMENTS"))
+	if err != nil {
+		slog.Info("Invalid CHANGE_WLED_LEDS or CHANGE_WLED_SEGMENTS, using defaults", "error", err,
+			"leds", defaultWLEDLEDs, "segments", defaultWLEDSegments)
+		return defaultWLEDLayout
+	}
+	return layout
+}
+
+// marshalWLED renders a palette as a WLED state for the given strip, encoding it with marshal
+func marshalWLED(layout wledLayout, marshal func(interface{}) ([]byte, error)) func(interface{}) ([]byte, error) {
+	return func(data interface{}) ([]byte, error) {
+		theme, ok := data.(ColorTheme)
+		if !ok {
+			return nil, fmt.Errorf("format is only supported for single palette responses")
+		}
+		state, err := wledState(theme, layout)
+		if err != nil {
+			return nil, err
+		}
+		return marshal(state)
+	}
+}
+
+// wledState splits the strip into segments and colors them along the palette's gradient in OKLCH, as transitions blend
+// A strip shorter than the segment count gets one segment per LED
+func wledState(theme ColorTheme, layout wledLayout) (WLEDState, error) {
+	gradient, err := palette.GradientFromColors(theme.Colors)
+	if err != nil {
+		return WLEDState{}, fmt.Errorf("palette has no gradient for WLED: %w", err)
+	}
+	from, err := palette.ParseHex(gradient.From)
+	if err != nil {
+		return WLEDState{}, err
+	}
+	to, err := palette.ParseHex(gradient.To)
+	if err != nil {
+		return WLEDState{}, err
+	}
+
+	segments := min(layout.Segments, layout.LEDs)
+	state := WLEDState{On: true, Segments: make([]WLEDSegment, segments)}
+	for i := range segments {
+		t := 0.5
+		if segments > 1 {
+			t = float64(i) / float64(segments-1)
+		}
+		r, g, b := palette.InterpolateOKLCH(from.OKLCH(), to.OKLCH(), t).RGB().Bytes()
+		state.Segments[i] = WLEDSegment{
+			ID:     i,
+			Start:  i * layout.LEDs / segments,
+			Stop:   (i + 1) * layout.LEDs / segments,
+			On:     true,
+			Colors: [][3]int{{int(r), int(g), int(b)}},
+		}
+	}
+	return state, nil
+}
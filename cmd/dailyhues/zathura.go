@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// marshalZathura renders a palette as zathurarc settings (format=zathura)
+// Documents are recolored to the overlay's text on its background, so pages match the interface in recolor mode
+func marshalZathura(data interface{}) ([]byte, error) {
+	theme, ok := data.(ColorTheme)
+	if !ok {
+		return nil, fmt.Errorf("format is only supported for single palette responses")
+	}
+	_, colors, err := themeOverlay(theme, "a document viewer theme")
+	if err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# dailyhues colors for %s (%s)\n", strings.ReplaceAll(theme.Title, "\n", " "), theme.StartDate)
+	b.WriteString("set recolor true\n")
+	for _, option := range [][2]string{
+		{"recolor-lightcolor", colors.Background}, // Page white
+		{"recolor-darkcolor", colors.Text},        // Page text
+		{"default-bg", colors.Background},
+		{"default-fg", colors.Text},
+		{"statusbar-bg", colors.Background},
+		{"statusbar-fg", colors.TextMuted},
+		{"inputbar-bg", colors.Background},
+		{"inputbar-fg", colors.Text},
+		{"notification-bg", colors.Accent},
+		{"notification-fg", colors.AccentText},
+		{"highlight-color", colors.TextMuted},
+		{"highlight-active-color", colors.Accent},
+		{"index-bg", colors.Background},
+		{"index-fg", colors.Text},
+		{"index-active-bg", colors.Accent},
+		{"index-active-fg", colors.AccentText},
+		{"completion-bg", colors.Background},
+		{"completion-fg", colors.Text},
+		{"completion-highlight-bg", colors.Accent},
+		{"completion-highlight-fg", colors.AccentText},
+	} {
+		fmt.Fprintf(&b, "set %s %q\n", option[0], option[1])
+	}
+	return []byte(b.String()), nil
+}
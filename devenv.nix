@@ -33,7 +33,7 @@
 
   scripts.build.exec = ''
     echo "Building binary..."
-    go build -o bin/dailyhues ./cmd/dailyhues
+    go build -ldflags "-X github.com/mgabor3141/dailyhues/internal/version.Version=$(git describe --tags --always --dirty) -X github.com/mgabor3141/dailyhues/internal/version.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)" -o bin/dailyhues ./cmd/dailyhues
   '';
 
   scripts.test.exec = ''
@@ -13,13 +13,20 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/mgabor3141/dailyhues/internal/logging"
+	"github.com/mgabor3141/dailyhues/internal/metrics"
+	"github.com/mgabor3141/dailyhues/internal/version"
 )
 
 const (
 	openRouterURL       = "https://openrouter.ai/api/v1/chat/completions"
-	claudeModel         = "anthropic/claude-sonnet-4.5"
+	defaultModel        = "anthropic/claude-sonnet-4.5"
 	aiRequestTimeout    = 60 * time.Second
+	analysisImageHeight = 540 // Images are downscaled to this height to reduce token count
 	colorAnalysisPrompt = `You are a professional UI/UX designer and artist with a strong background in color theory and accessibility guidelines. You are working on the theme for a desktop window manager, and need to design a gradient for when the attached image is set as the desktop wallpaper. Please design a gradient that will work well as the color for the focused window's border!
 
 - Think about the mood of the image and how you can use the UI colors to enhance it.
@@ -30,42 +37,95 @@ const (
 - You can choose to use two similar colors for a subtle gradient, or distinct ones if the composition calls for it.
 - The gradient direction should compliment the image, but keep in mind that the bottom and top of the image are the most important areas for contrast!
 - Keep in mind that the colors should have enough contrast to be readable, but must not clash with the image's colors.
+- Also pick an "inactive_border" color for unfocused windows: a muted, lower contrast color from the same palette that recedes into the wallpaper while still being visible.
 
 (Reminder: A gradient direction of 135 degrees goes from the top left to the bottom right, 180 degrees goes from top to bottom, etc.)
 
 Reply only with a JSON object with the following format. Do not include any additional text or comments.
 
-{"gradient_from": "#34495e", "gradient_to": "#456789", "gradient_angle": 45}`
+{"gradient_from": "#34495e", "gradient_to": "#456789", "gradient_angle": 45, "inactive_border": "#3d4650"}`
 )
 
+// PromptVersion identifies the revision of colorAnalysisPrompt; bump it whenever the prompt changes
+// so palettes can be traced back to the instructions that produced them
+const PromptVersion = 1
+
+// logger returns the logger for this package's messages
+func logger() *slog.Logger {
+	return logging.Package("ai")
+}
+
 // Analyzer handles AI-powered color analysis of images
 type Analyzer struct {
-	apiKey     string
-	httpClient *http.Client
+	apiKey         string
+	endpoint       string // OpenRouter chat completions URL
+	modelsEndpoint string // OpenRouter model list, for pricing
+	httpClient     *http.Client
+
+	breaker breaker // Shared by all models, since they fail together when OpenRouter does
+	limiter limiter // Bounds concurrent analyses, so a burst of uncached locales can't fan out into as many model calls
+
+	modelsMu      sync.Mutex
+	models        map[string]modelInfo // OpenRouter's model list by ID; nil until first fetched
+	modelsFetched time.Time
 }
 
 // NewAnalyzer creates a new AI analyzer
 func NewAnalyzer(apiKey string) *Analyzer {
 	return &Analyzer{
-		apiKey: apiKey,
+		apiKey:         apiKey,
+		endpoint:       openRouterURL,
+		modelsEndpoint: openRouterModelsURL,
 		httpClient: &http.Client{
 			Timeout: aiRequestTimeout,
 		},
 	}
 }
 
+// Usage records which model produced an analysis and what it cost
+type Usage struct {
+	Model            string
+	PromptTokens     int
+	CompletionTokens int
+	Cost             float64 // OpenRouter credits (USD); 0 if not reported
+}
+
+// Plus returns the combined usage of two sets of requests, keeping u's model if it has one
+func (u Usage) Plus(other Usage) Usage {
+	if u.Model == "" {
+		u.Model = other.Model
+	}
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+	u.Cost += other.Cost
+	return u
+}
+
+// Billed reports whether OpenRouter charged for any of the requests
+func (u Usage) Billed() bool {
+	return u.PromptTokens > 0 || u.CompletionTokens > 0 || u.Cost > 0
+}
+
 // openRouterRequest represents the request format for OpenRouter API
 type openRouterRequest struct {
-	Model     string    `json:"model"`
-	Reasoning reasoning `json:"reasoning"`
-	Messages  []message `json:"messages"`
-	MaxTokens int       `json:"max_tokens"`
+	Model          string               `json:"model"`
+	Reasoning      reasoning            `json:"reasoning"`
+	Messages       []message            `json:"messages"`
+	MaxTokens      int                  `json:"max_tokens"`
+	Usage          usageRequest         `json:"usage"`
+	Provider       *ProviderPreferences `json:"provider,omitempty"`
+	ResponseFormat *responseFormat      `json:"response_format,omitempty"`
 }
 
 type reasoning struct {
 	Enabled bool `json:"enabled"`
 }
 
+// usageRequest asks OpenRouter to include the cost in the usage block of the response
+type usageRequest struct {
+	Include bool `json:"include"`
+}
+
 type message struct {
 	Role    string        `json:"role"`
 	Content []contentPart `json:"content"`
@@ -93,10 +153,12 @@ type openRouterResponse struct {
 		Message string `json:"message"`
 		Code    string `json:"code"`
 	} `json:"error,omitempty"`
+	Model string `json:"model"`
 	Usage *struct {
-		PromptTokens     int `json:"prompt_tokens"`
-		CompletionTokens int `json:"completion_tokens"`
-		TotalTokens      int `json:"total_tokens"`
+		PromptTokens     int     `json:"prompt_tokens"`
+		CompletionTokens int     `json:"completion_tokens"`
+		TotalTokens      int     `json:"total_tokens"`
+		Cost             float64 `json:"cost"`
 	} `json:"usage,omitempty"`
 }
 
@@ -107,6 +169,7 @@ type debugResponse struct {
 	ImageName    string                 `json:"image_name"`
 	ImageSize    int                    `json:"image_size_bytes"`
 	Model        string                 `json:"model"`
+	Version      version.Info           `json:"version"`
 	Content      string                 `json:"content"`
 	ParsedColors map[string]interface{} `json:"parsed_colors"`
 	Usage        map[string]int         `json:"usage,omitempty"`
@@ -114,14 +177,14 @@ type debugResponse struct {
 }
 
 // saveDebugResponse saves the AI response to a debug file
-func (a *Analyzer) saveDebugResponse(imageHash string, imageName string, imageSize int, apiResp *openRouterResponse, colors map[string]interface{}) error {
+func (a *Analyzer) saveDebugResponse(model string, imageHash string, imageName string, imageSize int, apiResp *openRouterResponse, colors map[string]interface{}) error {
 	// Only save debug responses if explicitly enabled
 	if os.Getenv("DEBUG_AI_RESPONSES") != "true" {
 		return nil
 	}
 
 	// Create debug directory if it doesn't exist
-	debugDir := "debug_responses"
+	debugDir := DebugDir()
 	if err := os.MkdirAll(debugDir, 0755); err != nil {
 		return fmt.Errorf("failed to create debug directory: %w", err)
 	}
@@ -133,7 +196,8 @@ func (a *Analyzer) saveDebugResponse(imageHash string, imageName string, imageSi
 		ImageHash:    imageHash,
 		ImageName:    imageName,
 		ImageSize:    imageSize,
-		Model:        claudeModel,
+		Model:        model,
+		Version:      version.Get(),
 		ParsedColors: colors,
 		RawResponse:  apiResp,
 	}
@@ -162,53 +226,163 @@ func (a *Analyzer) saveDebugResponse(imageHash string, imageName string, imageSi
 	if len(sanitizedName) > 50 {
 		sanitizedName = sanitizedName[:50]
 	}
+	// Analyses by other models (canaries) get their own file instead of replacing the primary one
+	if model != Model() {
+		sanitizedName += "_" + regexp.MustCompile(`[^a-zA-Z0-9-_]`).ReplaceAllString(model, "_")
+	}
 	filename := filepath.Join(debugDir, fmt.Sprintf("%s_%s_%s.json", timestamp, sanitizedName, imageHash[:12]))
 	if err := os.WriteFile(filename, jsonData, 0644); err != nil {
 		return fmt.Errorf("failed to write debug file: %w", err)
 	}
 
-	slog.Info("Debug response saved", "filename", filename)
+	logger().Info("Debug response saved", "filename", filename)
+
+	if removed, err := pruneDebugResponses(debugDir, loadDebugRetention(), time.Now()); err != nil {
+		logger().Error("Failed to prune debug responses", "error", err)
+	} else if removed > 0 {
+		logger().Info("Pruned debug responses", "removed", removed)
+	}
 	return nil
 }
 
-// AnalyzeColors sends an image to Claude via OpenRouter for color analysis
-// Returns a map of named hex color codes suitable for theming
-func (a *Analyzer) AnalyzeColors(imageData []byte, imageHash string, title string, copyright string) (map[string]interface{}, error) {
+// AnalyzeColors sends an image to the configured model via OpenRouter for color analysis
+// promptAddendum holds operator instructions appended to the prompt; empty uses the prompt as is
+// Returns a map of named hex color codes suitable for theming, plus the model and token usage of the call
+// A failed analysis still returns the usage of any requests that were billed, so callers can record it
+func (a *Analyzer) AnalyzeColors(imageData []byte, imageHash string, title string, copyright string, promptAddendum string) (map[string]interface{}, Usage, error) {
+	return a.AnalyzeColorsWithModel(Model(), imageData, imageHash, title, copyright, promptAddendum)
+}
+
+// AnalyzeColorsWithModel is AnalyzeColors with another OpenRouter model, e.g. a candidate being evaluated
+func (a *Analyzer) AnalyzeColorsWithModel(model string, imageData []byte, imageHash string, title string, copyright string, promptAddendum string) (map[string]interface{}, Usage, error) {
+	// Hold the model to the reply format where it supports structured outputs
+	return a.analyze(model, analysisPrompt(promptAddendum), a.replyFormat(model, colorsFormat), validateColors, imageData, imageHash, title)
+}
+
+// AnalyzeWithPrompt analyzes an image with an operator's own prompt instead of colorAnalysisPrompt
+// The prompt decides which colors are returned, so the reply is only required to be a JSON object of
+// valid hex colors, and no schema is sent
+func (a *Analyzer) AnalyzeWithPrompt(prompt string, imageData []byte, imageHash string, title string) (map[string]interface{}, Usage, error) {
+	return a.analyze(Model(), prompt, nil, validateProfileColors, imageData, imageHash, title)
+}
+
+// analyze sends an image with a prompt and returns the reply's colors once validate has no problems with them
+// The usage of every billed request is returned, with the error too when the correction fails
+func (a *Analyzer) analyze(model, prompt string, format *responseFormat, validate func(map[string]interface{}) []string, imageData []byte, imageHash string, title string) (map[string]interface{}, Usage, error) {
 	// Resize image to reduce token count
-	resizedImage, err := a.resizeImage(imageData, 540)
+	resizeStart := time.Now()
+	resizedImage, err := AnalysisImage(imageData)
+	metrics.StageLatency.Since("image_resize", resizeStart)
 	if err != nil {
-		return nil, fmt.Errorf("failed to resize image: %w", err)
+		return nil, Usage{}, fmt.Errorf("failed to resize image: %w", err)
 	}
 
+	// Wait for a slot, held until the correction request below is done too
+	queueStart := time.Now()
+	if err := a.limiter.acquire(); err != nil {
+		logger().Info("AI analysis not started", "hash", imageHash, "model", model, "error", err)
+		return nil, Usage{}, err
+	}
+	defer a.limiter.release()
+	metrics.StageLatency.Since("ai_queue", queueStart)
+
 	// Encode image as base64
 	base64Image := base64.StdEncoding.EncodeToString(resizedImage)
 
-	// Construct the request
-	reqBody := openRouterRequest{
-		Model: claudeModel,
-		Reasoning: reasoning{
-			Enabled: true,
-		},
-		MaxTokens: 4168,
-		Messages: []message{
-			{
-				Role: "user",
-				Content: []contentPart{
-					{
-						Type: "image_url",
-						ImageURL: &imageURL{
-							URL: "data:image/jpeg;base64," + base64Image,
-						},
-					},
-					{
-						Type: "text",
-						Text: fmt.Sprintf(colorAnalysisPrompt, title, copyright),
+	messages := []message{
+		{
+			Role: "user",
+			Content: []contentPart{
+				{
+					Type: "image_url",
+					ImageURL: &imageURL{
+						URL: "data:image/jpeg;base64," + base64Image,
 					},
 				},
+				{
+					Type: "text",
+					Text: prompt,
+				},
 			},
 		},
 	}
 
+	apiResp, err := a.complete(model, messages, format)
+	if err != nil && format != nil && schemaRejected(err) {
+		logger().Info("Structured output was refused, retrying without a schema", "model", model, "error", err)
+		format = nil
+		apiResp, err = a.complete(model, messages, nil)
+	}
+	if err != nil {
+		return nil, Usage{}, err
+	}
+	usage := usageOf(model, apiResp)
+
+	// Retry once with the validation errors when the reply is unusable, instead of failing the request
+	content := apiResp.Choices[0].Message.Content
+	colors, problems := a.parseAndValidate(content, validate)
+	if len(problems) > 0 {
+		logger().Info("AI reply failed validation, asking for a correction", "hash", imageHash, "model", model, "problems", problems)
+		messages = append(messages,
+			message{Role: "assistant", Content: []contentPart{{Type: "text", Text: content}}},
+			message{Role: "user", Content: []contentPart{{Type: "text", Text: correctionPrompt(problems)}}},
+		)
+
+		apiResp, err = a.complete(model, messages, format)
+		if err != nil {
+			return nil, usage, fmt.Errorf("correction request failed: %w", err)
+		}
+		usage = usage.Plus(usageOf(model, apiResp))
+
+		colors, problems = a.parseAndValidate(apiResp.Choices[0].Message.Content, validate)
+		if len(problems) > 0 {
+			return nil, usage, fmt.Errorf("invalid colors after correction: %s", strings.Join(problems, "; "))
+		}
+	}
+
+	// Save debug response (log error but don't fail the request)
+	if debugErr := a.saveDebugResponse(model, imageHash, title, len(imageData), apiResp, colors); debugErr != nil {
+		logger().Error("Warning: Failed to save debug response", "error", debugErr)
+	}
+
+	return colors, usage, nil
+}
+
+// complete sends a conversation to OpenRouter and returns the reply, which has at least one choice
+// Transient failures are retried with backoff; while OpenRouter keeps failing, ErrCircuitOpen is returned right away
+// format constrains the reply to a JSON schema; nil leaves it free text
+func (a *Analyzer) complete(model string, messages []message, format *responseFormat) (*openRouterResponse, error) {
+	if err := a.breaker.allow(time.Now()); err != nil {
+		return nil, err
+	}
+
+	policy := loadRetryPolicy()
+	apiResp, err := a.send(model, messages, format)
+	for attempt := 1; attempt <= policy.retries && err != nil && transient(err); attempt++ {
+		delay := policy.delay(attempt, err)
+		logger().Info("OpenRouter request failed, retrying", "model", model, "attempt", attempt, "delay", delay, "error", err)
+		time.Sleep(delay)
+		apiResp, err = a.send(model, messages, format)
+	}
+
+	a.breaker.record(err, time.Now())
+	return apiResp, err
+}
+
+// send makes a single chat completions request
+func (a *Analyzer) send(model string, messages []message, format *responseFormat) (*openRouterResponse, error) {
+	reqBody := openRouterRequest{
+		Model: model,
+		Reasoning: reasoning{
+			Enabled: true,
+		},
+		MaxTokens:      4168,
+		Usage:          usageRequest{Include: true},
+		Messages:       messages,
+		Provider:       loadProviderPreferences(),
+		ResponseFormat: format,
+	}
+
 	// Marshal request to JSON
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
@@ -216,7 +390,7 @@ func (a *Analyzer) AnalyzeColors(imageData []byte, imageHash string, title strin
 	}
 
 	// Create HTTP request
-	req, err := http.NewRequest("POST", openRouterURL, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequest("POST", a.endpoint, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -224,25 +398,28 @@ func (a *Analyzer) AnalyzeColors(imageData []byte, imageHash string, title strin
 	// Set headers
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+a.apiKey)
-	req.Header.Set("HTTP-Referer", "https://github.com/mgabor3141/dailyhues")
-	req.Header.Set("X-Title", "dailyhues")
+	referer, appTitle := attribution()
+	req.Header.Set("HTTP-Referer", referer)
+	req.Header.Set("X-Title", appTitle)
 
 	// Make the request
+	requestStart := time.Now()
 	resp, err := a.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request to OpenRouter: %w", err)
+		return nil, &networkError{fmt.Errorf("failed to send request to OpenRouter: %w", err)}
 	}
 	defer resp.Body.Close()
 
 	// Read response body
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, &networkError{fmt.Errorf("failed to read response: %w", err)}
 	}
+	metrics.StageLatency.Since("ai_request", requestStart)
 
 	// Check for HTTP errors
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("OpenRouter API returned status %d: %s", resp.StatusCode, string(body))
+		return nil, &statusError{StatusCode: resp.StatusCode, RetryAfter: retryAfter(resp.Header.Get("Retry-After")), Body: string(body)}
 	}
 
 	// Parse response
@@ -261,65 +438,133 @@ func (a *Analyzer) AnalyzeColors(imageData []byte, imageHash string, title strin
 		return nil, fmt.Errorf("no response from AI model")
 	}
 
-	content := apiResp.Choices[0].Message.Content
+	return &apiResp, nil
+}
+
+// usageOf reads the model and token usage of a reply, falling back to the requested model
+func usageOf(model string, apiResp *openRouterResponse) Usage {
+	usage := Usage{Model: apiResp.Model}
+	if usage.Model == "" {
+		usage.Model = model
+	}
+	if apiResp.Usage != nil {
+		usage.PromptTokens = apiResp.Usage.PromptTokens
+		usage.CompletionTokens = apiResp.Usage.CompletionTokens
+		usage.Cost = apiResp.Usage.Cost
+	}
+	return usage
+}
 
-	// Parse the color array from the response
+// parseAndValidate extracts the colors from a reply, repairs unambiguous mistakes and lists what is still wrong, if anything
+func (a *Analyzer) parseAndValidate(content string, validate func(map[string]interface{}) []string) (map[string]interface{}, []string) {
+	parseStart := time.Now()
 	colors, err := a.parseColorsFromResponse(content)
+	metrics.StageLatency.Since("ai_parse", parseStart)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse colors: %w", err)
+		return nil, []string{"the reply did not contain a JSON object"}
 	}
-
-	// Save debug response (log error but don't fail the request)
-	if debugErr := a.saveDebugResponse(imageHash, title, len(imageData), &apiResp, colors); debugErr != nil {
-		slog.Error("Warning: Failed to save debug response", "error", debugErr)
+	if repairs := repairColors(colors); len(repairs) > 0 {
+		logger().Info("Repaired AI reply", "repairs", repairs)
 	}
+	return colors, validate(colors)
+}
 
-	return colors, nil
+// analysisPrompt returns the color analysis prompt with the operator's addendum, if any
+func analysisPrompt(addendum string) string {
+	if addendum == "" {
+		return colorAnalysisPrompt
+	}
+	return colorAnalysisPrompt + "\n\nAdditional instructions (still reply only with the JSON object): " + addendum
 }
 
 // parseColorsFromResponse extracts named color codes and other values from the AI's response
 // Returns a map with flexible value types to handle both strings (colors) and ints (angles) or other future types
+// Structured replies are exactly the object and decode directly; free text is searched for the object
 func (a *Analyzer) parseColorsFromResponse(content string) (map[string]interface{}, error) {
-	// Try to parse as JSON object first
-	var result map[string]interface{}
-	if err := json.Unmarshal([]byte(content), &result); err == nil {
-		return result, nil
-	}
-
-	// If that fails, try to extract JSON object from the text
-	// Use a more flexible regex that can handle nested structures and multiline JSON
-	jsonObjectRegex := regexp.MustCompile(`(?s)\{[^{}]*(?:\{[^{}]*\}[^{}]*)*\}`)
-	matches := jsonObjectRegex.FindStringSubmatch(content)
-	if len(matches) > 0 {
-		if err := json.Unmarshal([]byte(matches[0]), &result); err == nil {
-			return result, nil
-		}
+	var colors map[string]interface{}
+	if err := json.Unmarshal([]byte(content), &colors); err == nil && colors != nil {
+		return colors, nil
 	}
+	return extractJSONObject(content)
+}
 
-	return nil, fmt.Errorf("could not extract colors from response: %s", content)
+// Model returns the OpenRouter model used for new analyses, from AI_MODEL or the default
+// Read per analysis so a config reload switches models without a restart
+func Model() string {
+	if model := strings.TrimSpace(os.Getenv("AI_MODEL")); model != "" {
+		return model
+	}
+	return defaultModel
+}
+
+// attribution returns how requests are attributed in OpenRouter's app rankings
+// Self-hosted deployments are identified by BASE_URL and INSTANCE_NAME instead of the upstream project
+func attribution() (referer, title string) {
+	referer, title = os.Getenv("BASE_URL"), os.Getenv("INSTANCE_NAME")
+	if referer == "" {
+		referer = "https://github.com/mgabor3141/dailyhues"
+	}
+	if title == "" {
+		title = "dailyhues"
+	}
+	return referer, title
+}
+
+// AnalysisImage returns the downscaled image that AnalyzeColors sends to the model
+// With AI_IMAGE_MAX_BYTES or AI_IMAGE_MAX_TOKENS set, quality and resolution are reduced further to fit
+func AnalysisImage(imageData []byte) ([]byte, error) {
+	resized, err := resizeImage(imageData, analysisImageHeight)
+	if err != nil {
+		return nil, err
+	}
+
+	budget := loadImageBudget()
+	if budget == (imageBudget{}) {
+		return resized, nil
+	}
+
+	img, _, err := image.DecodeConfig(bytes.NewReader(resized))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode resized image: %w", err)
+	}
+	if budget.fits(resized, img.Width, img.Height) {
+		return resized, nil
+	}
+
+	return fitImageBudget(imageData, budget)
 }
 
 // resizeImage resizes an image to a maximum height while maintaining aspect ratio
-func (a *Analyzer) resizeImage(imageData []byte, maxHeight int) ([]byte, error) {
+func resizeImage(imageData []byte, maxHeight int) ([]byte, error) {
 	// Decode image
 	img, _, err := image.Decode(bytes.NewReader(imageData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode image: %w", err)
 	}
 
+	// If already smaller than max height, return original
+	if img.Bounds().Dy() <= maxHeight {
+		return imageData, nil
+	}
+
+	// Encode to JPEG
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, scaleImage(img, maxHeight), &jpeg.Options{Quality: defaultJPEGQuality}); err != nil {
+		return nil, fmt.Errorf("failed to encode resized image: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// scaleImage scales an image to the given height while maintaining aspect ratio
+func scaleImage(img image.Image, newHeight int) image.Image {
 	// Get original dimensions
 	bounds := img.Bounds()
 	width := bounds.Dx()
 	height := bounds.Dy()
 
-	// If already smaller than max height, return original
-	if height <= maxHeight {
-		return imageData, nil
-	}
-
 	// Calculate new dimensions maintaining aspect ratio
-	newHeight := maxHeight
-	newWidth := (width * maxHeight) / height
+	newWidth := (width * newHeight) / height
 
 	// Create new image with calculated dimensions
 	resized := image.NewRGBA(image.Rect(0, 0, newWidth, newHeight))
@@ -327,17 +572,11 @@ func (a *Analyzer) resizeImage(imageData []byte, maxHeight int) ([]byte, error)
 	// Simple nearest-neighbor scaling
 	for y := 0; y < newHeight; y++ {
 		for x := 0; x < newWidth; x++ {
-			srcX := (x * width) / newWidth
-			srcY := (y * height) / newHeight
+			srcX := bounds.Min.X + (x*width)/newWidth
+			srcY := bounds.Min.Y + (y*height)/newHeight
 			resized.Set(x, y, img.At(srcX, srcY))
 		}
 	}
 
-	// Encode to JPEG
-	var buf bytes.Buffer
-	if err := jpeg.Encode(&buf, resized, &jpeg.Options{Quality: 85}); err != nil {
-		return nil, fmt.Errorf("failed to encode resized image: %w", err)
-	}
-
-	return buf.Bytes(), nil
+	return resized
 }
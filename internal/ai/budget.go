@@ -0,0 +1,106 @@
+package ai
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"os"
+	"strconv"
+)
+
+const (
+	defaultJPEGQuality = 85
+	// minBudgetHeight is the smallest image worth analyzing; budgets that need less fail instead
+	minBudgetHeight = 128
+	// pixelsPerImageToken approximates how the model bills images (width * height / 750)
+	pixelsPerImageToken = 750
+)
+
+// budgetQualities are the JPEG qualities tried at each height before shrinking further
+var budgetQualities = []int{defaultJPEGQuality, 75, 65, 55}
+
+// imageBudget caps the size of the image sent to the model; zero fields are unlimited
+type imageBudget struct {
+	maxBytes  int // Length of the base64 payload
+	maxTokens int // Estimated image tokens
+}
+
+// loadImageBudget reads AI_IMAGE_MAX_BYTES and AI_IMAGE_MAX_TOKENS
+func loadImageBudget() imageBudget {
+	var budget imageBudget
+
+	if value := os.Getenv("AI_IMAGE_MAX_BYTES"); value != "" {
+		if maxBytes, err := strconv.Atoi(value); err == nil && maxBytes >= 0 {
+			budget.maxBytes = maxBytes
+		} else {
+			logger().Info("Ignoring invalid AI_IMAGE_MAX_BYTES", "value", value)
+		}
+	}
+
+	if value := os.Getenv("AI_IMAGE_MAX_TOKENS"); value != "" {
+		if maxTokens, err := strconv.Atoi(value); err == nil && maxTokens >= 0 {
+			budget.maxTokens = maxTokens
+		} else {
+			logger().Info("Ignoring invalid AI_IMAGE_MAX_TOKENS", "value", value)
+		}
+	}
+
+	return budget
+}
+
+// ImageBudget returns the configured image budget in payload bytes and estimated tokens (0 = unlimited)
+func ImageBudget() (maxBytes, maxTokens int) {
+	budget := loadImageBudget()
+	return budget.maxBytes, budget.maxTokens
+}
+
+// fits reports whether an encoded image of the given dimensions stays within the budget
+func (b imageBudget) fits(encoded []byte, width, height int) bool {
+	if b.maxBytes > 0 && base64.StdEncoding.EncodedLen(len(encoded)) > b.maxBytes {
+		return false
+	}
+	if b.maxTokens > 0 && estimateImageTokens(width, height) > b.maxTokens {
+		return false
+	}
+	return true
+}
+
+// estimateImageTokens approximates the prompt tokens an image of the given size costs
+func estimateImageTokens(width, height int) int {
+	return (width*height + pixelsPerImageToken - 1) / pixelsPerImageToken
+}
+
+// fitImageBudget downscales the image to the analysis height, then lowers quality and resolution
+// step by step until the payload fits the budget
+func fitImageBudget(imageData []byte, budget imageBudget) ([]byte, error) {
+	img, _, err := image.Decode(bytes.NewReader(imageData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	height := min(img.Bounds().Dy(), analysisImageHeight)
+	for ; height >= minBudgetHeight; height = height * 4 / 5 {
+		scaled := scaleImage(img, height)
+		for _, quality := range budgetQualities {
+			var buf bytes.Buffer
+			if err := jpeg.Encode(&buf, scaled, &jpeg.Options{Quality: quality}); err != nil {
+				return nil, fmt.Errorf("failed to encode resized image: %w", err)
+			}
+
+			width := scaled.Bounds().Dx()
+			if budget.fits(buf.Bytes(), width, height) {
+				logger().Info("Fitted image to budget",
+					"width", width,
+					"height", height,
+					"quality", quality,
+					"payload_bytes", base64.StdEncoding.EncodedLen(buf.Len()),
+					"estimated_tokens", estimateImageTokens(width, height))
+				return buf.Bytes(), nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("image does not fit the budget (max %d bytes, %d tokens) above %dpx", budget.maxBytes, budget.maxTokens, minBudgetHeight)
+}
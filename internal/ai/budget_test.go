@@ -0,0 +1,76 @@
+package ai
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// testJPEG encodes a noisy image so JPEG quality has a visible effect on size
+func testJPEG(t *testing.T, width, height int) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 7), G: uint8(y * 13), B: uint8(x * y), A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatalf("Failed to encode test image: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// TestFitImageBudget tests that byte and token budgets are met by reducing quality and resolution
+func TestFitImageBudget(t *testing.T) {
+	data := testJPEG(t, 1920, 1080)
+
+	unlimited, err := resizeImage(data, analysisImageHeight)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	maxBytes := base64.StdEncoding.EncodedLen(len(unlimited)) / 3
+
+	fitted, err := fitImageBudget(data, imageBudget{maxBytes: maxBytes})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if size := base64.StdEncoding.EncodedLen(len(fitted)); size > maxBytes {
+		t.Errorf("Expected payload within %d bytes, got %d", maxBytes, size)
+	}
+
+	fitted, err = fitImageBudget(data, imageBudget{maxTokens: 200})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	cfg, _, _ := image.DecodeConfig(bytes.NewReader(fitted))
+	if tokens := estimateImageTokens(cfg.Width, cfg.Height); tokens > 200 {
+		t.Errorf("Expected at most 200 tokens, got %d (%dx%d)", tokens, cfg.Width, cfg.Height)
+	}
+
+	if _, err := fitImageBudget(data, imageBudget{maxBytes: 100}); err == nil {
+		t.Error("Expected error for an unreachable budget")
+	}
+}
+
+// TestAnalysisImage_NoBudget tests that without a budget the image is only downscaled
+func TestAnalysisImage_NoBudget(t *testing.T) {
+	t.Setenv("AI_IMAGE_MAX_BYTES", "")
+	t.Setenv("AI_IMAGE_MAX_TOKENS", "")
+
+	resized, err := AnalysisImage(testJPEG(t, 1920, 1080))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	cfg, _, _ := image.DecodeConfig(bytes.NewReader(resized))
+	if cfg.Height != analysisImageHeight || cfg.Width != 960 {
+		t.Errorf("Expected 960x%d, got %dx%d", analysisImageHeight, cfg.Width, cfg.Height)
+	}
+}
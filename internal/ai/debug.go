@@ -0,0 +1,134 @@
+package ai
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultDebugDir      = "debug_responses"
+	defaultDebugMaxFiles = 1000
+	defaultDebugMaxAge   = 30 * 24 * time.Hour
+)
+
+// ErrDebugResponseNotFound is returned by ReadDebugResponse for unknown or invalid names
+var ErrDebugResponseNotFound = errors.New("debug response not found")
+
+// DebugFile describes a saved debug response
+type DebugFile struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size_bytes"`
+	ModTime time.Time `json:"modified_at"`
+}
+
+// debugRetention limits how many debug responses are kept; zero values disable a limit
+type debugRetention struct {
+	maxFiles int
+	maxAge   time.Duration
+}
+
+// DebugDir returns the directory debug responses are written to (DEBUG_AI_DIR, default debug_responses)
+func DebugDir() string {
+	if dir := os.Getenv("DEBUG_AI_DIR"); dir != "" {
+		return dir
+	}
+	return defaultDebugDir
+}
+
+// loadDebugRetention reads DEBUG_AI_MAX_FILES and DEBUG_AI_MAX_AGE, falling back to the defaults
+func loadDebugRetention() debugRetention {
+	retention := debugRetention{maxFiles: defaultDebugMaxFiles, maxAge: defaultDebugMaxAge}
+
+	if value := os.Getenv("DEBUG_AI_MAX_FILES"); value != "" {
+		if maxFiles, err := strconv.Atoi(value); err == nil && maxFiles >= 0 {
+			retention.maxFiles = maxFiles
+		} else {
+			logger().Info("Ignoring invalid DEBUG_AI_MAX_FILES", "value", value)
+		}
+	}
+
+	if value := os.Getenv("DEBUG_AI_MAX_AGE"); value != "" {
+		if maxAge, err := time.ParseDuration(value); err == nil && maxAge >= 0 {
+			retention.maxAge = maxAge
+		} else {
+			logger().Info("Ignoring invalid DEBUG_AI_MAX_AGE", "value", value)
+		}
+	}
+
+	return retention
+}
+
+// ListDebugResponses returns the saved debug responses, newest first
+func ListDebugResponses() ([]DebugFile, error) {
+	return listDebugFiles(DebugDir())
+}
+
+// ReadDebugResponse returns the contents of a saved debug response by file name
+func ReadDebugResponse(name string) ([]byte, error) {
+	// Only plain file names from ListDebugResponses are accepted, never paths
+	if name == "" || name != filepath.Base(name) || !strings.HasSuffix(name, ".json") {
+		return nil, ErrDebugResponseNotFound
+	}
+
+	data, err := os.ReadFile(filepath.Join(DebugDir(), name))
+	if os.IsNotExist(err) {
+		return nil, ErrDebugResponseNotFound
+	}
+	return data, err
+}
+
+// listDebugFiles lists the .json files in dir, newest first; a missing directory is empty
+func listDebugFiles(dir string) ([]DebugFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []DebugFile{}, nil
+		}
+		return nil, fmt.Errorf("failed to read debug directory: %w", err)
+	}
+
+	files := []DebugFile{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, DebugFile{Name: entry.Name(), Size: info.Size(), ModTime: info.ModTime()})
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].ModTime.After(files[j].ModTime) })
+	return files, nil
+}
+
+// pruneDebugResponses deletes debug responses older than the max age, then the oldest beyond the max count
+func pruneDebugResponses(dir string, retention debugRetention, now time.Time) (int, error) {
+	files, err := listDebugFiles(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for i, file := range files {
+		expired := retention.maxAge > 0 && now.Sub(file.ModTime) > retention.maxAge
+		overLimit := retention.maxFiles > 0 && i >= retention.maxFiles
+		if !expired && !overLimit {
+			continue
+		}
+
+		if err := os.Remove(filepath.Join(dir, file.Name)); err != nil && !os.IsNotExist(err) {
+			return removed, fmt.Errorf("failed to remove debug response: %w", err)
+		}
+		removed++
+	}
+
+	return removed, nil
+}
@@ -0,0 +1,65 @@
+package ai
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeDebugFile creates a debug response file with the given age
+func writeDebugFile(t *testing.T, dir, name string, age time.Duration, now time.Time) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(`{}`), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+	if err := os.Chtimes(path, now.Add(-age), now.Add(-age)); err != nil {
+		t.Fatalf("Failed to set time on %s: %v", name, err)
+	}
+}
+
+// TestPruneDebugResponses tests removal by age and by count, oldest first
+func TestPruneDebugResponses(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+
+	writeDebugFile(t, dir, "new.json", time.Hour, now)
+	writeDebugFile(t, dir, "older.json", 2*time.Hour, now)
+	writeDebugFile(t, dir, "oldest.json", 3*time.Hour, now)
+	writeDebugFile(t, dir, "ancient.json", 48*time.Hour, now)
+
+	removed, err := pruneDebugResponses(dir, debugRetention{maxFiles: 2, maxAge: 24 * time.Hour}, now)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if removed != 2 {
+		t.Errorf("Expected 2 files removed, got %d", removed)
+	}
+
+	files, _ := listDebugFiles(dir)
+	if len(files) != 2 || files[0].Name != "new.json" || files[1].Name != "older.json" {
+		t.Errorf("Expected the two newest files to remain, got %+v", files)
+	}
+
+	if removed, _ := pruneDebugResponses(dir, debugRetention{}, now); removed != 0 {
+		t.Errorf("Expected no pruning without limits, got %d", removed)
+	}
+}
+
+// TestReadDebugResponse tests reading by name and rejecting paths outside the debug directory
+func TestReadDebugResponse(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DEBUG_AI_DIR", dir)
+	writeDebugFile(t, dir, "saved.json", 0, time.Now())
+
+	if data, err := ReadDebugResponse("saved.json"); err != nil || string(data) != "{}" {
+		t.Errorf("Expected saved response, got %q, %v", data, err)
+	}
+
+	for _, name := range []string{"", "missing.json", "../saved.json", "saved", filepath.Join("sub", "saved.json")} {
+		if _, err := ReadDebugResponse(name); err != ErrDebugResponseNotFound {
+			t.Errorf("Expected not found for %q, got %v", name, err)
+		}
+	}
+}
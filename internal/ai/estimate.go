@@ -0,0 +1,69 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"unicode/utf8"
+)
+
+// charsPerTextToken approximates how many characters of English prompt text make one token
+const charsPerTextToken = 4
+
+// Pricing is what OpenRouter charges for a model, in USD per token and per request
+type Pricing struct {
+	Prompt     float64 `json:"prompt"`
+	Completion float64 `json:"completion"`
+	Request    float64 `json:"request,omitempty"`
+}
+
+// TokenEstimate is the expected prompt size of one analysis, before the model has seen it
+type TokenEstimate struct {
+	Width       int `json:"width"` // Dimensions of the image sent to the model
+	Height      int `json:"height"`
+	ImageTokens int `json:"image_tokens"`
+	TextTokens  int `json:"text_tokens"` // The analysis prompt, including the addendum
+}
+
+// PromptTokens returns the estimated prompt tokens of the image and text together
+func (e TokenEstimate) PromptTokens() int {
+	return e.ImageTokens + e.TextTokens
+}
+
+// EstimateTokens predicts the prompt tokens of analyzing an image of the given size with a prompt addendum
+// The image is shrunk as AnalysisImage would; AI_IMAGE_MAX_BYTES is ignored since it depends on the encoded image
+func EstimateTokens(width, height int, promptAddendum string) TokenEstimate {
+	budget := loadImageBudget()
+
+	scaledHeight := min(height, analysisImageHeight)
+	for budget.maxTokens > 0 && scaledHeight*4/5 >= minBudgetHeight &&
+		estimateImageTokens(width*scaledHeight/height, scaledHeight) > budget.maxTokens {
+		scaledHeight = scaledHeight * 4 / 5
+	}
+	scaledWidth := width * scaledHeight / height
+
+	prompt := analysisPrompt(promptAddendum)
+	return TokenEstimate{
+		Width:       scaledWidth,
+		Height:      scaledHeight,
+		ImageTokens: estimateImageTokens(scaledWidth, scaledHeight),
+		TextTokens:  (utf8.RuneCountInString(prompt) + charsPerTextToken - 1) / charsPerTextToken,
+	}
+}
+
+// ModelPricing returns OpenRouter's current price of a model
+func (a *Analyzer) ModelPricing(ctx context.Context, model string) (Pricing, error) {
+	info, err := a.modelInfo(ctx, model)
+	if err != nil {
+		return Pricing{}, err
+	}
+	if info.Pricing == nil {
+		return Pricing{}, fmt.Errorf("OpenRouter lists no usable price for model %q", model)
+	}
+	return *info.Pricing, nil
+}
+
+// Cost returns the price of one request with the given token counts
+// Image tokens are billed at the prompt rate, as OpenRouter does for the default model
+func (p Pricing) Cost(promptTokens, completionTokens int) float64 {
+	return float64(promptTokens)*p.Prompt + float64(completionTokens)*p.Completion + p.Request
+}
@@ -0,0 +1,77 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestEstimateTokens tests that the estimate follows the analysis resize and the token budget
+func TestEstimateTokens(t *testing.T) {
+	estimate := EstimateTokens(1920, 1080, "")
+	if estimate.Width != 960 || estimate.Height != 540 {
+		t.Errorf("Expected 960x540, got %dx%d", estimate.Width, estimate.Height)
+	}
+	if estimate.ImageTokens != 692 {
+		t.Errorf("Expected 692 image tokens, got %d", estimate.ImageTokens)
+	}
+	if estimate.TextTokens == 0 || estimate.PromptTokens() != estimate.ImageTokens+estimate.TextTokens {
+		t.Errorf("Unexpected text tokens: %+v", estimate)
+	}
+
+	if withAddendum := EstimateTokens(1920, 1080, "Prefer cooler tones."); withAddendum.TextTokens <= estimate.TextTokens {
+		t.Errorf("Expected the addendum to add text tokens, got %d and %d", withAddendum.TextTokens, estimate.TextTokens)
+	}
+
+	if small := EstimateTokens(320, 180, ""); small.Width != 320 || small.Height != 180 {
+		t.Errorf("Expected small images to keep their size, got %dx%d", small.Width, small.Height)
+	}
+
+	t.Setenv("AI_IMAGE_MAX_TOKENS", "300")
+	budgeted := EstimateTokens(1920, 1080, "")
+	if budgeted.ImageTokens > 300 || budgeted.Height >= 540 {
+		t.Errorf("Expected the image to shrink within 300 tokens, got %+v", budgeted)
+	}
+}
+
+// TestModelPricing tests that prices are parsed from the model list and fetched once
+func TestModelPricing(t *testing.T) {
+	fetches := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		fmt.Fprint(w, `{"data": [
+			{"id": "anthropic/claude-sonnet-4.5", "pricing": {"prompt": "0.000003", "completion": "0.000015", "image": "0.0048"}},
+			{"id": "example/paid-per-request", "pricing": {"prompt": "0", "completion": "0", "request": "0.01"}},
+			{"id": "example/broken", "pricing": {"prompt": "-", "completion": "0"}}
+		]}`)
+	}))
+	defer server.Close()
+
+	analyzer := NewAnalyzer("test-key")
+	analyzer.modelsEndpoint = server.URL
+
+	pricing, err := analyzer.ModelPricing(context.Background(), "anthropic/claude-sonnet-4.5")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if pricing != (Pricing{Prompt: 0.000003, Completion: 0.000015}) {
+		t.Errorf("Unexpected pricing: %+v", pricing)
+	}
+	if cost := pricing.Cost(1000, 100); cost < 0.00449 || cost > 0.00451 {
+		t.Errorf("Expected a cost of 0.0045, got %f", cost)
+	}
+
+	perRequest, err := analyzer.ModelPricing(context.Background(), "example/paid-per-request")
+	if err != nil || perRequest.Cost(1000, 100) != 0.01 {
+		t.Errorf("Expected the request price, got %+v, %v", perRequest, err)
+	}
+
+	if _, err := analyzer.ModelPricing(context.Background(), "example/broken"); err == nil {
+		t.Error("Expected an error for a model with unparseable prices")
+	}
+	if fetches != 1 {
+		t.Errorf("Expected the model list to be fetched once, got %d", fetches)
+	}
+}
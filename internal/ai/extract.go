@@ -0,0 +1,159 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// extractJSONObject finds the JSON object in a model reply
+// Models wrap the object in code fences, prose before or after it, or emit minor defects such as
+// trailing commas, so every balanced {...} candidate is tried as-is and then repaired.
+// Objects that contain a gradient are preferred over other objects the reply may quote
+func extractJSONObject(content string) (map[string]interface{}, error) {
+	var fallback map[string]interface{}
+
+	for _, candidate := range jsonCandidates(content) {
+		object, ok := parseJSONObject(candidate)
+		if !ok {
+			continue
+		}
+		if _, hasGradient := object["gradient_from"]; hasGradient {
+			return object, nil
+		}
+		if fallback == nil {
+			fallback = object
+		}
+	}
+
+	if fallback != nil {
+		return fallback, nil
+	}
+	return nil, fmt.Errorf("could not extract colors from response: %s", content)
+}
+
+// jsonCandidates returns the whole reply, the contents of any code fences, and every balanced top-level {...}
+func jsonCandidates(content string) []string {
+	candidates := []string{strings.TrimSpace(content)}
+
+	rest := content
+	for {
+		start := strings.Index(rest, "```")
+		if start < 0 {
+			break
+		}
+		body := rest[start+3:]
+		end := strings.Index(body, "```")
+		if end < 0 {
+			break
+		}
+
+		// Drop the language tag (```json)
+		fenced := body[:end]
+		if newline := strings.IndexByte(fenced, '\n'); newline >= 0 && !strings.Contains(fenced[:newline], "{") {
+			fenced = fenced[newline+1:]
+		}
+		candidates = append(candidates, strings.TrimSpace(fenced))
+		rest = body[end+3:]
+	}
+
+	return append(candidates, balancedObjects(content)...)
+}
+
+// balancedObjects returns each top-level {...} span, skipping braces inside strings
+func balancedObjects(content string) []string {
+	var objects []string
+	depth, start := 0, -1
+	inString, escaped := false, false
+
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+
+		switch c {
+		case '"':
+			// Quotes only delimit strings inside an object; prose apostrophes and quotes are ignored
+			inString = depth > 0
+		case '{':
+			if depth == 0 {
+				start = i
+			}
+			depth++
+		case '}':
+			if depth == 0 {
+				continue
+			}
+			depth--
+			if depth == 0 {
+				objects = append(objects, content[start:i+1])
+			}
+		}
+	}
+
+	return objects
+}
+
+// parseJSONObject decodes a candidate, retrying once with common defects repaired
+func parseJSONObject(candidate string) (map[string]interface{}, bool) {
+	var object map[string]interface{}
+	if err := json.Unmarshal([]byte(candidate), &object); err == nil {
+		return object, true
+	}
+
+	repaired := repairJSON(candidate)
+	if repaired == candidate {
+		return nil, false
+	}
+	if err := json.Unmarshal([]byte(repaired), &object); err == nil {
+		return object, true
+	}
+	return nil, false
+}
+
+// repairJSON fixes defects models commonly produce: typographic quotes and trailing commas
+func repairJSON(candidate string) string {
+	candidate = strings.NewReplacer("“", `"`, "”", `"`).Replace(candidate)
+
+	var b strings.Builder
+	inString, escaped := false, false
+	for i := 0; i < len(candidate); i++ {
+		c := candidate[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			b.WriteByte(c)
+			continue
+		}
+
+		if c == '"' {
+			inString = true
+		}
+
+		// Drop a comma when the next non-space character closes the object or array
+		if c == ',' {
+			next := strings.TrimLeft(candidate[i+1:], " \t\r\n")
+			if strings.HasPrefix(next, "}") || strings.HasPrefix(next, "]") {
+				continue
+			}
+		}
+		b.WriteByte(c)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,46 @@
+package ai
+
+import "testing"
+
+// TestExtractJSONObject tests extraction over replies in the shapes models actually produce
+func TestExtractJSONObject(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"bare", `{"gradient_from": "#c67d3a", "gradient_to": "#6b8d7d", "gradient_angle": 135}`},
+		{"whitespace", "\n\n  {\n  \"gradient_from\": \"#c67d3a\",\n  \"gradient_to\": \"#6b8d7d\",\n  \"gradient_angle\": 135\n}\n"},
+		{"code fence", "```json\n{\"gradient_from\": \"#c67d3a\", \"gradient_to\": \"#6b8d7d\", \"gradient_angle\": 135}\n```"},
+		{"unlabeled fence", "```\n{\"gradient_from\": \"#c67d3a\", \"gradient_to\": \"#6b8d7d\", \"gradient_angle\": 135}\n```"},
+		{"leading prose", "Looking at the warm sunset tones, here's the gradient:\n\n{\"gradient_from\": \"#c67d3a\", \"gradient_to\": \"#6b8d7d\", \"gradient_angle\": 135}"},
+		{"trailing commentary", "{\"gradient_from\": \"#c67d3a\", \"gradient_to\": \"#6b8d7d\", \"gradient_angle\": 135}\n\nThe amber top picks up the sky while the sage {bottom} echoes the moss."},
+		{"prose and fence", "I'd suggest the following:\n```json\n{\n  \"gradient_from\": \"#c67d3a\",\n  \"gradient_to\": \"#6b8d7d\",\n  \"gradient_angle\": 135\n}\n```\nThis keeps black text readable."},
+		{"trailing comma", "{\n  \"gradient_from\": \"#c67d3a\",\n  \"gradient_to\": \"#6b8d7d\",\n  \"gradient_angle\": 135,\n}"},
+		{"typographic quotes", "{“gradient_from”: “#c67d3a”, “gradient_to”: “#6b8d7d”, “gradient_angle”: 135}"},
+		{"braces in strings", `Note: {"gradient_from": "#c67d3a", "gradient_to": "#6b8d7d", "gradient_angle": 135, "note": "avoid } in names"}`},
+		{"example before answer", "The format is {\"example\": true}. My answer:\n{\"gradient_from\": \"#c67d3a\", \"gradient_to\": \"#6b8d7d\", \"gradient_angle\": 135}"},
+		{"apostrophes in prose", "Here's the palette that's best: {\"gradient_from\": \"#c67d3a\", \"gradient_to\": \"#6b8d7d\", \"gradient_angle\": 135}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			colors, err := extractJSONObject(tt.content)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if colors["gradient_from"] != "#c67d3a" || colors["gradient_to"] != "#6b8d7d" || colors["gradient_angle"] != float64(135) {
+				t.Errorf("Unexpected colors: %v", colors)
+			}
+		})
+	}
+}
+
+// TestExtractJSONObject_Failure tests that replies without an object are rejected
+func TestExtractJSONObject_Failure(t *testing.T) {
+	for _, content := range []string{"", "I cannot analyze this image.", "{gradient_from: #c67d3a}", "{\"gradient_from\": \"#c67d3a\""} {
+		if _, err := extractJSONObject(content); err == nil {
+			t.Errorf("Expected error for %q", content)
+		}
+	}
+}
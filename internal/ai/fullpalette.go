@@ -0,0 +1,114 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	// Bounds of the swatches in a full palette
+	minSwatches = 8
+	maxSwatches = 16
+
+	fullPalettePrompt = `You are a professional UI/UX designer and artist with a strong background in color theory and accessibility guidelines. You are designing a complete desktop color scheme for when the attached image is set as the desktop wallpaper: terminals, editors, bars and notifications will all use it.
+
+- Think about the mood of the image and how the UI colors can enhance it.
+- "background" is the base color of windows and panels, and "foreground" the color of regular text on it. Text must be comfortably readable: aim for a contrast ratio of at least 7:1.
+- "accent" highlights the focused and selected elements, and "muted" is for secondary text, borders and inactive elements. Both must remain readable on the background.
+- "error" and "success" must be clearly recognizable as such (a red and a green family color), yet still belong to the image's palette.
+- "swatches" is a list of 8 to 16 further colors taken from or inspired by the image, ordered from darkest to lightest, for charts, syntax highlighting and the like.
+
+Reply only with a JSON object with the following format. Do not include any additional text or comments.
+
+{"background": "#1d2021", "foreground": "#ebdbb2", "accent": "#d79921", "muted": "#928374", "error": "#cc241d", "success": "#98971a", "swatches": ["#282828", "#3c3836", "#504945", "#665c54", "#7c6f64", "#a89984", "#bdae93", "#d5c4a1"]}`
+)
+
+// FullPalettePromptVersion identifies the revision of fullPalettePrompt; bump it whenever the prompt changes
+const FullPalettePromptVersion = 1
+
+// FullPalette is a complete semantic color scheme for a wallpaper, beyond the window border gradient
+type FullPalette struct {
+	Background string   `json:"background"`
+	Foreground string   `json:"foreground"`
+	Accent     string   `json:"accent"`
+	Muted      string   `json:"muted"`
+	Error      string   `json:"error"`
+	Success    string   `json:"success"`
+	Swatches   []string `json:"swatches"` // 8 to 16 colors from the image, darkest first
+}
+
+// fullPaletteRoles are the named colors of a FullPalette, in the order the prompt introduces them
+var fullPaletteRoles = []string{"background", "foreground", "accent", "muted", "error", "success"}
+
+// fullPaletteFormat is the reply format fullPalettePrompt asks for
+var fullPaletteFormat = &responseFormat{
+	Type: "json_schema",
+	JSONSchema: jsonSchema{
+		Name:   "wallpaper_full_palette",
+		Strict: true,
+		Schema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"background": hexColorSchema("Base color of windows and panels"),
+				"foreground": hexColorSchema("Regular text on the background"),
+				"accent":     hexColorSchema("Focused and selected elements"),
+				"muted":      hexColorSchema("Secondary text, borders and inactive elements"),
+				"error":      hexColorSchema("Errors; a red family color"),
+				"success":    hexColorSchema("Success; a green family color"),
+				"swatches": map[string]any{
+					"type":        "array",
+					"items":       hexColorSchema("A color from the image"),
+					"minItems":    minSwatches,
+					"maxItems":    maxSwatches,
+					"description": "Further colors from the image, darkest first",
+				},
+			},
+			"required":             append(append([]string{}, fullPaletteRoles...), "swatches"),
+			"additionalProperties": false,
+		},
+	},
+}
+
+// AnalyzeFullPalette asks the configured model for a complete semantic palette of an image
+func (a *Analyzer) AnalyzeFullPalette(imageData []byte, imageHash string, title string) (FullPalette, Usage, error) {
+	model := Model()
+	colors, usage, err := a.analyze(model, fullPalettePrompt, a.replyFormat(model, fullPaletteFormat), validateFullPalette, imageData, imageHash, title)
+	if err != nil {
+		return FullPalette{}, usage, err
+	}
+
+	// The colors are validated, so they convert to the typed palette
+	data, err := json.Marshal(colors)
+	if err != nil {
+		return FullPalette{}, usage, fmt.Errorf("failed to convert full palette: %w", err)
+	}
+	var palette FullPalette
+	if err := json.Unmarshal(data, &palette); err != nil {
+		return FullPalette{}, usage, fmt.Errorf("failed to convert full palette: %w", err)
+	}
+	return palette, usage, nil
+}
+
+// validateFullPalette lists what is wrong with a reply to fullPalettePrompt
+func validateFullPalette(colors map[string]interface{}) []string {
+	var problems []string
+	for _, role := range fullPaletteRoles {
+		if problem := validateHex(colors, role, true); problem != "" {
+			problems = append(problems, problem)
+		}
+	}
+
+	swatches, ok := colors["swatches"].([]interface{})
+	if !ok {
+		return append(problems, fmt.Sprintf(`"swatches" is %v, it must be a list of hex colors`, colors["swatches"]))
+	}
+	if len(swatches) < minSwatches || len(swatches) > maxSwatches {
+		problems = append(problems, fmt.Sprintf(`"swatches" has %d colors, it must have %d to %d`, len(swatches), minSwatches, maxSwatches))
+	}
+	for i, swatch := range swatches {
+		if s, isString := swatch.(string); !isString || !hexColorPattern.MatchString(s) {
+			problems = append(problems, fmt.Sprintf(`"swatches[%d]" is %v, it must be a hex color like #34495e`, i, swatch))
+		}
+	}
+	return problems
+}
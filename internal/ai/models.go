@@ -0,0 +1,101 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const (
+	openRouterModelsURL = "https://openrouter.ai/api/v1/models"
+	// modelsTTL is how long the fetched model list is reused before OpenRouter is asked again
+	modelsTTL = time.Hour
+)
+
+// modelInfo is what OpenRouter's model list says about one model
+type modelInfo struct {
+	Pricing           *Pricing // nil if the listed prices are unparseable
+	StructuredOutputs bool     // The model can be held to a JSON schema with response_format
+}
+
+// modelsResponse is the part of OpenRouter's model list used here
+// Prices are decimal strings, e.g. "0.000003"
+type modelsResponse struct {
+	Data []struct {
+		ID      string `json:"id"`
+		Pricing struct {
+			Prompt     string `json:"prompt"`
+			Completion string `json:"completion"`
+			Request    string `json:"request"`
+		} `json:"pricing"`
+		SupportedParameters []string `json:"supported_parameters"`
+	} `json:"data"`
+}
+
+// modelInfo returns what OpenRouter lists for a model
+// The model list is fetched at most once per modelsTTL and shared by all models
+func (a *Analyzer) modelInfo(ctx context.Context, model string) (modelInfo, error) {
+	a.modelsMu.Lock()
+	defer a.modelsMu.Unlock()
+
+	if a.models == nil || time.Since(a.modelsFetched) > modelsTTL {
+		models, err := a.fetchModels(ctx)
+		if err != nil {
+			return modelInfo{}, err
+		}
+		a.models = models
+		a.modelsFetched = time.Now()
+	}
+
+	info, ok := a.models[model]
+	if !ok {
+		return modelInfo{}, fmt.Errorf("model %q is not listed by OpenRouter", model)
+	}
+	return info, nil
+}
+
+// fetchModels downloads OpenRouter's list of models
+func (a *Analyzer) fetchModels(ctx context.Context) (map[string]modelInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.modelsEndpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	referer, appTitle := attribution()
+	req.Header.Set("HTTP-Referer", referer)
+	req.Header.Set("X-Title", appTitle)
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch OpenRouter models: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OpenRouter models returned status %d", resp.StatusCode)
+	}
+
+	var list modelsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("failed to parse OpenRouter models: %w", err)
+	}
+
+	models := make(map[string]modelInfo, len(list.Data))
+	for _, model := range list.Data {
+		info := modelInfo{StructuredOutputs: slices.Contains(model.SupportedParameters, "structured_outputs")}
+
+		// Models with unparseable prices are left unpriced rather than priced at zero
+		prompt, promptErr := strconv.ParseFloat(model.Pricing.Prompt, 64)
+		completion, completionErr := strconv.ParseFloat(model.Pricing.Completion, 64)
+		if promptErr == nil && completionErr == nil {
+			request, _ := strconv.ParseFloat(model.Pricing.Request, 64) // Often missing; free when it is
+			info.Pricing = &Pricing{Prompt: prompt, Completion: completion, Request: request}
+		}
+		models[model.ID] = info
+	}
+	logger().Info("Fetched OpenRouter models", "models", len(models))
+	return models, nil
+}
@@ -0,0 +1,117 @@
+package ai
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxConcurrent = 4
+	defaultQueueSize     = 16
+	defaultQueueTimeout  = 45 * time.Second
+)
+
+// ErrBusy is returned without contacting OpenRouter when an analysis could not get a slot:
+// the queue was full, or the analysis waited in it for longer than the queue timeout
+var ErrBusy = errors.New("too many AI analyses in progress")
+
+// QueueSettings reads AI_MAX_CONCURRENT (0 disables the limit), AI_QUEUE_SIZE and AI_QUEUE_TIMEOUT
+func QueueSettings() (maxConcurrent, queueSize int, timeout time.Duration) {
+	maxConcurrent, queueSize, timeout = defaultMaxConcurrent, defaultQueueSize, defaultQueueTimeout
+
+	if value := os.Getenv("AI_MAX_CONCURRENT"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed >= 0 {
+			maxConcurrent = parsed
+		} else {
+			logger().Info("Invalid AI_MAX_CONCURRENT, using default", "value", value, "default", defaultMaxConcurrent)
+		}
+	}
+
+	if value := os.Getenv("AI_QUEUE_SIZE"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed >= 0 {
+			queueSize = parsed
+		} else {
+			logger().Info("Invalid AI_QUEUE_SIZE, using default", "value", value, "default", defaultQueueSize)
+		}
+	}
+
+	if value := os.Getenv("AI_QUEUE_TIMEOUT"); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil && parsed > 0 {
+			timeout = parsed
+		} else {
+			logger().Info("Invalid AI_QUEUE_TIMEOUT, using default", "value", value, "default", defaultQueueTimeout)
+		}
+	}
+
+	return maxConcurrent, queueSize, timeout
+}
+
+// limiter bounds how many analyses talk to OpenRouter at once, across all models and prompts
+// Analyses beyond the limit wait in a bounded queue; the settings are read on every acquire, so a reload applies
+// to the next analysis without dropping the ones already running
+type limiter struct {
+	mu      sync.Mutex
+	running int
+	waiting int
+	freed   chan struct{} // Closed and replaced whenever a slot is released; nil while nobody waits
+}
+
+// acquire takes a slot, waiting in the queue if all are taken. Returns ErrBusy if the queue is full or the wait times out
+func (l *limiter) acquire() error {
+	maxConcurrent, queueSize, timeout := QueueSettings()
+
+	l.mu.Lock()
+	if maxConcurrent == 0 || l.running < maxConcurrent {
+		l.running++
+		l.mu.Unlock()
+		return nil
+	}
+	if l.waiting >= queueSize {
+		l.mu.Unlock()
+		return fmt.Errorf("%w: the queue is full", ErrBusy)
+	}
+	l.waiting++
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for {
+		if l.freed == nil {
+			l.freed = make(chan struct{})
+		}
+		freed := l.freed
+		l.mu.Unlock()
+
+		select {
+		case <-freed:
+		case <-deadline.C:
+			l.mu.Lock()
+			l.waiting--
+			l.mu.Unlock()
+			return fmt.Errorf("%w: no slot within %s", ErrBusy, timeout)
+		}
+
+		// Every waiter wakes up, and only those that find a free slot leave the queue
+		l.mu.Lock()
+		if l.running < maxConcurrent {
+			l.running++
+			l.waiting--
+			l.mu.Unlock()
+			return nil
+		}
+	}
+}
+
+// release returns a slot taken by acquire and wakes up the queue
+func (l *limiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.running--
+	if l.freed != nil {
+		close(l.freed)
+		l.freed = nil
+	}
+}
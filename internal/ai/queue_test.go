@@ -0,0 +1,65 @@
+package ai
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestLimiter tests that analyses beyond the limit queue for a slot, and are turned away when the queue is full or the wait times out
+func TestLimiter(t *testing.T) {
+	t.Setenv("AI_MAX_CONCURRENT", "1")
+	t.Setenv("AI_QUEUE_SIZE", "1")
+	t.Setenv("AI_QUEUE_TIMEOUT", "50ms")
+
+	var l limiter
+	if err := l.acquire(); err != nil {
+		t.Fatalf("Expected a free slot, got %v", err)
+	}
+
+	// The second analysis queues and gets the slot once the first one is done
+	queued := make(chan error, 1)
+	go func() { queued <- l.acquire() }()
+	waitForQueue(t, &l, 1)
+
+	if err := l.acquire(); !errors.Is(err, ErrBusy) {
+		t.Errorf("Expected ErrBusy with a full queue, got %v", err)
+	}
+
+	l.release()
+	if err := <-queued; err != nil {
+		t.Fatalf("Expected the queued analysis to get the slot, got %v", err)
+	}
+
+	// A queued analysis gives up once the timeout has passed
+	start := time.Now()
+	if err := l.acquire(); !errors.Is(err, ErrBusy) {
+		t.Errorf("Expected ErrBusy after the queue timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Expected to wait for the queue timeout, gave up after %s", elapsed)
+	}
+	l.release()
+
+	// 0 disables the limit
+	t.Setenv("AI_MAX_CONCURRENT", "0")
+	for i := range 3 {
+		if err := l.acquire(); err != nil {
+			t.Errorf("Expected analysis %d to start without a limit, got %v", i, err)
+		}
+	}
+}
+
+// waitForQueue waits until n analyses are queued
+func waitForQueue(t *testing.T, l *limiter, n int) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		l.mu.Lock()
+		waiting := l.waiting
+		l.mu.Unlock()
+		if waiting == n {
+			return
+		}
+	}
+	t.Fatalf("Expected %d queued analyses", n)
+}
@@ -0,0 +1,172 @@
+package ai
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRetries        = 2
+	defaultRetryBaseDelay = time.Second
+	// maxRetryDelay caps a single wait, including one requested by Retry-After
+	maxRetryDelay           = 30 * time.Second
+	defaultBreakerThreshold = 5
+	defaultBreakerCooldown  = 2 * time.Minute
+
+	// maxRetries keeps a misconfigured AI_RETRIES from retrying a failing request for hours
+	maxRetries = 10
+)
+
+// ErrCircuitOpen is returned without contacting OpenRouter while it is failing repeatedly
+var ErrCircuitOpen = errors.New("OpenRouter is failing repeatedly, skipping AI analysis for now")
+
+// statusError is a non-200 reply from OpenRouter
+type statusError struct {
+	StatusCode int
+	RetryAfter time.Duration // From the Retry-After header; 0 if absent
+	Body       string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("OpenRouter API returned status %d: %s", e.StatusCode, e.Body)
+}
+
+// transient reports whether a failed request may succeed when repeated: rate limits, server errors and network failures
+// Rejected requests (4xx) and unusable replies are not, since repeating them costs the same and fails the same way
+func transient(err error) bool {
+	var status *statusError
+	if errors.As(err, &status) {
+		return status.StatusCode == http.StatusTooManyRequests || status.StatusCode == http.StatusRequestTimeout || status.StatusCode >= 500
+	}
+	var network *networkError
+	return errors.As(err, &network)
+}
+
+// networkError wraps a failure to reach OpenRouter or read its reply
+type networkError struct {
+	err error
+}
+
+func (e *networkError) Error() string { return e.err.Error() }
+func (e *networkError) Unwrap() error { return e.err }
+
+// retryPolicy is how often and how patiently transient failures are retried
+type retryPolicy struct {
+	retries   int // Repeats after the first attempt
+	baseDelay time.Duration
+}
+
+// loadRetryPolicy reads AI_RETRIES and AI_RETRY_BASE_DELAY
+func loadRetryPolicy() retryPolicy {
+	policy := retryPolicy{retries: defaultRetries, baseDelay: defaultRetryBaseDelay}
+
+	if value := os.Getenv("AI_RETRIES"); value != "" {
+		if retries, err := strconv.Atoi(value); err == nil && retries >= 0 && retries <= maxRetries {
+			policy.retries = retries
+		} else {
+			logger().Info("Invalid AI_RETRIES, using default", "value", value, "default", defaultRetries, "max", maxRetries)
+		}
+	}
+
+	if value := os.Getenv("AI_RETRY_BASE_DELAY"); value != "" {
+		if delay, err := time.ParseDuration(value); err == nil && delay > 0 {
+			policy.baseDelay = delay
+		} else {
+			logger().Info("Invalid AI_RETRY_BASE_DELAY, using default", "value", value, "default", defaultRetryBaseDelay)
+		}
+	}
+
+	return policy
+}
+
+// delay returns the wait before retry number attempt (starting at 1): exponential backoff with equal jitter,
+// or OpenRouter's Retry-After if it asks for longer
+func (p retryPolicy) delay(attempt int, err error) time.Duration {
+	// The shift is only made while it stays under the cap, as a large one overflows to zero or a negative delay
+	backoff := maxRetryDelay
+	if shift := attempt - 1; p.baseDelay <= maxRetryDelay>>shift {
+		backoff = p.baseDelay << shift
+	}
+	wait := backoff/2 + rand.N(backoff/2+1)
+
+	var status *statusError
+	if errors.As(err, &status) && status.RetryAfter > wait {
+		wait = min(status.RetryAfter, maxRetryDelay)
+	}
+	return wait
+}
+
+// retryAfter parses a Retry-After header given in seconds; HTTP dates are not used by OpenRouter
+func retryAfter(header string) time.Duration {
+	seconds, err := strconv.Atoi(header)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// breaker stops calling OpenRouter after consecutive transient failures, for a cooldown
+// Once the cooldown has passed requests go through again; a single further failure reopens it
+type breaker struct {
+	mu        sync.Mutex
+	failures  int // Consecutive transient failures, after retries
+	openUntil time.Time
+}
+
+// breakerSettings reads AI_BREAKER_THRESHOLD (0 disables the breaker) and AI_BREAKER_COOLDOWN
+func breakerSettings() (threshold int, cooldown time.Duration) {
+	threshold, cooldown = defaultBreakerThreshold, defaultBreakerCooldown
+
+	if value := os.Getenv("AI_BREAKER_THRESHOLD"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed >= 0 {
+			threshold = parsed
+		} else {
+			logger().Info("Invalid AI_BREAKER_THRESHOLD, using default", "value", value, "default", defaultBreakerThreshold)
+		}
+	}
+
+	if value := os.Getenv("AI_BREAKER_COOLDOWN"); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil && parsed > 0 {
+			cooldown = parsed
+		} else {
+			logger().Info("Invalid AI_BREAKER_COOLDOWN, using default", "value", value, "default", defaultBreakerCooldown)
+		}
+	}
+
+	return threshold, cooldown
+}
+
+// allow returns ErrCircuitOpen while the breaker is open
+func (b *breaker) allow(now time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if now.Before(b.openUntil) {
+		return ErrCircuitOpen
+	}
+	return nil
+}
+
+// record counts the outcome of a request, opening the breaker once transient failures reach the threshold
+func (b *breaker) record(err error, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil || !transient(err) {
+		// Anything OpenRouter answered deliberately shows it is up
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	threshold, cooldown := breakerSettings()
+	if threshold > 0 && b.failures >= threshold {
+		b.openUntil = now.Add(cooldown)
+		logger().Info("OpenRouter keeps failing, pausing AI analyses", "failures", b.failures, "cooldown", cooldown, "error", err)
+	}
+}
@@ -0,0 +1,120 @@
+package ai
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// flakyOpenRouter fails with the given statuses in order, then answers with valid colors
+func flakyOpenRouter(t *testing.T, statuses ...int) (*httptest.Server, *int) {
+	t.Helper()
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			fmt.Fprint(w, testModels)
+			return
+		}
+		requests++
+		if requests <= len(statuses) {
+			http.Error(w, "upstream unavailable", statuses[requests-1])
+			return
+		}
+		fmt.Fprint(w, `{"choices": [{"message": {"content": "{\"gradient_from\": \"#c67d3a\", \"gradient_to\": \"#6b8d7d\", \"gradient_angle\": 135}"}}]}`)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+// TestAnalyzeColors_RetriesTransientFailures tests that rate limits and server errors are retried, but rejected requests are not
+func TestAnalyzeColors_RetriesTransientFailures(t *testing.T) {
+	t.Setenv("AI_RETRY_BASE_DELAY", "1ms")
+
+	server, requests := flakyOpenRouter(t, http.StatusTooManyRequests, http.StatusBadGateway)
+	analyzer := NewAnalyzer("test-key")
+	analyzer.endpoint = server.URL
+	analyzer.modelsEndpoint = server.URL
+	if _, _, err := analyzer.AnalyzeColors(testImage(t), "abc123", "Test", "", ""); err != nil {
+		t.Fatalf("Expected the retries to succeed, got %v", err)
+	}
+	if *requests != 3 {
+		t.Errorf("Expected 3 requests, got %d", *requests)
+	}
+
+	server, requests = flakyOpenRouter(t, http.StatusBadRequest)
+	analyzer.endpoint = server.URL
+	analyzer.modelsEndpoint = server.URL
+	if _, _, err := analyzer.AnalyzeColors(testImage(t), "abc123", "Test", "", ""); err == nil {
+		t.Fatal("Expected a rejected request to fail")
+	}
+	if *requests != 1 {
+		t.Errorf("Expected a rejected request not to be retried, got %d requests", *requests)
+	}
+
+	t.Setenv("AI_RETRIES", "1")
+	server, requests = flakyOpenRouter(t, http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+	analyzer.endpoint = server.URL
+	analyzer.modelsEndpoint = server.URL
+	if _, _, err := analyzer.AnalyzeColors(testImage(t), "abc123", "Test", "", ""); err == nil {
+		t.Fatal("Expected the request to fail once the retries are used up")
+	}
+	if *requests != 2 {
+		t.Errorf("Expected 2 requests, got %d", *requests)
+	}
+}
+
+// TestAnalyzeColors_CircuitBreaker tests that repeated failures stop requests until the cooldown has passed
+func TestAnalyzeColors_CircuitBreaker(t *testing.T) {
+	t.Setenv("AI_RETRIES", "0")
+	t.Setenv("AI_BREAKER_THRESHOLD", "2")
+	t.Setenv("AI_BREAKER_COOLDOWN", "50ms")
+
+	server, requests := flakyOpenRouter(t, http.StatusInternalServerError, http.StatusInternalServerError)
+	analyzer := NewAnalyzer("test-key")
+	analyzer.endpoint = server.URL
+	analyzer.modelsEndpoint = server.URL
+
+	for range 2 {
+		if _, _, err := analyzer.AnalyzeColors(testImage(t), "abc123", "Test", "", ""); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("Expected the request to fail upstream, got %v", err)
+		}
+	}
+	if _, _, err := analyzer.AnalyzeColors(testImage(t), "abc123", "Test", "", ""); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected ErrCircuitOpen, got %v", err)
+	}
+	if *requests != 2 {
+		t.Errorf("Expected no request while the breaker is open, got %d requests", *requests)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if _, _, err := analyzer.AnalyzeColors(testImage(t), "abc123", "Test", "", ""); err != nil {
+		t.Fatalf("Expected a request after the cooldown to succeed, got %v", err)
+	}
+}
+
+// TestRetryDelay tests the backoff range, that it is capped however long it grows, and that Retry-After can only lengthen it
+func TestRetryDelay(t *testing.T) {
+	policy := retryPolicy{retries: 3, baseDelay: time.Second}
+
+	for attempt, backoff := range map[int]time.Duration{1: time.Second, 3: 4 * time.Second, 10: maxRetryDelay, 64: maxRetryDelay, 100: maxRetryDelay} {
+		delay := policy.delay(attempt, errors.New("network"))
+		if delay < backoff/2 || delay > backoff {
+			t.Errorf("Attempt %d: expected a delay between %v and %v, got %v", attempt, backoff/2, backoff, delay)
+		}
+	}
+
+	huge := retryPolicy{retries: maxRetries, baseDelay: time.Duration(1) << 62}
+	if delay := huge.delay(maxRetries, errors.New("network")); delay < maxRetryDelay/2 || delay > maxRetryDelay {
+		t.Errorf("Expected a huge base delay to be capped, got %v", delay)
+	}
+
+	if delay := policy.delay(1, &statusError{StatusCode: 429, RetryAfter: 5 * time.Second}); delay != 5*time.Second {
+		t.Errorf("Expected Retry-After to be honored, got %v", delay)
+	}
+	if delay := policy.delay(1, &statusError{StatusCode: 429, RetryAfter: time.Hour}); delay != maxRetryDelay {
+		t.Errorf("Expected Retry-After to be capped, got %v", delay)
+	}
+}
@@ -0,0 +1,73 @@
+package ai
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ProviderPreferences controls which upstream providers OpenRouter may route analyses to
+// Field names follow OpenRouter's "provider" request object, so it is sent as is
+type ProviderPreferences struct {
+	Order             []string `json:"order,omitempty"`  // Providers to try first, in this order
+	Only              []string `json:"only,omitempty"`   // Allowlist; no other provider is used
+	Ignore            []string `json:"ignore,omitempty"` // Denylist
+	AllowFallbacks    *bool    `json:"allow_fallbacks,omitempty"`
+	RequireParameters bool     `json:"require_parameters,omitempty"` // Skip providers that ignore reasoning or max_tokens
+	DataCollection    string   `json:"data_collection,omitempty"`    // "deny" skips providers that may store or train on prompts
+}
+
+// loadProviderPreferences reads the OPENROUTER_* routing settings; nil if none are set
+// Read per request so a config reload takes effect with the next analysis
+func loadProviderPreferences() *ProviderPreferences {
+	var prefs ProviderPreferences
+	prefs.Order = splitProviders(os.Getenv("OPENROUTER_PROVIDER_ORDER"))
+	prefs.Only = splitProviders(os.Getenv("OPENROUTER_PROVIDER_ONLY"))
+	prefs.Ignore = splitProviders(os.Getenv("OPENROUTER_PROVIDER_IGNORE"))
+
+	if value := os.Getenv("OPENROUTER_ALLOW_FALLBACKS"); value != "" {
+		if allow, err := strconv.ParseBool(value); err == nil {
+			prefs.AllowFallbacks = &allow
+		} else {
+			logger().Info("Ignoring invalid OPENROUTER_ALLOW_FALLBACKS", "value", value)
+		}
+	}
+
+	if value := os.Getenv("OPENROUTER_REQUIRE_PARAMETERS"); value != "" {
+		if require, err := strconv.ParseBool(value); err == nil {
+			prefs.RequireParameters = require
+		} else {
+			logger().Info("Ignoring invalid OPENROUTER_REQUIRE_PARAMETERS", "value", value)
+		}
+	}
+
+	switch value := strings.ToLower(os.Getenv("OPENROUTER_DATA_COLLECTION")); value {
+	case "":
+	case "allow", "deny":
+		prefs.DataCollection = value
+	default:
+		logger().Info("Ignoring invalid OPENROUTER_DATA_COLLECTION", "value", value)
+	}
+
+	if prefs.Order == nil && prefs.Only == nil && prefs.Ignore == nil &&
+		prefs.AllowFallbacks == nil && !prefs.RequireParameters && prefs.DataCollection == "" {
+		return nil
+	}
+	return &prefs
+}
+
+// ProviderRouting returns the provider preferences sent with each analysis, or nil if OpenRouter's defaults apply
+func ProviderRouting() *ProviderPreferences {
+	return loadProviderPreferences()
+}
+
+// splitProviders parses a comma separated list of provider names, e.g. "Anthropic, Amazon Bedrock"
+func splitProviders(value string) []string {
+	var providers []string
+	for _, provider := range strings.Split(value, ",") {
+		if provider = strings.TrimSpace(provider); provider != "" {
+			providers = append(providers, provider)
+		}
+	}
+	return providers
+}
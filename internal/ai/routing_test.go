@@ -0,0 +1,55 @@
+package ai
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestLoadProviderPreferences tests parsing of the OPENROUTER_* routing settings
+func TestLoadProviderPreferences(t *testing.T) {
+	if prefs := loadProviderPreferences(); prefs != nil {
+		t.Errorf("Expected no preferences without settings, got %+v", prefs)
+	}
+
+	t.Setenv("OPENROUTER_PROVIDER_ONLY", " Anthropic, Amazon Bedrock ,")
+	t.Setenv("OPENROUTER_PROVIDER_IGNORE", "DeepInfra")
+	t.Setenv("OPENROUTER_ALLOW_FALLBACKS", "false")
+	t.Setenv("OPENROUTER_REQUIRE_PARAMETERS", "true")
+	t.Setenv("OPENROUTER_DATA_COLLECTION", "Deny")
+
+	prefs := loadProviderPreferences()
+	if prefs == nil {
+		t.Fatal("Expected preferences")
+	}
+	if !reflect.DeepEqual(prefs.Only, []string{"Anthropic", "Amazon Bedrock"}) || !reflect.DeepEqual(prefs.Ignore, []string{"DeepInfra"}) {
+		t.Errorf("Unexpected provider lists: %+v", prefs)
+	}
+	if prefs.AllowFallbacks == nil || *prefs.AllowFallbacks || !prefs.RequireParameters || prefs.DataCollection != "deny" {
+		t.Errorf("Unexpected flags: %+v", prefs)
+	}
+
+	t.Setenv("OPENROUTER_ALLOW_FALLBACKS", "sometimes")
+	t.Setenv("OPENROUTER_DATA_COLLECTION", "maybe")
+	if prefs := loadProviderPreferences(); prefs.AllowFallbacks != nil || prefs.DataCollection != "" {
+		t.Errorf("Expected invalid values to be ignored, got %+v", prefs)
+	}
+}
+
+// TestAnalyzeColors_SendsProviderPreferences tests that routing settings reach OpenRouter with every request
+func TestAnalyzeColors_SendsProviderPreferences(t *testing.T) {
+	t.Setenv("OPENROUTER_PROVIDER_IGNORE", "DeepInfra")
+	t.Setenv("OPENROUTER_DATA_COLLECTION", "deny")
+
+	server, requests := fakeOpenRouter(t, `{"gradient_from": "#c67d3a", "gradient_to": "#6b8d7d", "gradient_angle": 135}`)
+	analyzer := NewAnalyzer("test-key")
+	analyzer.endpoint = server.URL
+	analyzer.modelsEndpoint = server.URL
+
+	if _, _, err := analyzer.AnalyzeColors(testImage(t), "abc123", "Test", "", ""); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	provider := (*requests)[0].Provider
+	if provider == nil || !reflect.DeepEqual(provider.Ignore, []string{"DeepInfra"}) || provider.DataCollection != "deny" {
+		t.Errorf("Expected the provider preferences in the request, got %+v", provider)
+	}
+}
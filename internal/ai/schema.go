@@ -0,0 +1,72 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// modelLookupTimeout bounds how long an analysis waits for the model list before going without a schema
+const modelLookupTimeout = 10 * time.Second
+
+// responseFormat holds the model's reply to a JSON schema (OpenRouter structured outputs)
+type responseFormat struct {
+	Type       string     `json:"type"` // Always "json_schema"
+	JSONSchema jsonSchema `json:"json_schema"`
+}
+
+type jsonSchema struct {
+	Name   string         `json:"name"`
+	Strict bool           `json:"strict"`
+	Schema map[string]any `json:"schema"`
+}
+
+// colorsFormat is the reply format colorAnalysisPrompt asks for, as a schema the model cannot deviate from
+var colorsFormat = &responseFormat{
+	Type: "json_schema",
+	JSONSchema: jsonSchema{
+		Name:   "wallpaper_colors",
+		Strict: true,
+		Schema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"gradient_from":   hexColorSchema("Start of the focused window border gradient; black text must be readable on it"),
+				"gradient_to":     hexColorSchema("End of the focused window border gradient"),
+				"gradient_angle":  map[string]any{"type": "number", "minimum": 0, "maximum": 360, "description": "Gradient direction in CSS degrees"},
+				"inactive_border": hexColorSchema("Muted border color for unfocused windows"),
+			},
+			"required":             []string{"gradient_from", "gradient_to", "gradient_angle", "inactive_border"},
+			"additionalProperties": false,
+		},
+	},
+}
+
+// hexColorSchema describes a #rrggbb color property
+func hexColorSchema(description string) map[string]any {
+	return map[string]any{"type": "string", "pattern": "^#[0-9a-fA-F]{6}$", "description": description}
+}
+
+// replyFormat returns format if OpenRouter lists structured output support for the model, nil otherwise
+// Without a schema, or if a provider ignores it, the reply is parsed from free text as before
+func (a *Analyzer) replyFormat(model string, format *responseFormat) *responseFormat {
+	ctx, cancel := context.WithTimeout(context.Background(), modelLookupTimeout)
+	defer cancel()
+
+	info, err := a.modelInfo(ctx, model)
+	if err != nil {
+		logger().Info("Could not check structured output support, parsing the reply as text", "model", model, "error", err)
+		return nil
+	}
+	if !info.StructuredOutputs {
+		return nil
+	}
+	return format
+}
+
+// schemaRejected reports whether a request failed because the provider refused the response format
+// OpenRouter answers 400 for parameters a provider cannot honor; the model list is not always up to date
+func schemaRejected(err error) bool {
+	var status *statusError
+	return errors.As(err, &status) && status.StatusCode == http.StatusBadRequest
+}
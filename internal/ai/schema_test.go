@@ -0,0 +1,74 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestAnalyzeColors_StructuredOutputs tests that the schema is only sent to models listed with structured output support
+func TestAnalyzeColors_StructuredOutputs(t *testing.T) {
+	server, requests := fakeOpenRouter(t,
+		`{"gradient_from": "#c67d3a", "gradient_to": "#6b8d7d", "gradient_angle": 135, "inactive_border": "#686a57"}`,
+		"Here you go:\n```json\n{\"gradient_from\": \"#c67d3a\", \"gradient_to\": \"#6b8d7d\", \"gradient_angle\": 135}\n```",
+	)
+	analyzer := NewAnalyzer("test-key")
+	analyzer.endpoint = server.URL
+	analyzer.modelsEndpoint = server.URL
+
+	if _, _, err := analyzer.AnalyzeColorsWithModel("example/structured", testImage(t), "abc123", "Test", "", ""); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	format := (*requests)[0].ResponseFormat
+	if format == nil || format.Type != "json_schema" || !format.JSONSchema.Strict || format.JSONSchema.Name != "wallpaper_colors" {
+		t.Errorf("Expected the color schema, got %+v", format)
+	}
+
+	// Free-text replies of models without schema support are still parsed
+	colors, _, err := analyzer.AnalyzeColorsWithModel("example/unlisted", testImage(t), "abc123", "Test", "", "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if (*requests)[1].ResponseFormat != nil {
+		t.Errorf("Expected no schema for a model without structured outputs, got %+v", (*requests)[1].ResponseFormat)
+	}
+	if colors["gradient_to"] != "#6b8d7d" {
+		t.Errorf("Expected the colors from the free-text reply, got %v", colors)
+	}
+}
+
+// TestAnalyzeColors_SchemaRejected tests that a provider refusing the schema gets the request again without it
+func TestAnalyzeColors_SchemaRejected(t *testing.T) {
+	var withSchema, withoutSchema int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			fmt.Fprint(w, testModels)
+			return
+		}
+		var req openRouterRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		if req.ResponseFormat != nil {
+			withSchema++
+			http.Error(w, `{"error": {"message": "response_format is not supported"}}`, http.StatusBadRequest)
+			return
+		}
+		withoutSchema++
+		fmt.Fprint(w, `{"choices": [{"message": {"content": "{\"gradient_from\": \"#c67d3a\", \"gradient_to\": \"#6b8d7d\", \"gradient_angle\": 135}"}}]}`)
+	}))
+	defer server.Close()
+
+	analyzer := NewAnalyzer("test-key")
+	analyzer.endpoint = server.URL
+	analyzer.modelsEndpoint = server.URL
+
+	if _, _, err := analyzer.AnalyzeColorsWithModel("example/structured", testImage(t), "abc123", "Test", "", ""); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if withSchema != 1 || withoutSchema != 1 {
+		t.Errorf("Expected one request with and one without the schema, got %d and %d", withSchema, withoutSchema)
+	}
+}
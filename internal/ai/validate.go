@@ -0,0 +1,166 @@
+package ai
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// hexColorPattern matches the #rrggbb and #rgb colors the prompt asks for
+var hexColorPattern = regexp.MustCompile(`^#([0-9a-fA-F]{6}|[0-9a-fA-F]{3})$`)
+
+// validateColors lists what is wrong with a parsed reply, in terms the model can act on
+// An empty result means the colors are usable
+func validateColors(colors map[string]interface{}) []string {
+	var problems []string
+	for _, key := range []string{"gradient_from", "gradient_to"} {
+		if problem := validateHex(colors, key, true); problem != "" {
+			problems = append(problems, problem)
+		}
+	}
+	if problem := validateHex(colors, "inactive_border", false); problem != "" {
+		problems = append(problems, problem)
+	}
+
+	switch angle := colors["gradient_angle"].(type) {
+	case nil:
+		problems = append(problems, `"gradient_angle" is missing`)
+	case float64:
+		if angle < 0 || angle > 360 {
+			problems = append(problems, fmt.Sprintf(`"gradient_angle" is %v, it must be from 0 to 360`, angle))
+		}
+	default:
+		problems = append(problems, fmt.Sprintf(`"gradient_angle" is %v, it must be a number of degrees`, angle))
+	}
+	return problems
+}
+
+// bareHexPattern matches a six digit hex color missing its leading #
+var bareHexPattern = regexp.MustCompile(`^[0-9a-fA-F]{6}$`)
+
+// repairColors fixes mistakes in a reply that can only mean one thing, so they need no correction request:
+// hex colors without the leading #, and a gradient angle given as a string ("135deg") or beyond 0-360 (450 is 90)
+// The map is changed in place; the repairs made are returned for the log
+func repairColors(colors map[string]interface{}) []string {
+	var repairs []string
+	repairHex(&repairs, "", colors)
+
+	switch angle := colors["gradient_angle"].(type) {
+	case string:
+		trimmed := strings.TrimSpace(strings.TrimSuffix(strings.TrimSuffix(strings.TrimSpace(angle), "deg"), "°"))
+		if degrees, err := strconv.ParseFloat(trimmed, 64); err == nil && !math.IsNaN(degrees) && !math.IsInf(degrees, 0) {
+			colors["gradient_angle"] = wrapAngle(degrees)
+			repairs = append(repairs, fmt.Sprintf(`"gradient_angle" %q is %v`, angle, colors["gradient_angle"]))
+		}
+	case float64:
+		if angle < 0 || angle > 360 {
+			colors["gradient_angle"] = wrapAngle(angle)
+			repairs = append(repairs, fmt.Sprintf(`"gradient_angle" %v is %v`, angle, colors["gradient_angle"]))
+		}
+	}
+	return repairs
+}
+
+// repairHex adds the missing # to bare six digit hex colors, descending into objects and arrays
+// Shorter strings are left alone, since words like "bad" are valid three digit hex too
+func repairHex(repairs *[]string, path string, value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			child := key
+			if path != "" {
+				child = path + "." + key
+			}
+			if s, ok := item.(string); ok && bareHexPattern.MatchString(s) {
+				v[key] = "#" + s
+				*repairs = append(*repairs, fmt.Sprintf("%q %s is #%s", child, s, s))
+				continue
+			}
+			repairHex(repairs, child, item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			child := fmt.Sprintf("%s[%d]", path, i)
+			if s, ok := item.(string); ok && bareHexPattern.MatchString(s) {
+				v[i] = "#" + s
+				*repairs = append(*repairs, fmt.Sprintf("%q %s is #%s", child, s, s))
+				continue
+			}
+			repairHex(repairs, child, item)
+		}
+	}
+}
+
+// wrapAngle brings an angle in degrees into 0-360 without changing its direction
+func wrapAngle(degrees float64) float64 {
+	if degrees >= 0 && degrees <= 360 {
+		return degrees
+	}
+	wrapped := math.Mod(degrees, 360)
+	if wrapped < 0 {
+		wrapped += 360
+	}
+	return wrapped
+}
+
+// validateProfileColors lists what is wrong with a reply to an operator's prompt
+// Its keys are up to the prompt, so only the values are checked: every string starting with # must be a hex color
+func validateProfileColors(colors map[string]interface{}) []string {
+	if len(colors) == 0 {
+		return []string{"the JSON object is empty"}
+	}
+	var problems []string
+	collectHexProblems(&problems, "", colors)
+	return problems
+}
+
+// collectHexProblems checks the # strings in a value, descending into objects and arrays
+func collectHexProblems(problems *[]string, path string, value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			child := key
+			if path != "" {
+				child = path + "." + key
+			}
+			collectHexProblems(problems, child, v[key])
+		}
+	case []interface{}:
+		for i, item := range v {
+			collectHexProblems(problems, fmt.Sprintf("%s[%d]", path, i), item)
+		}
+	case string:
+		if strings.HasPrefix(v, "#") && !hexColorPattern.MatchString(v) {
+			*problems = append(*problems, fmt.Sprintf("%q is %v, it must be a hex color like #34495e", path, v))
+		}
+	}
+}
+
+// validateHex checks that a key holds a hex color, or is absent if it is optional
+func validateHex(colors map[string]interface{}, key string, required bool) string {
+	value, ok := colors[key]
+	if !ok {
+		if required {
+			return fmt.Sprintf("%q is missing", key)
+		}
+		return ""
+	}
+	if s, isString := value.(string); !isString || !hexColorPattern.MatchString(s) {
+		return fmt.Sprintf("%q is %v, it must be a hex color like #34495e", key, value)
+	}
+	return ""
+}
+
+// correctionPrompt asks the model to fix the listed problems in its previous reply
+func correctionPrompt(problems []string) string {
+	return "Your reply could not be used:\n- " + strings.Join(problems, "\n- ") +
+		"\n\nReply again with only the corrected JSON object, in the same format as before."
+}
@@ -0,0 +1,276 @@
+package ai
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestValidateColors tests the problems reported for malformed replies
+func TestValidateColors(t *testing.T) {
+	tests := []struct {
+		name     string
+		colors   string
+		problems int
+	}{
+		{"valid", `{"gradient_from": "#c67d3a", "gradient_to": "#6b8d7d", "gradient_angle": 135, "inactive_border": "#3d4650"}`, 0},
+		{"short hex", `{"gradient_from": "#c73", "gradient_to": "#6b8d7d", "gradient_angle": 0}`, 0},
+		{"no inactive border", `{"gradient_from": "#c67d3a", "gradient_to": "#6b8d7d", "gradient_angle": 360}`, 0},
+		{"bad hex", `{"gradient_from": "c67d3a", "gradient_to": "#6b8d7g", "gradient_angle": 135}`, 2},
+		{"missing keys", `{"gradient_from": "#c67d3a"}`, 2},
+		{"angle out of range", `{"gradient_from": "#c67d3a", "gradient_to": "#6b8d7d", "gradient_angle": 400}`, 1},
+		{"angle as string", `{"gradient_from": "#c67d3a", "gradient_to": "#6b8d7d", "gradient_angle": "135deg"}`, 1},
+		{"bad inactive border", `{"gradient_from": "#c67d3a", "gradient_to": "#6b8d7d", "gradient_angle": 135, "inactive_border": "grey"}`, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var colors map[string]interface{}
+			if err := json.Unmarshal([]byte(tt.colors), &colors); err != nil {
+				t.Fatalf("Invalid test colors: %v", err)
+			}
+			if problems := validateColors(colors); len(problems) != tt.problems {
+				t.Errorf("Expected %d problems, got %q", tt.problems, problems)
+			}
+		})
+	}
+}
+
+// testModels is the model list of the fake OpenRouter servers; models not listed are analyzed without a schema
+const testModels = `{"data": [{"id": "example/structured", "supported_parameters": ["response_format", "structured_outputs"]}]}`
+
+// fakeOpenRouter serves the given replies in order and records the conversations it received
+func fakeOpenRouter(t *testing.T, replies ...string) (*httptest.Server, *[]openRouterRequest) {
+	t.Helper()
+	var requests []openRouterRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			fmt.Fprint(w, testModels)
+			return
+		}
+		var req openRouterRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		requests = append(requests, req)
+		if len(requests) > len(replies) {
+			http.Error(w, "unexpected request", http.StatusInternalServerError)
+			return
+		}
+		reply := replies[len(requests)-1]
+		fmt.Fprintf(w, `{"model": %q, "choices": [{"message": {"content": %q}}], "usage": {"prompt_tokens": 100, "completion_tokens": 10, "cost": 0.5}}`, req.Model, reply)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+// testImage returns a small JPEG to analyze
+func testImage(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 16, 9)), nil); err != nil {
+		t.Fatalf("Failed to encode image: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// TestAnalyzeColors_RetriesInvalidColors tests that an invalid reply is corrected with one follow-up message
+func TestAnalyzeColors_RetriesInvalidColors(t *testing.T) {
+	server, requests := fakeOpenRouter(t,
+		`{"gradient_from": "#c67d3a", "gradient_to": "sage", "gradient_angle": 135}`,
+		`{"gradient_from": "#c67d3a", "gradient_to": "#6b8d7d", "gradient_angle": 135}`,
+	)
+	analyzer := NewAnalyzer("test-key")
+	analyzer.endpoint = server.URL
+	analyzer.modelsEndpoint = server.URL
+
+	colors, usage, err := analyzer.AnalyzeColors(testImage(t), "abc123", "Test", "", "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if colors["gradient_to"] != "#6b8d7d" {
+		t.Errorf("Expected the corrected gradient_to, got %v", colors["gradient_to"])
+	}
+	if usage.PromptTokens != 200 || usage.CompletionTokens != 20 || usage.Cost != 1 {
+		t.Errorf("Expected usage of both requests, got %+v", usage)
+	}
+
+	if len(*requests) != 2 {
+		t.Fatalf("Expected 2 requests, got %d", len(*requests))
+	}
+	retry := (*requests)[1].Messages
+	if len(retry) != 3 || retry[1].Role != "assistant" || retry[2].Role != "user" {
+		t.Fatalf("Expected the retry to continue the conversation, got %+v", retry)
+	}
+	if correction := retry[2].Content[0].Text; !strings.Contains(correction, `"gradient_to"`) {
+		t.Errorf("Expected the correction to name gradient_to, got %q", correction)
+	}
+}
+
+// TestAnalyzeColors_FailsAfterOneRetry tests that a reply still invalid after the correction is an error
+func TestAnalyzeColors_FailsAfterOneRetry(t *testing.T) {
+	server, requests := fakeOpenRouter(t,
+		`{"gradient_from": "#c67d3a", "gradient_to": "#6b8d7d", "gradient_angle": "diagonal"}`,
+		`{"gradient_from": "#c67d3a", "gradient_to": "#6b8d7d"}`,
+	)
+	analyzer := NewAnalyzer("test-key")
+	analyzer.endpoint = server.URL
+	analyzer.modelsEndpoint = server.URL
+
+	_, usage, err := analyzer.AnalyzeColors(testImage(t), "abc123", "Test", "", "")
+	if err == nil || !strings.Contains(err.Error(), "gradient_angle") {
+		t.Errorf("Expected an error naming gradient_angle, got %v", err)
+	}
+	if len(*requests) != 2 {
+		t.Errorf("Expected 2 requests, got %d", len(*requests))
+	}
+	if usage.PromptTokens != 200 || usage.CompletionTokens != 20 || usage.Cost != 1 {
+		t.Errorf("Expected the usage of both billed requests with the error, got %+v", usage)
+	}
+}
+
+// TestAnalyzeColors_CorrectionRequestFails tests that the first request's usage is returned when the correction fails
+func TestAnalyzeColors_CorrectionRequestFails(t *testing.T) {
+	t.Setenv("AI_RETRIES", "0")
+	server, _ := fakeOpenRouter(t,
+		`{"gradient_from": "#c67d3a", "gradient_to": "sage", "gradient_angle": 135}`,
+	)
+	analyzer := NewAnalyzer("test-key")
+	analyzer.endpoint = server.URL
+	analyzer.modelsEndpoint = server.URL
+
+	_, usage, err := analyzer.AnalyzeColors(testImage(t), "abc123", "Test", "", "")
+	if err == nil || !strings.Contains(err.Error(), "correction request failed") {
+		t.Errorf("Expected the correction request to fail, got %v", err)
+	}
+	if usage.PromptTokens != 100 || usage.CompletionTokens != 10 || usage.Cost != 0.5 || usage.Model == "" {
+		t.Errorf("Expected the first request's usage with the error, got %+v", usage)
+	}
+}
+
+// TestAnalyzeColors_ValidReplyIsNotRetried tests that valid replies take a single request
+func TestAnalyzeColors_ValidReplyIsNotRetried(t *testing.T) {
+	server, requests := fakeOpenRouter(t,
+		"```json\n{\"gradient_from\": \"#c67d3a\", \"gradient_to\": \"#6b8d7d\", \"gradient_angle\": 135}\n```",
+	)
+	analyzer := NewAnalyzer("test-key")
+	analyzer.endpoint = server.URL
+	analyzer.modelsEndpoint = server.URL
+
+	if _, _, err := analyzer.AnalyzeColors(testImage(t), "abc123", "Test", "", ""); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(*requests) != 1 {
+		t.Errorf("Expected 1 request, got %d", len(*requests))
+	}
+}
+
+// TestAnalyzeWithPrompt tests that profile prompts are sent as is, and their replies need only valid hex colors
+func TestAnalyzeWithPrompt(t *testing.T) {
+	t.Setenv("AI_MODEL", "example/structured")
+	server, requests := fakeOpenRouter(t,
+		`{"background": "#1d2021", "palette": ["#cc241d", "#98971g"]}`,
+		`{"background": "#1d2021", "palette": ["#cc241d", "#98971a"], "style": "dark"}`,
+	)
+	analyzer := NewAnalyzer("test-key")
+	analyzer.endpoint = server.URL
+	analyzer.modelsEndpoint = server.URL
+
+	colors, _, err := analyzer.AnalyzeWithPrompt("Design a terminal theme.", testImage(t), "abc123", "Test")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if colors["style"] != "dark" {
+		t.Errorf("Expected the corrected reply with its own keys, got %v", colors)
+	}
+
+	if len(*requests) != 2 {
+		t.Fatalf("Expected 2 requests, got %d", len(*requests))
+	}
+	first := (*requests)[0]
+	if first.ResponseFormat != nil {
+		t.Error("Expected no schema for a profile prompt")
+	}
+	if prompt := first.Messages[0].Content[1].Text; prompt != "Design a terminal theme." {
+		t.Errorf("Expected the profile prompt alone, got %q", prompt)
+	}
+	if correction := (*requests)[1].Messages[2].Content[0].Text; !strings.Contains(correction, `"palette[1]"`) {
+		t.Errorf("Expected the correction to name palette[1], got %q", correction)
+	}
+
+	if problems := validateProfileColors(map[string]interface{}{}); len(problems) != 1 {
+		t.Errorf("Expected an empty reply to be rejected, got %q", problems)
+	}
+}
+
+// TestAnalyzeFullPalette tests that full palettes are held to their schema, validated and returned typed
+func TestAnalyzeFullPalette(t *testing.T) {
+	t.Setenv("AI_MODEL", "example/structured")
+	roles := `"background": "#1d2021", "foreground": "#ebdbb2", "accent": "#d79921", "muted": "#928374", "error": "#cc241d", "success": "#98971a"`
+	server, requests := fakeOpenRouter(t,
+		`{`+roles+`, "swatches": ["#282828", "#3c3836"]}`,
+		`{`+roles+`, "swatches": ["#282828", "#3c3836", "#504945", "#665c54", "#7c6f64", "#a89984", "#bdae93", "#d5c4a1"]}`,
+	)
+	analyzer := NewAnalyzer("test-key")
+	analyzer.endpoint = server.URL
+	analyzer.modelsEndpoint = server.URL
+
+	palette, _, err := analyzer.AnalyzeFullPalette(testImage(t), "abc123", "Test")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if palette.Foreground != "#ebdbb2" || len(palette.Swatches) != 8 {
+		t.Errorf("Expected the corrected palette, got %+v", palette)
+	}
+
+	if len(*requests) != 2 {
+		t.Fatalf("Expected 2 requests, got %d", len(*requests))
+	}
+	if format := (*requests)[0].ResponseFormat; format == nil || format.JSONSchema.Name != "wallpaper_full_palette" {
+		t.Errorf("Expected the full palette schema, got %+v", format)
+	}
+	if correction := (*requests)[1].Messages[2].Content[0].Text; !strings.Contains(correction, `"swatches" has 2 colors`) {
+		t.Errorf("Expected the correction to count the swatches, got %q", correction)
+	}
+}
+
+// TestRepairColors tests that unambiguous mistakes are fixed locally instead of with a correction request
+func TestRepairColors(t *testing.T) {
+	tests := []struct {
+		name    string
+		colors  string
+		want    string
+		repairs int
+	}{
+		{"valid", `{"gradient_from": "#c67d3a", "gradient_angle": 360}`, `{"gradient_angle":360,"gradient_from":"#c67d3a"}`, 0},
+		{"missing #", `{"gradient_from": "c67d3a", "notifications": {"low": "3A3D3B"}}`, `{"gradient_from":"#c67d3a","notifications":{"low":"#3A3D3B"}}`, 2},
+		{"short word", `{"style": "bad"}`, `{"style":"bad"}`, 0},
+		{"angle as string", `{"gradient_angle": "135deg"}`, `{"gradient_angle":135}`, 1},
+		{"angle beyond a turn", `{"gradient_angle": 450}`, `{"gradient_angle":90}`, 1},
+		{"negative angle", `{"gradient_angle": -45}`, `{"gradient_angle":315}`, 1},
+		{"angle as a word", `{"gradient_angle": "diagonal"}`, `{"gradient_angle":"diagonal"}`, 0},
+		{"swatches", `{"swatches": ["#282828", "3c3836"]}`, `{"swatches":["#282828","#3c3836"]}`, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var colors map[string]interface{}
+			if err := json.Unmarshal([]byte(tt.colors), &colors); err != nil {
+				t.Fatalf("Invalid test colors: %v", err)
+			}
+			repairs := repairColors(colors)
+			if len(repairs) != tt.repairs {
+				t.Errorf("Expected %d repairs, got %q", tt.repairs, repairs)
+			}
+			if got, _ := json.Marshal(colors); string(got) != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
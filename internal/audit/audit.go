@@ -0,0 +1,143 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// SystemActor is recorded for mutations made by the server itself, e.g. scheduled re-analyses
+const SystemActor = "system"
+
+// Event is one admin mutation in the audit log
+type Event struct {
+	At      time.Time         `json:"at"`
+	Actor   string            `json:"actor"`  // Admin subject, or SystemActor
+	Action  string            `json:"action"` // e.g. "pin.set", "config.reload"
+	Keys    []string          `json:"keys,omitempty"`
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// Filter selects events from the audit log; zero values match everything
+type Filter struct {
+	Actor  string
+	Action string
+	Key    string
+	Since  time.Time
+	Limit  int
+}
+
+// matches reports whether the event passes the filter (ignoring Limit)
+func (f Filter) matches(event Event) bool {
+	if f.Actor != "" && event.Actor != f.Actor {
+		return false
+	}
+	if f.Action != "" && event.Action != f.Action {
+		return false
+	}
+	if !f.Since.IsZero() && event.At.Before(f.Since) {
+		return false
+	}
+	if f.Key == "" {
+		return true
+	}
+	for _, key := range event.Keys {
+		if key == f.Key {
+			return true
+		}
+	}
+	return false
+}
+
+// Log is an append-only JSON lines file of admin mutations
+// A nil *Log discards events, so tests and tools can run without one
+type Log struct {
+	mu   sync.Mutex
+	path string
+}
+
+// Open opens the audit log in dir, creating the directory if needed
+func Open(dir string) (*Log, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+	return &Log{path: filepath.Join(dir, "audit.jsonl")}, nil
+}
+
+// Record appends an event, stamping it with the current time if At is unset
+func (l *Log) Record(event Event) error {
+	if l == nil {
+		return nil
+	}
+	if event.At.IsZero() {
+		event.At = time.Now().UTC()
+	}
+
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit event: %w", err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	file, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return nil
+}
+
+// Query returns the events matching the filter, newest first
+func (l *Log) Query(filter Filter) ([]Event, error) {
+	events := []Event{}
+	if l == nil {
+		return events, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	file, err := os.Open(l.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return events, nil
+		}
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var event Event
+		// Skip a line torn by a crash mid-write rather than hiding the rest of the log
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			continue
+		}
+		if filter.matches(event) {
+			events = append(events, event)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	// Events are appended in order, so reversing gives newest first
+	for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+		events[i], events[j] = events[j], events[i]
+	}
+	if filter.Limit > 0 && len(events) > filter.Limit {
+		events = events[:filter.Limit]
+	}
+	return events, nil
+}
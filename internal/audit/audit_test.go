@@ -0,0 +1,68 @@
+package audit
+
+import (
+	"testing"
+	"time"
+)
+
+// TestLogQuery tests that recorded events come back newest first and filtered
+func TestLogQuery(t *testing.T) {
+	log, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	events := []Event{
+		{At: start, Actor: "alice", Action: "pin.set", Keys: []string{"aaa"}},
+		{At: start.Add(time.Hour), Actor: "bob", Action: "config.reload"},
+		{At: start.Add(2 * time.Hour), Actor: "alice", Action: "pin.remove", Keys: []string{"aaa"}},
+	}
+	for _, event := range events {
+		if err := log.Record(event); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		filter  Filter
+		actions []string
+	}{
+		{"all", Filter{}, []string{"pin.remove", "config.reload", "pin.set"}},
+		{"actor", Filter{Actor: "alice"}, []string{"pin.remove", "pin.set"}},
+		{"action", Filter{Action: "config.reload"}, []string{"config.reload"}},
+		{"key", Filter{Key: "aaa"}, []string{"pin.remove", "pin.set"}},
+		{"since", Filter{Since: start.Add(30 * time.Minute)}, []string{"pin.remove", "config.reload"}},
+		{"limit", Filter{Limit: 1}, []string{"pin.remove"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := log.Query(tt.filter)
+			if err != nil {
+				t.Fatalf("Query() error = %v", err)
+			}
+			if len(got) != len(tt.actions) {
+				t.Fatalf("Query() returned %d events, want %d", len(got), len(tt.actions))
+			}
+			for i, event := range got {
+				if event.Action != tt.actions[i] {
+					t.Errorf("event %d action = %q, want %q", i, event.Action, tt.actions[i])
+				}
+			}
+		})
+	}
+}
+
+// TestNilLog tests that a nil log discards events
+func TestNilLog(t *testing.T) {
+	var log *Log
+	if err := log.Record(Event{Action: "pin.set"}); err != nil {
+		t.Errorf("Record() error = %v", err)
+	}
+	events, err := log.Query(Filter{})
+	if err != nil || len(events) != 0 {
+		t.Errorf("Query() = %v, %v, want no events", events, err)
+	}
+}
@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrMissingCredentials is returned when a request carries no bearer token
+var ErrMissingCredentials = errors.New("missing bearer token")
+
+// Config configures which credentials are accepted for admin routes
+type Config struct {
+	APIKeys   []string // Static bearer tokens
+	JWTSecret string   // Shared secret for HS256/HS384/HS512 tokens
+	JWKSURL   string   // Key set URL for RS256/RS384/RS512 tokens
+	Issuer    string   // Required "iss" claim, if set
+	Audience  string   // Required "aud" claim, if set
+}
+
+// Authenticator validates admin credentials from incoming requests
+type Authenticator struct {
+	mu     sync.RWMutex
+	config Config
+	jwks   *jwksCache
+	now    func() time.Time
+}
+
+// NewAuthenticator creates an authenticator for the given configuration
+func NewAuthenticator(config Config) *Authenticator {
+	a := &Authenticator{now: time.Now}
+	a.Update(config)
+	return a
+}
+
+// Update replaces the accepted credentials, e.g. on configuration reload
+// The cached key set is kept when the JWKS URL is unchanged
+func (a *Authenticator) Update(config Config) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if config.JWKSURL == "" {
+		a.jwks = nil
+	} else if a.jwks == nil || a.jwks.url != config.JWKSURL {
+		a.jwks = newJWKSCache(config.JWKSURL)
+	}
+
+	a.config = config
+}
+
+// Enabled reports whether any credential type is configured
+// Admin routes should stay disabled when nothing is configured
+func (a *Authenticator) Enabled() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return len(a.config.APIKeys) > 0 || a.config.JWTSecret != "" || a.config.JWKSURL != ""
+}
+
+// Authenticate validates the request's bearer token and returns the caller's identity
+// API keys identify as "api-key"; JWTs identify by their "sub" claim
+func (a *Authenticator) Authenticate(r *http.Request) (string, error) {
+	header := r.Header.Get("Authorization")
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok || token == "" {
+		return "", ErrMissingCredentials
+	}
+
+	a.mu.RLock()
+	config, jwks := a.config, a.jwks
+	a.mu.RUnlock()
+
+	for _, key := range config.APIKeys {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(key)) == 1 {
+			return "api-key", nil
+		}
+	}
+
+	// Anything that isn't a known API key must be a JWT
+	if strings.Count(token, ".") != 2 {
+		return "", fmt.Errorf("invalid credentials")
+	}
+
+	claims, err := a.verifyJWT(r.Context(), config, jwks, token)
+	if err != nil {
+		return "", err
+	}
+
+	if claims.Subject == "" {
+		return "jwt", nil
+	}
+	return claims.Subject, nil
+}
+
+type subjectKey struct{}
+
+// WithSubject stores the authenticated caller's identity in a context
+func WithSubject(ctx context.Context, subject string) context.Context {
+	return context.WithValue(ctx, subjectKey{}, subject)
+}
+
+// SubjectFromContext returns the authenticated caller's identity, if any
+func SubjectFromContext(ctx context.Context) string {
+	subject, _ := ctx.Value(subjectKey{}).(string)
+	return subject
+}
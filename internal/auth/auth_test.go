@@ -0,0 +1,206 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// signHS256 builds an HS256 token for the given claims
+func signHS256(t *testing.T, secret string, claims map[string]interface{}) string {
+	t.Helper()
+	unsigned := encodeSegment(t, map[string]string{"alg": "HS256", "typ": "JWT"}) + "." + encodeSegment(t, claims)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// signRS256 builds an RS256 token for the given claims
+func signRS256(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	t.Helper()
+	unsigned := encodeSegment(t, map[string]string{"alg": "RS256", "kid": kid}) + "." + encodeSegment(t, claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func encodeSegment(t *testing.T, v interface{}) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("Failed to marshal segment: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func requestWithToken(token string) *http.Request {
+	req := httptest.NewRequest("GET", "/admin", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req
+}
+
+// TestAuthenticate_APIKey tests static API key authentication
+func TestAuthenticate_APIKey(t *testing.T) {
+	a := NewAuthenticator(Config{APIKeys: []string{"secret-key"}})
+
+	subject, err := a.Authenticate(requestWithToken("secret-key"))
+	if err != nil {
+		t.Fatalf("Expected valid API key, got: %v", err)
+	}
+	if subject != "api-key" {
+		t.Errorf("Expected subject api-key, got %s", subject)
+	}
+
+	if _, err := a.Authenticate(requestWithToken("wrong-key")); err == nil {
+		t.Error("Expected error for wrong API key")
+	}
+
+	if _, err := a.Authenticate(requestWithToken("")); err != ErrMissingCredentials {
+		t.Errorf("Expected ErrMissingCredentials, got %v", err)
+	}
+}
+
+// TestAuthenticate_HS256 tests shared-secret JWT validation
+func TestAuthenticate_HS256(t *testing.T) {
+	a := NewAuthenticator(Config{JWTSecret: "shared", Issuer: "idp", Audience: "dailyhues"})
+	exp := time.Now().Add(time.Hour).Unix()
+
+	tests := []struct {
+		name   string
+		token  string
+		wantOK bool
+	}{
+		{"Valid", signHS256(t, "shared", map[string]interface{}{"sub": "alice", "iss": "idp", "aud": "dailyhues", "exp": exp}), true},
+		{"Audience list", signHS256(t, "shared", map[string]interface{}{"sub": "alice", "iss": "idp", "aud": []string{"other", "dailyhues"}, "exp": exp}), true},
+		{"Wrong secret", signHS256(t, "other", map[string]interface{}{"sub": "alice", "iss": "idp", "aud": "dailyhues", "exp": exp}), false},
+		{"Expired", signHS256(t, "shared", map[string]interface{}{"sub": "alice", "iss": "idp", "aud": "dailyhues", "exp": time.Now().Add(-time.Hour).Unix()}), false},
+		{"No expiry", signHS256(t, "shared", map[string]interface{}{"sub": "alice", "iss": "idp", "aud": "dailyhues"}), false},
+		{"Wrong issuer", signHS256(t, "shared", map[string]interface{}{"sub": "alice", "iss": "evil", "aud": "dailyhues", "exp": exp}), false},
+		{"Wrong audience", signHS256(t, "shared", map[string]interface{}{"sub": "alice", "iss": "idp", "aud": "other", "exp": exp}), false},
+		{"Not yet valid", signHS256(t, "shared", map[string]interface{}{"sub": "alice", "iss": "idp", "aud": "dailyhues", "exp": exp, "nbf": time.Now().Add(time.Hour).Unix()}), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subject, err := a.Authenticate(requestWithToken(tt.token))
+			if tt.wantOK && err != nil {
+				t.Errorf("Expected valid token, got: %v", err)
+			}
+			if !tt.wantOK && err == nil {
+				t.Error("Expected token to be rejected")
+			}
+			if tt.wantOK && subject != "alice" {
+				t.Errorf("Expected subject alice, got %s", subject)
+			}
+		})
+	}
+}
+
+// TestAuthenticate_AlgNone tests that unsigned tokens are rejected
+func TestAuthenticate_AlgNone(t *testing.T) {
+	a := NewAuthenticator(Config{JWTSecret: "shared"})
+	token := encodeSegment(t, map[string]string{"alg": "none"}) + "." + encodeSegment(t, map[string]interface{}{"exp": time.Now().Add(time.Hour).Unix()}) + "."
+
+	if _, err := a.Authenticate(requestWithToken(token)); err == nil {
+		t.Error("Expected alg=none token to be rejected")
+	}
+}
+
+// TestAuthenticate_JWKS tests RS256 validation against a key set endpoint
+func TestAuthenticate_JWKS(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "key-1",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	}))
+	defer server.Close()
+
+	a := NewAuthenticator(Config{JWKSURL: server.URL})
+	claims := map[string]interface{}{"sub": "bob", "exp": time.Now().Add(time.Hour).Unix()}
+
+	subject, err := a.Authenticate(requestWithToken(signRS256(t, key, "key-1", claims)))
+	if err != nil {
+		t.Fatalf("Expected valid token, got: %v", err)
+	}
+	if subject != "bob" {
+		t.Errorf("Expected subject bob, got %s", subject)
+	}
+
+	if _, err := a.Authenticate(requestWithToken(signRS256(t, key, "unknown", claims))); err == nil {
+		t.Error("Expected unknown key ID to be rejected")
+	}
+
+	// An HMAC token must not be accepted when only JWKS is configured
+	if _, err := a.Authenticate(requestWithToken(signHS256(t, "anything", claims))); err == nil {
+		t.Error("Expected HMAC token to be rejected without a shared secret")
+	}
+}
+
+// TestJWKSCache_FailureBackoff tests that a failing endpoint is not refetched for every unknown key ID
+func TestJWKSCache_FailureBackoff(t *testing.T) {
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	c := newJWKSCache(server.URL)
+	for range 3 {
+		if _, err := c.key(context.Background(), "key-1"); err == nil {
+			t.Error("Expected an error while the endpoint is down")
+		}
+	}
+	if fetches.Load() != 1 {
+		t.Errorf("Expected one fetch during the backoff, got %d", fetches.Load())
+	}
+
+	// Once the backoff has passed the endpoint is tried again, and the next wait is longer
+	c.failedAt = time.Now().Add(-jwksMinRefresh)
+	c.key(context.Background(), "key-1")
+	if fetches.Load() != 2 || c.backoff() != 2*jwksMinRefresh {
+		t.Errorf("Expected a second fetch and a doubled backoff, got %d fetches and %s", fetches.Load(), c.backoff())
+	}
+
+	c.failures = 100
+	if c.backoff() != jwksMaxBackoff {
+		t.Errorf("Expected the backoff to be capped, got %s", c.backoff())
+	}
+}
+
+// TestEnabled tests that the authenticator is disabled without credentials
+func TestEnabled(t *testing.T) {
+	if NewAuthenticator(Config{}).Enabled() {
+		t.Error("Expected authenticator without credentials to be disabled")
+	}
+	if !NewAuthenticator(Config{JWTSecret: "x"}).Enabled() {
+		t.Error("Expected authenticator with a secret to be enabled")
+	}
+}
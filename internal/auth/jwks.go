@@ -0,0 +1,144 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	jwksRefreshInterval = time.Hour
+	jwksMinRefresh      = time.Minute // Throttles refetches triggered by unknown key IDs
+	jwksRequestTimeout  = 10 * time.Second
+	jwksMaxBackoff      = 15 * time.Minute // Caps the wait after failed fetches, which doubles from jwksMinRefresh
+)
+
+// jwksCache fetches and caches RSA public keys from a JWKS endpoint
+type jwksCache struct {
+	url        string
+	httpClient *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey // key: kid
+	fetchedAt time.Time
+	failedAt  time.Time // Last failed fetch, so a down endpoint is not refetched for every request
+	failures  int       // Consecutive failed fetches
+	lastErr   error
+}
+
+type jsonWebKeySet struct {
+	Keys []struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Use string `json:"use"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	} `json:"keys"`
+}
+
+func newJWKSCache(url string) *jwksCache {
+	return &jwksCache{
+		url:        url,
+		httpClient: &http.Client{Timeout: jwksRequestTimeout},
+		keys:       make(map[string]*rsa.PublicKey),
+	}
+}
+
+// key returns the public key for a key ID, refreshing the set when it is stale or the ID is unknown
+func (c *jwksCache) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key, known := c.keys[kid]
+	age := time.Since(c.fetchedAt)
+
+	if known && age < jwksRefreshInterval {
+		return key, nil
+	}
+	if !known && age < jwksMinRefresh {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	// Keep serving the last known key if the endpoint is temporarily unavailable
+	if c.failures > 0 && time.Since(c.failedAt) < c.backoff() {
+		if known {
+			return key, nil
+		}
+		return nil, fmt.Errorf("JWKS endpoint unavailable, retrying later: %w", c.lastErr)
+	}
+
+	if err := c.refresh(ctx); err != nil {
+		c.failures++
+		c.failedAt = time.Now()
+		c.lastErr = err
+		if known {
+			return key, nil
+		}
+		return nil, err
+	}
+	c.failures = 0
+
+	if key, ok := c.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// backoff returns how long to wait after the last failed fetch; callers must hold c.mu
+func (c *jwksCache) backoff() time.Duration {
+	if c.failures > 5 {
+		return jwksMaxBackoff
+	}
+	return min(jwksMinRefresh<<(c.failures-1), jwksMaxBackoff)
+}
+
+// refresh replaces the cached keys with the current key set; callers must hold c.mu
+func (c *jwksCache) refresh(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create JWKS request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("JWKS endpoint returned status %d", resp.StatusCode)
+	}
+
+	var set jsonWebKeySet
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("failed to parse JWKS: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil {
+			continue
+		}
+
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	c.keys = keys
+	c.fetchedAt = time.Now()
+	return nil
+}
@@ -0,0 +1,177 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"strings"
+	"time"
+)
+
+// clockSkew is the tolerance applied to exp and nbf checks
+const clockSkew = 30 * time.Second
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// Claims holds the registered JWT claims we validate
+type Claims struct {
+	Subject   string   `json:"sub"`
+	Issuer    string   `json:"iss"`
+	Audience  audience `json:"aud"`
+	ExpiresAt int64    `json:"exp"`
+	NotBefore int64    `json:"nbf"`
+}
+
+// audience accepts both the string and array forms of the "aud" claim
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("invalid aud claim")
+	}
+	*a = list
+	return nil
+}
+
+// verifyJWT checks the token's signature and registered claims
+func (a *Authenticator) verifyJWT(ctx context.Context, config Config, jwks *jwksCache, token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("invalid token header: %w", err)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid token signature encoding")
+	}
+
+	signed := []byte(parts[0] + "." + parts[1])
+	if err := verifySignature(ctx, config, jwks, header, signed, signature); err != nil {
+		return nil, err
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("invalid token claims: %w", err)
+	}
+
+	if err := validateClaims(config, a.now(), &claims); err != nil {
+		return nil, err
+	}
+
+	return &claims, nil
+}
+
+// verifySignature dispatches on the token's algorithm
+// The algorithm family must match the configured key type, so "none" and HS/RS confusion are rejected
+func verifySignature(ctx context.Context, config Config, jwks *jwksCache, header jwtHeader, signed, signature []byte) error {
+	switch header.Alg {
+	case "HS256", "HS384", "HS512":
+		if config.JWTSecret == "" {
+			return fmt.Errorf("HMAC tokens are not accepted")
+		}
+		mac := hmac.New(hmacHash(header.Alg), []byte(config.JWTSecret))
+		mac.Write(signed)
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return fmt.Errorf("invalid token signature")
+		}
+		return nil
+
+	case "RS256", "RS384", "RS512":
+		if jwks == nil {
+			return fmt.Errorf("RSA tokens are not accepted")
+		}
+		key, err := jwks.key(ctx, header.Kid)
+		if err != nil {
+			return err
+		}
+		hashFunc, digest := rsaDigest(header.Alg, signed)
+		if err := rsa.VerifyPKCS1v15(key, hashFunc, digest, signature); err != nil {
+			return fmt.Errorf("invalid token signature")
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unsupported token algorithm %q", header.Alg)
+	}
+}
+
+// validateClaims checks expiry, not-before, issuer, and audience
+func validateClaims(config Config, now time.Time, claims *Claims) error {
+	if claims.ExpiresAt == 0 {
+		return fmt.Errorf("token has no expiry")
+	}
+	if now.After(time.Unix(claims.ExpiresAt, 0).Add(clockSkew)) {
+		return fmt.Errorf("token expired")
+	}
+	if claims.NotBefore != 0 && now.Add(clockSkew).Before(time.Unix(claims.NotBefore, 0)) {
+		return fmt.Errorf("token not yet valid")
+	}
+
+	if config.Issuer != "" && claims.Issuer != config.Issuer {
+		return fmt.Errorf("unexpected token issuer")
+	}
+
+	if config.Audience != "" {
+		for _, aud := range claims.Audience {
+			if aud == config.Audience {
+				return nil
+			}
+		}
+		return fmt.Errorf("unexpected token audience")
+	}
+
+	return nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func hmacHash(alg string) func() hash.Hash {
+	switch alg {
+	case "HS384":
+		return sha512.New384
+	case "HS512":
+		return sha512.New
+	default:
+		return sha256.New
+	}
+}
+
+func rsaDigest(alg string, signed []byte) (crypto.Hash, []byte) {
+	switch alg {
+	case "RS384":
+		sum := sha512.Sum384(signed)
+		return crypto.SHA384, sum[:]
+	case "RS512":
+		sum := sha512.Sum512(signed)
+		return crypto.SHA512, sum[:]
+	default:
+		sum := sha256.Sum256(signed)
+		return crypto.SHA256, sum[:]
+	}
+}
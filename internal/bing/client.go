@@ -1,23 +1,38 @@
 package bing
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/mgabor3141/dailyhues/internal/logging"
 )
 
 const (
-	bingAPIURL  = "https://www.bing.com/HPImageArchive.aspx"
-	bingBaseURL = "https://www.bing.com"
-	httpTimeout = 30 * time.Second
+	bingAPIURL         = "https://www.bing.com/HPImageArchive.aspx"
+	bingBaseURL        = "https://www.bing.com"
+	defaultHTTPTimeout = 30 * time.Second
+
+	// maxArchiveImages is the most wallpapers Bing returns from a single archive request
+	maxArchiveImages = 8
 )
 
+// logger returns the logger for this package's messages
+func logger() *slog.Logger {
+	return logging.Package("bing")
+}
+
 // Client handles interactions with the Bing wallpaper API
 type Client struct {
 	httpClient *http.Client
-	market     string // e.g., "en-US", "ja-JP"
+	market     string         // e.g., "en-US", "ja-JP"
+	metadata   *metadataCache // Shared by the clients WithLocale derives
 }
 
 // WallpaperInfo contains metadata about a Bing wallpaper
@@ -28,23 +43,44 @@ type WallpaperInfo struct {
 	Title         string
 	Copyright     string
 	CopyrightLink string
+	Photographer  string // Parsed from Copyright; empty when only an agency is credited
+	Agency        string // Parsed from Copyright
 	StartDate     string // Format: YYYYMMDD (e.g., "20251019")
 	FullStartDate string // Format: YYYYMMDDHHMM (e.g., "202510190700")
 	EndDate       string // Format: YYYYMMDD (e.g., "20251020")
+
+	// Set on days Bing serves a video background instead of a still image
+	VideoURLs     map[string]string // Video URLs by format (e.g., "mp4", "hls")
+	VideoFrameURL string            // Representative frame of the video, analyzed in place of the still
+}
+
+// HasVideo reports whether Bing offers a video background for this day
+func (info *WallpaperInfo) HasVideo() bool {
+	return len(info.VideoURLs) > 0
 }
 
 // bingAPIResponse represents the JSON response from Bing's API
 type bingAPIResponse struct {
-	Images []struct {
-		URL           string `json:"url"`
-		URLBase       string `json:"urlbase"`
-		Title         string `json:"title"`
-		Copyright     string `json:"copyright"`
-		CopyrightURL  string `json:"copyrightlink"`
-		StartDate     string `json:"startdate"`     // Format: YYYYMMDD (e.g., "20251019")
-		FullStartDate string `json:"fullstartdate"` // Format: YYYYMMDDHHMM (e.g., "202510190700")
-		EndDate       string `json:"enddate"`       // Format: YYYYMMDD (e.g., "20251020")
-	} `json:"images"`
+	Images []bingImage `json:"images"`
+}
+
+// bingImage is a single wallpaper entry in Bing's API response
+type bingImage struct {
+	URL           string     `json:"url"`
+	URLBase       string     `json:"urlbase"`
+	Title         string     `json:"title"`
+	Copyright     string     `json:"copyright"`
+	CopyrightURL  string     `json:"copyrightlink"`
+	StartDate     string     `json:"startdate"`     // Format: YYYYMMDD (e.g., "20251019")
+	FullStartDate string     `json:"fullstartdate"` // Format: YYYYMMDDHHMM (e.g., "202510190700")
+	EndDate       string     `json:"enddate"`       // Format: YYYYMMDD (e.g., "20251020")
+	Video         *bingVideo `json:"vid"`           // Only present on video days
+}
+
+// bingVideo describes the video background Bing serves on some days
+type bingVideo struct {
+	Sources [][]string `json:"sources"` // Each source is [MIME type, codecs, URL]
+	Image   string     `json:"image"`   // Poster frame shown while the video loads
 }
 
 // NewClient creates a new Bing wallpaper client
@@ -55,43 +91,85 @@ func NewClient(market string) *Client {
 
 	return &Client{
 		httpClient: &http.Client{
-			Timeout: httpTimeout,
+			Timeout:   Timeout(), // Includes retries and waiting for slots in the shared scheduler
+			Transport: sharedTransport,
 		},
-		market: market,
+		market:   market,
+		metadata: newMetadataCache(),
 	}
 }
 
 // SetLocale updates the market/locale for the client
+//
+// Deprecated: SetLocale races with requests in flight on the same client; use WithLocale.
 func (c *Client) SetLocale(locale string) {
 	c.market = locale
 }
 
-// GetWallpaperInfo fetches metadata for the wallpaper on a given date
-// date should be in "YYYY-MM-DD" format
-func (c *Client) GetWallpaperInfo(date string) (*WallpaperInfo, error) {
-	// Calculate days offset from today
+// WithLocale returns a client for another market that shares this client's connections and pacing
+// Unlike SetLocale it is safe while other goroutines use the original client
+func (c *Client) WithLocale(locale string) *Client {
+	if locale == "" {
+		locale = "en-US"
+	}
+	return &Client{httpClient: c.httpClient, market: locale, metadata: c.metadata}
+}
+
+// GetWallpaperInfo fetches metadata for the wallpaper that started on a given date
+// date should be in "YYYY-MM-DD" format. It is matched against the market's startdate,
+// so the lookup follows the market's own rollover rather than the server's clock
+func (c *Client) GetWallpaperInfo(ctx context.Context, date string) (*WallpaperInfo, error) {
 	targetDate, err := time.Parse("2006-01-02", date)
 	if err != nil {
 		return nil, fmt.Errorf("invalid date format: %w", err)
 	}
 
-	today := time.Now().Truncate(24 * time.Hour)
-	daysAgo := int(today.Sub(targetDate).Hours() / 24)
+	infos, err := c.GetRecentWallpaperInfos(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	startDate := targetDate.Format(dateLayout)
+	for _, info := range infos {
+		if info.StartDate == startDate {
+			return info, nil
+		}
+	}
+	return nil, fmt.Errorf("no wallpaper found for date %s (Bing only keeps ~%d days)", date, maxArchiveImages)
+}
 
-	if daysAgo < 0 {
-		daysAgo = 0
+// GetRecentWallpaperInfos fetches metadata for every wallpaper Bing still serves, newest first
+// Index i of the result is the wallpaper from i days ago
+func (c *Client) GetRecentWallpaperInfos(ctx context.Context) ([]*WallpaperInfo, error) {
+	images, err := c.fetchImages(ctx, 0, maxArchiveImages)
+	if err != nil {
+		return nil, err
 	}
 
-	// Bing API only keeps about 7-8 days of history
-	if daysAgo > 7 {
-		return nil, fmt.Errorf("wallpaper too old (Bing only keeps ~7 days)")
+	infos := make([]*WallpaperInfo, len(images))
+	for i, image := range images {
+		infos[i] = newWallpaperInfo(image)
+	}
+	return infos, nil
+}
+
+// fetchImages requests n wallpapers starting idx days ago from Bing's archive API
+// Entries fetched within the metadata TTL are served from memory
+func (c *Client) fetchImages(ctx context.Context, idx, n int) ([]bingImage, error) {
+	if images, ok := c.metadata.get(c.market, idx, n); ok {
+		logger().Debug("Bing metadata cache hit", "market", c.market, "idx", idx, "n", n)
+		return images, nil
 	}
 
 	// Build API URL
-	url := fmt.Sprintf("%s?format=js&idx=%d&n=1&mkt=%s", bingAPIURL, daysAgo, c.market)
+	url := fmt.Sprintf("%s?format=js&idx=%d&n=%d&mkt=%s&video=1", bingAPIURL, idx, n, c.market)
 
 	// Make request
-	resp, err := c.httpClient.Get(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch from Bing API: %w", err)
 	}
@@ -101,21 +179,19 @@ func (c *Client) GetWallpaperInfo(date string) (*WallpaperInfo, error) {
 		return nil, fmt.Errorf("Bing API returned status %d", resp.StatusCode)
 	}
 
-	// Parse response
-	var apiResp bingAPIResponse
-	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
-		return nil, fmt.Errorf("failed to parse Bing API response: %w", err)
-	}
-
-	if len(apiResp.Images) == 0 {
-		return nil, fmt.Errorf("no wallpaper found for date %s", date)
+	images, err := parseAPIResponse(resp.Body)
+	if err != nil {
+		return nil, err
 	}
+	c.metadata.put(c.market, idx, images, MetadataTTL())
+	return images, nil
+}
 
-	image := apiResp.Images[0]
-
+// newWallpaperInfo converts a normalized API image entry into WallpaperInfo
+func newWallpaperInfo(image bingImage) *WallpaperInfo {
 	// Construct full URL
-	imageURL := bingBaseURL + image.URL
-	urlBase := bingBaseURL + image.URLBase
+	imageURL := absoluteURL(image.URL)
+	urlBase := absoluteURL(image.URLBase)
 
 	// Generate different size URLs (based on actual Bing availability)
 	imageURLs := map[string]string{
@@ -126,12 +202,14 @@ func (c *Client) GetWallpaperInfo(date string) (*WallpaperInfo, error) {
 		"1280x720":  urlBase + "_1280x720.jpg",  // HD (~179KB)
 		"1024x768":  urlBase + "_1024x768.jpg",  // XGA (~66KB)
 		"800x600":   urlBase + "_800x600.jpg",   // SVGA (~93KB)
+		"1080x1920": urlBase + "_1080x1920.jpg", // Phone, portrait
+		"768x1280":  urlBase + "_768x1280.jpg",  // Small phone, portrait
 	}
 
 	// Extract image ID from URLBase (e.g., "/th?id=OHR.ImageName_EN-US123456" -> "OHR.ImageName_EN-US123456")
 	imageID := extractImageID(image.URLBase)
 
-	return &WallpaperInfo{
+	info := &WallpaperInfo{
 		URL:           imageURL,
 		ImageID:       imageID,
 		ImageURLs:     imageURLs,
@@ -141,23 +219,123 @@ func (c *Client) GetWallpaperInfo(date string) (*WallpaperInfo, error) {
 		StartDate:     image.StartDate,
 		FullStartDate: image.FullStartDate,
 		EndDate:       image.EndDate,
-	}, nil
+	}
+
+	credit := ParseCopyright(image.Copyright)
+	info.Photographer, info.Agency = credit.Photographer, credit.Agency
+
+	if image.Video != nil {
+		info.VideoURLs = videoURLs(image.Video.Sources)
+		if info.HasVideo() {
+			// Fall back to the still when Bing doesn't name a poster frame
+			info.VideoFrameURL = absoluteURL(image.Video.Image)
+			if info.VideoFrameURL == "" {
+				info.VideoFrameURL = imageURLs["1920x1080"]
+			}
+		}
+	}
+
+	return info
+}
+
+// Orientations of the resolutions in WallpaperInfo.ImageURLs
+const (
+	Landscape = "landscape"
+	Portrait  = "portrait"
+)
+
+// Orientation returns whether a resolution key of WallpaperInfo.ImageURLs is landscape or portrait
+// Keys are "WIDTHxHEIGHT", except "UHD", which is landscape
+func Orientation(resolution string) string {
+	width, height, ok := strings.Cut(resolution, "x")
+	w, errW := strconv.Atoi(width)
+	h, errH := strconv.Atoi(height)
+	if ok && errW == nil && errH == nil && h > w {
+		return Portrait
+	}
+	return Landscape
 }
 
-// extractImageID extracts the image ID from the URLBase
-// Example: "/th?id=OHR.MartimoaapaFinland_EN-US3685817058" -> "OHR.MartimoaapaFinland_EN-US3685817058"
-func extractImageID(urlBase string) string {
-	// URLBase format: "/th?id=<IMAGE_ID>"
-	const prefix = "/th?id="
-	if len(urlBase) > len(prefix) {
-		return urlBase[len(prefix):]
+// Orientations maps each resolution of an ImageURLs map to its orientation
+func Orientations(imageURLs map[string]string) map[string]string {
+	if len(imageURLs) == 0 {
+		return nil
 	}
-	return urlBase
+	orientations := make(map[string]string, len(imageURLs))
+	for resolution := range imageURLs {
+		orientations[resolution] = Orientation(resolution)
+	}
+	return orientations
+}
+
+// videoURLs maps Bing's video sources to their URLs by format, keeping the first source of each format
+func videoURLs(sources [][]string) map[string]string {
+	urls := make(map[string]string)
+	for _, source := range sources {
+		if len(source) < 3 || source[2] == "" {
+			continue
+		}
+		format := videoFormat(source[0])
+		if _, ok := urls[format]; !ok {
+			urls[format] = absoluteURL(source[2])
+		}
+	}
+
+	if len(urls) == 0 {
+		return nil
+	}
+	return urls
+}
+
+// videoFormat turns a MIME type into a short format name ("video/mp4" -> "mp4", HLS playlists -> "hls")
+func videoFormat(mimeType string) string {
+	mimeType = strings.ToLower(mimeType)
+	if strings.Contains(mimeType, "mpegurl") {
+		return "hls"
+	}
+	if _, subtype, ok := strings.Cut(mimeType, "/"); ok {
+		return subtype
+	}
+	return mimeType
+}
+
+// absoluteURL resolves Bing's protocol-relative ("//host/...") and site-relative ("/th?...") URLs
+func absoluteURL(url string) string {
+	switch {
+	case strings.HasPrefix(url, "//"):
+		return "https:" + url
+	case strings.HasPrefix(url, "/"):
+		return bingBaseURL + url
+	}
+	return url
 }
 
 // DownloadWallpaper downloads the actual wallpaper image data
-func (c *Client) DownloadWallpaper(info *WallpaperInfo) ([]byte, error) {
-	resp, err := c.httpClient.Get(info.URL)
+func (c *Client) DownloadWallpaper(ctx context.Context, info *WallpaperInfo) ([]byte, error) {
+	return c.download(ctx, info.URL)
+}
+
+// DownloadFrame downloads the image to analyze for a day's palette:
+// the video's representative frame on video days, otherwise the wallpaper itself
+func (c *Client) DownloadFrame(ctx context.Context, info *WallpaperInfo) ([]byte, error) {
+	if info.HasVideo() && info.VideoFrameURL != "" {
+		return c.download(ctx, info.VideoFrameURL)
+	}
+	return c.download(ctx, info.URL)
+}
+
+// DownloadImage downloads an image by URL, e.g. another size from WallpaperInfo.ImageURLs
+func (c *Client) DownloadImage(ctx context.Context, url string) ([]byte, error) {
+	return c.download(ctx, url)
+}
+
+// download fetches an image into memory
+func (c *Client) download(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to download wallpaper: %w", err)
 	}
@@ -176,14 +354,76 @@ func (c *Client) DownloadWallpaper(info *WallpaperInfo) ([]byte, error) {
 	return data, nil
 }
 
+// DownloadHeaders downloads the first n bytes of an image along with the size of the whole file (0 if unknown)
+// Uses a range request so metadata can be read without fetching multi-megabyte images in full
+func (c *Client) DownloadHeaders(ctx context.Context, url string, n int) ([]byte, int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=0-%d", n-1))
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to download image headers: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var size int64
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		// Content-Range: bytes 0-65535/3245678
+		_, total, _ := strings.Cut(resp.Header.Get("Content-Range"), "/")
+		size, _ = strconv.ParseInt(total, 10, 64)
+	case http.StatusOK:
+		// The server ignored the range and sends the whole file
+		size = max(resp.ContentLength, 0)
+	default:
+		return nil, 0, fmt.Errorf("image download returned status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, int64(n)))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read image headers: %w", err)
+	}
+
+	return data, size, nil
+}
+
+// ErrImageMissing is returned by ImageSize when Bing has no image at the URL
+var ErrImageMissing = errors.New("image does not exist")
+
+// ImageSize checks with a HEAD request that an image exists and returns its size in bytes (0 if unknown)
+// Not every resolution exists for every wallpaper; missing ones return ErrImageMissing
+func (c *Client) ImageSize(ctx context.Context, url string) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check image: %w", err)
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return max(resp.ContentLength, 0), nil
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return 0, ErrImageMissing
+	default:
+		return 0, fmt.Errorf("image check returned status %d", resp.StatusCode)
+	}
+}
+
 // GetWallpaper is a convenience method that fetches info and downloads in one call
-func (c *Client) GetWallpaper(date string) ([]byte, *WallpaperInfo, error) {
-	info, err := c.GetWallpaperInfo(date)
+func (c *Client) GetWallpaper(ctx context.Context, date string) ([]byte, *WallpaperInfo, error) {
+	info, err := c.GetWallpaperInfo(ctx, date)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	data, err := c.DownloadWallpaper(info)
+	data, err := c.DownloadWallpaper(ctx, info)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -193,7 +433,7 @@ func (c *Client) GetWallpaper(date string) ([]byte, *WallpaperInfo, error) {
 
 // GetWallpaperInfoByDaysAgo fetches metadata for the wallpaper by days ago
 // daysAgo should be 0 (today), 1 (yesterday), etc.
-func (c *Client) GetWallpaperInfoByDaysAgo(daysAgo int) (*WallpaperInfo, error) {
+func (c *Client) GetWallpaperInfoByDaysAgo(ctx context.Context, daysAgo int) (*WallpaperInfo, error) {
 	// Validate range
 	if daysAgo < 0 {
 		return nil, fmt.Errorf("daysAgo cannot be negative")
@@ -204,71 +444,26 @@ func (c *Client) GetWallpaperInfoByDaysAgo(daysAgo int) (*WallpaperInfo, error)
 		return nil, fmt.Errorf("wallpaper too old (Bing only keeps ~7 days)")
 	}
 
-	// Build API URL
-	url := fmt.Sprintf("%s?format=js&idx=%d&n=1&mkt=%s", bingAPIURL, daysAgo, c.market)
-
-	// Make request
-	resp, err := c.httpClient.Get(url)
+	images, err := c.fetchImages(ctx, daysAgo, 1)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch from Bing API: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("Bing API returned status %d", resp.StatusCode)
-	}
-
-	// Parse response
-	var apiResp bingAPIResponse
-	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
-		return nil, fmt.Errorf("failed to parse Bing API response: %w", err)
+		return nil, err
 	}
 
-	if len(apiResp.Images) == 0 {
+	if len(images) == 0 {
 		return nil, fmt.Errorf("no wallpaper found for daysAgo=%d", daysAgo)
 	}
 
-	image := apiResp.Images[0]
-
-	// Construct full URL
-	imageURL := bingBaseURL + image.URL
-	urlBase := bingBaseURL + image.URLBase
-
-	// Generate different size URLs (based on actual Bing availability)
-	imageURLs := map[string]string{
-		"UHD":       urlBase + "_UHD.jpg",       // Ultra HD (~3.2MB)
-		"1920x1200": urlBase + "_1920x1200.jpg", // Wide (~850KB)
-		"1920x1080": urlBase + "_1920x1080.jpg", // Full HD (~320KB)
-		"1366x768":  urlBase + "_1366x768.jpg",  // Laptop (~163KB)
-		"1280x720":  urlBase + "_1280x720.jpg",  // HD (~179KB)
-		"1024x768":  urlBase + "_1024x768.jpg",  // XGA (~66KB)
-		"800x600":   urlBase + "_800x600.jpg",   // SVGA (~93KB)
-	}
-
-	// Extract image ID from URLBase (e.g., "/th?id=OHR.ImageName_EN-US123456" -> "OHR.ImageName_EN-US123456")
-	imageID := extractImageID(image.URLBase)
-
-	return &WallpaperInfo{
-		URL:           imageURL,
-		ImageID:       imageID,
-		ImageURLs:     imageURLs,
-		Title:         image.Title,
-		Copyright:     image.Copyright,
-		CopyrightLink: image.CopyrightURL,
-		StartDate:     image.StartDate,
-		FullStartDate: image.FullStartDate,
-		EndDate:       image.EndDate,
-	}, nil
+	return newWallpaperInfo(images[0]), nil
 }
 
 // GetWallpaperByDaysAgo is a convenience method that fetches info and downloads by daysAgo
-func (c *Client) GetWallpaperByDaysAgo(daysAgo int) ([]byte, *WallpaperInfo, error) {
-	info, err := c.GetWallpaperInfoByDaysAgo(daysAgo)
+func (c *Client) GetWallpaperByDaysAgo(ctx context.Context, daysAgo int) ([]byte, *WallpaperInfo, error) {
+	info, err := c.GetWallpaperInfoByDaysAgo(ctx, daysAgo)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	data, err := c.DownloadWallpaper(info)
+	data, err := c.DownloadWallpaper(ctx, info)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -0,0 +1,244 @@
+package bing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestNewWallpaperInfoVideo tests that video days expose their video URLs and poster frame
+func TestNewWallpaperInfoVideo(t *testing.T) {
+	var image bingImage
+	err := json.Unmarshal([]byte(`{
+		"url": "/th?id=OHR.Fjord_EN-US123_1920x1080.jpg",
+		"urlbase": "/th?id=OHR.Fjord_EN-US123",
+		"startdate": "20251019",
+		"vid": {
+			"sources": [
+				["video/mp4", "codecs=\"avc1.42E01E\"", "//videos.example.com/fjord_1080.mp4"],
+				["video/mp4", "", "//videos.example.com/fjord_720.mp4"],
+				["application/x-mpegURL", "", "/videos/fjord.m3u8"]
+			],
+			"image": "//videos.example.com/fjord_poster.jpg"
+		}
+	}`), &image)
+	if err != nil {
+		t.Fatalf("Failed to parse image: %v", err)
+	}
+
+	info := newWallpaperInfo(image)
+	if !info.HasVideo() {
+		t.Fatal("Expected a video")
+	}
+	if got := info.VideoURLs["mp4"]; got != "https://videos.example.com/fjord_1080.mp4" {
+		t.Errorf("Expected the first mp4 source, got %q", got)
+	}
+	if got := info.VideoURLs["hls"]; got != "https://www.bing.com/videos/fjord.m3u8" {
+		t.Errorf("Expected the HLS source, got %q", got)
+	}
+	if info.VideoFrameURL != "https://videos.example.com/fjord_poster.jpg" {
+		t.Errorf("Expected the poster frame, got %q", info.VideoFrameURL)
+	}
+}
+
+// TestNewWallpaperInfoStill tests that days without a video have no video fields
+func TestNewWallpaperInfoStill(t *testing.T) {
+	info := newWallpaperInfo(bingImage{URLBase: "/th?id=OHR.Fjord_EN-US123", Video: &bingVideo{}})
+	if info.HasVideo() || info.VideoFrameURL != "" {
+		t.Errorf("Expected no video, got %v (frame %q)", info.VideoURLs, info.VideoFrameURL)
+	}
+}
+
+// TestNewWallpaperInfoPortrait tests that the portrait resolutions are listed and marked as such
+func TestNewWallpaperInfoPortrait(t *testing.T) {
+	info := newWallpaperInfo(bingImage{URLBase: "/th?id=OHR.Fjord_EN-US123"})
+	if got := info.ImageURLs["1080x1920"]; got != "https://www.bing.com/th?id=OHR.Fjord_EN-US123_1080x1920.jpg" {
+		t.Errorf("Expected the 1080x1920 URL, got %q", got)
+	}
+
+	orientations := Orientations(info.ImageURLs)
+	for resolution, want := range map[string]string{"1080x1920": Portrait, "768x1280": Portrait, "1920x1080": Landscape, "UHD": Landscape} {
+		if orientations[resolution] != want {
+			t.Errorf("Expected %s to be %s, got %q", resolution, want, orientations[resolution])
+		}
+	}
+}
+
+// TestParseAPIResponseFixtures tests parsing archive responses in the shapes different markets return
+func TestParseAPIResponseFixtures(t *testing.T) {
+	tests := []struct {
+		fixture       string
+		images        int
+		imageID       string
+		url           string
+		startDate     string
+		fullStartDate string
+		endDate       string
+		video         bool
+	}{
+		{"en-US", 2, "OHR.MartimoaapaFinland_EN-US3685817058", "https://www.bing.com/th?id=OHR.MartimoaapaFinland_EN-US3685817058_1920x1080.jpg&rf=LaDigue_1920x1080.jpg&pid=hp", "20251019", "202510190700", "20251020", false},
+		{"ja-JP", 1, "OHR.AutumnKyoto_JA-JP9021746615", "https://www.bing.com/th?id=OHR.AutumnKyoto_JA-JP9021746615_1920x1080.jpg&rf=LaDigue_1920x1080.jpg&pid=hp", "20251018", "202510181500", "20251019", false},
+		{"de-DE", 1, "OHR.Neuschwanstein_DE-DE5508621771", "https://www.bing.com/th?id=OHR.Neuschwanstein_DE-DE5508621771_1920x1080.jpg&rf=LaDigue_1920x1080.jpg&pid=hp", "20251019", "202510192200", "20251020", true},
+		// Older responses: absolute path-style url, no urlbase, fullstartdate or enddate
+		{"legacy", 1, "MartimoaapaFinland_EN-US3685817058", "https://www.bing.com/az/hprichbg/rb/MartimoaapaFinland_EN-US3685817058_1920x1080.jpg", "20191019", "", "20191020", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			file, err := os.Open(filepath.Join("testdata", tt.fixture+".json"))
+			if err != nil {
+				t.Fatalf("Failed to open fixture: %v", err)
+			}
+			defer file.Close()
+
+			images, err := parseAPIResponse(file)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(images) != tt.images {
+				t.Fatalf("Expected %d images, got %d", tt.images, len(images))
+			}
+
+			info := newWallpaperInfo(images[0])
+			if info.ImageID != tt.imageID {
+				t.Errorf("Expected image ID %q, got %q", tt.imageID, info.ImageID)
+			}
+			if info.URL != tt.url {
+				t.Errorf("Expected URL %q, got %q", tt.url, info.URL)
+			}
+			if info.StartDate != tt.startDate || info.FullStartDate != tt.fullStartDate || info.EndDate != tt.endDate {
+				t.Errorf("Expected dates %s/%s/%s, got %s/%s/%s", tt.startDate, tt.fullStartDate, tt.endDate, info.StartDate, info.FullStartDate, info.EndDate)
+			}
+			if !strings.HasSuffix(info.ImageURLs["UHD"], tt.imageID+"_UHD.jpg") {
+				t.Errorf("Expected the UHD URL to use the image ID, got %q", info.ImageURLs["UHD"])
+			}
+			if info.HasVideo() != tt.video {
+				t.Errorf("Expected video %v, got %v", tt.video, info.VideoURLs)
+			}
+		})
+	}
+}
+
+// TestNormalizeImage tests filling in fields from the ones Bing did send
+func TestNormalizeImage(t *testing.T) {
+	tests := []struct {
+		name    string
+		image   bingImage
+		want    bingImage
+		wantErr bool
+	}{
+		{
+			name:  "url only",
+			image: bingImage{URL: "/th?id=OHR.Fjord_EN-US123_UHD.jpg&pid=hp", StartDate: "20251019"},
+			want:  bingImage{URL: "/th?id=OHR.Fjord_EN-US123_UHD.jpg&pid=hp", URLBase: "/th?id=OHR.Fjord_EN-US123", StartDate: "20251019", EndDate: "20251020"},
+		},
+		{
+			name:  "urlbase only",
+			image: bingImage{URLBase: "th?id=OHR.Fjord_EN-US123", StartDate: "20251019", EndDate: "20251020"},
+			want:  bingImage{URL: "/th?id=OHR.Fjord_EN-US123_1920x1080.jpg", URLBase: "/th?id=OHR.Fjord_EN-US123", StartDate: "20251019", EndDate: "20251020"},
+		},
+		{
+			name:  "protocol-relative urlbase",
+			image: bingImage{URLBase: "//www.bing.com/th?id=OHR.Fjord_EN-US123", FullStartDate: "202512310700"},
+			want:  bingImage{URL: "/th?id=OHR.Fjord_EN-US123_1920x1080.jpg", URLBase: "/th?id=OHR.Fjord_EN-US123", StartDate: "20251231", FullStartDate: "202512310700", EndDate: "20260101"},
+		},
+		{
+			name:  "mismatched fullstartdate",
+			image: bingImage{URLBase: "/th?id=OHR.Fjord_EN-US123", StartDate: "20251019", FullStartDate: "202510180700", EndDate: "soon"},
+			want:  bingImage{URL: "/th?id=OHR.Fjord_EN-US123_1920x1080.jpg", URLBase: "/th?id=OHR.Fjord_EN-US123", StartDate: "20251019", EndDate: "20251020"},
+		},
+		{
+			name:    "no image",
+			image:   bingImage{StartDate: "20251019"},
+			wantErr: true,
+		},
+		{
+			name:    "no date",
+			image:   bingImage{URLBase: "/th?id=OHR.Fjord_EN-US123"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			image := tt.image
+			err := image.normalize()
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected an error, got %+v", image)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if image != tt.want {
+				t.Errorf("Expected %+v, got %+v", tt.want, image)
+			}
+		})
+	}
+}
+
+// TestParseAPIResponseMissingImages tests that a response without images parses to none
+func TestParseAPIResponseMissingImages(t *testing.T) {
+	images, err := parseAPIResponse(strings.NewReader(`{"tooltips": {"loading": "Loading..."}}`))
+	if err != nil || len(images) != 0 {
+		t.Errorf("Expected no images and no error, got %d images and %v", len(images), err)
+	}
+}
+
+// TestDownloadImageContext tests that downloads stop when their context is cancelled
+func TestDownloadImageContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("image"))
+	}))
+	defer server.Close()
+
+	client := NewClient("en-US")
+	if data, err := client.DownloadImage(context.Background(), server.URL); err != nil || string(data) != "image" {
+		t.Fatalf("Expected the image, got %q (%v)", data, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.DownloadImage(ctx, server.URL); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+// TestImageSize tests that existing images report their size and missing ones ErrImageMissing
+func TestImageSize(t *testing.T) {
+	t.Setenv("BING_RETRY_BACKOFF", "0s")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			t.Errorf("Expected HEAD, got %s", r.Method)
+		}
+		switch r.URL.Path {
+		case "/OHR.Example_UHD.jpg":
+			w.Header().Set("Content-Length", "3245678")
+		case "/OHR.Example_1920x1200.jpg":
+			http.NotFound(w, r)
+		default:
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient("en-US")
+	if size, err := client.ImageSize(context.Background(), server.URL+"/OHR.Example_UHD.jpg"); err != nil || size != 3245678 {
+		t.Errorf("Expected 3245678 bytes, got %d (%v)", size, err)
+	}
+	if _, err := client.ImageSize(context.Background(), server.URL+"/OHR.Example_1920x1200.jpg"); !errors.Is(err, ErrImageMissing) {
+		t.Errorf("Expected ErrImageMissing, got %v", err)
+	}
+	if _, err := client.ImageSize(context.Background(), server.URL+"/OHR.Example_800x600.jpg"); err == nil || errors.Is(err, ErrImageMissing) {
+		t.Errorf("Expected a server error not to count as missing, got %v", err)
+	}
+}
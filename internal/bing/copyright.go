@@ -0,0 +1,45 @@
+package bing
+
+import "strings"
+
+// Credit is the attribution part of a Bing copyright string, split into its fields
+type Credit struct {
+	Description  string // What the photo shows, without the credit
+	Holder       string // Everything after the © sign (e.g., "romikatarina/Shutterstock")
+	Photographer string // Empty when the credit names only an agency or institution
+	Agency       string
+}
+
+// ParseCopyright splits a copyright string such as
+// "Aerial view of peatland in Martimoaapa Mire Reserve, Finland (© romikatarina/Shutterstock)"
+// into the description and the photographer and agency credited for it
+// Handles the variations between locales: full-width brackets and slashes (zh-CN, ja-JP),
+// non-breaking spaces after © (fr-FR) and text following the credit
+func ParseCopyright(copyright string) Credit {
+	copyright = strings.TrimSpace(fullWidthReplacer.Replace(copyright))
+
+	idx := strings.LastIndex(copyright, "©")
+	if idx < 0 {
+		return Credit{Description: copyright}
+	}
+
+	holder, _, _ := strings.Cut(copyright[idx+len("©"):], ")")
+	credit := Credit{
+		Description: strings.TrimSpace(strings.TrimRight(strings.TrimSpace(copyright[:idx]), "(")),
+		Holder:      strings.TrimSpace(holder),
+	}
+
+	// Photographer names don't contain slashes, agencies sometimes do ("Offset/Shutterstock")
+	if photographer, agency, ok := strings.Cut(credit.Holder, "/"); ok {
+		credit.Photographer = strings.TrimSpace(photographer)
+		credit.Agency = strings.TrimSpace(agency)
+		credit.Holder = credit.Photographer + "/" + credit.Agency
+	} else {
+		credit.Agency = credit.Holder
+	}
+
+	return credit
+}
+
+// fullWidthReplacer maps the full-width punctuation used by CJK locales to its ASCII form
+var fullWidthReplacer = strings.NewReplacer("（", "(", "）", ")", "／", "/")
@@ -0,0 +1,56 @@
+package bing
+
+import "testing"
+
+// TestParseCopyright tests splitting copyright strings into description, photographer and agency
+func TestParseCopyright(t *testing.T) {
+	tests := []struct {
+		copyright string
+		want      Credit
+	}{
+		{
+			"Aerial view of peatland in Martimoaapa Mire Reserve, Finland (© romikatarina/Shutterstock)",
+			Credit{"Aerial view of peatland in Martimoaapa Mire Reserve, Finland", "romikatarina/Shutterstock", "romikatarina", "Shutterstock"},
+		},
+		{
+			"Lofoten Islands, Norway (© Amazing Aerial Agency/Offset/Shutterstock)",
+			Credit{"Lofoten Islands, Norway", "Amazing Aerial Agency/Offset/Shutterstock", "Amazing Aerial Agency", "Offset/Shutterstock"},
+		},
+		{
+			"Pillars of Creation (© NASA)",
+			Credit{"Pillars of Creation", "NASA", "", "NASA"},
+		},
+		{
+			"Moorlandschaft im Naturschutzgebiet Martimoaapa, Finnland (© romikatarina/Shutterstock)",
+			Credit{"Moorlandschaft im Naturschutzgebiet Martimoaapa, Finnland", "romikatarina/Shutterstock", "romikatarina", "Shutterstock"},
+		},
+		{
+			"Vue aérienne de la réserve naturelle de Martimoaapa, Finlande (©\u00a0romikatarina/Shutterstock)",
+			Credit{"Vue aérienne de la réserve naturelle de Martimoaapa, Finlande", "romikatarina/Shutterstock", "romikatarina", "Shutterstock"},
+		},
+		{
+			"マルティモアーパ湿原保護区, フィンランド (© romikatarina/Shutterstock)",
+			Credit{"マルティモアーパ湿原保護区, フィンランド", "romikatarina/Shutterstock", "romikatarina", "Shutterstock"},
+		},
+		{
+			"马尔蒂莫阿帕沼泽保护区鸟瞰图，芬兰 （© romikatarina／Shutterstock）",
+			Credit{"马尔蒂莫阿帕沼泽保护区鸟瞰图，芬兰", "romikatarina/Shutterstock", "romikatarina", "Shutterstock"},
+		},
+		{
+			"Lake Bled, Slovenia (©Jan Wlodarczyk / Alamy) - Bing Gallery",
+			Credit{"Lake Bled, Slovenia", "Jan Wlodarczyk/Alamy", "Jan Wlodarczyk", "Alamy"},
+		},
+		{
+			"No credit given",
+			Credit{Description: "No credit given"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want.Holder, func(t *testing.T) {
+			if got := ParseCopyright(tt.copyright); got != tt.want {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
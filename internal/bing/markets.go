@@ -0,0 +1,79 @@
+package bing
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RestOfWorld is Bing's market for regions without a localized wallpaper feed
+const RestOfWorld = "ROW"
+
+// Markets is the canonical list of markets Bing serves wallpapers for
+// Markets are grouped by language with the primary market for each language first
+var Markets = []string{
+	"en-US", "en-GB", "en-AU", "en-CA", "en-IN", "en-ID", "en-MY", "en-NZ", "en-PH", "en-ZA",
+	"de-DE", "de-AT", "de-CH",
+	"fr-FR", "fr-BE", "fr-CA", "fr-CH",
+	"es-ES", "es-AR", "es-CL", "es-MX", "es-US",
+	"pt-BR",
+	"it-IT",
+	"nl-NL", "nl-BE",
+	"ja-JP",
+	"ko-KR",
+	"zh-CN", "zh-HK", "zh-TW",
+	"ru-RU",
+	"pl-PL",
+	"tr-TR",
+	"sv-SE",
+	"da-DK",
+	"fi-FI",
+	"nb-NO",
+	RestOfWorld,
+}
+
+// languageAliases maps legacy language codes to the one used in Markets
+var languageAliases = map[string]string{
+	"no": "nb",
+}
+
+// ResolveMarket returns the canonical market for a locale code, matched case-insensitively
+// Region-less codes ("de") and unlisted regions of a known language ("de-LU") fall back to
+// that language's primary market; exact reports whether no fallback was needed
+func ResolveMarket(code string) (market string, exact bool, err error) {
+	for _, m := range Markets {
+		if strings.EqualFold(code, m) {
+			return m, true, nil
+		}
+	}
+
+	language, region, hasRegion := strings.Cut(code, "-")
+	if !isLetters(language, 2, 3) || (hasRegion && !isLetters(region, 2, 2)) {
+		return "", false, fmt.Errorf("malformed market %q", code)
+	}
+
+	language = strings.ToLower(language)
+	if alias, ok := languageAliases[language]; ok {
+		language = alias
+	}
+
+	for _, m := range Markets {
+		if strings.HasPrefix(m, language+"-") {
+			return m, false, nil
+		}
+	}
+
+	return "", false, fmt.Errorf("unsupported market %q", code)
+}
+
+// isLetters reports whether s consists of between min and max ASCII letters
+func isLetters(s string, minLen, maxLen int) bool {
+	if len(s) < minLen || len(s) > maxLen {
+		return false
+	}
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,41 @@
+package bing
+
+import "testing"
+
+// TestResolveMarket tests canonical matching and the fallbacks for region-less and unlisted markets
+func TestResolveMarket(t *testing.T) {
+	tests := []struct {
+		code   string
+		market string
+		exact  bool
+	}{
+		{"en-US", "en-US", true},
+		{"de-ch", "de-CH", true},
+		{"row", "ROW", true},
+		{"de", "de-DE", false},
+		{"de-LU", "de-DE", false},
+		{"pt-PT", "pt-BR", false},
+		{"no-NO", "nb-NO", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			market, exact, err := ResolveMarket(tt.code)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if market != tt.market || exact != tt.exact {
+				t.Errorf("Expected %s (exact %v), got %s (exact %v)", tt.market, tt.exact, market, exact)
+			}
+		})
+	}
+}
+
+// TestResolveMarket_Invalid tests that malformed codes and unknown languages are rejected
+func TestResolveMarket_Invalid(t *testing.T) {
+	for _, code := range []string{"", "invalid", "en_US", "xx-XX", "en-USA", "e1-US"} {
+		if _, _, err := ResolveMarket(code); err == nil {
+			t.Errorf("Expected error for %q", code)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package bing
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// defaultMetadataTTL is how long archive entries are reused; short, as Bing occasionally corrects a day's entry
+const defaultMetadataTTL = 10 * time.Minute
+
+// MetadataTTL returns how long archive API entries are cached from BING_METADATA_TTL (0 disables the cache)
+func MetadataTTL() time.Duration {
+	value := os.Getenv("BING_METADATA_TTL")
+	if value == "" {
+		return defaultMetadataTTL
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl < 0 {
+		logger().Info("Ignoring invalid BING_METADATA_TTL", "value", value)
+		return defaultMetadataTTL
+	}
+	return ttl
+}
+
+// metadataKey identifies a wallpaper as the archive API addresses it: by market and days ago
+type metadataKey struct {
+	market  string
+	daysAgo int
+}
+
+// metadataEntry is a cached archive entry
+type metadataEntry struct {
+	image     bingImage
+	expiresAt time.Time
+}
+
+// metadataCache keeps archive API entries in memory, separately from the request and analysis caches,
+// so palettes that miss the request cache do not fetch the same metadata from Bing again
+// An entry expires after the TTL, or when the market rolls over and its daysAgo points at another wallpaper
+type metadataCache struct {
+	mu      sync.Mutex
+	entries map[metadataKey]metadataEntry
+	now     func() time.Time
+}
+
+// newMetadataCache creates an empty metadata cache
+func newMetadataCache() *metadataCache {
+	return &metadataCache{entries: make(map[metadataKey]metadataEntry), now: time.Now}
+}
+
+// get returns the n entries starting daysAgo days ago, if all of them are cached
+func (c *metadataCache) get(market string, daysAgo, n int) ([]bingImage, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	images := make([]bingImage, 0, n)
+	for i := daysAgo; i < daysAgo+n; i++ {
+		entry, ok := c.entries[metadataKey{market, i}]
+		if !ok || !now.Before(entry.expiresAt) {
+			return nil, false
+		}
+		images = append(images, entry.image)
+	}
+	return images, true
+}
+
+// put caches the entries of an archive response that started daysAgo days ago
+func (c *metadataCache) put(market string, daysAgo int, images []bingImage, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	for key, entry := range c.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(c.entries, key)
+		}
+	}
+
+	for i, image := range images {
+		expiresAt := now.Add(ttl)
+		// The entry i+daysAgo days ago moves on at the (i+daysAgo+1)th rollover after it started
+		if start := imageStart(image); !start.IsZero() {
+			rollover := start.Add(time.Duration(daysAgo+i+1) * 24 * time.Hour)
+			if rollover.Before(expiresAt) {
+				expiresAt = rollover
+			}
+		}
+		c.entries[metadataKey{market, daysAgo + i}] = metadataEntry{image: image, expiresAt: expiresAt}
+	}
+}
+
+// imageStart returns when an archive entry became its market's wallpaper, or midnight UTC of its start date
+func imageStart(image bingImage) time.Time {
+	if start, err := time.Parse(fullDateLayout, image.FullStartDate); err == nil {
+		return start
+	}
+	if start, err := time.Parse(dateLayout, image.StartDate); err == nil {
+		return start
+	}
+	return time.Time{}
+}
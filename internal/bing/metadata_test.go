@@ -0,0 +1,78 @@
+package bing
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// roundTripFunc adapts a function to http.RoundTripper
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// TestFetchImagesMetadataCache tests that archive entries are reused per market and day until the TTL or rollover
+func TestFetchImagesMetadataCache(t *testing.T) {
+	var fetches atomic.Int32
+	client := NewClient("en-US")
+	client.httpClient = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		fetches.Add(1)
+		body, err := os.Open("testdata/en-US.json")
+		if err != nil {
+			return nil, err
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: body, Header: make(http.Header)}, nil
+	})}
+
+	// Between the fixture's newest wallpaper starting and the next rollover at 2025-10-20 07:00 UTC
+	now := time.Date(2025, 10, 19, 12, 0, 0, 0, time.UTC)
+	client.metadata.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if _, err := client.GetRecentWallpaperInfos(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	info, err := client.GetWallpaperInfoByDaysAgo(ctx, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if info.StartDate != "20251019" {
+		t.Errorf("Expected the cached entry for today, got %s", info.StartDate)
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("Expected the archive to be fetched once, got %d", n)
+	}
+
+	if _, err := client.WithLocale("de-DE").GetWallpaperInfoByDaysAgo(ctx, 0); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("Expected another market to be fetched separately, got %d fetches", n)
+	}
+
+	// Within the TTL, but after the rollover: daysAgo=0 is another wallpaper now
+	now = time.Date(2025, 10, 20, 7, 5, 0, 0, time.UTC)
+	if _, err := client.GetWallpaperInfoByDaysAgo(ctx, 0); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if n := fetches.Load(); n != 3 {
+		t.Errorf("Expected the rollover to expire the entry, got %d fetches", n)
+	}
+
+	t.Setenv("BING_METADATA_TTL", "0s")
+	now = now.Add(time.Minute)
+	client.metadata = newMetadataCache()
+	for range 2 {
+		if _, err := client.GetWallpaperInfoByDaysAgo(ctx, 1); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if n := fetches.Load(); n != 5 {
+		t.Errorf("Expected BING_METADATA_TTL=0s to disable the cache, got %d fetches", n)
+	}
+}
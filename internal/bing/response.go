@@ -0,0 +1,115 @@
+package bing
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Date layouts of Bing's startdate/enddate and fullstartdate fields
+const (
+	dateLayout     = "20060102"
+	fullDateLayout = "200601021504"
+)
+
+// imageSizeSuffix matches the size Bing appends to urlbase to form url, e.g. "_1920x1080.jpg" or "_UHD.jpg"
+var imageSizeSuffix = regexp.MustCompile(`_(UHD|\d+x\d+)\.(jpg|jpeg|webp)$`)
+
+// parseAPIResponse decodes an archive response and normalizes every image entry
+// Unknown fields are ignored; an entry without an image URL or date fails the whole response,
+// since the caller relies on entry i being the wallpaper from i days ago
+func parseAPIResponse(r io.Reader) ([]bingImage, error) {
+	var apiResp bingAPIResponse
+	if err := json.NewDecoder(r).Decode(&apiResp); err != nil {
+		return nil, fmt.Errorf("failed to parse Bing API response: %w", err)
+	}
+
+	for i := range apiResp.Images {
+		if err := apiResp.Images[i].normalize(); err != nil {
+			return nil, fmt.Errorf("unusable image %d in Bing API response: %w", i, err)
+		}
+	}
+	return apiResp.Images, nil
+}
+
+// normalize fills in fields some markets or response versions leave out, from the fields Bing did send
+// url and urlbase may be site-relative ("/th?id=..."), bare ("th?id=..."), protocol-relative or absolute,
+// and either can be derived from the other
+func (image *bingImage) normalize() error {
+	image.URL = siteRelative(image.URL)
+	image.URLBase = siteRelative(image.URLBase)
+	switch {
+	case image.URL == "" && image.URLBase == "":
+		return errors.New("no url or urlbase")
+	case image.URLBase == "":
+		image.URLBase = urlBaseFromURL(image.URL)
+	case image.URL == "":
+		image.URL = image.URLBase + "_1920x1080.jpg"
+	}
+
+	if image.StartDate == "" && len(image.FullStartDate) >= len(dateLayout) {
+		image.StartDate = image.FullStartDate[:len(dateLayout)]
+	}
+	start, err := time.Parse(dateLayout, image.StartDate)
+	if err != nil {
+		return fmt.Errorf("invalid startdate %q", image.StartDate)
+	}
+
+	if _, err := time.Parse(fullDateLayout, image.FullStartDate); err != nil || !strings.HasPrefix(image.FullStartDate, image.StartDate) {
+		image.FullStartDate = ""
+	}
+	if _, err := time.Parse(dateLayout, image.EndDate); err != nil {
+		image.EndDate = start.AddDate(0, 0, 1).Format(dateLayout)
+	}
+	return nil
+}
+
+// siteRelative turns the URL forms Bing uses for its own images into site-relative URLs
+// URLs on other hosts are left absolute
+func siteRelative(rawURL string) string {
+	rawURL = strings.TrimSpace(rawURL)
+	switch {
+	case rawURL == "":
+		return ""
+	case strings.HasPrefix(rawURL, "//"):
+		rawURL = "https:" + rawURL
+	case !strings.Contains(rawURL, "://") && !strings.HasPrefix(rawURL, "/"):
+		return "/" + rawURL
+	}
+
+	parsed, err := url.Parse(rawURL)
+	if err != nil || !strings.HasSuffix(parsed.Host, "bing.com") {
+		return rawURL
+	}
+	return parsed.RequestURI()
+}
+
+// urlBaseFromURL strips the size and extra query parameters from an image URL
+// Example: "/th?id=OHR.Fjord_EN-US123_1920x1080.jpg&rf=LaDigue_1920x1080.jpg&pid=hp" -> "/th?id=OHR.Fjord_EN-US123"
+func urlBaseFromURL(imageURL string) string {
+	base, _, _ := strings.Cut(imageURL, "&")
+	return imageSizeSuffix.ReplaceAllString(base, "")
+}
+
+// extractImageID extracts the image ID from the URLBase
+// Example: "/th?id=OHR.MartimoaapaFinland_EN-US3685817058" -> "OHR.MartimoaapaFinland_EN-US3685817058"
+// Older responses use a path instead: "/az/hprichbg/rb/MartimoaapaFinland_EN-US3685817058"
+func extractImageID(urlBase string) string {
+	parsed, err := url.Parse(urlBase)
+	if err != nil {
+		return urlBase
+	}
+	if id := parsed.Query().Get("id"); id != "" {
+		return id
+	}
+	if name := path.Base(parsed.Path); name != "/" && name != "." {
+		return name
+	}
+	return urlBase
+}
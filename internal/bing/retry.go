@@ -0,0 +1,129 @@
+package bing
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	// defaultRetries is how many times a failed GET or HEAD is tried again
+	defaultRetries = 2
+
+	// defaultRetryBackoff is the wait before the first retry; it doubles with each one after
+	defaultRetryBackoff = 500 * time.Millisecond
+
+	// maxRetries keeps a misconfigured BING_RETRIES from hammering Bing
+	maxRetries = 10
+)
+
+// retrier retries idempotent requests that fail on the network or with a 5xx status
+// Every attempt goes through base, so retries are paced by the scheduler like any other request
+type retrier struct {
+	base http.RoundTripper
+}
+
+// sharedTransport is the transport of every Client: retries on top of the shared scheduler
+var sharedTransport = &retrier{base: sharedScheduler}
+
+// Timeout returns the limit on one Bing request from BING_TIMEOUT, retries and scheduling included
+// It is read when a client is created, so changes apply after a restart
+func Timeout() time.Duration {
+	value := os.Getenv("BING_TIMEOUT")
+	if value == "" {
+		return defaultHTTPTimeout
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		logger().Info("Ignoring invalid BING_TIMEOUT", "value", value)
+		return defaultHTTPTimeout
+	}
+	return timeout
+}
+
+// Retries returns how many times a failed request is retried from BING_RETRIES (0 disables retries)
+func Retries() int {
+	value := os.Getenv("BING_RETRIES")
+	if value == "" {
+		return defaultRetries
+	}
+	retries, err := strconv.Atoi(value)
+	if err != nil || retries < 0 || retries > maxRetries {
+		logger().Info("Ignoring invalid BING_RETRIES", "value", value, "max", maxRetries)
+		return defaultRetries
+	}
+	return retries
+}
+
+// RetryBackoff returns the wait before the first retry from BING_RETRY_BACKOFF
+func RetryBackoff() time.Duration {
+	value := os.Getenv("BING_RETRY_BACKOFF")
+	if value == "" {
+		return defaultRetryBackoff
+	}
+	backoff, err := time.ParseDuration(value)
+	if err != nil || backoff < 0 {
+		logger().Info("Ignoring invalid BING_RETRY_BACKOFF", "value", value)
+		return defaultRetryBackoff
+	}
+	return backoff
+}
+
+// RoundTrip sends the request, retrying GETs and HEADs with exponential backoff while they fail transiently
+// The last attempt's response or error is returned as is
+func (r *retrier) RoundTrip(req *http.Request) (*http.Response, error) {
+	retries := Retries()
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		retries = 0
+	}
+	backoff := RetryBackoff()
+
+	for attempt := 0; ; attempt++ {
+		resp, err := r.base.RoundTrip(req)
+		if attempt >= retries || !transient(req.Context(), resp, err) {
+			return resp, err
+		}
+
+		if err != nil {
+			logger().Info("Retrying Bing request", "url", req.URL.Redacted(), "attempt", attempt+1, "error", err)
+		} else {
+			logger().Info("Retrying Bing request", "url", req.URL.Redacted(), "attempt", attempt+1, "status", resp.StatusCode)
+			// Drain a little of the body so the connection can be reused
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+		}
+
+		if err := sleep(req.Context(), backoff<<attempt); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// transient reports whether a failed attempt may succeed when tried again:
+// network errors, unless the caller gave up, and server errors
+func transient(ctx context.Context, resp *http.Response, err error) bool {
+	if err != nil {
+		return ctx.Err() == nil
+	}
+	return resp.StatusCode >= 500
+}
+
+// sleep waits for d, or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
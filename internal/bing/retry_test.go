@@ -0,0 +1,78 @@
+package bing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// TestRetrier tests that GETs and HEADs are retried on 5xx until they succeed or run out of retries
+func TestRetrier(t *testing.T) {
+	t.Setenv("BING_MIN_INTERVAL", "0s")
+	t.Setenv("BING_RETRY_BACKOFF", "0s")
+
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := attempts.Add(1)
+		switch {
+		case strings.HasSuffix(r.URL.Path, "missing.jpg"):
+			http.NotFound(w, r)
+		case strings.HasSuffix(r.URL.Path, "flaky.jpg") && n < 3:
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		case strings.HasSuffix(r.URL.Path, "flaky.jpg"):
+			w.Header().Set("Content-Length", "320000")
+		default:
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient("en-US")
+	ctx := context.Background()
+
+	size, err := client.ImageSize(ctx, server.URL+"/flaky.jpg")
+	if err != nil || size != 320000 {
+		t.Errorf("Expected the third attempt to succeed, got %d, %v", size, err)
+	}
+	if n := attempts.Swap(0); n != 3 {
+		t.Errorf("Expected 3 attempts, got %d", n)
+	}
+
+	if _, err := client.ImageSize(ctx, server.URL+"/missing.jpg"); err != ErrImageMissing {
+		t.Errorf("Expected ErrImageMissing, got %v", err)
+	}
+	if n := attempts.Swap(0); n != 1 {
+		t.Errorf("Expected a 404 not to be retried, got %d attempts", n)
+	}
+
+	t.Setenv("BING_RETRIES", "1")
+	if _, err := client.ImageSize(ctx, server.URL+"/down.jpg"); err == nil {
+		t.Error("Expected an error once retries run out")
+	}
+	if n := attempts.Swap(0); n != 2 {
+		t.Errorf("Expected 2 attempts with BING_RETRIES=1, got %d", n)
+	}
+
+	resp, err := client.httpClient.Post(server.URL+"/down.jpg", "text/plain", strings.NewReader("body"))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if n := attempts.Swap(0); n != 1 {
+		t.Errorf("Expected a POST not to be retried, got %d attempts", n)
+	}
+}
+
+// TestRetrySettings tests that invalid settings fall back to the defaults
+func TestRetrySettings(t *testing.T) {
+	t.Setenv("BING_TIMEOUT", "-1s")
+	t.Setenv("BING_RETRIES", "100")
+	t.Setenv("BING_RETRY_BACKOFF", "soon")
+
+	if Timeout() != defaultHTTPTimeout || Retries() != defaultRetries || RetryBackoff() != defaultRetryBackoff {
+		t.Errorf("Expected defaults, got %v, %d, %v", Timeout(), Retries(), RetryBackoff())
+	}
+}
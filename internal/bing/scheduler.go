@@ -0,0 +1,97 @@
+package bing
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mgabor3141/dailyhues/internal/version"
+)
+
+// defaultMinInterval spaces out requests to Bing from the whole process
+const defaultMinInterval = 250 * time.Millisecond
+
+// scheduler is the transport shared by every Client, so all outbound Bing traffic
+// (archive lookups and image downloads) is labeled with a User-Agent and paced globally
+type scheduler struct {
+	base http.RoundTripper
+	mu   sync.Mutex
+	next time.Time // Earliest time the next request may start
+}
+
+// sharedScheduler paces the requests of all clients in the process together
+var sharedScheduler = &scheduler{base: http.DefaultTransport}
+
+// UserAgent returns the User-Agent sent to Bing: BING_USER_AGENT, or one naming the instance
+// Self-hosted deployments are identified by BASE_URL instead of the upstream project
+func UserAgent() string {
+	if userAgent := os.Getenv("BING_USER_AGENT"); userAgent != "" {
+		return userAgent
+	}
+	contact := os.Getenv("BASE_URL")
+	if contact == "" {
+		contact = "https://github.com/mgabor3141/dailyhues"
+	}
+	return "dailyhues/" + version.Get().Version + " (+" + contact + ")"
+}
+
+// MinInterval returns the minimum time between the starts of two Bing requests from BING_MIN_INTERVAL
+func MinInterval() time.Duration {
+	value := os.Getenv("BING_MIN_INTERVAL")
+	if value == "" {
+		return defaultMinInterval
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil || interval < 0 {
+		logger().Info("Ignoring invalid BING_MIN_INTERVAL", "value", value)
+		return defaultMinInterval
+	}
+	return interval
+}
+
+// RoundTrip waits for the next free slot, then sends the request with the User-Agent set
+func (s *scheduler) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := s.wait(req.Context(), MinInterval()); err != nil {
+		return nil, err
+	}
+
+	// A RoundTripper must not modify the caller's request
+	req = req.Clone(req.Context())
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", UserAgent())
+	}
+	return s.base.RoundTrip(req)
+}
+
+// wait blocks until the request's reserved slot, or until ctx is done
+func (s *scheduler) wait(ctx context.Context, interval time.Duration) error {
+	delay := s.reserve(time.Now(), interval)
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// reserve claims the next slot at least interval after the previous one, returning how long until it starts
+func (s *scheduler) reserve(now time.Time, interval time.Duration) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	start := now
+	if s.next.After(now) {
+		start = s.next
+	}
+	s.next = start.Add(interval)
+	return start.Sub(now)
+}
@@ -0,0 +1,57 @@
+package bing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestSchedulerReserve tests that slots are spaced by the minimum interval
+func TestSchedulerReserve(t *testing.T) {
+	s := &scheduler{}
+	now := time.Date(2025, 10, 19, 12, 0, 0, 0, time.UTC)
+
+	delays := []time.Duration{
+		s.reserve(now, time.Second),
+		s.reserve(now, time.Second),
+		s.reserve(now.Add(500*time.Millisecond), time.Second),
+		s.reserve(now.Add(10*time.Second), time.Second),
+	}
+	want := []time.Duration{0, time.Second, 1500 * time.Millisecond, 0}
+
+	for i := range want {
+		if delays[i] != want[i] {
+			t.Errorf("reserve %d delay = %v, want %v", i, delays[i], want[i])
+		}
+	}
+}
+
+// TestSchedulerUserAgent tests that requests are labeled with the configured User-Agent
+func TestSchedulerUserAgent(t *testing.T) {
+	t.Setenv("BING_MIN_INTERVAL", "0s")
+
+	var got string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("User-Agent")
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &scheduler{base: http.DefaultTransport}}
+
+	t.Setenv("BASE_URL", "https://hues.example.com")
+	if _, err := client.Get(server.URL); err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if got != "dailyhues/dev (+https://hues.example.com)" {
+		t.Errorf("Expected default User-Agent naming the instance, got %q", got)
+	}
+
+	t.Setenv("BING_USER_AGENT", "my-instance/1.0")
+	if _, err := client.Get(server.URL); err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if got != "my-instance/1.0" {
+		t.Errorf("Expected configured User-Agent, got %q", got)
+	}
+}
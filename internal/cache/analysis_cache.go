@@ -6,14 +6,89 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"time"
+
+	"github.com/mgabor3141/dailyhues/internal/logging"
 )
 
+// logger returns the logger for this package's messages
+func logger() *slog.Logger {
+	return logging.Package("cache")
+}
+
 // AnalysisEntry stores AI analysis results for a wallpaper image
 type AnalysisEntry struct {
-	ImageHash string                 `json:"image_hash"`
-	Colors    map[string]interface{} `json:"colors"`
+	ImageHash        string                   `json:"image_hash"` // Analysis key: the image hash, plus the prompt addendum's or profile prompt's hash if one was used
+	PromptAddendum   string                   `json:"prompt_addendum,omitempty"`
+	Prompt           string                   `json:"prompt,omitempty"` // The whole prompt of a prompt profile's analysis, in place of the default one
+	Colors           map[string]interface{}   `json:"colors"`
+	Model            string                   `json:"model,omitempty"`             // Empty for entries analyzed before usage was recorded
+	PromptVersion    int                      `json:"prompt_version,omitempty"`    // ai.PromptVersion at analysis time; 0 for older entries
+	Source           string                   `json:"source,omitempty"`            // "image", or "video_frame" on video days
+	SourceResolution string                   `json:"source_resolution,omitempty"` // Dimensions of the downloaded image before downscaling
+	AnalyzedSize     string                   `json:"analyzed_size,omitempty"`     // Bing resolution analyzed in place of the hashed image (ANALYSIS_IMAGE_SIZE)
+	PromptTokens     int                      `json:"prompt_tokens,omitempty"`
+	CompletionTokens int                      `json:"completion_tokens,omitempty"`
+	Cost             float64                  `json:"cost,omitempty"`              // OpenRouter credits (USD)
+	ResizeDivergence float64                  `json:"resize_divergence,omitempty"` // Set when RESIZE_CHECK compared the analyzed image with the UHD original
+	Regions          map[string]string        `json:"regions,omitempty"`           // Average colors of the top, bottom and center of the image
+	AnalyzedAt       time.Time                `json:"analyzed_at"`                 // Falls back to the file's modification time for older entries
+	Pin              *Pin                     `json:"pin,omitempty"`               // Set when an operator replaced the AI palette
+	Palettes         map[string]*PaletteEntry `json:"palettes,omitempty"`          // Further kinds of palette analyzed for the image, by kind
+	Reanalysis       *ReanalysisFailure       `json:"reanalysis,omitempty"`        // Set while re-analyzing the image keeps failing
+}
+
+// PaletteKindFull is the complete semantic palette of an image, analyzed on demand
+const PaletteKindFull = "full"
+
+// PaletteEntry is another kind of palette for an image than the gradient in Colors, from an analysis of its own
+type PaletteEntry struct {
+	Colors           map[string]interface{} `json:"colors"`
+	Model            string                 `json:"model,omitempty"`
+	PromptVersion    int                    `json:"prompt_version,omitempty"`
+	PromptTokens     int                    `json:"prompt_tokens,omitempty"`
+	CompletionTokens int                    `json:"completion_tokens,omitempty"`
+	Cost             float64                `json:"cost,omitempty"`
+	AnalyzedAt       time.Time              `json:"analyzed_at"`
+}
+
+// WithPalette returns a copy of the entry with a palette of another kind added or replaced
+// The cached entry is left unchanged, since readers hold on to it without locking
+func (e AnalysisEntry) WithPalette(kind string, palette PaletteEntry) AnalysisEntry {
+	palettes := make(map[string]*PaletteEntry, len(e.Palettes)+1)
+	for k, v := range e.Palettes {
+		palettes[k] = v
+	}
+	palettes[kind] = &palette
+	e.Palettes = palettes
+	return e
+}
+
+// Pin records who replaced an analysis' colors by hand, and what the AI had returned
+type Pin struct {
+	By       string                 `json:"by"` // Admin subject that set the pin
+	Reason   string                 `json:"reason,omitempty"`
+	At       time.Time              `json:"at"`
+	AIColors map[string]interface{} `json:"ai_colors,omitempty"` // Restored when the pin is removed; empty if the image was never analyzed
+}
+
+// ReanalysisFailure records failed attempts to re-analyze an entry, so they can be retried with a backoff
+// A successful re-analysis replaces the entry, clearing it
+type ReanalysisFailure struct {
+	At       time.Time `json:"at"` // Last failed attempt
+	Failures int       `json:"failures"`
+	Error    string    `json:"error"`
+}
+
+// PinnedAt returns when the entry's colors were pinned, or the zero time for AI results
+func (e *AnalysisEntry) PinnedAt() time.Time {
+	if e.Pin == nil {
+		return time.Time{}
+	}
+	return e.Pin.At
 }
 
 // AnalysisCache manages AI analysis results cache
@@ -27,7 +102,20 @@ type AnalysisCache struct {
 
 // NewAnalysisCache creates a new analysis cache
 func NewAnalysisCache(cacheDir string) (*AnalysisCache, error) {
-	dir := filepath.Join(cacheDir, "analysis")
+	return newAnalysisCacheIn(filepath.Join(cacheDir, "analysis"))
+}
+
+// NewProfileAnalysisCache creates the analysis cache of a named prompt profile, stored under analysis/profiles/<profile>
+// Profiles ask for different palettes of the same images, so their entries must not replace each other
+func NewProfileAnalysisCache(cacheDir, profile string) (*AnalysisCache, error) {
+	if !namePattern.MatchString(profile) {
+		return nil, fmt.Errorf("invalid profile %q", profile)
+	}
+	return newAnalysisCacheIn(filepath.Join(cacheDir, "analysis", "profiles", profile))
+}
+
+// newAnalysisCacheIn creates an analysis cache stored in dir
+func newAnalysisCacheIn(dir string) (*AnalysisCache, error) {
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create analysis cache directory: %w", err)
 	}
@@ -47,22 +135,109 @@ func (c *AnalysisCache) Get(imageHash string) *AnalysisEntry {
 	return c.data[imageHash]
 }
 
+// Len returns the number of cached analyses
+func (c *AnalysisCache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return len(c.data)
+}
+
+// Entries returns a copy of every cached analysis
+func (c *AnalysisCache) Entries() []AnalysisEntry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entries := make([]AnalysisEntry, 0, len(c.data))
+	for _, entry := range c.data {
+		entries = append(entries, *entry)
+	}
+	return entries
+}
+
 // Set stores an analysis entry and persists to disk
 func (c *AnalysisCache) Set(imageHash string, colors map[string]interface{}) error {
+	return c.SetEntry(AnalysisEntry{
+		ImageHash: imageHash,
+		Colors:    colors,
+	})
+}
+
+// SetEntry stores a fully populated analysis entry and persists to disk
+// AnalyzedAt defaults to the current time
+func (c *AnalysisCache) SetEntry(analysis AnalysisEntry) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	entry := &AnalysisEntry{
-		ImageHash: imageHash,
-		Colors:    colors,
+	if analysis.AnalyzedAt.IsZero() {
+		analysis.AnalyzedAt = time.Now().UTC()
 	}
 
-	c.data[imageHash] = entry
+	entry := &analysis
+	c.data[entry.ImageHash] = entry
 
 	// Persist to disk
 	return c.saveToFile(entry)
 }
 
+// AnalyzedBefore returns the entries analyzed before the cutoff, oldest first
+// Pinned entries are left out, since re-analyzing them would discard the operator's colors
+func (c *AnalysisCache) AnalyzedBefore(cutoff time.Time) []AnalysisEntry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var entries []AnalysisEntry
+	for _, entry := range c.data {
+		if entry.Pin == nil && entry.AnalyzedAt.Before(cutoff) {
+			entries = append(entries, *entry)
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].AnalyzedAt.Before(entries[j].AnalyzedAt)
+	})
+	return entries
+}
+
+// Pinned returns the entries whose colors were set by an operator, most recently pinned first
+func (c *AnalysisCache) Pinned() []AnalysisEntry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var entries []AnalysisEntry
+	for _, entry := range c.data {
+		if entry.Pin != nil {
+			entries = append(entries, *entry)
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Pin.At.After(entries[j].Pin.At)
+	})
+	return entries
+}
+
+// Delete removes an analysis entry from memory and soft-deletes its file
+// The file is moved to the deleted/ subdirectory (suffixed with the deletion time) so it can be restored by hand
+func (c *AnalysisCache) Delete(imageHash string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.data, imageHash)
+
+	deletedDir := filepath.Join(c.cacheDir, "deleted")
+	if err := os.MkdirAll(deletedDir, 0755); err != nil {
+		return fmt.Errorf("failed to create deleted analysis directory: %w", err)
+	}
+
+	deleted := filepath.Join(deletedDir, fmt.Sprintf("%s-%d.json", imageHash, time.Now().Unix()))
+	err := os.Rename(filepath.Join(c.cacheDir, imageHash+".json"), deleted)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove analysis cache file: %w", err)
+	}
+	return nil
+}
+
 // LoadAll loads all analysis entries from disk
 func (c *AnalysisCache) LoadAll() error {
 	files, err := os.ReadDir(c.cacheDir)
@@ -92,12 +267,18 @@ func (c *AnalysisCache) LoadAll() error {
 			continue
 		}
 
+		if entry.AnalyzedAt.IsZero() {
+			if info, err := file.Info(); err == nil {
+				entry.AnalyzedAt = info.ModTime()
+			}
+		}
+
 		c.data[entry.ImageHash] = &entry
 		loaded++
 	}
 
 	if loaded > 0 {
-		slog.Info("Loaded analysis cache entries", "count", loaded)
+		logger().Info("Loaded analysis cache entries", "count", loaded)
 	}
 
 	return nil
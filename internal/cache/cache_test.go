@@ -1,11 +1,20 @@
 package cache
 
 import (
+	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
 )
 
+// pinClock fixes the cache's clock to daysAgo days after the 20251019 fixture wallpaper went live
+func pinClock(c *RequestCache, daysAgo int) {
+	c.now = func() time.Time {
+		return time.Date(2025, 10, 19, 12, 0, 0, 0, time.UTC).AddDate(0, 0, daysAgo)
+	}
+}
+
 // TestRequestCache_New tests request cache initialization
 func TestRequestCache_New(t *testing.T) {
 	tmpDir := t.TempDir()
@@ -18,6 +27,10 @@ func TestRequestCache_New(t *testing.T) {
 	if cache == nil {
 		t.Fatal("Cache should not be nil")
 	}
+
+	if cache.Len() != 0 {
+		t.Errorf("Expected empty cache, got %d entries", cache.Len())
+	}
 }
 
 // TestRequestCache_SetAndGet tests basic request cache operations
@@ -27,6 +40,7 @@ func TestRequestCache_SetAndGet(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Failed to create cache: %v", err)
 	}
+	pinClock(cache, 0)
 
 	locale := "en-US"
 	daysAgo := 0
@@ -119,6 +133,8 @@ func TestRequestCache_Persistence(t *testing.T) {
 		t.Fatalf("Failed to create second cache: %v", err)
 	}
 
+	pinClock(cache2, daysAgo)
+
 	// Load from disk
 	err = cache2.LoadAll()
 	if err != nil {
@@ -143,6 +159,7 @@ func TestRequestCache_TTLExpiration(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Failed to create cache: %v", err)
 	}
+	pinClock(cache, 0)
 
 	locale := "en-US"
 	daysAgo := 0
@@ -192,6 +209,7 @@ func TestRequestCache_TTLExpiration(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Failed to create second cache: %v", err)
 	}
+	pinClock(cache2, 0)
 
 	err = cache2.LoadAll()
 	if err != nil {
@@ -509,6 +527,7 @@ func TestAnalysisCache_SharedImageAcrossLocales(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Failed to create request cache: %v", err)
 	}
+	pinClock(requestCache, 0)
 
 	// Simulate: Same image used by both en-US and ja-JP on same daysAgo
 	imageHash := "shared789012345678901234567890123456789012345678901234567890"
@@ -603,3 +622,296 @@ func BenchmarkAnalysisCache_Set(b *testing.B) {
 		}
 	}
 }
+
+// TestRequestCache_LocaleGroups tests that locales sharing an image hash are grouped
+func TestRequestCache_LocaleGroups(t *testing.T) {
+	tmpDir := t.TempDir()
+	cache, err := NewRequestCache(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+
+	expiresAt := time.Now().Add(time.Hour)
+	sharedHash := "shared01234567890123456789012345678901234567890123456789012345"
+
+	// Yesterday en-US and en-GB served the same image, ja-JP a different one
+	entries := []RequestEntry{
+		{Locale: "en-US", DaysAgo: 1, StartDate: "20251018", ImageHash: sharedHash, ImageID: "OHR.Lighthouse_EN-US123", ExpiresAt: expiresAt},
+		{Locale: "en-GB", DaysAgo: 1, StartDate: "20251018", ImageHash: sharedHash, ImageID: "OHR.Lighthouse_EN-GB456", ExpiresAt: expiresAt},
+		{Locale: "ja-JP", DaysAgo: 1, StartDate: "20251018", ImageHash: "other", ImageID: "OHR.Temple_JA-JP789", ExpiresAt: expiresAt},
+		// Today only en-US has been resolved so far
+		{Locale: "en-US", DaysAgo: 0, StartDate: "20251019", ImageHash: "today", ImageID: "OHR.Aurora_EN-US111", ExpiresAt: expiresAt},
+	}
+	for _, entry := range entries {
+		if err := cache.SetEntry(entry); err != nil {
+			t.Fatalf("Failed to set entry: %v", err)
+		}
+	}
+
+	peers := cache.SharedImagePeers("en-GB")
+	if !peers["en-US"] || peers["ja-JP"] || len(peers) != 1 {
+		t.Errorf("Expected en-GB to be grouped with en-US only, got %v", peers)
+	}
+
+	// en-GB today has the same image name as en-US
+	if peer := cache.FindPeerEntry("en-GB", "20251019", "OHR.Aurora_EN-GB222"); peer == nil || peer.ImageHash != "today" {
+		t.Errorf("Expected en-US peer entry, got %+v", peer)
+	}
+
+	// A different image name must not match, even within the group
+	if peer := cache.FindPeerEntry("en-GB", "20251019", "OHR.Desert_EN-GB333"); peer != nil {
+		t.Errorf("Expected no peer for different image, got %+v", peer)
+	}
+
+	// ja-JP is not grouped with en-US, so it must download even with a matching name
+	if peer := cache.FindPeerEntry("ja-JP", "20251019", "OHR.Aurora_JA-JP444"); peer != nil {
+		t.Errorf("Expected no peer for ungrouped locale, got %+v", peer)
+	}
+}
+
+// TestRequestCache_DateKeyedRollover tests that yesterday's entry is reused as daysAgo=1 after the rollover
+func TestRequestCache_DateKeyedRollover(t *testing.T) {
+	cache, err := NewRequestCache(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+
+	now := time.Date(2025, 10, 19, 12, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+
+	err = cache.SetEntry(RequestEntry{Locale: "en-US", DaysAgo: 0, ImageHash: "day19", StartDate: "20251019", FullStartDate: "202510190700", EndDate: "20251020"})
+	if err != nil {
+		t.Fatalf("Failed to set entry: %v", err)
+	}
+
+	if entry := cache.Get("en-US", 0); entry == nil || entry.ImageHash != "day19" {
+		t.Fatalf("Expected today's entry, got %+v", entry)
+	}
+
+	// Just before the next rollover it is still today's wallpaper
+	now = time.Date(2025, 10, 20, 6, 59, 0, 0, time.UTC)
+	if entry := cache.Get("en-US", 0); entry == nil || entry.ImageHash != "day19" {
+		t.Errorf("Expected entry to stay current until rollover, got %+v", entry)
+	}
+
+	// After the rollover, today is unknown but yesterday is served from the same entry
+	now = time.Date(2025, 10, 20, 7, 1, 0, 0, time.UTC)
+	if entry := cache.Get("en-US", 0); entry != nil {
+		t.Errorf("Expected miss for the new day, got %+v", entry)
+	}
+	if entry := cache.Get("en-US", 1); entry == nil || entry.ImageHash != "day19" {
+		t.Errorf("Expected yesterday's entry for daysAgo=1, got %+v", entry)
+	}
+
+	if entry := cache.GetByDate("en-US", "20251019"); entry == nil {
+		t.Error("Expected entry by date")
+	}
+}
+
+// TestRequestCache_MigratesLegacyFiles tests that daysAgo-keyed files are renamed on load
+func TestRequestCache_MigratesLegacyFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	cache, err := NewRequestCache(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+
+	legacy := filepath.Join(tmpDir, "requests", "en-US_0.json")
+	data := `{"locale": "en-US", "days_ago": 0, "image_hash": "legacy", "startdate": "20251019", "fullstartdate": "202510190700"}`
+	if err := os.WriteFile(legacy, []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write legacy file: %v", err)
+	}
+
+	if err := cache.LoadAll(); err != nil {
+		t.Fatalf("Failed to load: %v", err)
+	}
+
+	if _, err := os.Stat(legacy); !os.IsNotExist(err) {
+		t.Error("Expected legacy file to be removed")
+	}
+
+	if _, err := os.Stat(filepath.Join(tmpDir, "requests", SourceBing, "en-US_20251019.json")); err != nil {
+		t.Errorf("Expected date-keyed file: %v", err)
+	}
+
+	if entry := cache.GetByDate("en-US", "20251019"); entry == nil || entry.ImageHash != "legacy" {
+		t.Errorf("Expected migrated entry, got %+v", entry)
+	}
+}
+
+// TestRequestCache_MigratesUnnamespacedFiles tests that date-keyed files outside a source directory move to Bing's
+func TestRequestCache_MigratesUnnamespacedFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	os.MkdirAll(filepath.Join(tmpDir, "requests"), 0755)
+	unnamespaced := filepath.Join(tmpDir, "requests", "en-US_20251019.json")
+	data := `{"locale": "en-US", "image_hash": "old", "startdate": "20251019", "fullstartdate": "202510190700"}`
+	if err := os.WriteFile(unnamespaced, []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	bing, _ := NewRequestCache(tmpDir)
+	if err := bing.LoadAll(); err != nil {
+		t.Fatalf("Failed to load: %v", err)
+	}
+	if _, err := os.Stat(unnamespaced); !os.IsNotExist(err) {
+		t.Error("Expected the un-namespaced file to be moved")
+	}
+	if entry := bing.GetByDate("en-US", "20251019"); entry == nil || entry.ImageHash != "old" {
+		t.Errorf("Expected the migrated entry, got %+v", entry)
+	}
+
+	// Entries survive a restart in their new place
+	reloaded, _ := NewRequestCache(tmpDir)
+	reloaded.LoadAll()
+	if entry := reloaded.GetByDate("en-US", "20251019"); entry == nil {
+		t.Error("Expected the entry after reload")
+	}
+}
+
+// TestRequestCache_SourcesDoNotCollide tests that sources using the same locale and date keep separate entries
+func TestRequestCache_SourcesDoNotCollide(t *testing.T) {
+	tmpDir := t.TempDir()
+	bing, _ := NewRequestCache(tmpDir)
+	spotlight, err := NewSourceRequestCache(tmpDir, "spotlight")
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+
+	bing.SetEntry(RequestEntry{Locale: "en-US", ImageHash: "bing", StartDate: "20251019"})
+	spotlight.SetEntry(RequestEntry{Locale: "en-US", ImageHash: "spotlight", StartDate: "20251019"})
+
+	// Bing must not pick up the other source's directory as legacy entries
+	reloadedBing, _ := NewRequestCache(tmpDir)
+	reloadedBing.LoadAll()
+	reloadedSpotlight, _ := NewSourceRequestCache(tmpDir, "spotlight")
+	reloadedSpotlight.LoadAll()
+
+	if entry := reloadedBing.GetByDate("en-US", "20251019"); entry == nil || entry.ImageHash != "bing" {
+		t.Errorf("Expected Bing's entry, got %+v", entry)
+	}
+	if entry := reloadedSpotlight.GetByDate("en-US", "20251019"); entry == nil || entry.ImageHash != "spotlight" {
+		t.Errorf("Expected Spotlight's entry, got %+v", entry)
+	}
+	if reloadedBing.Len() != 1 || reloadedSpotlight.Len() != 1 {
+		t.Errorf("Expected one entry per source, got %d and %d", reloadedBing.Len(), reloadedSpotlight.Len())
+	}
+
+	if _, err := NewSourceRequestCache(tmpDir, "../analysis"); err == nil {
+		t.Error("Expected an error for an unsafe source name")
+	}
+}
+
+// TestAnalysisCache_SetEntryPersistsUsage tests that model and usage survive a reload
+func TestAnalysisCache_SetEntryPersistsUsage(t *testing.T) {
+	tmpDir := t.TempDir()
+	cache1, _ := NewAnalysisCache(tmpDir)
+
+	err := cache1.SetEntry(AnalysisEntry{ImageHash: "hash", Colors: map[string]interface{}{"gradient_from": "#000000"}, Model: "test-model", PromptTokens: 10, CompletionTokens: 5, Cost: 0.5})
+	if err != nil {
+		t.Fatalf("Failed to set entry: %v", err)
+	}
+
+	cache2, _ := NewAnalysisCache(tmpDir)
+	if err := cache2.LoadAll(); err != nil {
+		t.Fatalf("Failed to load cache: %v", err)
+	}
+
+	entry := cache2.Get("hash")
+	if entry == nil || entry.Model != "test-model" || entry.PromptTokens != 10 || entry.CompletionTokens != 5 || entry.Cost != 0.5 {
+		t.Errorf("Expected usage to be persisted, got %+v", entry)
+	}
+}
+
+// TestAnalysisCache_AnalyzedBefore tests stale entry selection and the file time fallback for older entries
+func TestAnalysisCache_AnalyzedBefore(t *testing.T) {
+	tmpDir := t.TempDir()
+	cache1, _ := NewAnalysisCache(tmpDir)
+
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache1.SetEntry(AnalysisEntry{ImageHash: "recent", AnalyzedAt: now.AddDate(0, 0, -1)})
+	cache1.SetEntry(AnalysisEntry{ImageHash: "old", AnalyzedAt: now.AddDate(0, 0, -100)})
+	cache1.SetEntry(AnalysisEntry{ImageHash: "older", AnalyzedAt: now.AddDate(0, 0, -200)})
+
+	stale := cache1.AnalyzedBefore(now.AddDate(0, 0, -90))
+	if len(stale) != 2 || stale[0].ImageHash != "older" || stale[1].ImageHash != "old" {
+		t.Errorf("Expected older then old, got %+v", stale)
+	}
+
+	// Entries written before analyzed_at existed take the file's modification time
+	legacy := filepath.Join(tmpDir, "analysis", "legacy.json")
+	os.WriteFile(legacy, []byte(`{"image_hash": "legacy", "colors": {}}`), 0644)
+	fileTime := now.AddDate(0, 0, -30)
+	os.Chtimes(legacy, fileTime, fileTime)
+
+	cache2, _ := NewAnalysisCache(tmpDir)
+	if err := cache2.LoadAll(); err != nil {
+		t.Fatalf("Failed to load cache: %v", err)
+	}
+	if entry := cache2.Get("legacy"); entry == nil || !entry.AnalyzedAt.Equal(fileTime) {
+		t.Errorf("Expected analyzed_at from file time %v, got %+v", fileTime, entry)
+	}
+}
+
+// TestAnalysisCache_DeleteIsSoft tests that deleted entries are moved aside instead of removed
+func TestAnalysisCache_DeleteIsSoft(t *testing.T) {
+	tmpDir := t.TempDir()
+	cache1, _ := NewAnalysisCache(tmpDir)
+	cache1.SetEntry(AnalysisEntry{ImageHash: "hash", Colors: map[string]interface{}{}})
+
+	if err := cache1.Delete("hash"); err != nil {
+		t.Fatalf("Failed to delete entry: %v", err)
+	}
+	if cache1.Get("hash") != nil {
+		t.Error("Expected entry to be gone from memory")
+	}
+
+	deleted, _ := filepath.Glob(filepath.Join(tmpDir, "analysis", "deleted", "hash-*.json"))
+	if len(deleted) != 1 {
+		t.Errorf("Expected one soft-deleted file, got %v", deleted)
+	}
+
+	cache2, _ := NewAnalysisCache(tmpDir)
+	if err := cache2.LoadAll(); err != nil {
+		t.Fatalf("Failed to load cache: %v", err)
+	}
+	if cache2.Get("hash") != nil {
+		t.Error("Expected soft-deleted entry not to be loaded")
+	}
+}
+
+// TestRequestCache_DaysAgo tests that start dates map back to the daysAgo Get serves them as, across a rollover
+func TestRequestCache_DaysAgo(t *testing.T) {
+	cache, err := NewRequestCache(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+
+	now := time.Date(2025, 10, 19, 12, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+
+	if _, ok := cache.DaysAgo("en-US", "20251019"); ok {
+		t.Error("Expected no daysAgo without cached entries")
+	}
+
+	cache.SetEntry(RequestEntry{Locale: "en-US", ImageHash: "day19", StartDate: "20251019", FullStartDate: "202510190700", EndDate: "20251020"})
+	cache.SetEntry(RequestEntry{Locale: "en-US", ImageHash: "day01", StartDate: "20251001", FullStartDate: "202510010700", EndDate: "20251002"})
+
+	for _, tc := range []struct {
+		now       time.Time
+		startDate string
+		want      int
+	}{
+		{now, "20251019", 0},
+		{now, "20251001", 18},
+		{time.Date(2025, 10, 20, 7, 1, 0, 0, time.UTC), "20251001", 19},
+	} {
+		now = tc.now
+		daysAgo, ok := cache.DaysAgo("en-US", tc.startDate)
+		if !ok || daysAgo != tc.want {
+			t.Errorf("%s at %s: expected %d, got %d (%v)", tc.startDate, tc.now, tc.want, daysAgo, ok)
+		}
+		if entry := cache.Get("en-US", daysAgo); entry == nil || entry.StartDate != tc.startDate {
+			t.Errorf("Expected Get(%d) to return %s, got %+v", daysAgo, tc.startDate, entry)
+		}
+	}
+}
@@ -3,11 +3,50 @@ package cache
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"strings"
 )
 
+// promptHashLength is how many hex digits of the prompt addendum's hash go into an analysis key
+const promptHashLength = 12
+
 // HashImage generates a unique hash for image data
 // This allows us to identify identical images even if they have different metadata/IDs
 func HashImage(imageData []byte) string {
 	hash := sha256.Sum256(imageData)
 	return hex.EncodeToString(hash[:])
 }
+
+// AnalysisKey identifies an analysis of an image made with a given prompt addendum
+// Without an addendum the key is the image hash itself, so existing cache entries keep their keys
+func AnalysisKey(imageHash, promptAddendum string) string {
+	if promptAddendum == "" {
+		return imageHash
+	}
+	hash := sha256.Sum256([]byte(promptAddendum))
+	return imageHash + "-" + hex.EncodeToString(hash[:])[:promptHashLength]
+}
+
+// ImageHashOf returns the image hash part of an analysis key
+func ImageHashOf(key string) string {
+	imageHash, _, _ := strings.Cut(key, "-")
+	return imageHash
+}
+
+// ValidHash reports whether s is formatted like a HashImage or AnalysisKey result, so it is safe to use as a file name
+func ValidHash(s string) bool {
+	imageHash, promptHash, hasPrompt := strings.Cut(s, "-")
+	if len(imageHash) != 2*sha256.Size || !isHex(imageHash) {
+		return false
+	}
+	return !hasPrompt || (len(promptHash) == promptHashLength && isHex(promptHash))
+}
+
+// isHex reports whether s only contains hex digits
+func isHex(s string) bool {
+	for _, r := range s {
+		if (r < '0' || r > '9') && (r < 'a' || r > 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -133,3 +133,32 @@ func BenchmarkHashImage_Large(b *testing.B) {
 		_ = HashImage(imageData)
 	}
 }
+
+// TestAnalysisKey tests that prompt addenda produce distinct, valid keys for the same image
+func TestAnalysisKey(t *testing.T) {
+	imageHash := HashImage([]byte("test image data"))
+
+	if key := AnalysisKey(imageHash, ""); key != imageHash {
+		t.Errorf("Expected the plain image hash without an addendum, got %s", key)
+	}
+
+	cooler := AnalysisKey(imageHash, "prefer cooler tones")
+	warmer := AnalysisKey(imageHash, "prefer warmer tones")
+	if cooler == warmer || cooler == imageHash {
+		t.Errorf("Expected distinct keys, got %s and %s", cooler, warmer)
+	}
+	if ImageHashOf(cooler) != imageHash || ImageHashOf(imageHash) != imageHash {
+		t.Errorf("Expected image hash %s from key %s", imageHash, cooler)
+	}
+
+	for _, key := range []string{imageHash, cooler} {
+		if !ValidHash(key) {
+			t.Errorf("Expected %s to be valid", key)
+		}
+	}
+	for _, key := range []string{"", "../etc/passwd", imageHash + "-", imageHash + "-../../x", imageHash[:10]} {
+		if ValidHash(key) {
+			t.Errorf("Expected %q to be invalid", key)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package cache
+
+import (
+	"strings"
+)
+
+// Locale groups are learned from the request cache: two locales are grouped once they
+// have been served byte-identical images (same image hash) on the same day. Bing gives
+// each market its own image ID, but the name before the market suffix stays the same,
+// so a grouped peer with a matching name on the same day almost certainly has our image.
+
+// SharedImagePeers returns the locales that have served the same image as locale on any cached day
+func (c *RequestCache) SharedImagePeers(locale string) map[string]bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	// Image hashes this locale has served, keyed by start date
+	own := make(map[string]string)
+	for _, entry := range c.data {
+		if entry.Locale == locale {
+			own[entry.StartDate] = entry.ImageHash
+		}
+	}
+
+	peers := make(map[string]bool)
+	for _, entry := range c.data {
+		if entry.Locale != locale && own[entry.StartDate] == entry.ImageHash {
+			peers[entry.Locale] = true
+		}
+	}
+
+	return peers
+}
+
+// FindPeerEntry looks for a grouped locale that already resolved the same image on the same day
+// Returns nil when no learned peer has a matching entry, in which case the image must be downloaded
+func (c *RequestCache) FindPeerEntry(locale, startDate, imageID string) *RequestEntry {
+	name := imageName(imageID)
+	if name == "" || startDate == "" {
+		return nil
+	}
+
+	peers := c.SharedImagePeers(locale)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, entry := range c.data {
+		if peers[entry.Locale] && entry.StartDate == startDate && imageName(entry.ImageID) == name {
+			return entry
+		}
+	}
+
+	return nil
+}
+
+// imageName strips the market suffix from a Bing image ID
+// Example: "OHR.MartimoaapaFinland_EN-US3685817058" -> "OHR.MartimoaapaFinland"
+func imageName(imageID string) string {
+	if i := strings.LastIndex(imageID, "_"); i > 0 {
+		return imageID[:i]
+	}
+	return imageID
+}
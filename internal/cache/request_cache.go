@@ -3,69 +3,221 @@ package cache
 import (
 	"encoding/json"
 	"fmt"
-	"log/slog"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 )
 
+const (
+	startDateLayout     = "20060102"     // Bing startdate format
+	fullStartDateLayout = "200601021504" // Bing fullstartdate format (UTC)
+)
+
+// SourceBing names Bing's daily wallpaper as an image source
+const SourceBing = "bing"
+
+// namePattern limits source and profile names to what is safe as a directory name
+var namePattern = regexp.MustCompile(`^[a-z0-9-]+$`)
+
 // RequestEntry stores metadata about a wallpaper request
 type RequestEntry struct {
 	Locale        string            `json:"locale"`
-	DaysAgo       int               `json:"days_ago"`
+	DaysAgo       int               `json:"days_ago"` // daysAgo at the time the entry was fetched (informational)
 	ImageHash     string            `json:"image_hash"`
+	ImageID       string            `json:"image_id,omitempty"` // Bing image ID (e.g., "OHR.MartimoaapaFinland_EN-US3685817058")
 	ImageURLs     map[string]string `json:"image_urls"`
 	Title         string            `json:"title"`
 	Copyright     string            `json:"copyright"`
 	CopyrightLink string            `json:"copyright_link"`
-	StartDate     string            `json:"startdate"`     // Format: YYYYMMDD (e.g., "20251019")
-	FullStartDate string            `json:"fullstartdate"` // Format: YYYYMMDDHHMM (e.g., "202510190700")
-	EndDate       string            `json:"enddate"`       // Format: YYYYMMDD (e.g., "20251020")
-	ExpiresAt     time.Time         `json:"expires_at"`
+	Photographer  string            `json:"photographer,omitempty"`    // Parsed from the copyright string
+	Agency        string            `json:"agency,omitempty"`          // Parsed from the copyright string
+	StartDate     string            `json:"startdate"`                 // Format: YYYYMMDD (e.g., "20251019")
+	FullStartDate string            `json:"fullstartdate"`             // Format: YYYYMMDDHHMM (e.g., "202510190700")
+	EndDate       string            `json:"enddate"`                   // Format: YYYYMMDD (e.g., "20251020")
+	ExpiresAt     time.Time         `json:"expires_at"`                // When this wallpaper stops being the locale's current one
+	VideoURLs     map[string]string `json:"video_urls,omitempty"`      // Video background URLs by format, on video days
+	VideoFrameURL string            `json:"video_frame_url,omitempty"` // Video frame that was analyzed in place of the still
 }
 
-// RequestCache manages request metadata cache
+// RequestCache manages request metadata cache for one image source
+// Entries are keyed by locale and the wallpaper's start date, so an entry fetched as
+// daysAgo=0 keeps serving as daysAgo=1 after the next rollover instead of being refetched
 type RequestCache struct {
-	mu       sync.RWMutex
-	data     map[string]*RequestEntry // key: "locale_daysago"
-	cacheDir string
+	mu        sync.RWMutex
+	data      map[string]*RequestEntry // key: "locale_startdate"
+	source    string
+	cacheDir  string // requests/<source>
+	legacyDir string // Un-namespaced requests/ directory migrated on load; only set for Bing
+	now       func() time.Time
 }
 
-// NewRequestCache creates a new request cache
+// NewRequestCache creates the request cache for Bing wallpapers
 func NewRequestCache(cacheDir string) (*RequestCache, error) {
-	dir := filepath.Join(cacheDir, "requests")
+	return NewSourceRequestCache(cacheDir, SourceBing)
+}
+
+// NewSourceRequestCache creates the request cache of an image source, stored under requests/<source>
+// Each source has its own cache, so providers that use the same locale names and dates cannot collide
+func NewSourceRequestCache(cacheDir, source string) (*RequestCache, error) {
+	if !namePattern.MatchString(source) {
+		return nil, fmt.Errorf("invalid image source %q", source)
+	}
+
+	root := filepath.Join(cacheDir, "requests")
+	dir := filepath.Join(root, source)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create request cache directory: %w", err)
 	}
 
-	return &RequestCache{
+	c := &RequestCache{
 		data:     make(map[string]*RequestEntry),
+		source:   source,
 		cacheDir: dir,
-	}, nil
+		now:      time.Now,
+	}
+	if source == SourceBing {
+		// Bing was the only source before requests were namespaced, so its entries sit directly in requests/
+		c.legacyDir = root
+	}
+	return c, nil
+}
+
+// Source returns the image source whose entries this cache holds
+func (c *RequestCache) Source() string {
+	return c.source
 }
 
-// makeKey creates a cache key from locale and daysAgo
-func (c *RequestCache) makeKey(locale string, daysAgo int) string {
-	return fmt.Sprintf("%s_%d", locale, daysAgo)
+// makeKey creates a cache key from locale and start date
+func (c *RequestCache) makeKey(locale string, startDate string) string {
+	return locale + "_" + startDate
 }
 
-// Get retrieves a request entry
+// Get retrieves the entry for the wallpaper that is daysAgo days old right now
+// Returns nil if the start date cannot be resolved yet or the day has not been cached
 func (c *RequestCache) Get(locale string, daysAgo int) *RequestEntry {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	key := c.makeKey(locale, daysAgo)
-	return c.data[key]
+	startDate, ok := c.resolveStartDate(locale, daysAgo)
+	if !ok {
+		return nil
+	}
+
+	return c.data[c.makeKey(locale, startDate)]
+}
+
+// GetByDate retrieves the entry for a locale's wallpaper with the given start date (YYYYMMDD)
+func (c *RequestCache) GetByDate(locale string, startDate string) *RequestEntry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.data[c.makeKey(locale, startDate)]
+}
+
+// DaysAgo returns the daysAgo a locale's wallpaper with the given start date (YYYYMMDD) is served as, the inverse of Get
+// Returns false if nothing is cached for the locale, since its rollover is unknown then
+func (c *RequestCache) DaysAgo(locale string, startDate string) (int, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	current, ok := c.resolveStartDate(locale, 0)
+	if !ok {
+		return 0, false
+	}
+	today, err := time.Parse(startDateLayout, current)
+	if err != nil {
+		return 0, false
+	}
+	day, err := time.Parse(startDateLayout, startDate)
+	if err != nil {
+		return 0, false
+	}
+	return int(today.Sub(day).Round(24*time.Hour) / (24 * time.Hour)), true
+}
+
+// Len returns the number of cached entries across all locales
+func (c *RequestCache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return len(c.data)
+}
+
+// Entries returns a copy of all cached entries, oldest start date first (ties ordered by locale)
+func (c *RequestCache) Entries() []RequestEntry {
+	c.mu.RLock()
+	entries := make([]RequestEntry, 0, len(c.data))
+	for _, entry := range c.data {
+		entries = append(entries, *entry)
+	}
+	c.mu.RUnlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].StartDate != entries[j].StartDate {
+			return entries[i].StartDate < entries[j].StartDate
+		}
+		return entries[i].Locale < entries[j].Locale
+	})
+	return entries
+}
+
+// resolveStartDate maps daysAgo to a start date using the locale's most recent known rollover
+// Bing rolls over once a day at a fixed time per market, so the current start date is the
+// latest known one plus the number of whole days since its fullstartdate; callers must hold c.mu
+func (c *RequestCache) resolveStartDate(locale string, daysAgo int) (string, bool) {
+	var latest *RequestEntry
+	for _, entry := range c.data {
+		if entry.Locale == locale && (latest == nil || entry.FullStartDate > latest.FullStartDate) {
+			latest = entry
+		}
+	}
+	if latest == nil {
+		return "", false
+	}
+
+	startDate, err := time.Parse(startDateLayout, latest.StartDate)
+	if err != nil {
+		return "", false
+	}
+
+	rollover := RolloverTime(latest.StartDate, latest.FullStartDate).Add(-24 * time.Hour)
+	elapsedDays := 0
+	if elapsed := c.now().Sub(rollover); elapsed > 0 {
+		elapsedDays = int(elapsed / (24 * time.Hour))
+	}
+
+	return startDate.AddDate(0, 0, elapsedDays-daysAgo).Format(startDateLayout), true
+}
+
+// RolloverTime returns when a wallpaper stops being its locale's current one
+// Falls back to midnight UTC after the start date when fullstartdate is missing
+func RolloverTime(startDate, fullStartDate string) time.Time {
+	start := StartTime(startDate, fullStartDate)
+	if start.IsZero() {
+		return start
+	}
+	return start.Add(24 * time.Hour)
+}
+
+// StartTime returns when a wallpaper became its locale's current one
+// Falls back to midnight UTC of the start date when fullstartdate is missing
+func StartTime(startDate, fullStartDate string) time.Time {
+	if start, err := time.Parse(fullStartDateLayout, fullStartDate); err == nil {
+		return start
+	}
+	if start, err := time.Parse(startDateLayout, startDate); err == nil {
+		return start
+	}
+	return time.Time{}
 }
 
 // Set stores a request entry and persists to disk
 func (c *RequestCache) Set(locale string, daysAgo int, imageHash string, imageURLs map[string]string, title, copyright, copyrightLink, startDate, fullStartDate, endDate string, expiresAt time.Time) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	entry := &RequestEntry{
+	return c.SetEntry(RequestEntry{
 		Locale:        locale,
 		DaysAgo:       daysAgo,
 		ImageHash:     imageHash,
@@ -77,59 +229,107 @@ func (c *RequestCache) Set(locale string, daysAgo int, imageHash string, imageUR
 		FullStartDate: fullStartDate,
 		EndDate:       endDate,
 		ExpiresAt:     expiresAt,
+	})
+}
+
+// SetEntry stores a complete request entry and persists to disk
+func (c *RequestCache) SetEntry(entry RequestEntry) error {
+	if entry.StartDate == "" {
+		return fmt.Errorf("request entry for %s has no start date", entry.Locale)
 	}
 
-	key := c.makeKey(locale, daysAgo)
-	c.data[key] = entry
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := c.makeKey(entry.Locale, entry.StartDate)
+	c.data[key] = &entry
 
 	// Persist to disk
-	return c.saveToFile(entry)
+	return c.saveToFile(&entry)
 }
 
 // LoadAll loads all request entries from disk
+// Files from older layouts (daysAgo-keyed names, or Bing entries outside the source directory)
+// are rewritten to the current layout
 func (c *RequestCache) LoadAll() error {
-	files, err := os.ReadDir(c.cacheDir)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	loaded, migrated, err := c.loadDir(c.cacheDir)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil
+		return err
+	}
+	if c.legacyDir != "" {
+		legacyLoaded, legacyMigrated, err := c.loadDir(c.legacyDir)
+		if err != nil {
+			return err
 		}
-		return fmt.Errorf("failed to read request cache directory: %w", err)
+		loaded += legacyLoaded
+		migrated += legacyMigrated
 	}
 
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	if loaded > 0 {
+		logger().Info("Loaded request cache entries", "source", c.source, "count", loaded)
+	}
+	if migrated > 0 {
+		logger().Info("Migrated request cache entries to the current layout", "source", c.source, "count", migrated)
+	}
+
+	return nil
+}
+
+// loadDir loads the entry files of one directory, moving any not stored under their current name and directory
+// Callers must hold c.mu
+func (c *RequestCache) loadDir(dir string) (loaded, migrated int, err error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, 0, nil
+		}
+		return 0, 0, fmt.Errorf("failed to read request cache directory: %w", err)
+	}
 
-	loaded := 0
 	for _, file := range files {
 		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
 			continue
 		}
 
-		data, err := os.ReadFile(filepath.Join(c.cacheDir, file.Name()))
+		path := filepath.Join(dir, file.Name())
+		data, err := os.ReadFile(path)
 		if err != nil {
 			continue
 		}
 
 		var entry RequestEntry
-		if err := json.Unmarshal(data, &entry); err != nil {
+		if err := json.Unmarshal(data, &entry); err != nil || entry.StartDate == "" {
 			continue
 		}
 
-		key := c.makeKey(entry.Locale, entry.DaysAgo)
-		c.data[key] = &entry
+		key := c.makeKey(entry.Locale, entry.StartDate)
+		if existing, ok := c.data[key]; !ok || entry.FullStartDate >= existing.FullStartDate {
+			c.data[key] = &entry
+		}
 		loaded++
-	}
 
-	if loaded > 0 {
-		slog.Info("Loaded request cache entries", "count", loaded)
+		if path != filepath.Join(c.cacheDir, c.filename(&entry)) {
+			if err := c.saveToFile(c.data[key]); err == nil {
+				os.Remove(path)
+				migrated++
+			}
+		}
 	}
 
-	return nil
+	return loaded, migrated, nil
+}
+
+// filename returns the on-disk file name for an entry
+func (c *RequestCache) filename(entry *RequestEntry) string {
+	return fmt.Sprintf("%s_%s.json", entry.Locale, entry.StartDate)
 }
 
 // saveToFile persists a request entry to disk
 func (c *RequestCache) saveToFile(entry *RequestEntry) error {
-	filename := filepath.Join(c.cacheDir, fmt.Sprintf("%s_%d.json", entry.Locale, entry.DaysAgo))
+	filename := filepath.Join(c.cacheDir, c.filename(entry))
 
 	data, err := json.MarshalIndent(entry, "", "  ")
 	if err != nil {
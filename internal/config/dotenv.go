@@ -0,0 +1,113 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// LoadFile parses a dotenv-style file of KEY=VALUE lines
+// Blank lines and # comments are skipped, an "export " prefix is allowed, and values may be quoted
+func LoadFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer file.Close()
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		line = strings.TrimPrefix(line, "export ")
+		key, value, found := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			return nil, fmt.Errorf("invalid config line %d: expected KEY=VALUE", lineNumber)
+		}
+
+		values[key] = parseValue(strings.TrimSpace(value))
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	return values, nil
+}
+
+// processValue is a variable's value before Apply first set it
+type processValue struct {
+	value string
+	set   bool
+}
+
+// applied holds the keys set by Apply, with the value each one replaced, so keys removed from the file can be restored
+var (
+	applied   = make(map[string]processValue)
+	appliedMu sync.Mutex
+)
+
+// Apply loads a config file and exports its values into the process environment
+// Settings read from the environment at use time pick up the new values immediately. Keys an earlier Apply
+// set that are no longer in the file are restored to the process's own value, or unset if it had none
+func Apply(path string) (map[string]string, error) {
+	values, err := LoadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	appliedMu.Lock()
+	defer appliedMu.Unlock()
+
+	for key, value := range values {
+		if _, ok := applied[key]; !ok {
+			original, set := os.LookupEnv(key)
+			applied[key] = processValue{value: original, set: set}
+		}
+		if err := os.Setenv(key, value); err != nil {
+			return nil, fmt.Errorf("failed to set %s: %w", key, err)
+		}
+	}
+
+	for key, original := range applied {
+		if _, ok := values[key]; ok {
+			continue
+		}
+		if original.set {
+			err = os.Setenv(key, original.value)
+		} else {
+			err = os.Unsetenv(key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to restore %s: %w", key, err)
+		}
+		delete(applied, key)
+	}
+
+	return values, nil
+}
+
+// parseValue strips matching quotes, or a trailing comment from unquoted values
+func parseValue(value string) string {
+	if len(value) >= 2 {
+		first, last := value[0], value[len(value)-1]
+		if (first == '"' || first == '\'') && first == last {
+			return value[1 : len(value)-1]
+		}
+	}
+
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = strings.TrimSpace(value[:i])
+	}
+
+	return value
+}
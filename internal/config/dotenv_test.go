@@ -0,0 +1,99 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestLoadFile tests parsing of dotenv syntax variants
+func TestLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dailyhues.env")
+	content := `# Comment line
+ALLOWED_LOCALES=en-US,de-DE
+
+export DEBUG_AI_RESPONSES=true
+QUOTED="value with # hash"
+SINGLE='single'
+TRAILING=plain # comment
+EMPTY=
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	values, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := map[string]string{
+		"ALLOWED_LOCALES":    "en-US,de-DE",
+		"DEBUG_AI_RESPONSES": "true",
+		"QUOTED":             "value with # hash",
+		"SINGLE":             "single",
+		"TRAILING":           "plain",
+		"EMPTY":              "",
+	}
+
+	if len(values) != len(want) {
+		t.Errorf("Expected %d values, got %d: %v", len(want), len(values), values)
+	}
+
+	for key, wantValue := range want {
+		if values[key] != wantValue {
+			t.Errorf("Expected %s=%q, got %q", key, wantValue, values[key])
+		}
+	}
+}
+
+// TestLoadFile_Invalid tests that malformed lines are reported
+func TestLoadFile_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.env")
+	os.WriteFile(path, []byte("GOOD=1\nnot a setting\n"), 0644)
+
+	if _, err := LoadFile(path); err == nil {
+		t.Error("Expected error for line without '='")
+	}
+}
+
+// TestApply tests that values are exported to the environment
+func TestApply(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "apply.env")
+	os.WriteFile(path, []byte("DAILYHUES_TEST_APPLY=yes\n"), 0644)
+	t.Cleanup(func() { os.Unsetenv("DAILYHUES_TEST_APPLY") })
+
+	if _, err := Apply(path); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if got := os.Getenv("DAILYHUES_TEST_APPLY"); got != "yes" {
+		t.Errorf("Expected yes, got %q", got)
+	}
+}
+
+// TestApply_Reload tests that keys removed from the file are unset, or restored to the process's own value
+func TestApply_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reload.env")
+	t.Setenv("DAILYHUES_TEST_ORIGINAL", "process")
+	t.Cleanup(func() { os.Unsetenv("DAILYHUES_TEST_ADDED") })
+
+	os.WriteFile(path, []byte("DAILYHUES_TEST_ADDED=file\nDAILYHUES_TEST_ORIGINAL=file\n"), 0644)
+	if _, err := Apply(path); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if os.Getenv("DAILYHUES_TEST_ADDED") != "file" || os.Getenv("DAILYHUES_TEST_ORIGINAL") != "file" {
+		t.Fatal("Expected the file's values to be applied")
+	}
+
+	os.WriteFile(path, []byte("# Both settings removed\n"), 0644)
+	if _, err := Apply(path); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if value, ok := os.LookupEnv("DAILYHUES_TEST_ADDED"); ok {
+		t.Errorf("Expected a removed key to be unset, got %q", value)
+	}
+	if got := os.Getenv("DAILYHUES_TEST_ORIGINAL"); got != "process" {
+		t.Errorf("Expected the process value to be restored, got %q", got)
+	}
+}
@@ -0,0 +1,73 @@
+package format
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// sample covers every type in the JSON data model
+var sample = map[string]interface{}{
+	"a": 1,
+	"b": []interface{}{true, nil},
+	"c": "x",
+	"d": -1,
+	"e": 1.5,
+	"f": 300,
+}
+
+// TestMarshalMsgPack tests MessagePack encoding against a hand-assembled byte sequence
+func TestMarshalMsgPack(t *testing.T) {
+	got, err := MarshalMsgPack(sample)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := "86" + "a161" + "01" + "a162" + "92c3c0" + "a163" + "a178" + "a164" + "ff" + "a165" + "cb3ff8000000000000" + "a166" + "cd012c"
+	if hex.EncodeToString(got) != want {
+		t.Errorf("Expected %s, got %x", want, got)
+	}
+}
+
+// TestMarshalCBOR tests CBOR encoding against a hand-assembled byte sequence
+func TestMarshalCBOR(t *testing.T) {
+	got, err := MarshalCBOR(sample)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := "a6" + "6161" + "01" + "6162" + "82f5f6" + "6163" + "6178" + "6164" + "20" + "6165" + "fb3ff8000000000000" + "6166" + "19012c"
+	if hex.EncodeToString(got) != want {
+		t.Errorf("Expected %s, got %x", want, got)
+	}
+}
+
+// TestMarshalMsgPack_LongString tests the str8 length form
+func TestMarshalMsgPack_LongString(t *testing.T) {
+	got, err := MarshalMsgPack(strings.Repeat("x", 40))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !bytes.HasPrefix(got, []byte{0xd9, 40}) || len(got) != 42 {
+		t.Errorf("Unexpected encoding prefix %x (len %d)", got[:2], len(got))
+	}
+}
+
+// TestMarshal_StructTags tests that struct fields use their JSON names
+func TestMarshal_StructTags(t *testing.T) {
+	value := struct {
+		Title string `json:"title"`
+		Empty string `json:"empty,omitempty"`
+	}{Title: "t"}
+
+	got, err := MarshalCBOR(value)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if want := "a1" + "657469746c65" + "6174"; hex.EncodeToString(got) != want {
+		t.Errorf("Expected %s, got %x", want, got)
+	}
+}
@@ -0,0 +1,96 @@
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// envPrefix namespaces exported shell variables
+const envPrefix = "DAILYHUES_"
+
+// PaletteText renders palette values as one "name value" pair per line
+// Nested groups are flattened, e.g. notifications.low becomes notifications_low
+func PaletteText(colors map[string]interface{}) []byte {
+	colors = flatten(colors)
+
+	var buf bytes.Buffer
+	for _, key := range sortedKeys(colors) {
+		fmt.Fprintf(&buf, "%s %s\n", key, scalarString(colors[key]))
+	}
+	return buf.Bytes()
+}
+
+// PaletteEnv renders values as shell variable assignments suitable for eval
+// Keys are upper-cased and prefixed, e.g. gradient_from becomes DAILYHUES_GRADIENT_FROM
+func PaletteEnv(values map[string]interface{}) []byte {
+	values = flatten(values)
+
+	var buf bytes.Buffer
+	for _, key := range sortedKeys(values) {
+		fmt.Fprintf(&buf, "%s=%s\n", envName(key), shellQuote(scalarString(values[key])))
+	}
+	return buf.Bytes()
+}
+
+// flatten lifts values of nested maps to the top level, joining keys with an underscore
+// List items are numbered from 1, e.g. swatches[0] becomes swatches_1
+func flatten(values map[string]interface{}) map[string]interface{} {
+	flat := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		switch nested := value.(type) {
+		case map[string]interface{}:
+			for nestedKey, nestedValue := range flatten(nested) {
+				flat[key+"_"+nestedKey] = nestedValue
+			}
+		case []interface{}:
+			items := make(map[string]interface{}, len(nested))
+			for i, item := range nested {
+				items[strconv.Itoa(i+1)] = item
+			}
+			for nestedKey, nestedValue := range flatten(items) {
+				flat[key+"_"+nestedKey] = nestedValue
+			}
+		default:
+			flat[key] = value
+		}
+	}
+	return flat
+}
+
+// envName converts a key to a valid shell variable name
+func envName(key string) string {
+	var b strings.Builder
+	b.WriteString(envPrefix)
+	for _, r := range strings.ToUpper(key) {
+		if r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+// shellQuote single-quotes a value unless it only contains characters that are safe unquoted
+func shellQuote(value string) string {
+	if value != "" && strings.Trim(value, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789#%+,-./:=@_") == "" {
+		return value
+	}
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// scalarString formats a palette value, printing whole numbers without a decimal point
+func scalarString(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case int:
+		return strconv.Itoa(val)
+	default:
+		return fmt.Sprint(val)
+	}
+}
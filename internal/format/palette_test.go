@@ -0,0 +1,61 @@
+package format
+
+import (
+	"testing"
+)
+
+// TestPaletteText tests the "name value" line format
+func TestPaletteText(t *testing.T) {
+	colors := map[string]interface{}{"gradient_from": "#c67d3a", "gradient_to": "#6b8d7d", "gradient_angle": float64(135)}
+
+	want := "gradient_angle 135\ngradient_from #c67d3a\ngradient_to #6b8d7d\n"
+	if got := string(PaletteText(colors)); got != want {
+		t.Errorf("Expected:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+// TestPaletteEnv tests shell assignments and quoting
+func TestPaletteEnv(t *testing.T) {
+	values := map[string]interface{}{
+		"gradient_from": "#c67d3a",
+		"css_gradient":  "linear-gradient(135deg, #c67d3a, #6b8d7d)",
+		"title":         "Finland's peatland",
+	}
+
+	want := "DAILYHUES_CSS_GRADIENT='linear-gradient(135deg, #c67d3a, #6b8d7d)'\n" +
+		"DAILYHUES_GRADIENT_FROM=#c67d3a\n" +
+		"DAILYHUES_TITLE='Finland'\\''s peatland'\n"
+	if got := string(PaletteEnv(values)); got != want {
+		t.Errorf("Expected:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+// TestPaletteText_Nested tests that nested groups are flattened into prefixed names
+func TestPaletteText_Nested(t *testing.T) {
+	colors := map[string]interface{}{
+		"gradient_from": "#c67d3a",
+		"notifications": map[string]interface{}{"low": "#3a3d3b", "critical": "#a8333c"},
+	}
+
+	want := "gradient_from #c67d3a\nnotifications_critical #a8333c\nnotifications_low #3a3d3b\n"
+	if got := string(PaletteText(colors)); got != want {
+		t.Errorf("Expected:\n%s\ngot:\n%s", want, got)
+	}
+
+	if got := string(PaletteEnv(colors)); got != "DAILYHUES_GRADIENT_FROM=#c67d3a\nDAILYHUES_NOTIFICATIONS_CRITICAL=#a8333c\nDAILYHUES_NOTIFICATIONS_LOW=#3a3d3b\n" {
+		t.Errorf("Unexpected env output:\n%s", got)
+	}
+}
+
+// TestPaletteText_List tests that list items are flattened into numbered names
+func TestPaletteText_List(t *testing.T) {
+	colors := map[string]interface{}{
+		"accent":   "#d79921",
+		"swatches": []interface{}{"#282828", "#3c3836"},
+	}
+
+	want := "accent #d79921\nswatches_1 #282828\nswatches_2 #3c3836\n"
+	if got := string(PaletteText(colors)); got != want {
+		t.Errorf("Expected:\n%s\ngot:\n%s", want, got)
+	}
+}
@@ -0,0 +1,83 @@
+package format
+
+import (
+	"testing"
+)
+
+// document mirrors the shape of a ColorTheme response
+var document = map[string]interface{}{
+	"title":  "Finland's \"living\" peatland",
+	"images": map[string]interface{}{"UHD": "https://example.com/uhd.jpg", "1920x1080": "https://example.com/fhd.jpg"},
+	"colors": map[string]interface{}{"gradient_from": "#c67d3a", "gradient_to": "#6b8d7d", "gradient_angle": 135},
+	"steps": []interface{}{
+		map[string]interface{}{"gradient_angle": 135.5},
+		map[string]interface{}{"gradient_angle": 180},
+	},
+	"tags":  []interface{}{"a", "b"},
+	"empty": nil,
+}
+
+// TestMarshalYAML tests block YAML output for nested maps and lists
+func TestMarshalYAML(t *testing.T) {
+	got, err := MarshalYAML(document)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := `colors:
+  gradient_angle: 135
+  gradient_from: "#c67d3a"
+  gradient_to: "#6b8d7d"
+empty: null
+images:
+  "1920x1080": "https://example.com/fhd.jpg"
+  UHD: "https://example.com/uhd.jpg"
+steps:
+  - gradient_angle: 135.5
+  - gradient_angle: 180
+tags:
+  - "a"
+  - "b"
+title: "Finland's \"living\" peatland"
+`
+	if string(got) != want {
+		t.Errorf("Unexpected YAML:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// TestMarshalTOML tests TOML output ordering of values, tables, and arrays of tables
+func TestMarshalTOML(t *testing.T) {
+	got, err := MarshalTOML(document)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := `tags = ["a", "b"]
+title = "Finland's \"living\" peatland"
+
+[colors]
+gradient_angle = 135
+gradient_from = "#c67d3a"
+gradient_to = "#6b8d7d"
+
+[images]
+1920x1080 = "https://example.com/fhd.jpg"
+UHD = "https://example.com/uhd.jpg"
+
+[[steps]]
+gradient_angle = 135.5
+
+[[steps]]
+gradient_angle = 180
+`
+	if string(got) != want {
+		t.Errorf("Unexpected TOML:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// TestMarshalTOML_NotTable tests that non-object documents are rejected
+func TestMarshalTOML_NotTable(t *testing.T) {
+	if _, err := MarshalTOML([]string{"a"}); err == nil {
+		t.Error("Expected error for non-table document")
+	}
+}
@@ -0,0 +1,132 @@
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// MarshalTOML encodes a value as a TOML document
+// The value must encode to a JSON object; null fields are omitted since TOML has no null
+func MarshalTOML(v interface{}) ([]byte, error) {
+	generic, err := toGeneric(v)
+	if err != nil {
+		return nil, err
+	}
+
+	root, ok := generic.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("TOML documents must be tables, got %T", generic)
+	}
+
+	var buf bytes.Buffer
+	if err := writeTOMLTable(&buf, "", root); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeTOMLTable writes a table's scalar fields, then its sub-tables and arrays of tables
+// TOML requires all plain key/value pairs to come before the first nested table header
+func writeTOMLTable(buf *bytes.Buffer, path string, table map[string]interface{}) error {
+	keys := sortedKeys(table)
+
+	for _, key := range keys {
+		value := table[key]
+		if value == nil || isTOMLTable(value) || isTOMLTableArray(value) {
+			continue
+		}
+		buf.WriteString(tomlKey(key))
+		buf.WriteString(" = ")
+		if err := writeTOMLValue(buf, value); err != nil {
+			return err
+		}
+		buf.WriteByte('\n')
+	}
+
+	for _, key := range keys {
+		childPath := tomlKey(key)
+		if path != "" {
+			childPath = path + "." + childPath
+		}
+
+		switch value := table[key].(type) {
+		case map[string]interface{}:
+			fmt.Fprintf(buf, "\n[%s]\n", childPath)
+			if err := writeTOMLTable(buf, childPath, value); err != nil {
+				return err
+			}
+		case []interface{}:
+			if !isTOMLTableArray(value) {
+				continue
+			}
+			for _, item := range value {
+				fmt.Fprintf(buf, "\n[[%s]]\n", childPath)
+				if err := writeTOMLTable(buf, childPath, item.(map[string]interface{})); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func writeTOMLValue(buf *bytes.Buffer, v interface{}) error {
+	switch val := v.(type) {
+	case bool:
+		buf.WriteString(strconv.FormatBool(val))
+	case float64:
+		if i, ok := asInteger(val); ok {
+			buf.WriteString(strconv.FormatInt(i, 10))
+		} else {
+			buf.WriteString(strconv.FormatFloat(val, 'f', -1, 64))
+		}
+	case string:
+		// JSON string escapes are valid TOML basic string escapes
+		quoted, _ := json.Marshal(val)
+		buf.Write(quoted)
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, item := range val {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			if err := writeTOMLValue(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	default:
+		return fmt.Errorf("unsupported type %T for TOML value", v)
+	}
+	return nil
+}
+
+func isTOMLTable(v interface{}) bool {
+	_, ok := v.(map[string]interface{})
+	return ok
+}
+
+// isTOMLTableArray reports whether a value is a non-empty list made up only of tables
+func isTOMLTableArray(v interface{}) bool {
+	list, ok := v.([]interface{})
+	if !ok || len(list) == 0 {
+		return false
+	}
+	for _, item := range list {
+		if !isTOMLTable(item) {
+			return false
+		}
+	}
+	return true
+}
+
+// tomlKey quotes keys that are not valid TOML bare keys
+func tomlKey(key string) string {
+	if isBareKey(key) {
+		return key
+	}
+	quoted, _ := json.Marshal(key)
+	return string(quoted)
+}
@@ -0,0 +1,134 @@
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MarshalYAML encodes a value as a block-style YAML document
+// Strings are always double-quoted so values like "#c67d3a" or "20251019" keep their type
+func MarshalYAML(v interface{}) ([]byte, error) {
+	generic, err := toGeneric(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := writeYAMLBlock(&buf, generic, 0, false); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeYAMLBlock writes a value on its own lines at the given indentation
+// When inline is set, the first line continues a "- " list marker already written
+func writeYAMLBlock(buf *bytes.Buffer, v interface{}, indent int, inline bool) error {
+	pad := strings.Repeat("  ", indent)
+
+	switch val := v.(type) {
+	case map[string]interface{}:
+		if len(val) == 0 {
+			buf.WriteString("{}\n")
+			return nil
+		}
+		for i, key := range sortedKeys(val) {
+			if i > 0 || !inline {
+				buf.WriteString(pad)
+			}
+			buf.WriteString(yamlKey(key))
+			buf.WriteByte(':')
+			if err := writeYAMLField(buf, val[key], indent+1); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		if len(val) == 0 {
+			buf.WriteString("[]\n")
+			return nil
+		}
+		for i, item := range val {
+			if i > 0 || !inline {
+				buf.WriteString(pad)
+			}
+			buf.WriteString("- ")
+			if err := writeYAMLBlock(buf, item, indent+1, true); err != nil {
+				return err
+			}
+		}
+	default:
+		if !inline {
+			buf.WriteString(pad)
+		}
+		if err := writeYAMLScalar(buf, val); err != nil {
+			return err
+		}
+		buf.WriteByte('\n')
+	}
+	return nil
+}
+
+// writeYAMLField writes the value following a "key:" marker
+func writeYAMLField(buf *bytes.Buffer, v interface{}, indent int) error {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		if len(val) == 0 {
+			buf.WriteString(" {}\n")
+			return nil
+		}
+		buf.WriteByte('\n')
+		return writeYAMLBlock(buf, val, indent, false)
+	case []interface{}:
+		if len(val) == 0 {
+			buf.WriteString(" []\n")
+			return nil
+		}
+		buf.WriteByte('\n')
+		return writeYAMLBlock(buf, val, indent, false)
+	default:
+		buf.WriteByte(' ')
+		return writeYAMLBlock(buf, val, indent, true)
+	}
+}
+
+func writeYAMLScalar(buf *bytes.Buffer, v interface{}) error {
+	switch val := v.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(val))
+	case float64:
+		buf.WriteString(strconv.FormatFloat(val, 'f', -1, 64))
+	case string:
+		// JSON string escapes are a subset of YAML double-quoted escapes
+		quoted, _ := json.Marshal(val)
+		buf.Write(quoted)
+	default:
+		return fmt.Errorf("unsupported type %T for YAML", v)
+	}
+	return nil
+}
+
+// yamlKey quotes keys that a YAML parser could read as something other than a string
+func yamlKey(key string) string {
+	if isBareKey(key) && !(key[0] >= '0' && key[0] <= '9') {
+		return key
+	}
+	quoted, _ := json.Marshal(key)
+	return string(quoted)
+}
+
+// isBareKey reports whether a key only contains letters, digits, dashes, and underscores
+func isBareKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, r := range key {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
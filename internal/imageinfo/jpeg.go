@@ -0,0 +1,220 @@
+package imageinfo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"unicode/utf16"
+)
+
+// defaultColorProfile is assumed for JPEGs without an embedded ICC profile
+const defaultColorProfile = "sRGB"
+
+// Info is technical metadata decoded from a JPEG's headers
+type Info struct {
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	FileSize     int64  `json:"file_size,omitempty"`
+	ColorProfile string `json:"color_profile"` // ICC profile description, or sRGB when none is embedded
+	EmbeddedICC  bool   `json:"embedded_icc"`
+	Quality      int    `json:"jpeg_quality,omitempty"` // Estimated from the luminance quantization table
+	Progressive  bool   `json:"progressive"`
+	Exif         bool   `json:"exif"`
+}
+
+// ParseJPEG decodes the metadata in a JPEG's headers
+// data only has to cover the file up to the start of the image data; fileSize is the size of the whole file
+func ParseJPEG(data []byte, fileSize int64) (Info, error) {
+	if len(data) < 2 || data[0] != 0xff || data[1] != 0xd8 {
+		return Info{}, fmt.Errorf("not a JPEG file")
+	}
+
+	info := Info{FileSize: fileSize, ColorProfile: defaultColorProfile}
+	var icc []byte
+	var luminance []uint16
+	foundFrame := false
+
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xff {
+			return Info{}, fmt.Errorf("invalid JPEG marker at offset %d", pos)
+		}
+		marker := data[pos+1]
+
+		// Fill bytes and markers without a payload
+		if marker == 0xff {
+			pos++
+			continue
+		}
+		if marker == 0x01 || (marker >= 0xd0 && marker <= 0xd8) {
+			pos += 2
+			continue
+		}
+
+		// Image data follows; everything of interest is in the headers before it
+		if marker == 0xda || marker == 0xd9 {
+			break
+		}
+
+		end := pos + 2 + int(binary.BigEndian.Uint16(data[pos+2:]))
+		if end > len(data) {
+			break
+		}
+		segment := data[pos+4 : end]
+
+		switch {
+		case marker == 0xe1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")):
+			info.Exif = true
+		case marker == 0xe2 && bytes.HasPrefix(segment, []byte("ICC_PROFILE\x00")) && len(segment) > 14:
+			// Large profiles are split across several APP2 segments, stored in order
+			icc = append(icc, segment[14:]...)
+		case marker == 0xdb:
+			if table := luminanceTable(segment); table != nil {
+				luminance = table
+			}
+		case isStartOfFrame(marker) && len(segment) >= 5:
+			info.Height = int(binary.BigEndian.Uint16(segment[1:]))
+			info.Width = int(binary.BigEndian.Uint16(segment[3:]))
+			info.Progressive = marker == 0xc2 || marker == 0xc6 || marker == 0xca || marker == 0xce
+			foundFrame = true
+		}
+
+		pos = end
+	}
+
+	if !foundFrame {
+		return Info{}, fmt.Errorf("no frame header found in the first %d bytes", len(data))
+	}
+
+	if len(icc) > 0 {
+		info.EmbeddedICC = true
+		if description := iccDescription(icc); description != "" {
+			info.ColorProfile = description
+		}
+	}
+	if luminance != nil {
+		info.Quality = estimateQuality(luminance)
+	}
+
+	return info, nil
+}
+
+// isStartOfFrame reports whether the marker is an SOFn marker (excluding DHT, JPG and DAC, which share the range)
+func isStartOfFrame(marker byte) bool {
+	return marker >= 0xc0 && marker <= 0xcf && marker != 0xc4 && marker != 0xc8 && marker != 0xcc
+}
+
+// luminanceTable returns quantization table 0 from a DQT segment, which may define several tables
+func luminanceTable(segment []byte) []uint16 {
+	for len(segment) > 0 {
+		precision, id := segment[0]>>4, segment[0]&0x0f
+		size := 64
+		if precision == 1 {
+			size = 128
+		}
+		if len(segment) < 1+size {
+			return nil
+		}
+
+		if id == 0 {
+			table := make([]uint16, 64)
+			for i := range table {
+				if precision == 1 {
+					table[i] = binary.BigEndian.Uint16(segment[1+2*i:])
+				} else {
+					table[i] = uint16(segment[1+i])
+				}
+			}
+			return table
+		}
+		segment = segment[1+size:]
+	}
+	return nil
+}
+
+// standardLuminance is the example luminance table from the JPEG spec, which libjpeg scales by quality
+var standardLuminance = [64]uint16{
+	16, 11, 10, 16, 24, 40, 51, 61,
+	12, 12, 14, 19, 26, 58, 60, 55,
+	14, 13, 16, 24, 40, 57, 69, 56,
+	14, 17, 22, 29, 51, 87, 80, 62,
+	18, 22, 37, 56, 68, 109, 103, 77,
+	24, 35, 55, 64, 81, 104, 113, 92,
+	49, 64, 78, 87, 103, 121, 120, 101,
+	72, 92, 95, 98, 112, 100, 103, 99,
+}
+
+// estimateQuality inverts libjpeg's quality scaling using the average ratio to the standard table
+// Encoders with custom tables get the libjpeg quality that compresses about as much
+func estimateQuality(table []uint16) int {
+	var sum, standardSum float64
+	for i, value := range table {
+		sum += float64(value)
+		standardSum += float64(standardLuminance[i])
+	}
+
+	scale := sum * 100 / standardSum
+	var quality float64
+	if scale <= 100 {
+		quality = (200 - scale) / 2
+	} else {
+		quality = 5000 / scale
+	}
+
+	return int(math.Max(1, math.Min(100, math.Round(quality))))
+}
+
+// iccDescription returns the profile description ("desc" tag) of an ICC profile, in v2 or v4 format
+func iccDescription(profile []byte) string {
+	if len(profile) < 132 {
+		return ""
+	}
+
+	count := int(binary.BigEndian.Uint32(profile[128:]))
+	for i := 0; i < count; i++ {
+		entry := 132 + 12*i
+		if entry+12 > len(profile) {
+			return ""
+		}
+		if string(profile[entry:entry+4]) != "desc" {
+			continue
+		}
+
+		offset := int(binary.BigEndian.Uint32(profile[entry+4:]))
+		size := int(binary.BigEndian.Uint32(profile[entry+8:]))
+		if offset < 0 || size < 12 || offset+size > len(profile) {
+			return ""
+		}
+		return decodeDescription(profile[offset : offset+size])
+	}
+	return ""
+}
+
+// decodeDescription decodes a textDescriptionType (ICC v2) or multiLocalizedUnicodeType (ICC v4) tag
+func decodeDescription(tag []byte) string {
+	switch string(tag[:4]) {
+	case "desc":
+		length := int(binary.BigEndian.Uint32(tag[8:]))
+		if length == 0 || 12+length > len(tag) {
+			return ""
+		}
+		return string(bytes.TrimRight(tag[12:12+length], "\x00"))
+	case "mluc":
+		// Use the first localized record
+		if len(tag) < 28 || binary.BigEndian.Uint32(tag[8:]) == 0 {
+			return ""
+		}
+		length := int(binary.BigEndian.Uint32(tag[20:]))
+		offset := int(binary.BigEndian.Uint32(tag[24:]))
+		if offset+length > len(tag) {
+			return ""
+		}
+		units := make([]uint16, length/2)
+		for i := range units {
+			units[i] = binary.BigEndian.Uint16(tag[offset+2*i:])
+		}
+		return string(utf16.Decode(units))
+	}
+	return ""
+}
@@ -0,0 +1,82 @@
+package imageinfo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"testing"
+)
+
+// encodeJPEG encodes a blank image at the given size and quality
+func encodeJPEG(t *testing.T, width, height, quality int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height)), &jpeg.Options{Quality: quality}); err != nil {
+		t.Fatalf("Failed to encode JPEG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// TestParseJPEG tests reading dimensions and estimating quality from an encoded JPEG
+func TestParseJPEG(t *testing.T) {
+	for _, quality := range []int{50, 75, 90} {
+		data := encodeJPEG(t, 64, 32, quality)
+
+		info, err := ParseJPEG(data, int64(len(data)))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if info.Width != 64 || info.Height != 32 || info.FileSize != int64(len(data)) {
+			t.Errorf("Unexpected dimensions or size: %+v", info)
+		}
+		if info.Quality < quality-1 || info.Quality > quality+1 {
+			t.Errorf("Expected quality near %d, got %d", quality, info.Quality)
+		}
+		if info.ColorProfile != defaultColorProfile || info.EmbeddedICC || info.Progressive || info.Exif {
+			t.Errorf("Unexpected metadata for a plain baseline JPEG: %+v", info)
+		}
+	}
+}
+
+// TestParseJPEG_ICCProfile tests reading the description of an embedded ICC v2 profile
+func TestParseJPEG_ICCProfile(t *testing.T) {
+	description := "Display P3"
+
+	// Minimal profile: header, a tag table with one desc tag, and the tag itself
+	tag := append([]byte("desc\x00\x00\x00\x00"), binary.BigEndian.AppendUint32(nil, uint32(len(description)+1))...)
+	tag = append(append(tag, description...), 0)
+	profile := make([]byte, 132, 144+len(tag))
+	binary.BigEndian.PutUint32(profile[128:], 1)
+	profile = append(profile, "desc"...)
+	profile = binary.BigEndian.AppendUint32(profile, 144)
+	profile = binary.BigEndian.AppendUint32(profile, uint32(len(tag)))
+	profile = append(profile, tag...)
+
+	payload := append([]byte("ICC_PROFILE\x00\x01\x01"), profile...)
+	segment := append([]byte{0xff, 0xe2}, binary.BigEndian.AppendUint16(nil, uint16(len(payload)+2))...)
+	segment = append(segment, payload...)
+
+	data := encodeJPEG(t, 8, 8, 80)
+	data = append(append(append([]byte{}, data[:2]...), segment...), data[2:]...)
+
+	info, err := ParseJPEG(data, int64(len(data)))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !info.EmbeddedICC || info.ColorProfile != description {
+		t.Errorf("Expected embedded %q profile, got %+v", description, info)
+	}
+}
+
+// TestParseJPEG_Invalid tests that non-JPEG and truncated data is rejected
+func TestParseJPEG_Invalid(t *testing.T) {
+	if _, err := ParseJPEG([]byte("\x89PNG"), 4); err == nil {
+		t.Error("Expected error for non-JPEG data")
+	}
+
+	data := encodeJPEG(t, 8, 8, 80)
+	if _, err := ParseJPEG(data[:20], 20); err == nil {
+		t.Error("Expected error when the frame header is missing")
+	}
+}
@@ -0,0 +1,55 @@
+// Package logging ties structured logs to the package and request that produced them
+package logging
+
+import (
+	"context"
+	"log/slog"
+)
+
+// requestIDKey is the context key of the request ID
+type requestIDKey struct{}
+
+// Package returns the default logger tagged with a package attribute
+// Resolved per call, so loggers of internal packages follow the format and level configured in main
+func Package(name string) *slog.Logger {
+	return slog.Default().With("package", name)
+}
+
+// WithRequestID returns a context carrying the ID of the HTTP request being served
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID carried by ctx, or "" outside a request
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// Handler adds a request_id attribute to records logged with a request's context (slog.InfoContext etc.)
+type Handler struct {
+	slog.Handler
+}
+
+// NewHandler wraps a handler so records carry the request ID of their context
+func NewHandler(next slog.Handler) *Handler {
+	return &Handler{Handler: next}
+}
+
+// Handle adds the request ID, if any, before passing the record on
+func (h *Handler) Handle(ctx context.Context, record slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+// WithAttrs keeps the request ID wrapper on derived handlers
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &Handler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup keeps the request ID wrapper on derived handlers
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{Handler: h.Handler.WithGroup(name)}
+}
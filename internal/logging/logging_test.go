@@ -0,0 +1,34 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+// TestHandler tests that records logged with a request context carry its ID and package loggers their package
+func TestHandler(t *testing.T) {
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(NewHandler(slog.NewJSONHandler(&buf, nil))))
+	defer slog.SetDefault(previous)
+
+	ctx := WithRequestID(context.Background(), "abc123")
+	Package("cache").InfoContext(ctx, "Loaded entries", "count", 3)
+
+	var record map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Failed to decode log record %q: %v", buf.String(), err)
+	}
+	if record["package"] != "cache" || record["request_id"] != "abc123" || record["count"] != float64(3) {
+		t.Errorf("Unexpected record: %v", record)
+	}
+
+	buf.Reset()
+	slog.Info("Outside a request")
+	if bytes.Contains(buf.Bytes(), []byte("request_id")) {
+		t.Errorf("Expected no request_id outside a request, got %s", buf.String())
+	}
+}
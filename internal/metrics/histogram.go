@@ -0,0 +1,170 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are upper bounds in seconds, spanning cache hits to slow AI calls
+var DefaultBuckets = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// StageLatency records how long each step of the palette pipeline takes
+var StageLatency = NewHistogramVec("dailyhues_stage_duration_seconds", "Duration of palette pipeline stages.", "stage", DefaultBuckets)
+
+var (
+	registryMu sync.Mutex
+	registry   []*HistogramVec
+)
+
+// Histogram counts observations into cumulative buckets
+type Histogram struct {
+	mu      sync.Mutex
+	buckets []float64
+	counts  []uint64 // counts[i] is the number of observations <= buckets[i]
+	sum     float64
+	count   uint64
+}
+
+// Snapshot is a point-in-time copy of a histogram
+type Snapshot struct {
+	Buckets []float64 `json:"buckets"`
+	Counts  []uint64  `json:"counts"`
+	Sum     float64   `json:"sum"`
+	Count   uint64    `json:"count"`
+}
+
+// Observe records a value in seconds
+func (h *Histogram) Observe(seconds float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i, bound := range h.buckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+// Snapshot returns a copy of the histogram's current state
+func (h *Histogram) Snapshot() Snapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return Snapshot{
+		Buckets: append([]float64(nil), h.buckets...),
+		Counts:  append([]uint64(nil), h.counts...),
+		Sum:     h.sum,
+		Count:   h.count,
+	}
+}
+
+// HistogramVec is a family of histograms partitioned by a single label
+type HistogramVec struct {
+	name, help, label string
+	buckets           []float64
+
+	mu       sync.Mutex
+	children map[string]*Histogram
+}
+
+// NewHistogramVec creates and registers a labeled histogram family
+func NewHistogramVec(name, help, label string, buckets []float64) *HistogramVec {
+	v := &HistogramVec{
+		name:     name,
+		help:     help,
+		label:    label,
+		buckets:  buckets,
+		children: make(map[string]*Histogram),
+	}
+
+	registryMu.Lock()
+	registry = append(registry, v)
+	registryMu.Unlock()
+
+	return v
+}
+
+// With returns the histogram for a label value, creating it on first use
+func (v *HistogramVec) With(value string) *Histogram {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	h, ok := v.children[value]
+	if !ok {
+		h = &Histogram{buckets: v.buckets, counts: make([]uint64, len(v.buckets))}
+		v.children[value] = h
+	}
+	return h
+}
+
+// Since records the time elapsed since start, e.g. defer metrics.StageLatency.Since("ai_request", time.Now())
+func (v *HistogramVec) Since(value string, start time.Time) {
+	v.With(value).Observe(time.Since(start).Seconds())
+}
+
+// Snapshots returns a copy of every histogram in the family keyed by label value
+func (v *HistogramVec) Snapshots() map[string]Snapshot {
+	v.mu.Lock()
+	children := make(map[string]*Histogram, len(v.children))
+	for value, h := range v.children {
+		children[value] = h
+	}
+	v.mu.Unlock()
+
+	snapshots := make(map[string]Snapshot, len(children))
+	for value, h := range children {
+		snapshots[value] = h.Snapshot()
+	}
+	return snapshots
+}
+
+// WriteText writes the family in the Prometheus text exposition format
+func (v *HistogramVec) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", v.name, v.help, v.name)
+
+	snapshots := v.Snapshots()
+	values := make([]string, 0, len(snapshots))
+	for value := range snapshots {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+
+	for _, value := range values {
+		s := snapshots[value]
+		label := fmt.Sprintf("%s=%q", v.label, value)
+		for i, bound := range s.Buckets {
+			fmt.Fprintf(w, "%s_bucket{%s,le=\"%s\"} %d\n", v.name, label, formatFloat(bound), s.Counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", v.name, label, s.Count)
+		fmt.Fprintf(w, "%s_sum{%s} %s\n", v.name, label, formatFloat(s.Sum))
+		fmt.Fprintf(w, "%s_count{%s} %d\n", v.name, label, s.Count)
+	}
+}
+
+// Handler serves all registered metrics in the Prometheus text format
+func Handler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	registryMu.Lock()
+	families := append([]*HistogramVec(nil), registry...)
+	registryMu.Unlock()
+
+	for _, family := range families {
+		family.WriteText(w)
+	}
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
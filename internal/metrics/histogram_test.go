@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// TestHistogram_Observe tests cumulative bucket counting
+func TestHistogram_Observe(t *testing.T) {
+	v := &HistogramVec{name: "test_seconds", help: "Test.", label: "stage", buckets: []float64{0.1, 1}, children: map[string]*Histogram{}}
+
+	v.With("a").Observe(0.05)
+	v.With("a").Observe(0.5)
+	v.With("a").Observe(5)
+
+	s := v.With("a").Snapshot()
+	if s.Count != 3 || s.Counts[0] != 1 || s.Counts[1] != 2 || s.Sum != 5.55 {
+		t.Errorf("Unexpected snapshot: %+v", s)
+	}
+}
+
+// TestHistogramVec_WriteText tests the Prometheus text output
+func TestHistogramVec_WriteText(t *testing.T) {
+	v := &HistogramVec{name: "test_seconds", help: "Test.", label: "stage", buckets: []float64{0.1, 1}, children: map[string]*Histogram{}}
+	v.With("download").Observe(0.5)
+
+	var buf bytes.Buffer
+	v.WriteText(&buf)
+
+	want := strings.Join([]string{
+		"# HELP test_seconds Test.",
+		"# TYPE test_seconds histogram",
+		`test_seconds_bucket{stage="download",le="0.1"} 0`,
+		`test_seconds_bucket{stage="download",le="1"} 1`,
+		`test_seconds_bucket{stage="download",le="+Inf"} 1`,
+		`test_seconds_sum{stage="download"} 0.5`,
+		`test_seconds_count{stage="download"} 1`,
+		"",
+	}, "\n")
+	if buf.String() != want {
+		t.Errorf("Unexpected output:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
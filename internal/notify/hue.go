@@ -0,0 +1,64 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// HueLightState is the body of a Philips Hue light state change (bridge API v1)
+type HueLightState struct {
+	On             bool       `json:"on"`
+	XY             [2]float64 `json:"xy"`
+	Brightness     int        `json:"bri"`            // 1 to 254
+	TransitionTime int        `json:"transitiontime"` // In tenths of a second
+}
+
+// hueReply is one item of a bridge's reply; failed changes have an error instead of success
+type hueReply struct {
+	Error *struct {
+		Description string `json:"description"`
+	} `json:"error"`
+}
+
+// SetHueLight changes the state of one light on a Hue bridge
+// apiURL is the bridge's API with the application key, e.g. "http://192.168.1.20/api/<key>"
+func SetHueLight(apiURL, lightID string, state HueLightState) error {
+	body, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal light state: %w", err)
+	}
+
+	url := strings.TrimSuffix(apiURL, "/") + "/lights/" + lightID + "/state"
+	req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("invalid Hue bridge URL: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach Hue bridge: %w", err)
+	}
+	defer resp.Body.Close()
+
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Hue bridge returned status %d: %s", resp.StatusCode, detail)
+	}
+
+	// The bridge answers 200 even when it rejects a change, with the reason in the body
+	var replies []hueReply
+	if err := json.Unmarshal(detail, &replies); err != nil {
+		return fmt.Errorf("unexpected Hue bridge reply: %s", detail)
+	}
+	for _, reply := range replies {
+		if reply.Error != nil {
+			return fmt.Errorf("Hue bridge refused light %s: %s", lightID, reply.Error.Description)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestSetHueLight tests the light state request and that errors in a 200 reply are reported
+func TestSetHueLight(t *testing.T) {
+	var got HueLightState
+	bridge := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			t.Errorf("Expected PUT, got %s", r.Method)
+		}
+		if r.URL.Path == "/api/key/lights/9/state" {
+			fmt.Fprint(w, `[{"error": {"type": 3, "description": "resource, /lights/9/state, not available"}}]`)
+			return
+		}
+		if r.URL.Path != "/api/key/lights/1/state" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("Failed to decode state: %v", err)
+		}
+		fmt.Fprint(w, `[{"success": {"/lights/1/state/on": true}}]`)
+	}))
+	defer bridge.Close()
+
+	state := HueLightState{On: true, XY: [2]float64{0.5275, 0.4194}, Brightness: 188, TransitionTime: 40}
+	if err := SetHueLight(bridge.URL+"/api/key/", "1", state); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got != state {
+		t.Errorf("Expected %+v, got %+v", state, got)
+	}
+
+	if err := SetHueLight(bridge.URL+"/api/key", "9", state); err == nil {
+		t.Error("Expected the bridge's error reply to fail")
+	}
+}
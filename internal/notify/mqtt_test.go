@@ -0,0 +1,96 @@
+package notify
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"testing"
+)
+
+// readPacket reads one MQTT packet, returning its type byte and body
+func readPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	length, multiplier := 0, 1
+	for {
+		digit, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length += int(digit&0x7f) * multiplier
+		multiplier *= 128
+		if digit&0x80 == 0 {
+			break
+		}
+	}
+
+	body := make([]byte, length)
+	_, err = io.ReadFull(r, body)
+	return header, body, err
+}
+
+// TestPublishMQTT tests the connect and publish exchange against a minimal broker
+func TestPublishMQTT(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	type published struct {
+		connect, publish []byte
+		header           byte
+	}
+	received := make(chan published, 1)
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+
+		_, connect, err := readPacket(r)
+		if err != nil {
+			return
+		}
+		conn.Write([]byte{0x20, 0x02, 0x00, 0x00})
+
+		header, publish, err := readPacket(r)
+		if err != nil {
+			return
+		}
+		received <- published{connect, publish, header}
+	}()
+
+	payload := bytes.Repeat([]byte("x"), 300) // long enough for a two byte remaining length
+	if err := PublishMQTT("mqtt://user:secret@"+listener.Addr().String()+"/dailyhues/digest", payload); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	got := <-received
+	if !bytes.Contains(got.connect, []byte("user")) || !bytes.Contains(got.connect, []byte("secret")) {
+		t.Error("Expected credentials in the connect packet")
+	}
+	if got.header != 0x31 {
+		t.Errorf("Expected a retained QoS 0 publish, got header %#x", got.header)
+	}
+	topic := "dailyhues/digest"
+	if string(got.publish[2:2+len(topic)]) != topic || !bytes.Equal(got.publish[2+len(topic):], payload) {
+		t.Errorf("Unexpected publish body: %q", got.publish)
+	}
+}
+
+// TestPublishMQTT_InvalidURL tests that URLs without a topic or with an unknown scheme are rejected
+func TestPublishMQTT_InvalidURL(t *testing.T) {
+	for _, brokerURL := range []string{"mqtt://broker:1883", "http://broker/topic"} {
+		if err := PublishMQTT(brokerURL, nil); err == nil {
+			t.Errorf("Expected error for %s", brokerURL)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const httpTimeout = 30 * time.Second
+
+var httpClient = &http.Client{Timeout: httpTimeout}
+
+// PostJSON sends payload as a JSON POST request, for generic webhooks
+func PostJSON(url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	resp, err := httpClient.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook returned status %d: %s", resp.StatusCode, detail)
+	}
+	return nil
+}
+
+// DiscordMessage is the body of a Discord webhook execution
+type DiscordMessage struct {
+	Content string         `json:"content,omitempty"`
+	Embeds  []DiscordEmbed `json:"embeds,omitempty"`
+}
+
+// DiscordEmbed is a rich embed in a Discord message
+type DiscordEmbed struct {
+	Title       string        `json:"title,omitempty"`
+	Description string        `json:"description,omitempty"`
+	URL         string        `json:"url,omitempty"`
+	Color       int           `json:"color,omitempty"` // 0xRRGGBB
+	Thumbnail   *DiscordImage `json:"thumbnail,omitempty"`
+}
+
+// DiscordImage references an image shown in an embed
+type DiscordImage struct {
+	URL string `json:"url"`
+}
+
+// MaxDiscordEmbeds is the most embeds Discord accepts in one message
+const MaxDiscordEmbeds = 10
+
+// PostDiscord sends a message to a Discord webhook URL
+func PostDiscord(webhookURL string, message DiscordMessage) error {
+	return PostJSON(webhookURL, message)
+}
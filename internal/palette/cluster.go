@@ -0,0 +1,146 @@
+package palette
+
+import (
+	"math"
+	"sort"
+)
+
+// maxClusterIterations bounds k-means when assignments keep oscillating
+const maxClusterIterations = 100
+
+// Family is a group of similar colors found by Cluster
+type Family struct {
+	Center OKLCH
+	Size   int
+}
+
+// Cluster groups colors into at most k families with k-means over hue and lightness
+// Hue is placed on the unit circle so 359° and 1° are neighbours; chroma does not affect grouping
+// but each family's center carries its members' average chroma. Results are sorted by size, largest first
+func Cluster(colors []OKLCH, k int) []Family {
+	if k > len(colors) {
+		k = len(colors)
+	}
+	if k <= 0 {
+		return nil
+	}
+
+	points := make([][3]float64, len(colors))
+	for i, c := range colors {
+		points[i] = clusterPoint(c)
+	}
+
+	centers := initialCenters(points, k)
+	assignments := make([]int, len(points))
+	for iteration := 0; iteration < maxClusterIterations; iteration++ {
+		changed := iteration == 0
+		for i, p := range points {
+			nearest := nearestCenter(centers, p)
+			if nearest != assignments[i] {
+				assignments[i] = nearest
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+
+		sums := make([][3]float64, k)
+		counts := make([]int, k)
+		for i, p := range points {
+			c := assignments[i]
+			for d := range p {
+				sums[c][d] += p[d]
+			}
+			counts[c]++
+		}
+		for c := range centers {
+			if counts[c] == 0 {
+				continue // keep empty clusters where they are
+			}
+			for d := range sums[c] {
+				centers[c][d] = sums[c][d] / float64(counts[c])
+			}
+		}
+	}
+
+	families := make([]Family, k)
+	chroma := make([]float64, k)
+	for i, c := range assignments {
+		families[c].Size++
+		chroma[c] += colors[i].C
+	}
+
+	var result []Family
+	for c, family := range families {
+		if family.Size == 0 {
+			continue
+		}
+		family.Center = OKLCH{
+			L: centers[c][0],
+			C: chroma[c] / float64(family.Size),
+			H: hueDegrees(centers[c][1], centers[c][2]),
+		}
+		result = append(result, family)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Size > result[j].Size })
+	return result
+}
+
+// MeanHue returns the circular mean of hue angles in degrees, so 350° and 10° average to 0°
+func MeanHue(hues []float64) float64 {
+	var x, y float64
+	for _, h := range hues {
+		x += math.Cos(h * math.Pi / 180)
+		y += math.Sin(h * math.Pi / 180)
+	}
+	return hueDegrees(x, y)
+}
+
+// clusterPoint maps a color to (lightness, cos hue, sin hue)
+func clusterPoint(c OKLCH) [3]float64 {
+	return [3]float64{c.L, math.Cos(c.H * math.Pi / 180), math.Sin(c.H * math.Pi / 180)}
+}
+
+// initialCenters picks k deterministic starting centers: the first point, then repeatedly the farthest point
+func initialCenters(points [][3]float64, k int) [][3]float64 {
+	centers := [][3]float64{points[0]}
+	for len(centers) < k {
+		farthest, farthestDistance := 0, -1.0
+		for i, p := range points {
+			if d := distance(p, centers[nearestCenter(centers, p)]); d > farthestDistance {
+				farthest, farthestDistance = i, d
+			}
+		}
+		centers = append(centers, points[farthest])
+	}
+	return centers
+}
+
+func nearestCenter(centers [][3]float64, p [3]float64) int {
+	nearest, nearestDistance := 0, math.Inf(1)
+	for i, c := range centers {
+		if d := distance(p, c); d < nearestDistance {
+			nearest, nearestDistance = i, d
+		}
+	}
+	return nearest
+}
+
+func distance(a, b [3]float64) float64 {
+	var sum float64
+	for d := range a {
+		sum += (a[d] - b[d]) * (a[d] - b[d])
+	}
+	return sum
+}
+
+// hueDegrees converts a direction on the hue circle to degrees in [0, 360)
+func hueDegrees(x, y float64) float64 {
+	hue := math.Atan2(y, x) * 180 / math.Pi
+	if hue < 0 {
+		hue += 360
+	}
+	return hue
+}
@@ -0,0 +1,179 @@
+package palette
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// RGB is a gamma-encoded sRGB color with components in the range [0, 1]
+type RGB struct {
+	R, G, B float64
+}
+
+// OKLCH is a color in the OKLCH space (polar form of OKLab)
+// L is perceived lightness [0, 1], C is chroma, H is hue in degrees [0, 360)
+type OKLCH struct {
+	L, C, H float64
+}
+
+// ParseHex parses a "#rrggbb" or "#rgb" color string, ignoring the alpha of "#rrggbbaa" or "#rgba"
+func ParseHex(s string) (RGB, error) {
+	hex := strings.TrimPrefix(strings.TrimSpace(s), "#")
+	switch len(hex) {
+	case 4, 8:
+		hex = hex[:len(hex)*3/4] // Drop the alpha channel
+	}
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 {
+		return RGB{}, fmt.Errorf("invalid hex color %q", s)
+	}
+
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return RGB{}, fmt.Errorf("invalid hex color %q", s)
+	}
+
+	return RGB{
+		R: float64((value>>16)&0xff) / 255,
+		G: float64((value>>8)&0xff) / 255,
+		B: float64(value&0xff) / 255,
+	}, nil
+}
+
+// Hex formats the color as a lowercase "#rrggbb" string, clamping out-of-gamut components
+func (c RGB) Hex() string {
+	return fmt.Sprintf("#%02x%02x%02x", toByte(c.R), toByte(c.G), toByte(c.B))
+}
+
+// Bytes returns the 0-255 components of the color, clamping out-of-gamut ones as Hex does
+func (c RGB) Bytes() (r, g, b uint8) {
+	return toByte(c.R), toByte(c.G), toByte(c.B)
+}
+
+// Luminance returns the WCAG relative luminance of the color, from 0 (black) to 1 (white)
+func (c RGB) Luminance() float64 {
+	return 0.2126*linearize(c.R) + 0.7152*linearize(c.G) + 0.0722*linearize(c.B)
+}
+
+// ContrastRatio returns the WCAG contrast ratio between two colors, from 1 to 21; the order does not matter
+func ContrastRatio(a, b RGB) float64 {
+	lighter, darker := a.Luminance(), b.Luminance()
+	if darker > lighter {
+		lighter, darker = darker, lighter
+	}
+	return (lighter + 0.05) / (darker + 0.05)
+}
+
+// DeltaE returns the perceptual difference between two colors as the OKLab distance (ΔEOK) scaled by 100
+// Around 2 is barely noticeable; differences above 10 read as different colors
+func DeltaE(a, b RGB) float64 {
+	ca, cb := a.OKLCH(), b.OKLCH()
+	da := ca.C*math.Cos(ca.H*math.Pi/180) - cb.C*math.Cos(cb.H*math.Pi/180)
+	db := ca.C*math.Sin(ca.H*math.Pi/180) - cb.C*math.Sin(cb.H*math.Pi/180)
+	return 100 * math.Sqrt((ca.L-cb.L)*(ca.L-cb.L)+da*da+db*db)
+}
+
+// OKLCH converts the color to the OKLCH color space
+func (c RGB) OKLCH() OKLCH {
+	r, g, b := linearize(c.R), linearize(c.G), linearize(c.B)
+
+	l := math.Cbrt(0.4122214708*r + 0.5363325363*g + 0.0514459929*b)
+	m := math.Cbrt(0.2119034982*r + 0.6806995451*g + 0.1073969566*b)
+	s := math.Cbrt(0.0883024619*r + 0.2817188376*g + 0.6299787005*b)
+
+	L := 0.2104542553*l + 0.7936177850*m - 0.0040720468*s
+	A := 1.9779984951*l - 2.4285922050*m + 0.4505937099*s
+	B := 0.0259040371*l + 0.7827717662*m - 0.8086757660*s
+
+	hue := math.Atan2(B, A) * 180 / math.Pi
+	if hue < 0 {
+		hue += 360
+	}
+
+	return OKLCH{L: L, C: math.Hypot(A, B), H: hue}
+}
+
+// RGB converts the color back to gamma-encoded sRGB
+// Out-of-gamut results are not clipped here; Hex clamps them when formatting
+func (c OKLCH) RGB() RGB {
+	A := c.C * math.Cos(c.H*math.Pi/180)
+	B := c.C * math.Sin(c.H*math.Pi/180)
+
+	l := c.L + 0.3963377774*A + 0.2158037573*B
+	m := c.L - 0.1055613458*A - 0.0638541728*B
+	s := c.L - 0.0894841775*A - 1.2914855480*B
+
+	l, m, s = l*l*l, m*m*m, s*s*s
+
+	return RGB{
+		R: delinearize(+4.0767416621*l - 3.3077115913*m + 0.2309699292*s),
+		G: delinearize(-1.2684380046*l + 2.6097574011*m - 0.3413193965*s),
+		B: delinearize(-0.0041960863*l - 0.7034186147*m + 1.7076147010*s),
+	}
+}
+
+// InterpolateOKLCH blends two colors at position t in [0, 1], taking the shorter way around the hue circle
+func InterpolateOKLCH(from, to OKLCH, t float64) OKLCH {
+	fromHue, toHue := from.H, to.H
+
+	// Achromatic colors have no meaningful hue, so borrow the other endpoint's
+	if from.C < achromaticChroma {
+		fromHue = toHue
+	}
+	if to.C < achromaticChroma {
+		toHue = fromHue
+	}
+
+	return OKLCH{
+		L: lerp(from.L, to.L, t),
+		C: lerp(from.C, to.C, t),
+		H: InterpolateAngle(fromHue, toHue, t),
+	}
+}
+
+// InterpolateAngle blends two angles in degrees along the shorter arc, returning a value in [0, 360)
+func InterpolateAngle(from, to, t float64) float64 {
+	delta := math.Mod(to-from, 360)
+	if delta > 180 {
+		delta -= 360
+	} else if delta < -180 {
+		delta += 360
+	}
+
+	angle := math.Mod(from+delta*t, 360)
+	if angle < 0 {
+		angle += 360
+	}
+	return angle
+}
+
+// achromaticChroma is the chroma below which a color is treated as gray
+const achromaticChroma = 1e-4
+
+func lerp(a, b, t float64) float64 {
+	return a + (b-a)*t
+}
+
+// linearize converts a gamma-encoded sRGB component to linear light
+func linearize(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+// delinearize converts a linear light component to gamma-encoded sRGB
+func delinearize(v float64) float64 {
+	if v <= 0.0031308 {
+		return v * 12.92
+	}
+	return 1.055*math.Pow(v, 1/2.4) - 0.055
+}
+
+func toByte(v float64) uint8 {
+	return uint8(math.Round(math.Max(0, math.Min(1, v)) * 255))
+}
@@ -0,0 +1,656 @@
+package palette
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"strings"
+	"testing"
+)
+
+// TestParseHex tests parsing of long and short hex colors, with and without alpha
+func TestParseHex(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"#c67d3a", "#c67d3a"},
+		{"#C67D3A", "#c67d3a"},
+		{"6b8d7d", "#6b8d7d"},
+		{"#fff", "#ffffff"},
+		{"#c67d3a80", "#c67d3a"},
+		{"#ABC8", "#aabbcc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			c, err := ParseHex(tt.input)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got := c.Hex(); got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+// TestParseHex_Invalid tests that malformed colors are rejected
+func TestParseHex_Invalid(t *testing.T) {
+	for _, input := range []string{"", "#12345", "#gggggg", "red"} {
+		if _, err := ParseHex(input); err == nil {
+			t.Errorf("Expected error for %q", input)
+		}
+	}
+}
+
+// TestContrastRatio tests WCAG contrast ratios against known values
+func TestContrastRatio(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"#000000", "#ffffff", 21},
+		{"#ffffff", "#000000", 21},
+		{"#777777", "#777777", 1},
+		{"#767676", "#ffffff", 4.54},
+	}
+
+	for _, tt := range tests {
+		a, _ := ParseHex(tt.a)
+		b, _ := ParseHex(tt.b)
+		if got := ContrastRatio(a, b); math.Abs(got-tt.want) > 0.01 {
+			t.Errorf("ContrastRatio(%s, %s) = %.3f, want %.2f", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+// TestDeltaE tests the perceptual distance between colors
+func TestDeltaE(t *testing.T) {
+	black, _ := ParseHex("#000000")
+	white, _ := ParseHex("#ffffff")
+	orange, _ := ParseHex("#c67d3a")
+	nearOrange, _ := ParseHex("#c77e3b")
+
+	if got := DeltaE(orange, orange); got != 0 {
+		t.Errorf("Expected identical colors to have distance 0, got %v", got)
+	}
+	if got := DeltaE(black, white); math.Abs(got-100) > 0.1 {
+		t.Errorf("Expected black to white to be 100, got %v", got)
+	}
+	if got := DeltaE(orange, nearOrange); got > 2 {
+		t.Errorf("Expected nearly identical colors to be below 2, got %v", got)
+	}
+}
+
+// TestCSSColor tests wide-gamut conversion of sRGB colors
+func TestCSSColor(t *testing.T) {
+	tests := []struct {
+		hex, space, want string
+	}{
+		{"#ffffff", DisplayP3, "color(display-p3 1 1 1)"},
+		{"#000000", Rec2020, "color(rec2020 0 0 0)"},
+		// Pure sRGB red sits inside both wider gamuts
+		{"#ff0000", DisplayP3, "color(display-p3 0.9175 0.2003 0.1386)"},
+		{"#ff0000", Rec2020, "color(rec2020 0.792 0.231 0.0738)"},
+	}
+
+	for _, tt := range tests {
+		got, err := CSSColor(tt.hex, tt.space)
+		if err != nil {
+			t.Fatalf("CSSColor(%s, %s): %v", tt.hex, tt.space, err)
+		}
+		if got != tt.want {
+			t.Errorf("CSSColor(%s, %s) = %s, want %s", tt.hex, tt.space, got, tt.want)
+		}
+	}
+
+	if _, err := CSSColor("#ffffff", "prophoto-rgb"); err == nil {
+		t.Error("Expected unsupported space to fail")
+	}
+}
+
+// TestOKLCH_RoundTrip tests that converting to OKLCH and back preserves the color
+func TestOKLCH_RoundTrip(t *testing.T) {
+	for _, hex := range []string{"#000000", "#ffffff", "#c67d3a", "#6b8d7d", "#ff0000", "#0000ff"} {
+		c, _ := ParseHex(hex)
+		if got := c.OKLCH().RGB().Hex(); got != hex {
+			t.Errorf("Round trip of %s gave %s", hex, got)
+		}
+	}
+}
+
+// TestOKLCH_KnownValues tests conversion against reference OKLCH values
+func TestOKLCH_KnownValues(t *testing.T) {
+	white, _ := ParseHex("#ffffff")
+	if lch := white.OKLCH(); math.Abs(lch.L-1) > 1e-3 || lch.C > 1e-3 {
+		t.Errorf("Expected white to be L=1 C=0, got %+v", lch)
+	}
+
+	red, _ := ParseHex("#ff0000")
+	lch := red.OKLCH()
+	if math.Abs(lch.L-0.628) > 1e-3 || math.Abs(lch.C-0.2577) > 1e-3 || math.Abs(lch.H-29.23) > 0.1 {
+		t.Errorf("Unexpected OKLCH for red: %+v", lch)
+	}
+}
+
+// TestInterpolateAngle tests that angles blend along the shorter arc
+func TestInterpolateAngle(t *testing.T) {
+	tests := []struct {
+		from, to, t, want float64
+	}{
+		{0, 90, 0.5, 45},
+		{350, 10, 0.5, 0},
+		{10, 350, 0.25, 5},
+		{135, 135, 0.7, 135},
+	}
+
+	for _, tt := range tests {
+		if got := InterpolateAngle(tt.from, tt.to, tt.t); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("InterpolateAngle(%v, %v, %v) = %v, want %v", tt.from, tt.to, tt.t, got, tt.want)
+		}
+	}
+}
+
+// TestInterpolate tests gradient interpolation endpoints and step count
+func TestInterpolate(t *testing.T) {
+	from := Gradient{From: "#c67d3a", To: "#6b8d7d", Angle: 135}
+	to := Gradient{From: "#3a7dc6", To: "#7d6b8d", Angle: 180}
+
+	steps, err := Interpolate(from, to, 30)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(steps) != 30 {
+		t.Fatalf("Expected 30 steps, got %d", len(steps))
+	}
+
+	if steps[0] != from {
+		t.Errorf("Expected first step %+v, got %+v", from, steps[0])
+	}
+
+	if steps[29] != to {
+		t.Errorf("Expected last step %+v, got %+v", to, steps[29])
+	}
+}
+
+// TestInterpolate_TooFewSteps tests that fewer than two steps is rejected
+func TestInterpolate_TooFewSteps(t *testing.T) {
+	g := Gradient{From: "#000000", To: "#ffffff", Angle: 0}
+	if _, err := Interpolate(g, g, 1); err == nil {
+		t.Error("Expected error for steps < 2")
+	}
+}
+
+// TestGradientFromColors tests extraction of gradient fields from an analysis map
+func TestGradientFromColors(t *testing.T) {
+	colors := map[string]interface{}{
+		"gradient_from":  "#c67d3a",
+		"gradient_to":    "#6b8d7d",
+		"gradient_angle": float64(135),
+	}
+
+	g, err := GradientFromColors(colors)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if g.From != "#c67d3a" || g.To != "#6b8d7d" || g.Angle != 135 {
+		t.Errorf("Unexpected gradient: %+v", g)
+	}
+
+	if _, err := GradientFromColors(map[string]interface{}{"gradient_from": "#000000"}); err == nil {
+		t.Error("Expected error for incomplete colors")
+	}
+
+	g, err = GradientFromColors(map[string]interface{}{"gradient_from": "#ABC", "gradient_to": "#6b8d7dff", "gradient_angle": 90})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if g.From != "#aabbcc" || g.To != "#6b8d7d" {
+		t.Errorf("Expected the stops as #rrggbb, got %+v", g)
+	}
+}
+
+// TestGradient_Strings tests the CSS and Hyprland gradient renderings
+func TestGradient_Strings(t *testing.T) {
+	g := Gradient{From: "#c67d3a", To: "#6b8d7d", Angle: 135}
+
+	if got, want := g.CSS(), "linear-gradient(135deg, #c67d3a, #6b8d7d)"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	if got, want := g.Hyprland(), "rgba(c67d3aff) rgba(6b8d7dff) 135deg"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	g.Angle = 157.5
+	if got, want := g.CSS(), "linear-gradient(157.5deg, #c67d3a, #6b8d7d)"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+// TestGradient_InactiveBorder tests that the derived inactive color is muted and darker than the gradient
+func TestGradient_InactiveBorder(t *testing.T) {
+	g := Gradient{From: "#c67d3a", To: "#6b8d7d", Angle: 135}
+
+	hex, err := g.InactiveBorder()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	inactive, _ := ParseHex(hex)
+	from, _ := ParseHex(g.From)
+	if lch, ref := inactive.OKLCH(), from.OKLCH(); lch.C >= ref.C || lch.L >= ref.L {
+		t.Errorf("Expected %s to be more muted and darker than %s", hex, g.From)
+	}
+
+	if _, err := (Gradient{From: "red", To: "#000000"}).InactiveBorder(); err == nil {
+		t.Error("Expected error for invalid color")
+	}
+}
+
+// TestGradient_Notifications tests that urgency colors are dark backgrounds with a reddish critical level
+func TestGradient_Notifications(t *testing.T) {
+	g := Gradient{From: "#c67d3a", To: "#6b8d7d", Angle: 135}
+
+	n, err := g.Notifications()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	low, _ := ParseHex(n.Low)
+	normal, _ := ParseHex(n.Normal)
+	critical, _ := ParseHex(n.Critical)
+
+	if low.OKLCH().C >= normal.OKLCH().C {
+		t.Errorf("Expected low (%s) to be more muted than normal (%s)", n.Low, n.Normal)
+	}
+	if l := normal.OKLCH().L; l > 0.5 {
+		t.Errorf("Expected normal background to be dark, got L=%v", l)
+	}
+	if h := critical.OKLCH().H; h > 60 && h < 340 {
+		t.Errorf("Expected critical (%s) to be red, got hue %v", n.Critical, h)
+	}
+}
+
+// TestGradient_Overlay tests that overlay text colors meet their contrast minimums, lightening a dark accent if needed
+func TestGradient_Overlay(t *testing.T) {
+	for _, g := range []Gradient{
+		{From: "#c67d3a", To: "#6b8d7d", Angle: 135},
+		{From: "#1a1a40", To: "#0b0b0b", Angle: 180},
+	} {
+		o, err := g.Overlay()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		background, _ := ParseHex(o.Background)
+		for _, check := range []struct {
+			name    string
+			color   string
+			minimum float64
+		}{
+			{"text", o.Text, overlayTextContrast},
+			{"text_muted", o.TextMuted, overlayMutedContrast},
+			{"accent", o.Accent, overlayMutedContrast},
+		} {
+			c, _ := ParseHex(check.color)
+			if ratio := ContrastRatio(c, background); ratio < check.minimum {
+				t.Errorf("%s: expected %s to contrast at least %v with %s, got %.2f", g.From, check.name, check.minimum, o.Background, ratio)
+			}
+		}
+
+		accent, _ := ParseHex(o.Accent)
+		accentText, _ := ParseHex(o.AccentText)
+		if ratio := ContrastRatio(accent, accentText); ratio < overlayMutedContrast {
+			t.Errorf("%s: expected accent text to contrast at least %v with the accent, got %.2f", g.From, overlayMutedContrast, ratio)
+		}
+	}
+}
+
+// TestGradient_Lighting tests that lamp colors keep the gradient's hues, and that grays become warm white
+func TestGradient_Lighting(t *testing.T) {
+	g := Gradient{From: "#c67d3a", To: "#6b8d7d", Angle: 135}
+
+	lights, err := g.Lighting()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(lights) != 4 {
+		t.Fatalf("Expected 4 lights, got %d", len(lights))
+	}
+
+	// An orange lamp is reddish in xy (x > y), a teal one is not
+	if xy := lights[0].XY; xy[0] <= xy[1] {
+		t.Errorf("Expected the first light to be orange, got xy %v", xy)
+	}
+	if xy := lights[2].XY; xy[0] >= xy[1] {
+		t.Errorf("Expected the last light to be teal, got xy %v", xy)
+	}
+	for i, light := range lights {
+		if light.Brightness < 1 || light.Brightness > 254 {
+			t.Errorf("Expected light %d brightness within 1-254, got %d", i, light.Brightness)
+		}
+	}
+	if lights[3].XY != lights[1].XY || lights[3].Brightness >= lights[1].Brightness {
+		t.Errorf("Expected a dim ambient light in the midpoint's color, got %+v and %+v", lights[3], lights[1])
+	}
+
+	gray, err := Gradient{From: "#808080", To: "#202020"}.Lighting()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if gray[0].XY != warmWhite {
+		t.Errorf("Expected gray to become warm white, got %v", gray[0].XY)
+	}
+	if gray[2].Brightness >= gray[0].Brightness || gray[2].Brightness < lightMinBrightness {
+		t.Errorf("Expected a darker color to give dimmer light, got %d and %d", gray[0].Brightness, gray[2].Brightness)
+	}
+}
+
+// TestCluster tests that colors group by hue and lightness, largest family first
+func TestCluster(t *testing.T) {
+	colors := []OKLCH{
+		{L: 0.6, C: 0.1, H: 355},
+		{L: 0.62, C: 0.12, H: 5},
+		{L: 0.58, C: 0.1, H: 0},
+		{L: 0.5, C: 0.1, H: 240},
+		{L: 0.52, C: 0.1, H: 250},
+	}
+
+	families := Cluster(colors, 2)
+	if len(families) != 2 {
+		t.Fatalf("Expected 2 families, got %d", len(families))
+	}
+
+	if families[0].Size != 3 || families[1].Size != 2 {
+		t.Errorf("Expected family sizes 3 and 2, got %d and %d", families[0].Size, families[1].Size)
+	}
+
+	// The reds straddle 0°, so their center must not average to 120°
+	if h := families[0].Center.H; h > 10 && h < 350 {
+		t.Errorf("Expected red family hue near 0, got %v", h)
+	}
+
+	if h := families[1].Center.H; math.Abs(h-245) > 1e-6 {
+		t.Errorf("Expected blue family hue 245, got %v", h)
+	}
+
+	if got := Cluster(colors[:1], 5); len(got) != 1 {
+		t.Errorf("Expected k to be capped at the number of colors, got %d families", len(got))
+	}
+}
+
+// TestMeanHue tests that hues average around the circle
+func TestMeanHue(t *testing.T) {
+	if got := MeanHue([]float64{350, 10}); math.Abs(got) > 1e-9 && math.Abs(got-360) > 1e-9 {
+		t.Errorf("Expected mean hue 0, got %v", got)
+	}
+	if got := MeanHue([]float64{80, 100}); math.Abs(got-90) > 1e-9 {
+		t.Errorf("Expected mean hue 90, got %v", got)
+	}
+}
+
+// TestNormalizePolicy tests each normalization option and that the zero policy changes nothing
+func TestNormalizePolicy(t *testing.T) {
+	colors := map[string]interface{}{
+		"gradient_from":  "#AABBCCFF",
+		"gradient_to":    "#c7d",
+		"gradient_angle": float64(142),
+		"note":           "#not-a-color",
+	}
+
+	tests := []struct {
+		name     string
+		policy   NormalizePolicy
+		from, to string
+		angle    float64
+	}{
+		{"zero", NormalizePolicy{}, "#AABBCCFF", "#c7d", 142},
+		{"lower long no alpha", NormalizePolicy{HexCase: HexLower, HexLength: HexLong, StripAlpha: true}, "#aabbcc", "#cc77dd", 142},
+		{"upper short", NormalizePolicy{HexCase: HexUpper, HexLength: HexShort, StripAlpha: true}, "#ABC", "#C7D", 142},
+		{"snap", NormalizePolicy{AngleStep: 15}, "#AABBCCFF", "#c7d", 135},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.policy.Apply(colors)
+			if got["gradient_from"] != tt.from || got["gradient_to"] != tt.to || got["gradient_angle"] != tt.angle {
+				t.Errorf("Unexpected result: %v", got)
+			}
+			if got["note"] != "#not-a-color" {
+				t.Errorf("Expected non-color to be unchanged, got %v", got["note"])
+			}
+		})
+	}
+
+	if colors["gradient_from"] != "#AABBCCFF" {
+		t.Error("Expected input map to be left unmodified")
+	}
+
+	if got := (NormalizePolicy{AngleStep: 15}).Apply(map[string]interface{}{"gradient_angle": float64(355)}); got["gradient_angle"] != float64(0) {
+		t.Errorf("Expected 355 to snap to 0, got %v", got["gradient_angle"])
+	}
+}
+
+// TestConstrainAngles tests moving angles to the nearest allowed one, wrapping around 360
+func TestConstrainAngles(t *testing.T) {
+	cardinal := []float64{0, 90, 180, 270}
+
+	tests := []struct {
+		angle, want float64
+	}{
+		{44, 0},
+		{46, 90},
+		{135, 90}, // Tie goes to the angle listed first
+		{350, 0},
+		{-100, 270},
+		{400, 0},
+	}
+	for _, tt := range tests {
+		if got := NearestAngle(tt.angle, cardinal); got != tt.want {
+			t.Errorf("NearestAngle(%v) = %v, want %v", tt.angle, got, tt.want)
+		}
+	}
+
+	colors := map[string]interface{}{"gradient_from": "#aabbcc", "gradient_angle": float64(142)}
+	got := ConstrainAngles(colors, cardinal)
+	if got["gradient_angle"] != float64(180) || got["gradient_from"] != "#aabbcc" {
+		t.Errorf("Unexpected result: %v", got)
+	}
+	if colors["gradient_angle"] != float64(142) {
+		t.Error("Expected input map to be left unmodified")
+	}
+	if got := ConstrainAngles(colors, nil); got["gradient_angle"] != float64(142) {
+		t.Errorf("Expected no constraint to leave the angle, got %v", got["gradient_angle"])
+	}
+}
+
+// TestHistogram tests bin shares, divergence and dominant colors
+func TestHistogram(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 1))
+	img.Set(0, 0, color.RGBA{R: 255, A: 255})
+	img.Set(1, 0, color.RGBA{R: 250, A: 255})
+	img.Set(2, 0, color.RGBA{R: 255, A: 255})
+	img.Set(3, 0, color.RGBA{B: 255, A: 255})
+
+	h := NewHistogram(img)
+
+	if got := h.Dominant(5); len(got) != 2 || got[0] != "#ef1010" || got[1] != "#1010ef" {
+		t.Errorf("Expected red then blue, got %v", got)
+	}
+
+	if d := h.Divergence(h); d > 1e-9 {
+		t.Errorf("Expected no divergence from itself, got %v", d)
+	}
+
+	blue := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	for _, p := range []image.Point{{0, 0}, {0, 1}, {1, 0}, {1, 1}} {
+		blue.Set(p.X, p.Y, color.RGBA{B: 255, A: 255})
+	}
+	if d := h.Divergence(NewHistogram(blue)); math.Abs(d-0.75) > 1e-9 {
+		t.Errorf("Expected divergence 0.75, got %v", d)
+	}
+}
+
+// TestRegionAverages tests that the top, bottom and center regions are averaged separately
+func TestRegionAverages(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 20, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 20; x++ {
+			c := color.RGBA{R: 128, G: 128, B: 128, A: 255}
+			switch {
+			case y < 2:
+				c = color.RGBA{R: 255, A: 255}
+			case y >= 18:
+				c = color.RGBA{B: 255, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+
+	regions := RegionAverages(img)
+	if regions.Top != "#ff0000" || regions.Bottom != "#0000ff" || regions.Center != "#808080" {
+		t.Errorf("Unexpected regions: %+v", regions)
+	}
+}
+
+// TestFallbackGradient tests that a gradient picked from an image's colors is readable and has two hues
+func TestFallbackGradient(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 40; x++ {
+			if x < 20 {
+				img.Set(x, y, color.RGBA{R: 0xe0, G: 0x90, B: 0x30, A: 255})
+			} else {
+				img.Set(x, y, color.RGBA{R: 0x40, G: 0x90, B: 0xe0, A: 255})
+			}
+		}
+	}
+
+	gradient := FallbackGradient(NewHistogram(img))
+	from, err := ParseHex(gradient.From)
+	if err != nil {
+		t.Fatalf("Invalid from color %q", gradient.From)
+	}
+	to, err := ParseHex(gradient.To)
+	if err != nil {
+		t.Fatalf("Invalid to color %q", gradient.To)
+	}
+	if ContrastRatio(from, RGB{}) < fallbackMinContrast {
+		t.Errorf("Expected black text to be readable on %s", gradient.From)
+	}
+	if hueDistance(from.OKLCH().H, to.OKLCH().H) < fallbackMinHueDistance {
+		t.Errorf("Expected two hues, got %s and %s", gradient.From, gradient.To)
+	}
+	if gradient.Angle != fallbackAngle {
+		t.Errorf("Expected angle %d, got %v", fallbackAngle, gradient.Angle)
+	}
+
+	// A gray image still yields a usable gradient
+	grayImage := image.NewGray(image.Rect(0, 0, 10, 10))
+	for i := range grayImage.Pix {
+		grayImage.Pix[i] = 0x30
+	}
+	gray := FallbackGradient(NewHistogram(grayImage))
+	if _, err := ParseHex(gray.From); err != nil || gray.From == gray.To {
+		t.Errorf("Unexpected gradient for a gray image: %+v", gray)
+	}
+}
+
+// TestGrayLevels tests the shades of 1-bit and grayscale e-paper displays
+func TestGrayLevels(t *testing.T) {
+	levels := GrayLevels(4)
+	var hexes []string
+	for _, level := range levels {
+		hexes = append(hexes, level.Hex())
+	}
+	if strings.Join(hexes, ",") != "#000000,#555555,#aaaaaa,#ffffff" {
+		t.Errorf("Unexpected levels %v", hexes)
+	}
+	if len(GrayLevels(1)) != 2 {
+		t.Error("Expected at least black and white")
+	}
+
+	orange, _ := ParseHex("#c67d3a")
+	if level := NearestLevel(orange, levels); level != 2 {
+		t.Errorf("Expected orange to be closest to the light gray, got level %d", level)
+	}
+}
+
+// TestDitherLevel tests that dithering mixes the two shades around a color in proportion to its lightness
+func TestDitherLevel(t *testing.T) {
+	levels := GrayLevels(2)
+	white, _ := ParseHex("#ffffff")
+	middle := OKLCH{L: 0.5}.RGB()
+
+	lit := 0
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			if DitherLevel(white, levels, x, y) != 1 {
+				t.Fatal("Expected white to stay white")
+			}
+			lit += DitherLevel(middle, levels, x, y)
+		}
+	}
+	if lit != 8 {
+		t.Errorf("Expected half of the pixels lit for middle gray, got %d of 16", lit)
+	}
+}
+
+// TestGradient_Prompt tests that prompt segments follow the gradient and that their text is readable
+func TestGradient_Prompt(t *testing.T) {
+	for _, g := range []Gradient{
+		{From: "#c67d3a", To: "#6b8d7d", Angle: 135},
+		{From: "#f0f0e8", To: "#0b0b0b", Angle: 180},
+	} {
+		p, err := g.Prompt(3)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(p.Segments) != 3 {
+			t.Fatalf("Expected 3 segments, got %d", len(p.Segments))
+		}
+		if p.Segments[0].Background != g.From || p.Segments[2].Background != g.To {
+			t.Errorf("%s: expected segments from %s to %s, got %+v", g.From, g.From, g.To, p.Segments)
+		}
+		for i, segment := range p.Segments {
+			background, _ := ParseHex(segment.Background)
+			foreground, _ := ParseHex(segment.Foreground)
+			if ratio := ContrastRatio(foreground, background); ratio < promptSegmentContrast {
+				t.Errorf("%s: expected segment %d text to contrast at least %v, got %.2f", g.From, i+1, promptSegmentContrast, ratio)
+			}
+		}
+		if p.Accent != g.From || p.Error == "" {
+			t.Errorf("%s: unexpected accent %q or error %q", g.From, p.Accent, p.Error)
+		}
+	}
+}
+
+// TestGradient_Editor tests that editor borders match the window borders and that status line text is readable
+func TestGradient_Editor(t *testing.T) {
+	g := Gradient{From: "#c67d3a", To: "#6b8d7d", Angle: 135}
+
+	e, err := g.Editor()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	inactive, _ := g.InactiveBorder()
+	if e.Border != g.From || e.InactiveBorder != inactive {
+		t.Errorf("Expected borders %s and %s, got %s and %s", g.From, inactive, e.Border, e.InactiveBorder)
+	}
+
+	for _, pair := range [][2]string{
+		{e.StatusLineText, e.StatusLine},
+		{e.StatusLineNCText, e.StatusLineNC},
+		{e.CursorLineNr, e.CursorLine},
+	} {
+		text, _ := ParseHex(pair[0])
+		background, _ := ParseHex(pair[1])
+		if ratio := ContrastRatio(text, background); ratio < promptSegmentContrast {
+			t.Errorf("Expected %s to contrast at least %v with %s, got %.2f", pair[0], promptSegmentContrast, pair[1], ratio)
+		}
+	}
+}
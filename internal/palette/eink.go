@@ -0,0 +1,54 @@
+package palette
+
+import "math"
+
+// bayer4 is the 4x4 ordered dithering matrix, with thresholds in sixteenths
+var bayer4 = [4][4]float64{
+	{0, 8, 2, 10},
+	{12, 4, 14, 6},
+	{3, 11, 1, 9},
+	{15, 7, 13, 5},
+}
+
+// GrayLevels returns n evenly spaced grays from black to white, the shades an e-paper display can show
+// n is 2 for 1-bit displays and 4 or 16 for grayscale ones; fewer than 2 is treated as 2
+func GrayLevels(n int) []RGB {
+	n = max(n, 2)
+	levels := make([]RGB, n)
+	for i := range levels {
+		v := float64(i) / float64(n-1)
+		levels[i] = RGB{R: v, G: v, B: v}
+	}
+	return levels
+}
+
+// NearestLevel returns the index of the gray that is closest to c in perceived lightness
+func NearestLevel(c RGB, levels []RGB) int {
+	lightness := c.OKLCH().L
+	nearest, best := 0, 2.0
+	for i, level := range levels {
+		if d := math.Abs(level.OKLCH().L - lightness); d < best {
+			nearest, best = i, d
+		}
+	}
+	return nearest
+}
+
+// DitherLevel returns the index of the gray to draw at pixel (x, y) so that an area of c matches its perceived lightness
+// Ordered dithering mixes the two grays around c in a fixed pattern, which keeps flat areas free of noise on e-paper
+// levels must be sorted from dark to light, as GrayLevels returns them
+func DitherLevel(c RGB, levels []RGB, x, y int) int {
+	lightness := c.OKLCH().L
+	for i := 1; i < len(levels); i++ {
+		lower, upper := levels[i-1].OKLCH().L, levels[i].OKLCH().L
+		if lightness > upper && i < len(levels)-1 {
+			continue
+		}
+		fraction := (lightness - lower) / (upper - lower)
+		if fraction > (bayer4[y%4][x%4]+0.5)/16 {
+			return i
+		}
+		return i - 1
+	}
+	return 0
+}
@@ -0,0 +1,64 @@
+package palette
+
+import "math"
+
+const (
+	// fallbackCandidates is how many of an image's most common colors FallbackGradient chooses from
+	fallbackCandidates = 12
+	// fallbackMinContrast keeps black text readable on the first stop, as the AI prompt asks
+	fallbackMinContrast = 4.5
+	// fallbackMinHueDistance is how far apart in degrees the stops' hues must be to form a two-color gradient
+	fallbackMinHueDistance = 30
+	// fallbackAngle runs the gradient from the top left to the bottom right
+	fallbackAngle = 135
+)
+
+// FallbackGradient picks a gradient from an image's own colors, for when no AI analysis is available
+// The first stop is the most colorful common color that black text is readable on, lightened if none is;
+// the second is the most colorful other common color of a different hue, or a hue-shifted first stop
+func FallbackGradient(h Histogram) Gradient {
+	var candidates []OKLCH
+	for _, hex := range h.Dominant(fallbackCandidates) {
+		if rgb, err := ParseHex(hex); err == nil {
+			candidates = append(candidates, rgb.OKLCH())
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = []OKLCH{{L: 0.7, C: 0.1, H: 250}}
+	}
+
+	black := RGB{}
+	from, found := mostColorful(candidates, func(c OKLCH) bool {
+		return ContrastRatio(c.RGB(), black) >= fallbackMinContrast
+	})
+	if !found {
+		from, _ = mostColorful(candidates, func(OKLCH) bool { return true })
+		from.L = 0.75
+	}
+
+	to, found := mostColorful(candidates, func(c OKLCH) bool {
+		return c.L >= 0.45 && hueDistance(c.H, from.H) >= fallbackMinHueDistance
+	})
+	if !found {
+		to = OKLCH{L: from.L - 0.1, C: from.C, H: math.Mod(from.H+fallbackMinHueDistance, 360)}
+	}
+
+	return Gradient{From: from.RGB().Hex(), To: to.RGB().Hex(), Angle: fallbackAngle}
+}
+
+// mostColorful returns the candidate with the highest chroma among those accepted
+func mostColorful(candidates []OKLCH, accept func(OKLCH) bool) (OKLCH, bool) {
+	var best OKLCH
+	found := false
+	for _, c := range candidates {
+		if accept(c) && (!found || c.C > best.C) {
+			best, found = c, true
+		}
+	}
+	return best, found
+}
+
+// hueDistance returns the distance between two hues in degrees along the shorter arc
+func hueDistance(a, b float64) float64 {
+	return math.Abs(math.Mod(a-b+540, 360) - 180)
+}
@@ -0,0 +1,123 @@
+package palette
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Wide-gamut color spaces, named as in the CSS color() function
+const (
+	DisplayP3 = "display-p3"
+	Rec2020   = "rec2020"
+)
+
+// WideGamutSpaces lists the supported wide-gamut color spaces
+var WideGamutSpaces = []string{DisplayP3, Rec2020}
+
+// Matrices from CSS Color Module Level 4, all relative to the D65 white point
+var (
+	linearSRGBToXYZ = [3][3]float64{
+		{0.41239079926595934, 0.357584339383878, 0.1804807884018343},
+		{0.21263900587151027, 0.715168678767756, 0.07219231536073371},
+		{0.01933081871559182, 0.11919477979462598, 0.9505321522496607},
+	}
+	xyzToLinearSRGB = [3][3]float64{
+		{3.2409699419045226, -1.537383177570094, -0.4986107602930034},
+		{-0.9692436362808796, 1.8759675015077202, 0.04155505740717559},
+		{0.05563007969699366, -0.20397695888897652, 1.0569715142428786},
+	}
+	xyzToLinearP3 = [3][3]float64{
+		{2.4934969119414254, -0.9313836179191239, -0.40271078445071684},
+		{-0.8294889695615747, 1.7626640603183463, 0.023624685841943577},
+		{0.03584583024378447, -0.07617238926804182, 0.9568845240076872},
+	}
+	xyzToLinearRec2020 = [3][3]float64{
+		{1.7166511879712674, -0.35567078377639233, -0.25336628137365974},
+		{-0.6666843518324892, 1.6164812366349395, 0.01576854581391113},
+		{0.017639857445310783, -0.042770613257808524, 0.9421031212354738},
+	}
+)
+
+// Rec. 2020 transfer function constants
+const (
+	rec2020Alpha = 1.09929682680944
+	rec2020Beta  = 0.018053968510807
+)
+
+// InGamut converts the color to a wide-gamut space, returning gamma-encoded components in [0, 1]
+// Every sRGB color fits in both spaces, so the same color is only expressed with different numbers
+func (c RGB) InGamut(space string) ([3]float64, error) {
+	linear := [3]float64{linearize(c.R), linearize(c.G), linearize(c.B)}
+	xyz := multiply(linearSRGBToXYZ, linear)
+
+	var result [3]float64
+	switch space {
+	case DisplayP3:
+		// Display P3 shares the sRGB transfer function
+		for i, v := range multiply(xyzToLinearP3, xyz) {
+			result[i] = delinearize(v)
+		}
+	case Rec2020:
+		for i, v := range multiply(xyzToLinearRec2020, xyz) {
+			result[i] = rec2020Encode(v)
+		}
+	default:
+		return result, fmt.Errorf("unsupported color space %q", space)
+	}
+
+	for i, v := range result {
+		result[i] = math.Max(0, math.Min(1, v))
+	}
+	return result, nil
+}
+
+// CSSColor formats a hex color in a wide-gamut space using the CSS color() syntax,
+// e.g. color(display-p3 0.7359 0.5032 0.2785)
+func CSSColor(hex, space string) (string, error) {
+	rgb, err := ParseHex(hex)
+	if err != nil {
+		return "", err
+	}
+	components, err := rgb.InGamut(space)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("color(%s %s %s %s)", space,
+		formatComponent(components[0]), formatComponent(components[1]), formatComponent(components[2])), nil
+}
+
+// CSSIn returns the gradient as a CSS linear-gradient() value with its stops in a wide-gamut space
+func (g Gradient) CSSIn(space string) (string, error) {
+	from, err := CSSColor(g.From, space)
+	if err != nil {
+		return "", err
+	}
+	to, err := CSSColor(g.To, space)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("linear-gradient(%sdeg, %s, %s)", formatAngle(g.Angle), from, to), nil
+}
+
+// rec2020Encode applies the Rec. 2020 transfer function to a linear component
+func rec2020Encode(v float64) float64 {
+	if v < rec2020Beta {
+		return 4.5 * v
+	}
+	return rec2020Alpha*math.Pow(v, 0.45) - (rec2020Alpha - 1)
+}
+
+// multiply applies a 3x3 matrix to a vector
+func multiply(m [3][3]float64, v [3]float64) [3]float64 {
+	return [3]float64{
+		m[0][0]*v[0] + m[0][1]*v[1] + m[0][2]*v[2],
+		m[1][0]*v[0] + m[1][1]*v[1] + m[1][2]*v[2],
+		m[2][0]*v[0] + m[2][1]*v[1] + m[2][2]*v[2],
+	}
+}
+
+// formatComponent prints a color component with four decimals, enough to round-trip 8-bit sRGB
+func formatComponent(v float64) string {
+	return strconv.FormatFloat(math.Round(v*10000)/10000, 'f', -1, 64)
+}
@@ -0,0 +1,394 @@
+package palette
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Gradient is the two-stop border gradient produced by the AI analysis
+type Gradient struct {
+	From  string  `json:"gradient_from"`
+	To    string  `json:"gradient_to"`
+	Angle float64 `json:"gradient_angle"`
+}
+
+// GradientFromColors extracts the gradient fields from an analysis colors map
+// The stops are rewritten as "#rrggbb", so renderers never see the cached display form (short, uppercase or with alpha)
+func GradientFromColors(colors map[string]interface{}) (Gradient, error) {
+	fromHex, ok := colors["gradient_from"].(string)
+	if !ok {
+		return Gradient{}, fmt.Errorf("missing gradient_from color")
+	}
+	from, err := ParseHex(fromHex)
+	if err != nil {
+		return Gradient{}, fmt.Errorf("gradient_from: %w", err)
+	}
+
+	toHex, ok := colors["gradient_to"].(string)
+	if !ok {
+		return Gradient{}, fmt.Errorf("missing gradient_to color")
+	}
+	to, err := ParseHex(toHex)
+	if err != nil {
+		return Gradient{}, fmt.Errorf("gradient_to: %w", err)
+	}
+
+	// JSON numbers decode as float64, but accept ints set directly in code too
+	var angle float64
+	switch v := colors["gradient_angle"].(type) {
+	case float64:
+		angle = v
+	case int:
+		angle = float64(v)
+	default:
+		return Gradient{}, fmt.Errorf("missing gradient_angle")
+	}
+
+	return Gradient{From: from.Hex(), To: to.Hex(), Angle: angle}, nil
+}
+
+// Interpolate returns the given number of gradients evenly spaced between from and to (inclusive)
+// Colors are blended in OKLCH so midpoints stay vivid instead of turning muddy
+func Interpolate(from, to Gradient, steps int) ([]Gradient, error) {
+	if steps < 2 {
+		return nil, fmt.Errorf("steps must be at least 2")
+	}
+
+	fromStart, err := ParseHex(from.From)
+	if err != nil {
+		return nil, err
+	}
+	fromEnd, err := ParseHex(from.To)
+	if err != nil {
+		return nil, err
+	}
+	toStart, err := ParseHex(to.From)
+	if err != nil {
+		return nil, err
+	}
+	toEnd, err := ParseHex(to.To)
+	if err != nil {
+		return nil, err
+	}
+
+	startA, startB := fromStart.OKLCH(), toStart.OKLCH()
+	endA, endB := fromEnd.OKLCH(), toEnd.OKLCH()
+
+	result := make([]Gradient, steps)
+	for i := range result {
+		t := float64(i) / float64(steps-1)
+		result[i] = Gradient{
+			From:  InterpolateOKLCH(startA, startB, t).RGB().Hex(),
+			To:    InterpolateOKLCH(endA, endB, t).RGB().Hex(),
+			Angle: InterpolateAngle(from.Angle, to.Angle, t),
+		}
+	}
+
+	return result, nil
+}
+
+// InactiveBorder derives a muted, lower contrast border color for unfocused windows
+// Used for analyses made before the model returned inactive_border itself
+func (g Gradient) InactiveBorder() (string, error) {
+	from, err := ParseHex(g.From)
+	if err != nil {
+		return "", err
+	}
+	to, err := ParseHex(g.To)
+	if err != nil {
+		return "", err
+	}
+
+	mid := InterpolateOKLCH(from.OKLCH(), to.OKLCH(), 0.5)
+	mid.C *= inactiveChromaScale
+	mid.L = lerp(mid.L, inactiveLightness, 0.5)
+	return mid.RGB().Hex(), nil
+}
+
+// Inactive borders keep the gradient's hue but recede towards a mid-dark gray
+const (
+	inactiveChromaScale = 0.35
+	inactiveLightness   = 0.4
+)
+
+// NotificationColors are background colors for notification daemons, one per urgency level
+type NotificationColors struct {
+	Low      string
+	Normal   string
+	Critical string
+}
+
+// Notifications derives notification backgrounds that harmonize with the gradient
+// All three are dark enough for light text; low is muted, normal carries the gradient's hue,
+// and critical pulls that hue towards red so it stands out without clashing
+func (g Gradient) Notifications() (NotificationColors, error) {
+	from, err := ParseHex(g.From)
+	if err != nil {
+		return NotificationColors{}, err
+	}
+	to, err := ParseHex(g.To)
+	if err != nil {
+		return NotificationColors{}, err
+	}
+
+	mid := InterpolateOKLCH(from.OKLCH(), to.OKLCH(), 0.5)
+
+	low := OKLCH{L: notificationLightness, C: mid.C * inactiveChromaScale, H: mid.H}
+	normal := OKLCH{L: notificationLightness, C: math.Min(mid.C, notificationMaxChroma), H: mid.H}
+	critical := OKLCH{
+		L: criticalLightness,
+		C: criticalChroma,
+		H: InterpolateAngle(criticalHue, mid.H, criticalHueShift),
+	}
+
+	return NotificationColors{
+		Low:      low.RGB().Hex(),
+		Normal:   normal.RGB().Hex(),
+		Critical: critical.RGB().Hex(),
+	}, nil
+}
+
+// Notification backgrounds sit at a fixed dark lightness; critical is a red slightly tinted by the palette
+const (
+	notificationLightness = 0.3
+	notificationMaxChroma = 0.08
+	criticalLightness     = 0.45
+	criticalChroma        = 0.16
+	criticalHue           = 27
+	criticalHueShift      = 0.15
+)
+
+// LockscreenColors are colors for overlaying a clock and password field on the blurred wallpaper
+type LockscreenColors struct {
+	BackgroundTint    string  // Dark tint laid over the wallpaper so text stays readable
+	BackgroundOpacity float64 // Suggested opacity of the tint
+	Clock             string
+	InputInner        string
+	InputOuter        string
+	InputText         string
+	InputCheck        string // Shown while the password is being verified
+	InputFail         string
+}
+
+// Lockscreen derives a lock screen palette (hyprlock, swaylock) from the gradient
+// Text is near-white with a hint of the palette's hue, so it reads well on any dimmed wallpaper
+func (g Gradient) Lockscreen() (LockscreenColors, error) {
+	from, err := ParseHex(g.From)
+	if err != nil {
+		return LockscreenColors{}, err
+	}
+	to, err := ParseHex(g.To)
+	if err != nil {
+		return LockscreenColors{}, err
+	}
+
+	mid := InterpolateOKLCH(from.OKLCH(), to.OKLCH(), 0.5)
+	text := OKLCH{L: 0.95, C: 0.02, H: mid.H}.RGB().Hex()
+
+	return LockscreenColors{
+		BackgroundTint:    OKLCH{L: 0.2, C: math.Min(mid.C, 0.04), H: mid.H}.RGB().Hex(),
+		BackgroundOpacity: lockscreenTintOpacity,
+		Clock:             text,
+		InputInner:        OKLCH{L: 0.25, C: math.Min(mid.C, 0.03), H: mid.H}.RGB().Hex(),
+		InputOuter:        g.From,
+		InputText:         text,
+		InputCheck:        g.To,
+		InputFail:         OKLCH{L: 0.6, C: criticalChroma, H: InterpolateAngle(criticalHue, mid.H, criticalHueShift)}.RGB().Hex(),
+	}, nil
+}
+
+// lockscreenTintOpacity dims the wallpaper enough for text without hiding it
+const lockscreenTintOpacity = 0.45
+
+// OverlayColors are colors for a streaming overlay shown over a video feed, e.g. an OBS browser source
+type OverlayColors struct {
+	Background        string  // Dark tint behind overlay panels
+	BackgroundOpacity float64 // Suggested opacity of the tint
+	Text              string
+	TextMuted         string // Secondary text such as labels and timestamps
+	Accent            string // Highlights: bars, borders and headings
+	AccentText        string // Text on the accent
+}
+
+// Minimum contrast against the overlay background, checked against the opaque tint
+// Text meets WCAG AAA, muted text and the accent AA, so both stay readable as text too
+const (
+	overlayTextContrast  = 7
+	overlayMutedContrast = 4.5
+)
+
+// overlayBackgroundOpacity keeps the video visible through overlay panels
+const overlayBackgroundOpacity = 0.8
+
+// Overlay derives a streaming overlay palette from the gradient, with gradient_from as the accent
+// Colors that fall short of their minimum contrast with the background are lightened until they meet it
+func (g Gradient) Overlay() (OverlayColors, error) {
+	from, err := ParseHex(g.From)
+	if err != nil {
+		return OverlayColors{}, err
+	}
+	to, err := ParseHex(g.To)
+	if err != nil {
+		return OverlayColors{}, err
+	}
+
+	mid := InterpolateOKLCH(from.OKLCH(), to.OKLCH(), 0.5)
+	background := OKLCH{L: 0.2, C: math.Min(mid.C, 0.04), H: mid.H}.RGB()
+	text := withContrast(OKLCH{L: 0.95, C: 0.015, H: mid.H}, background, overlayTextContrast)
+	accent := withContrast(from.OKLCH(), background, overlayMutedContrast)
+
+	// Text on the accent is the background or the text color, whichever stands out more
+	accentText := background
+	if ContrastRatio(accent, text) > ContrastRatio(accent, background) {
+		accentText = text
+	}
+
+	return OverlayColors{
+		Background:        background.Hex(),
+		BackgroundOpacity: overlayBackgroundOpacity,
+		Text:              text.Hex(),
+		TextMuted:         withContrast(OKLCH{L: 0.75, C: math.Min(mid.C, 0.05), H: mid.H}, background, overlayMutedContrast).Hex(),
+		Accent:            accent.Hex(),
+		AccentText:        accentText.Hex(),
+	}, nil
+}
+
+// withContrast lightens c in steps until it has at least the minimum contrast with a darker background
+// The result is clamped to sRGB, as its hex value will be
+func withContrast(c OKLCH, background RGB, minimum float64) RGB {
+	for ; ; c.L = math.Min(1, c.L+0.01) {
+		r, g, b := c.RGB().Bytes()
+		clamped := RGB{R: float64(r) / 255, G: float64(g) / 255, B: float64(b) / 255}
+		if ContrastRatio(clamped, background) >= minimum || c.L >= 1 {
+			return clamped
+		}
+	}
+}
+
+// PromptColors are colors for shell prompt themes such as starship and oh-my-posh
+type PromptColors struct {
+	Segments []PromptSegment // Along the gradient, from gradient_from to gradient_to
+	Accent   string          // The prompt character after a successful command
+	Error    string          // The prompt character after a failed command
+}
+
+// PromptSegment is the background of a prompt segment and the text color readable on it
+type PromptSegment struct {
+	Background string
+	Foreground string
+}
+
+// promptSegmentContrast is the minimum contrast of segment text, WCAG AA
+const promptSegmentContrast = 4.5
+
+// Prompt derives prompt colors with n segments blended along the gradient in OKLCH
+// Each segment's text is a dark or light tint of its hue, whichever contrasts more, or black or white if neither reaches AA
+func (g Gradient) Prompt(n int) (PromptColors, error) {
+	from, err := ParseHex(g.From)
+	if err != nil {
+		return PromptColors{}, err
+	}
+	to, err := ParseHex(g.To)
+	if err != nil {
+		return PromptColors{}, err
+	}
+
+	start, end := from.OKLCH(), to.OKLCH()
+	segments := make([]PromptSegment, n)
+	for i := range segments {
+		t := 0.5
+		if n > 1 {
+			t = float64(i) / float64(n-1)
+		}
+		background := InterpolateOKLCH(start, end, t)
+		segments[i] = PromptSegment{Background: background.RGB().Hex(), Foreground: readableOn(background).Hex()}
+	}
+
+	mid := InterpolateOKLCH(start, end, 0.5)
+	return PromptColors{
+		Segments: segments,
+		Accent:   g.From,
+		Error:    OKLCH{L: 0.65, C: criticalChroma, H: InterpolateAngle(criticalHue, mid.H, criticalHueShift)}.RGB().Hex(),
+	}, nil
+}
+
+// readableOn returns a text color for a background: a dark or light tint of its hue, whichever contrasts more
+func readableOn(background OKLCH) RGB {
+	bg, _ := ParseHex(background.RGB().Hex())
+	dark, _ := ParseHex(OKLCH{L: 0.2, C: math.Min(background.C, 0.03), H: background.H}.RGB().Hex())
+	light, _ := ParseHex(OKLCH{L: 0.97, C: math.Min(background.C, 0.015), H: background.H}.RGB().Hex())
+
+	text, fallback := dark, RGB{}
+	if ContrastRatio(light, bg) > ContrastRatio(dark, bg) {
+		text, fallback = light, RGB{R: 1, G: 1, B: 1}
+	}
+	if ContrastRatio(text, bg) < promptSegmentContrast {
+		return fallback
+	}
+	return text
+}
+
+// EditorColors are highlight colors for text editors, with borders matching the window manager's
+type EditorColors struct {
+	Border           string // Focused borders such as floating windows, gradient_from as on the focused window
+	InactiveBorder   string // Separators between windows, as unfocused window borders
+	StatusLine       string
+	StatusLineText   string
+	StatusLineNC     string // Status line of the other windows
+	StatusLineNCText string
+	CursorLine       string // A dark tint for dark editor backgrounds
+	CursorLineNr     string
+}
+
+// editorCursorLineLightness keeps the cursor line just above a dark editor background
+const editorCursorLineLightness = 0.27
+
+// Editor derives editor highlight colors from the gradient
+// Status line text is readable on its background, and the cursor line number on the cursor line, as with Prompt
+func (g Gradient) Editor() (EditorColors, error) {
+	from, err := ParseHex(g.From)
+	if err != nil {
+		return EditorColors{}, err
+	}
+	to, err := ParseHex(g.To)
+	if err != nil {
+		return EditorColors{}, err
+	}
+	inactiveHex, err := g.InactiveBorder()
+	if err != nil {
+		return EditorColors{}, err
+	}
+	inactive, _ := ParseHex(inactiveHex)
+
+	mid := InterpolateOKLCH(from.OKLCH(), to.OKLCH(), 0.5)
+	cursorLine := OKLCH{L: editorCursorLineLightness, C: math.Min(mid.C, 0.04), H: mid.H}.RGB()
+	cursorLine, _ = ParseHex(cursorLine.Hex())
+
+	return EditorColors{
+		Border:           g.From,
+		InactiveBorder:   inactiveHex,
+		StatusLine:       g.From,
+		StatusLineText:   readableOn(from.OKLCH()).Hex(),
+		StatusLineNC:     inactiveHex,
+		StatusLineNCText: readableOn(inactive.OKLCH()).Hex(),
+		CursorLine:       cursorLine.Hex(),
+		CursorLineNr:     withContrast(from.OKLCH(), cursorLine, promptSegmentContrast).Hex(),
+	}, nil
+}
+
+// CSS returns the gradient as a CSS linear-gradient() value
+func (g Gradient) CSS() string {
+	return fmt.Sprintf("linear-gradient(%sdeg, %s, %s)", formatAngle(g.Angle), g.From, g.To)
+}
+
+// Hyprland returns the gradient in Hyprland's border color syntax (e.g. for col.active_border)
+func (g Gradient) Hyprland() string {
+	return fmt.Sprintf("rgba(%sff) rgba(%sff) %sdeg", strings.TrimPrefix(g.From, "#"), strings.TrimPrefix(g.To, "#"), formatAngle(g.Angle))
+}
+
+// formatAngle prints an angle without trailing zeros (135, 157.5)
+func formatAngle(angle float64) string {
+	return strconv.FormatFloat(angle, 'f', -1, 64)
+}
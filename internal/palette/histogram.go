@@ -0,0 +1,87 @@
+package palette
+
+import (
+	"image"
+	"sort"
+)
+
+const (
+	// histogramLevels is the number of levels each RGB channel is quantized to
+	histogramLevels = 8
+	// maxHistogramSamples bounds the pixels read from large images; a strided sample is representative enough
+	maxHistogramSamples = 250000
+)
+
+// Histogram is a normalized distribution of an image's colors over coarse RGB bins
+type Histogram [histogramLevels * histogramLevels * histogramLevels]float64
+
+// NewHistogram computes the color histogram of an image
+func NewHistogram(img image.Image) Histogram {
+	var h Histogram
+
+	bounds := img.Bounds()
+	stride := 1
+	for (bounds.Dx()/stride)*(bounds.Dy()/stride) > maxHistogramSamples {
+		stride++
+	}
+
+	var total float64
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += stride {
+		for x := bounds.Min.X; x < bounds.Max.X; x += stride {
+			r, g, b, _ := img.At(x, y).RGBA()
+			h[histogramBin(r)*histogramLevels*histogramLevels+histogramBin(g)*histogramLevels+histogramBin(b)]++
+			total++
+		}
+	}
+
+	if total > 0 {
+		for i := range h {
+			h[i] /= total
+		}
+	}
+	return h
+}
+
+// Divergence returns how differently two histograms distribute their colors, from 0 (identical) to 1 (disjoint)
+func (h Histogram) Divergence(other Histogram) float64 {
+	var overlap float64
+	for i := range h {
+		overlap += min(h[i], other[i])
+	}
+	return max(0, 1-overlap)
+}
+
+// Dominant returns the centers of the n most populated bins as hex colors, most common first
+func (h Histogram) Dominant(n int) []string {
+	bins := make([]int, 0, len(h))
+	for i, share := range h {
+		if share > 0 {
+			bins = append(bins, i)
+		}
+	}
+	sort.SliceStable(bins, func(i, j int) bool { return h[bins[i]] > h[bins[j]] })
+
+	if n < len(bins) {
+		bins = bins[:n]
+	}
+
+	colors := make([]string, len(bins))
+	for i, bin := range bins {
+		colors[i] = RGB{
+			R: binCenter(bin / (histogramLevels * histogramLevels)),
+			G: binCenter(bin / histogramLevels % histogramLevels),
+			B: binCenter(bin % histogramLevels),
+		}.Hex()
+	}
+	return colors
+}
+
+// histogramBin maps a 16-bit color component to its bin index
+func histogramBin(v uint32) int {
+	return int(v * histogramLevels / 0x10000)
+}
+
+// binCenter returns the middle of a bin as a [0, 1] component
+func binCenter(bin int) float64 {
+	return (float64(bin) + 0.5) / histogramLevels
+}
@@ -0,0 +1,83 @@
+package palette
+
+import "math"
+
+// Lamps emit light instead of reflecting it: dark colors become dim light rather than black, grays look cold,
+// and pale tints wash out to white. Lights are therefore made from the hue at a fixed lightness with boosted chroma,
+// and the source lightness only sets the brightness
+const (
+	lightLightness      = 0.7
+	lightChromaBoost    = 1.4
+	lightMinChroma      = 0.1
+	lightGrayChroma     = 0.03 // Below this a color has no usable hue and becomes warm white
+	lightMinBrightness  = 64   // Dark colors still give some light
+	lightMaxBrightness  = 254  // The highest brightness Hue accepts
+	ambientBrightness   = 0.4  // Of the middle light's
+	lightCoordPrecision = 1e4
+)
+
+// warmWhite is the CIE 1931 xy chromaticity of a 2700 K lamp, used for colors without a hue
+var warmWhite = [2]float64{0.4578, 0.4101}
+
+// Light is one lamp of a lighting scene
+type Light struct {
+	Hex        string     // The lamp's color as sRGB, for previews
+	XY         [2]float64 // CIE 1931 chromaticity, as Hue and most smart lamps take it
+	Brightness int        // 1 to 254, as Hue's bri
+}
+
+// Lighting derives a scene of four lamps from the gradient: gradient_from, the midpoint and gradient_to,
+// then a dim ambient light in the midpoint's hue
+func (g Gradient) Lighting() ([]Light, error) {
+	from, err := ParseHex(g.From)
+	if err != nil {
+		return nil, err
+	}
+	to, err := ParseHex(g.To)
+	if err != nil {
+		return nil, err
+	}
+
+	start, end := from.OKLCH(), to.OKLCH()
+	mid := InterpolateOKLCH(start, end, 0.5)
+
+	lights := []Light{lampLight(start), lampLight(mid), lampLight(end)}
+	ambient := lights[1]
+	ambient.Brightness = max(1, int(math.Round(float64(ambient.Brightness)*ambientBrightness)))
+	return append(lights, ambient), nil
+}
+
+// lampLight converts a screen color to a lamp color and brightness
+func lampLight(c OKLCH) Light {
+	brightness := int(math.Round(lightMinBrightness + (lightMaxBrightness-lightMinBrightness)*math.Max(0, math.Min(1, c.L))))
+	if c.C < lightGrayChroma {
+		return Light{Hex: xyToRGB(warmWhite).Hex(), XY: warmWhite, Brightness: brightness}
+	}
+
+	lamp := OKLCH{L: lightLightness, C: math.Max(c.C*lightChromaBoost, lightMinChroma), H: c.H}.RGB()
+	// Clamp to sRGB, so the chromaticity is one a screen can preview
+	r, g, b := lamp.Bytes()
+	lamp = RGB{R: float64(r) / 255, G: float64(g) / 255, B: float64(b) / 255}
+	return Light{Hex: lamp.Hex(), XY: chromaticity(lamp), Brightness: brightness}
+}
+
+// chromaticity returns the CIE 1931 xy coordinates of a color, rounded to four decimals
+func chromaticity(c RGB) [2]float64 {
+	xyz := multiply(linearSRGBToXYZ, [3]float64{linearize(c.R), linearize(c.G), linearize(c.B)})
+	sum := xyz[0] + xyz[1] + xyz[2]
+	if sum == 0 {
+		return warmWhite
+	}
+	return [2]float64{
+		math.Round(xyz[0]/sum*lightCoordPrecision) / lightCoordPrecision,
+		math.Round(xyz[1]/sum*lightCoordPrecision) / lightCoordPrecision,
+	}
+}
+
+// xyToRGB returns the brightest sRGB color with the given chromaticity
+func xyToRGB(xy [2]float64) RGB {
+	xyz := [3]float64{xy[0] / xy[1], 1, (1 - xy[0] - xy[1]) / xy[1]}
+	linear := multiply(xyzToLinearSRGB, xyz)
+	peak := math.Max(linear[0], math.Max(linear[1], linear[2]))
+	return RGB{R: delinearize(linear[0] / peak), G: delinearize(linear[1] / peak), B: delinearize(linear[2] / peak)}
+}
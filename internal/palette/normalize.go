@@ -0,0 +1,170 @@
+package palette
+
+import (
+	"math"
+	"strings"
+)
+
+// Hex case and length options for NormalizePolicy; empty values leave colors as the model wrote them
+const (
+	HexLower = "lower"
+	HexUpper = "upper"
+	HexLong  = "long"  // #abc -> #aabbcc
+	HexShort = "short" // #aabbcc -> #abc where possible
+)
+
+// NormalizePolicy describes how analyzed colors are rewritten before caching
+// The zero value leaves everything unchanged
+type NormalizePolicy struct {
+	HexCase    string  `json:"hex_case,omitempty"`   // HexLower, HexUpper or empty
+	HexLength  string  `json:"hex_length,omitempty"` // HexLong, HexShort or empty
+	StripAlpha bool    `json:"strip_alpha"`          // drop the alpha channel from #rgba / #rrggbbaa
+	AngleStep  float64 `json:"angle_step,omitempty"` // snap *_angle values to multiples of this many degrees; 0 disables
+}
+
+// Apply returns a normalized copy of an analysis colors map; non-color values are copied unchanged
+func (p NormalizePolicy) Apply(colors map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(colors))
+	for key, value := range colors {
+		switch v := value.(type) {
+		case string:
+			if isHexColor(v) {
+				value = p.normalizeHex(v)
+			}
+		case float64:
+			if strings.HasSuffix(key, "_angle") {
+				value = p.snapAngle(v)
+			}
+		case int:
+			if strings.HasSuffix(key, "_angle") {
+				value = p.snapAngle(float64(v))
+			}
+		}
+		result[key] = value
+	}
+	return result
+}
+
+// normalizeHex applies alpha stripping, then length, then case to a "#..." color
+func (p NormalizePolicy) normalizeHex(color string) string {
+	digits := color[1:]
+
+	if p.StripAlpha {
+		switch len(digits) {
+		case 4:
+			digits = digits[:3]
+		case 8:
+			digits = digits[:6]
+		}
+	}
+
+	switch p.HexLength {
+	case HexLong:
+		if len(digits) == 3 || len(digits) == 4 {
+			long := make([]byte, 0, len(digits)*2)
+			for i := 0; i < len(digits); i++ {
+				long = append(long, digits[i], digits[i])
+			}
+			digits = string(long)
+		}
+	case HexShort:
+		if (len(digits) == 6 || len(digits) == 8) && isShortenable(digits) {
+			short := make([]byte, 0, len(digits)/2)
+			for i := 0; i < len(digits); i += 2 {
+				short = append(short, digits[i])
+			}
+			digits = string(short)
+		}
+	}
+
+	switch p.HexCase {
+	case HexLower:
+		digits = strings.ToLower(digits)
+	case HexUpper:
+		digits = strings.ToUpper(digits)
+	}
+
+	return "#" + digits
+}
+
+// snapAngle rounds an angle to the nearest step, keeping it in [0, 360)
+func (p NormalizePolicy) snapAngle(angle float64) float64 {
+	if p.AngleStep <= 0 {
+		return angle
+	}
+
+	snapped := math.Mod(math.Round(angle/p.AngleStep)*p.AngleStep, 360)
+	if snapped < 0 {
+		snapped += 360
+	}
+	return snapped
+}
+
+// NearestAngle returns the allowed angle closest to angle, measured around the circle
+// Ties go to the angle listed first; an empty list leaves angle unchanged
+func NearestAngle(angle float64, allowed []float64) float64 {
+	if len(allowed) == 0 {
+		return angle
+	}
+
+	best, bestDistance := allowed[0], math.Inf(1)
+	for _, candidate := range allowed {
+		distance := math.Mod(math.Abs(angle-candidate), 360)
+		distance = math.Min(distance, 360-distance)
+		if distance < bestDistance {
+			best, bestDistance = candidate, distance
+		}
+	}
+	return best
+}
+
+// ConstrainAngles returns a copy of a colors map with every *_angle value moved to the nearest allowed angle
+// An empty list returns colors unchanged
+func ConstrainAngles(colors map[string]interface{}, allowed []float64) map[string]interface{} {
+	if len(allowed) == 0 || colors == nil {
+		return colors
+	}
+
+	result := make(map[string]interface{}, len(colors))
+	for key, value := range colors {
+		if strings.HasSuffix(key, "_angle") {
+			switch v := value.(type) {
+			case float64:
+				value = NearestAngle(v, allowed)
+			case int:
+				value = NearestAngle(float64(v), allowed)
+			}
+		}
+		result[key] = value
+	}
+	return result
+}
+
+// isHexColor reports whether s is "#" followed by 3, 4, 6 or 8 hex digits
+func isHexColor(s string) bool {
+	if !strings.HasPrefix(s, "#") {
+		return false
+	}
+	switch len(s) - 1 {
+	case 3, 4, 6, 8:
+	default:
+		return false
+	}
+	for _, c := range s[1:] {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// isShortenable reports whether every pair of digits repeats (aabbcc), case-insensitively
+func isShortenable(digits string) bool {
+	lower := strings.ToLower(digits)
+	for i := 0; i < len(lower); i += 2 {
+		if lower[i] != lower[i+1] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,55 @@
+package palette
+
+import "image"
+
+// Share of the image height counted as the top and bottom edges, and of each dimension as the center
+const (
+	edgeFraction   = 0.1
+	centerFraction = 0.5
+)
+
+// Regions holds the average color of the areas where desktop UI usually sits
+type Regions struct {
+	Top    string `json:"top"`
+	Bottom string `json:"bottom"`
+	Center string `json:"center"`
+}
+
+// RegionAverages computes the average colors of the top edge, bottom edge and center of an image
+// Colors are averaged in linear light so bright and dark areas are weighted as the eye sees them
+func RegionAverages(img image.Image) Regions {
+	b := img.Bounds()
+	edge := max(1, int(float64(b.Dy())*edgeFraction))
+	insetX := int(float64(b.Dx()) * (1 - centerFraction) / 2)
+	insetY := int(float64(b.Dy()) * (1 - centerFraction) / 2)
+
+	return Regions{
+		Top:    averageColor(img, image.Rect(b.Min.X, b.Min.Y, b.Max.X, b.Min.Y+edge)),
+		Bottom: averageColor(img, image.Rect(b.Min.X, b.Max.Y-edge, b.Max.X, b.Max.Y)),
+		Center: averageColor(img, image.Rect(b.Min.X+insetX, b.Min.Y+insetY, b.Max.X-insetX, b.Max.Y-insetY)),
+	}
+}
+
+// averageColor returns the mean color of a rectangle, sampling large areas with a stride
+func averageColor(img image.Image, rect image.Rectangle) string {
+	stride := 1
+	for (rect.Dx()/stride)*(rect.Dy()/stride) > maxHistogramSamples {
+		stride++
+	}
+
+	var r, g, bl, n float64
+	for y := rect.Min.Y; y < rect.Max.Y; y += stride {
+		for x := rect.Min.X; x < rect.Max.X; x += stride {
+			cr, cg, cb, _ := img.At(x, y).RGBA()
+			r += linearize(float64(cr) / 0xffff)
+			g += linearize(float64(cg) / 0xffff)
+			bl += linearize(float64(cb) / 0xffff)
+			n++
+		}
+	}
+
+	if n == 0 {
+		return ""
+	}
+	return RGB{R: delinearize(r / n), G: delinearize(g / n), B: delinearize(bl / n)}.Hex()
+}
@@ -0,0 +1,46 @@
+package pipeline
+
+import (
+	"sync"
+)
+
+// flightGroup runs one call per key at a time; callers that arrive while it runs wait for and share its result
+// The zero value is ready to use
+type flightGroup[T any] struct {
+	mu    sync.Mutex
+	calls map[string]*flight[T]
+}
+
+// flight is a call in progress; value and err are set before done is closed
+type flight[T any] struct {
+	done  chan struct{}
+	value T
+	err   error
+}
+
+// do runs fn for key unless a call for key is already running, in which case it waits for that call's result
+// shared reports whether the result came from another caller's call
+func (g *flightGroup[T]) do(key string, fn func() (T, error)) (value T, err error, shared bool) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flight[T])
+	}
+	if call, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		<-call.done
+		return call.value, call.err, true
+	}
+	call := &flight[T]{done: make(chan struct{})}
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(call.done)
+	}()
+
+	call.value, call.err = fn()
+	return call.value, call.err, false
+}
@@ -0,0 +1,61 @@
+package pipeline
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestFlightGroup tests that concurrent calls for one key share a single run while other keys run separately
+func TestFlightGroup(t *testing.T) {
+	var group flightGroup[int]
+	var calls atomic.Int32
+	release := make(chan struct{})
+
+	const callers = 5
+	results := make(chan int, callers)
+	var sharedCount atomic.Int32
+	for range callers {
+		go func() {
+			value, _, shared := group.do("en-US_0", func() (int, error) {
+				calls.Add(1)
+				<-release
+				return 42, nil
+			})
+			if shared {
+				sharedCount.Add(1)
+			}
+			results <- value
+		}()
+	}
+
+	// Wait until every caller is either running or waiting on the first call
+	deadline := time.Now().Add(5 * time.Second)
+	for calls.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	if value, _, shared := group.do("ja-JP_0", func() (int, error) { return 7, nil }); value != 7 || shared {
+		t.Errorf("Expected another key to run on its own, got %d (shared %v)", value, shared)
+	}
+
+	close(release)
+	for range callers {
+		if value := <-results; value != 42 {
+			t.Errorf("Expected the shared result, got %d", value)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Expected one call, got %d", calls.Load())
+	}
+	if sharedCount.Load() != callers-1 {
+		t.Errorf("Expected %d callers to share the result, got %d", callers-1, sharedCount.Load())
+	}
+
+	// A finished call is not reused
+	group.do("en-US_0", func() (int, error) { calls.Add(1); return 0, nil })
+	if calls.Load() != 2 {
+		t.Errorf("Expected a new call after the first finished, got %d calls", calls.Load())
+	}
+}
@@ -0,0 +1,278 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/mgabor3141/dailyhues/internal/ai"
+	"github.com/mgabor3141/dailyhues/internal/bing"
+	"github.com/mgabor3141/dailyhues/internal/cache"
+	"github.com/mgabor3141/dailyhues/internal/logging"
+	"github.com/mgabor3141/dailyhues/internal/metrics"
+)
+
+// MaxBingDaysAgo is the oldest day Bing's archive API still serves; older days only resolve from the caches
+const MaxBingDaysAgo = 7
+
+// ErrNotArchived is returned for days older than Bing keeps that were never cached, so there is nothing to serve
+var ErrNotArchived = fmt.Errorf("no archived wallpaper for this day. Bing only keeps wallpapers for the last %d days", MaxBingDaysAgo)
+
+func logger() *slog.Logger {
+	return logging.Package("pipeline")
+}
+
+// Palette is a resolved palette: the wallpaper's metadata and the analysis its colors come from
+type Palette struct {
+	Request  cache.RequestEntry
+	Analysis cache.AnalysisEntry // Its ImageHash identifies the colors, e.g. for a profile's own analysis
+	Fallback string              // How Analysis was made while the AI is unavailable; such palettes are not cached
+}
+
+// Pipeline resolves palettes from the caches, or else from Bing's metadata, the image and an AI analysis,
+// which are cached for later requests. It is shared by the HTTP handlers, the CLI, warming and backfill.
+// The steps that depend on the server's configuration are supplied as functions; only Analyze is required
+type Pipeline struct {
+	Requests *cache.RequestCache
+	Analyses *cache.AnalysisCache
+	Bing     *bing.Client
+
+	// PromptAddendum returns the operator's prompt addendum for a source and locale, folded into analysis keys
+	PromptAddendum func(source, locale string) string
+	// Download returns the wallpaper's image, whose hash identifies the wallpaper in the caches
+	// Defaults to the default image, or a representative frame on video days
+	Download func(ctx context.Context, info *bing.WallpaperInfo) ([]byte, error)
+	// AnalysisImage returns a smaller image to analyze in place of the downloaded one, and its resolution
+	// It is only called when an analysis is needed; nil data analyzes the downloaded image
+	AnalysisImage func(ctx context.Context, info *bing.WallpaperInfo) ([]byte, string)
+	// Analyze asks the AI for an image's colors, returning the entry to cache under imageHash
+	Analyze func(imageData []byte, imageHash, promptAddendum string, info *bing.WallpaperInfo) (cache.AnalysisEntry, error)
+	// Fallback returns an analysis to serve, and its kind, while Analyze fails with ai.ErrCircuitOpen
+	Fallback func(imageData []byte, imageHash string, info *bing.WallpaperInfo) (cache.AnalysisEntry, string, error)
+	// Profile returns the analysis of a profile other than the default, given the default palette
+	Profile func(profile string, palette Palette) (cache.AnalysisEntry, error)
+	// Stored is called with every request entry the pipeline caches, e.g. to archive the images
+	Stored func(entry cache.RequestEntry)
+
+	resolving flightGroup[Palette]             // Cache misses being resolved, by locale and daysAgo
+	profiles  flightGroup[cache.AnalysisEntry] // Profile analyses being resolved, by profile and image
+}
+
+// GetPalette resolves the palette of a locale's wallpaper from daysAgo days ago
+// profile "" is the default analysis; other profiles are resolved from it with Profile.
+// Work on a cache miss is not tied to ctx's cancellation, so a palette still reaches the caches if the client leaves
+func (p *Pipeline) GetPalette(ctx context.Context, source, locale string, daysAgo int, profile string) (Palette, error) {
+	if source != cache.SourceBing {
+		return Palette{}, fmt.Errorf("unsupported image source %q", source)
+	}
+
+	palette, err := p.resolve(ctx, source, locale, daysAgo)
+	if err != nil || profile == "" {
+		return palette, err
+	}
+	if p.Profile == nil {
+		return Palette{}, fmt.Errorf("unsupported profile %q", profile)
+	}
+
+	// Concurrent misses for the same image and profile share one download and analysis
+	analysis, err, _ := p.profiles.do(profile+"_"+palette.Request.ImageHash, func() (cache.AnalysisEntry, error) {
+		return p.Profile(profile, palette)
+	})
+	if err != nil {
+		return Palette{}, err
+	}
+	palette.Analysis = analysis
+	return palette, nil
+}
+
+// ResolveWallpaper resolves the default palette for known wallpaper metadata, e.g. from an archive listing
+// It shares the download and analysis with any other resolution of the same day that is in flight
+func (p *Pipeline) ResolveWallpaper(ctx context.Context, source, locale string, daysAgo int, info *bing.WallpaperInfo) (Palette, error) {
+	if source != cache.SourceBing {
+		return Palette{}, fmt.Errorf("unsupported image source %q", source)
+	}
+
+	palette, err, _ := p.resolving.do(resolvingKey(locale, daysAgo), func() (Palette, error) {
+		return p.analyzeWallpaper(context.WithoutCancel(ctx), source, locale, daysAgo, info)
+	})
+	return palette, err
+}
+
+// resolvingKey identifies a day's palette resolution in resolving
+func resolvingKey(locale string, daysAgo int) string {
+	return locale + "_" + strconv.Itoa(daysAgo)
+}
+
+// resolve resolves the default palette for a locale and day, using the caches where possible
+func (p *Pipeline) resolve(ctx context.Context, source, locale string, daysAgo int) (Palette, error) {
+	// Step 1: Check request cache (entries are keyed by start date, so daysAgo resolves across rollovers)
+	lookupStart := time.Now()
+	if reqEntry := p.Requests.Get(locale, daysAgo); reqEntry != nil {
+		// Request cached, now check if we have the analysis
+		if analysisEntry := p.Analyses.Get(reqEntry.ImageHash); analysisEntry != nil {
+			metrics.StageLatency.Since("cache_lookup", lookupStart)
+			return Palette{Request: *reqEntry, Analysis: *analysisEntry}, nil
+		}
+	}
+	metrics.StageLatency.Since("cache_lookup", lookupStart)
+
+	// Bing no longer has the day, so only the archive could have served it
+	if daysAgo > MaxBingDaysAgo {
+		return Palette{}, ErrNotArchived
+	}
+
+	// Concurrent misses for the same day share one metadata fetch, download and analysis
+	palette, err, shared := p.resolving.do(resolvingKey(locale, daysAgo), func() (Palette, error) {
+		return p.fetch(context.WithoutCancel(ctx), source, locale, daysAgo)
+	})
+	if shared {
+		logger().DebugContext(ctx, "Shared an in-flight palette resolution", "locale", locale, "daysAgo", daysAgo)
+	}
+	return palette, err
+}
+
+// fetch resolves a palette that is not cached, starting from Bing's metadata
+func (p *Pipeline) fetch(ctx context.Context, source, locale string, daysAgo int) (Palette, error) {
+	// Step 2: Fetch wallpaper metadata from Bing
+	metadataStart := time.Now()
+	info, err := p.Bing.WithLocale(locale).GetWallpaperInfoByDaysAgo(ctx, daysAgo)
+	metrics.StageLatency.Since("bing_metadata", metadataStart)
+	if err != nil {
+		logger().InfoContext(ctx, "Failed to download wallpaper", "error", err)
+		return Palette{}, fmt.Errorf("Failed to download wallpaper: %w", err)
+	}
+
+	return p.analyzeWallpaper(ctx, source, locale, daysAgo, info)
+}
+
+// analyzeWallpaper resolves the palette for known wallpaper metadata, downloading and analyzing the image if needed
+func (p *Pipeline) analyzeWallpaper(ctx context.Context, source, locale string, daysAgo int, info *bing.WallpaperInfo) (Palette, error) {
+	promptAddendum := ""
+	if p.PromptAddendum != nil {
+		promptAddendum = p.PromptAddendum(source, locale)
+	}
+
+	// Step 2b: Reuse the image of a locale that is known to share wallpapers with this one
+	// The peer's analysis only applies if it was made with the same prompt addendum
+	if peer := p.Requests.FindPeerEntry(locale, info.StartDate, info.ImageID); peer != nil &&
+		cache.AnalysisKey(cache.ImageHashOf(peer.ImageHash), promptAddendum) == peer.ImageHash {
+		if analysisEntry := p.Analyses.Get(peer.ImageHash); analysisEntry != nil {
+			logger().InfoContext(ctx, "Reusing image from grouped locale", "locale", locale, "peer", peer.Locale, "hash", peer.ImageHash)
+			return Palette{Request: p.storeRequest(locale, daysAgo, peer.ImageHash, info), Analysis: *analysisEntry}, nil
+		}
+	}
+
+	// Step 2c: Download the wallpaper image (or a representative frame on video days)
+	downloadStart := time.Now()
+	imageData, err := p.download(ctx, info)
+	metrics.StageLatency.Since("image_download", downloadStart)
+	if err != nil {
+		logger().InfoContext(ctx, "Failed to download wallpaper", "error", err)
+		return Palette{}, fmt.Errorf("Failed to download wallpaper: %w", err)
+	}
+
+	logger().InfoContext(ctx, "Downloaded wallpaper", "title", info.Title, "bytes", len(imageData), "video", info.HasVideo())
+
+	// Step 3: Generate image hash (this is our unique identifier)
+	// Operator prompt addenda are folded in, so analyses made with different prompts don't collide
+	imageHash := cache.AnalysisKey(cache.HashImage(imageData), promptAddendum)
+	logger().InfoContext(ctx, "Image hash", "hash", imageHash)
+
+	// Step 4: Check analysis cache by image hash
+	if analysisEntry := p.Analyses.Get(imageHash); analysisEntry != nil {
+		// Analysis exists! Just cache the request metadata and return
+		logger().InfoContext(ctx, "Analysis cache hit for image hash", "hash", imageHash)
+		return Palette{Request: p.storeRequest(locale, daysAgo, imageHash, info), Analysis: *analysisEntry}, nil
+	}
+
+	// Step 5: Acquire mutex for this image hash (prevents duplicate analysis)
+	imageMutex := p.Analyses.GetMutex(imageHash)
+	imageMutex.Lock()
+	defer imageMutex.Unlock()
+	defer p.Analyses.ReleaseMutex(imageHash)
+
+	// Step 6: Double-check analysis cache (another goroutine might have completed)
+	if analysisEntry := p.Analyses.Get(imageHash); analysisEntry != nil {
+		logger().InfoContext(ctx, "Analysis completed by another request for image hash", "hash", imageHash)
+		return Palette{Request: p.storeRequest(locale, daysAgo, imageHash, info), Analysis: *analysisEntry}, nil
+	}
+
+	// Step 7: Analyze colors with AI, from a smaller image if one is configured
+	// The hash stays that of the downloaded image, so the analysis size does not change which wallpapers are cached
+	analysisData, analyzedSize := imageData, ""
+	if p.AnalysisImage != nil {
+		if data, size := p.AnalysisImage(ctx, info); data != nil {
+			analysisData, analyzedSize = data, size
+		}
+	}
+	analysisEntry, err := p.Analyze(analysisData, imageHash, promptAddendum, info)
+	if errors.Is(err, ai.ErrCircuitOpen) && p.Fallback != nil {
+		logger().InfoContext(ctx, "OpenRouter is unavailable, serving a fallback palette", "hash", imageHash)
+		fallback, kind, err := p.Fallback(analysisData, imageHash, info)
+		if err != nil {
+			return Palette{}, err
+		}
+		// Nothing is cached, so the image is analyzed as usual once OpenRouter recovers
+		return Palette{Request: requestEntry(locale, daysAgo, imageHash, info), Analysis: fallback, Fallback: kind}, nil
+	}
+	if err != nil {
+		return Palette{}, err
+	}
+	analysisEntry.AnalyzedSize = analyzedSize
+
+	// Step 8: Store analysis in cache (shared across all locales with this image)
+	if err := p.Analyses.SetEntry(analysisEntry); err != nil {
+		logger().InfoContext(ctx, "Failed to cache analysis", "error", err)
+	}
+
+	// Step 9: Store request metadata in cache
+	reqEntry := p.storeRequest(locale, daysAgo, imageHash, info)
+
+	// Step 10: Return the palette
+	return Palette{Request: reqEntry, Analysis: analysisEntry}, nil
+}
+
+// download returns the wallpaper's image with Download, or else its default image
+func (p *Pipeline) download(ctx context.Context, info *bing.WallpaperInfo) ([]byte, error) {
+	if p.Download != nil {
+		return p.Download(ctx, info)
+	}
+	return p.Bing.DownloadFrame(ctx, info)
+}
+
+// storeRequest caches the request metadata for a locale and day, logging failures, and returns the entry
+func (p *Pipeline) storeRequest(locale string, daysAgo int, imageHash string, info *bing.WallpaperInfo) cache.RequestEntry {
+	entry := requestEntry(locale, daysAgo, imageHash, info)
+	if err := p.Requests.SetEntry(entry); err != nil {
+		logger().Info("Failed to cache request", "error", err)
+	}
+	if p.Stored != nil {
+		p.Stored(entry)
+	}
+	return entry
+}
+
+// requestEntry builds the request entry of a wallpaper for a locale and day
+func requestEntry(locale string, daysAgo int, imageHash string, info *bing.WallpaperInfo) cache.RequestEntry {
+	return cache.RequestEntry{
+		Locale:        locale,
+		DaysAgo:       daysAgo,
+		ImageHash:     imageHash,
+		ImageID:       info.ImageID,
+		ImageURLs:     info.ImageURLs,
+		Title:         info.Title,
+		Copyright:     info.Copyright,
+		CopyrightLink: info.CopyrightLink,
+		Photographer:  info.Photographer,
+		Agency:        info.Agency,
+		StartDate:     info.StartDate,
+		FullStartDate: info.FullStartDate,
+		EndDate:       info.EndDate,
+		ExpiresAt:     cache.RolloverTime(info.StartDate, info.FullStartDate),
+		VideoURLs:     info.VideoURLs,
+		VideoFrameURL: info.VideoFrameURL,
+	}
+}
@@ -0,0 +1,209 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mgabor3141/dailyhues/internal/ai"
+	"github.com/mgabor3141/dailyhues/internal/bing"
+	"github.com/mgabor3141/dailyhues/internal/cache"
+)
+
+var testColors = map[string]interface{}{"gradient_from": "#c67d3a", "gradient_to": "#6b8d7d", "gradient_angle": float64(135)}
+
+// newTestPipeline returns a pipeline over empty caches whose downloads return image, and that fails any analysis
+func newTestPipeline(t *testing.T, image string) *Pipeline {
+	t.Helper()
+	tmpDir := t.TempDir()
+	requests, _ := cache.NewRequestCache(tmpDir)
+	analyses, _ := cache.NewAnalysisCache(tmpDir)
+
+	return &Pipeline{
+		Requests: requests,
+		Analyses: analyses,
+		Bing:     bing.NewClient("en-US"),
+		Download: func(ctx context.Context, info *bing.WallpaperInfo) ([]byte, error) {
+			return []byte(image), nil
+		},
+		Analyze: func(imageData []byte, imageHash, promptAddendum string, info *bing.WallpaperInfo) (cache.AnalysisEntry, error) {
+			t.Errorf("Unexpected analysis of %s", imageHash)
+			return cache.AnalysisEntry{}, errors.New("unexpected analysis")
+		},
+	}
+}
+
+// testInfo returns the metadata of a wallpaper that started daysAgo days ago
+func testInfo(daysAgo int) *bing.WallpaperInfo {
+	day := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -daysAgo)
+	return &bing.WallpaperInfo{
+		Title:         "Title",
+		ImageURLs:     map[string]string{"UHD": "https://bing.com/uhd.jpg"},
+		StartDate:     day.Format("20060102"),
+		FullStartDate: day.Format("20060102") + "0000",
+		EndDate:       day.AddDate(0, 0, 1).Format("20060102"),
+	}
+}
+
+// TestGetPalette_Cached tests that cached days resolve without downloading or analyzing, and what cannot resolve
+func TestGetPalette_Cached(t *testing.T) {
+	p := newTestPipeline(t, "image")
+	p.Download = nil
+	hash := cache.HashImage([]byte("image"))
+	p.Analyses.Set(hash, testColors)
+	info := testInfo(0)
+	p.Requests.Set("en-US", 0, hash, info.ImageURLs, info.Title, "Copyright", "", info.StartDate, info.FullStartDate, info.EndDate, time.Now().Add(time.Hour))
+
+	palette, err := p.GetPalette(context.Background(), cache.SourceBing, "en-US", 0, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if palette.Request.Title != "Title" || palette.Analysis.ImageHash != hash || palette.Analysis.Colors["gradient_from"] != "#c67d3a" {
+		t.Errorf("Expected the cached palette, got %+v", palette)
+	}
+
+	if _, err := p.GetPalette(context.Background(), "unsplash", "en-US", 0, ""); err == nil {
+		t.Error("Expected an error for an unsupported source")
+	}
+	if _, err := p.GetPalette(context.Background(), cache.SourceBing, "en-US", MaxBingDaysAgo+1, ""); !errors.Is(err, ErrNotArchived) {
+		t.Errorf("Expected ErrNotArchived past Bing's retention, got %v", err)
+	}
+	if _, err := p.GetPalette(context.Background(), cache.SourceBing, "en-US", 0, "terminal"); err == nil {
+		t.Error("Expected an error for a profile without a Profile step")
+	}
+}
+
+// TestGetPalette_Profile tests that profiles are resolved from the default palette
+func TestGetPalette_Profile(t *testing.T) {
+	p := newTestPipeline(t, "image")
+	hash := cache.HashImage([]byte("image"))
+	p.Analyses.Set(hash, testColors)
+	info := testInfo(0)
+	p.Requests.Set("en-US", 0, hash, info.ImageURLs, info.Title, "Copyright", "", info.StartDate, info.FullStartDate, info.EndDate, time.Now().Add(time.Hour))
+
+	p.Profile = func(profile string, base Palette) (cache.AnalysisEntry, error) {
+		if base.Analysis.ImageHash != hash {
+			t.Errorf("Expected the default palette, got %+v", base)
+		}
+		return cache.AnalysisEntry{ImageHash: profile + "-key", Colors: map[string]interface{}{"primary": "#123456"}}, nil
+	}
+
+	palette, err := p.GetPalette(context.Background(), cache.SourceBing, "en-US", 0, "terminal")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if palette.Request.ImageHash != hash || palette.Analysis.ImageHash != "terminal-key" {
+		t.Errorf("Expected the profile's analysis of the cached wallpaper, got %+v", palette)
+	}
+}
+
+// TestResolveWallpaper tests that an uncached wallpaper is analyzed once and cached for later requests
+func TestResolveWallpaper(t *testing.T) {
+	p := newTestPipeline(t, "image")
+	var analyses atomic.Int32
+	p.Analyze = func(imageData []byte, imageHash, promptAddendum string, info *bing.WallpaperInfo) (cache.AnalysisEntry, error) {
+		analyses.Add(1)
+		return cache.AnalysisEntry{ImageHash: imageHash, Colors: testColors}, nil
+	}
+	var stored []string
+	p.Stored = func(entry cache.RequestEntry) { stored = append(stored, entry.ImageHash) }
+
+	palette, err := p.ResolveWallpaper(context.Background(), cache.SourceBing, "en-US", 0, testInfo(0))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	hash := cache.HashImage([]byte("image"))
+	if palette.Request.ImageHash != hash || palette.Analysis.ImageHash != hash || palette.Fallback != "" {
+		t.Errorf("Expected the analyzed palette, got %+v", palette)
+	}
+	if len(stored) != 1 || stored[0] != hash {
+		t.Errorf("Expected the request entry to be stored once, got %v", stored)
+	}
+
+	if _, err := p.GetPalette(context.Background(), cache.SourceBing, "en-US", 0, ""); err != nil {
+		t.Fatalf("Expected the palette to be cached, got %v", err)
+	}
+	if analyses.Load() != 1 {
+		t.Errorf("Expected one analysis, got %d", analyses.Load())
+	}
+}
+
+// TestResolveWallpaper_AnalysisImage tests that a smaller analysis image is analyzed, but the downloaded image is hashed
+func TestResolveWallpaper_AnalysisImage(t *testing.T) {
+	p := newTestPipeline(t, "image")
+	hash := cache.HashImage([]byte("image"))
+	p.AnalysisImage = func(ctx context.Context, info *bing.WallpaperInfo) ([]byte, string) {
+		return []byte("small"), "800x600"
+	}
+	p.Analyze = func(imageData []byte, imageHash, promptAddendum string, info *bing.WallpaperInfo) (cache.AnalysisEntry, error) {
+		if string(imageData) != "small" || imageHash != hash {
+			t.Errorf("Expected the small image under the downloaded image's hash, got %q under %s", imageData, imageHash)
+		}
+		return cache.AnalysisEntry{ImageHash: imageHash, Colors: testColors}, nil
+	}
+
+	palette, err := p.ResolveWallpaper(context.Background(), cache.SourceBing, "en-US", 0, testInfo(0))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if palette.Request.ImageHash != hash || palette.Analysis.AnalyzedSize != "800x600" {
+		t.Errorf("Expected the analyzed size as provenance of the analysis, got %+v", palette)
+	}
+	if cached := p.Analyses.Get(hash); cached == nil || cached.AnalyzedSize != "800x600" {
+		t.Errorf("Expected the analyzed size to be cached, got %+v", cached)
+	}
+
+	// Once analyzed, the small image is not downloaded again
+	p.AnalysisImage = func(ctx context.Context, info *bing.WallpaperInfo) ([]byte, string) {
+		t.Error("Unexpected analysis image download")
+		return nil, ""
+	}
+	if _, err := p.ResolveWallpaper(context.Background(), cache.SourceBing, "en-GB", 0, testInfo(0)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+// TestResolveWallpaper_Fallback tests that palettes made while the AI is unavailable are served but not cached
+func TestResolveWallpaper_Fallback(t *testing.T) {
+	p := newTestPipeline(t, "image")
+	p.Analyze = func(imageData []byte, imageHash, promptAddendum string, info *bing.WallpaperInfo) (cache.AnalysisEntry, error) {
+		return cache.AnalysisEntry{}, ai.ErrCircuitOpen
+	}
+	p.Fallback = func(imageData []byte, imageHash string, info *bing.WallpaperInfo) (cache.AnalysisEntry, string, error) {
+		return cache.AnalysisEntry{ImageHash: imageHash, Colors: testColors}, "local", nil
+	}
+
+	palette, err := p.ResolveWallpaper(context.Background(), cache.SourceBing, "en-US", 0, testInfo(0))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if palette.Fallback != "local" || palette.Request.Title != "Title" {
+		t.Errorf("Expected a fallback palette, got %+v", palette)
+	}
+	if p.Requests.Get("en-US", 0) != nil || p.Analyses.Get(palette.Analysis.ImageHash) != nil {
+		t.Error("Expected nothing to be cached for a fallback palette")
+	}
+}
+
+// TestResolveWallpaper_SharesResolution tests that known metadata joins a resolution of the same day already in flight,
+// such as one started by GetPalette for /api/colors, instead of analyzing the image again
+func TestResolveWallpaper_SharesResolution(t *testing.T) {
+	p := newTestPipeline(t, "image")
+
+	running := make(chan struct{})
+	release := make(chan struct{})
+	go p.resolving.do(resolvingKey("en-US", 0), func() (Palette, error) {
+		close(running)
+		<-release
+		return Palette{Request: cache.RequestEntry{Title: "Shared"}}, nil
+	})
+	<-running
+	time.AfterFunc(20*time.Millisecond, func() { close(release) })
+
+	palette, err := p.ResolveWallpaper(context.Background(), cache.SourceBing, "en-US", 0, testInfo(0))
+	if err != nil || palette.Request.Title != "Shared" {
+		t.Errorf("Expected the in-flight resolution to be shared, got %+v (%v)", palette, err)
+	}
+}
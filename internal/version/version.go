@@ -0,0 +1,67 @@
+package version
+
+import (
+	"runtime/debug"
+)
+
+// Set at build time, e.g.:
+//
+//	go build -ldflags "-X github.com/mgabor3141/dailyhues/internal/version.Version=v1.2.0 \
+//	  -X github.com/mgabor3141/dailyhues/internal/version.Commit=$(git rev-parse HEAD) \
+//	  -X github.com/mgabor3141/dailyhues/internal/version.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildDate = ""
+)
+
+// Info describes the running build
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the build info, falling back to VCS data embedded by the Go toolchain when ldflags are not set
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+	}
+
+	if build, ok := debug.ReadBuildInfo(); ok {
+		info.GoVersion = build.GoVersion
+		for _, setting := range build.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				if info.BuildDate == "" {
+					info.BuildDate = setting.Value
+				}
+			}
+		}
+	}
+
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	if info.BuildDate == "" {
+		info.BuildDate = "unknown"
+	}
+
+	return info
+}
+
+// String returns a compact identifier such as "v1.2.0 (abc1234)"
+func (i Info) String() string {
+	commit := i.Commit
+	if len(commit) > 7 {
+		commit = commit[:7]
+	}
+	return i.Version + " (" + commit + ")"
+}